@@ -0,0 +1,322 @@
+// Package idempotency makes gRPC calls carrying an idempotency-key header
+// run at most once per key and method. A retry with the same request gets
+// the stored response and response headers back; a retry with a different
+// request is rejected with InvalidArgument, and one racing the first call
+// with Aborted. Failed calls don't keep their key, so they can be retried.
+//
+// The response is stored after the handler returns, outside its
+// transaction, so a server stopping in between leaves a key whose call may
+// or may not have taken effect. Such a key is never run again: once
+// claimTTL passes, retries get Unknown until the key expires, and the
+// caller has to find out what happened before calling with a new key.
+//
+// Only the methods that change state are wrapped:
+//
+//	interceptor := idempotency.NewInterceptor(db, "x-user-id")
+//	srv.RegisterService(idempotency.Wrap(svc.Desc(), interceptor, svc.Commands()...), nil)
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KeyHeader is the metadata key clients retrying a call send
+// (Grpc-Metadata-Idempotency-Key through the gateway).
+const KeyHeader = "idempotency-key"
+
+// keyTTL is how long a key replays its response. Afterwards the key can be
+// used again.
+const keyTTL = 24 * time.Hour
+
+// claimTTL is how long a call may run. A key older than that without a
+// response was left by a server that stopped mid-call or before storing the
+// response.
+const claimTTL = 2 * time.Minute
+
+// Key is a call made with an idempotency key. Response holds the serialized
+// response once the call succeeded; a key without response is still being
+// handled.
+type Key struct {
+	Key            string    `gorm:"column:idempotency_key;primaryKey" json:"idempotency_key"`
+	Method         string    `gorm:"column:method;primaryKey" json:"method"`
+	RequestHash    []byte    `gorm:"column:request_hash" json:"-"`
+	ResponseType   string    `gorm:"column:response_type" json:"response_type"`
+	Response       []byte    `gorm:"column:response" json:"-"`
+	ResponseHeader []byte    `gorm:"column:response_header" json:"-"`
+	CreatedAt      time.Time `gorm:"column:created_at;index" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Key) TableName() string {
+	return "idempotency_keys"
+}
+
+func (m *Key) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Key) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// NewInterceptor returns the interceptor storing the calls made with an
+// idempotency key in db. headers are the request metadata the handlers act
+// on besides the message, such as the caller or a reason: they are part of
+// the request a retry must repeat.
+func NewInterceptor(db *gorm.DB, headers ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := keyFromContext(ctx)
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		hash, err := requestHash(ctx, msg, headers)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		record := Key{
+			Key:         key,
+			Method:      info.FullMethod,
+			RequestHash: hash,
+		}
+
+		claimed, err := claim(db.WithContext(ctx), &record)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if !claimed {
+			return replay(ctx, record, hash)
+		}
+
+		stream := &recordingStream{ServerTransportStream: grpc.ServerTransportStreamFromContext(ctx)}
+		if stream.ServerTransportStream != nil {
+			ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+		}
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if delErr := db.WithContext(context.WithoutCancel(ctx)).Delete(&record).Error; delErr != nil {
+				err = errors.Join(err, delErr)
+			}
+			return nil, err
+		}
+
+		if out, ok := resp.(proto.Message); ok {
+			body, err := proto.Marshal(out)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			header, err := json.Marshal(stream.header)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			if err = db.WithContext(context.WithoutCancel(ctx)).Model(&record).Updates(Key{
+				ResponseType:   string(proto.MessageName(out)),
+				Response:       body,
+				ResponseHeader: header,
+			}).Error; err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// Wrap returns a copy of desc whose methods listed in methods, by full
+// method name, run through the interceptor, inside the interceptors the
+// server was built with. Other methods are left as they are.
+func Wrap(desc *grpc.ServiceDesc, interceptor grpc.UnaryServerInterceptor, methods ...string) *grpc.ServiceDesc {
+	wrapped := *desc
+	wrapped.Methods = make([]grpc.MethodDesc, len(desc.Methods))
+
+	for i, m := range desc.Methods {
+		if !slices.Contains(methods, "/"+desc.ServiceName+"/"+m.MethodName) {
+			wrapped.Methods[i] = m
+			continue
+		}
+
+		handler := m.Handler
+		wrapped.Methods[i] = grpc.MethodDesc{
+			MethodName: m.MethodName,
+			Handler: func(srv any, ctx context.Context, dec func(any) error, next grpc.UnaryServerInterceptor) (any, error) {
+				return handler(srv, ctx, dec, func(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+					inner := func(ctx context.Context, req any) (any, error) {
+						return interceptor(ctx, req, info, h)
+					}
+
+					if next == nil {
+						return inner(ctx, req)
+					}
+					return next(ctx, req, info, inner)
+				})
+			},
+		}
+	}
+
+	return &wrapped
+}
+
+// Methods returns the full method names of the named methods of desc, for
+// services generated without a list of their commands.
+func Methods(desc *grpc.ServiceDesc, names ...string) (methods []string) {
+	for _, name := range names {
+		methods = append(methods, "/"+desc.ServiceName+"/"+name)
+	}
+	return
+}
+
+// requestHash hashes msg along with the values of headers in the incoming
+// metadata.
+func requestHash(ctx context.Context, msg proto.Message, headers []string) ([]byte, error) {
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(raw)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, header := range headers {
+		values, err := json.Marshal(md.Get(header))
+		if err != nil {
+			return nil, err
+		}
+
+		h.Write([]byte{0})
+		h.Write([]byte(header))
+		h.Write(values)
+	}
+
+	return h.Sum(nil), nil
+}
+
+// claim stores record unless its key is already in use, in which case
+// record is loaded with the stored call. Expired keys are claimed again.
+func claim(db *gorm.DB, record *Key) (claimed bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) (err error) {
+		if err = tx.Where("idempotency_key = ? AND method = ?", record.Key, record.Method).
+			Where("created_at < ?", time.Now().Add(-keyTTL)).
+			Delete(&Key{}).Error; err != nil {
+			return
+		}
+
+		stmt := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if err = stmt.Error; err != nil {
+			return
+		}
+
+		if stmt.RowsAffected == 1 {
+			claimed = true
+			return
+		}
+
+		return tx.Where(&Key{Key: record.Key, Method: record.Method}).First(record).Error
+	})
+	return
+}
+
+// replay returns the response stored for a key already used, and sends its
+// response headers again.
+func replay(ctx context.Context, record Key, hash []byte) (any, error) {
+	if !bytes.Equal(record.RequestHash, hash) {
+		return nil, status.Error(codes.InvalidArgument, "idempotency key was used with a different request")
+	}
+
+	if record.ResponseType == "" {
+		if time.Since(record.UpdatedAt) > claimTTL {
+			return nil, status.Error(codes.Unknown, "a request with this idempotency key didn't finish and may have taken effect")
+		}
+		return nil, status.Error(codes.Aborted, "a request with this idempotency key is in progress")
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(record.ResponseType))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := mt.New().Interface()
+	if err = proto.Unmarshal(record.Response, resp); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if len(record.ResponseHeader) > 0 {
+		var header metadata.MD
+		if err = json.Unmarshal(record.ResponseHeader, &header); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if header.Len() > 0 {
+			if err = grpc.SetHeader(ctx, header); err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// recordingStream keeps a copy of the headers a handler sets.
+type recordingStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *recordingStream) SetHeader(md metadata.MD) error {
+	if err := s.ServerTransportStream.SetHeader(md); err != nil {
+		return err
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *recordingStream) SendHeader(md metadata.MD) error {
+	if err := s.ServerTransportStream.SendHeader(md); err != nil {
+		return err
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func keyFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(KeyHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestRequestHash(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]any{"order_id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := structpb.NewStruct(map[string]any{"order_id": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := []string{"x-user-id", "x-reason"}
+	base := metadata.Pairs("x-user-id", "alice")
+
+	tests := []struct {
+		name  string
+		msg   *structpb.Struct
+		md    metadata.MD
+		equal bool
+	}{
+		{"same request", msg, metadata.Pairs("x-user-id", "alice"), true},
+		{"unhashed header", msg, metadata.Pairs("x-user-id", "alice", "x-trace", "1"), true},
+		{"other message", other, metadata.Pairs("x-user-id", "alice"), false},
+		{"other header value", msg, metadata.Pairs("x-user-id", "bob"), false},
+		{"extra hashed header", msg, metadata.Pairs("x-user-id", "alice", "x-reason", "damaged"), false},
+		{"missing header", msg, nil, false},
+	}
+
+	want, err := requestHash(metadata.NewIncomingContext(context.Background(), base), msg, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requestHash(metadata.NewIncomingContext(context.Background(), tt.md), tt.msg, headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Equal(got, want) != tt.equal {
+				t.Errorf("hash equal = %v, want %v", !tt.equal, tt.equal)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	desc := &grpc.ServiceDesc{ServiceName: "test.v1.EchoService"}
+	for _, name := range []string{"Echo", "Reset"} {
+		name := name
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(_ any, ctx context.Context, _ func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				handle := func(context.Context, any) (any, error) { return name, nil }
+				if interceptor == nil {
+					return handle(ctx, nil)
+				}
+				return interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.v1.EchoService/" + name}, handle)
+			},
+		})
+	}
+
+	var intercepted []string
+	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		intercepted = append(intercepted, info.FullMethod)
+		return handler(ctx, req)
+	}
+
+	wrapped := Wrap(desc, interceptor, Methods(desc, "Reset")...)
+	for _, m := range wrapped.Methods {
+		resp, err := m.Handler(nil, context.Background(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp != m.MethodName {
+			t.Errorf("%s returned %v", m.MethodName, resp)
+		}
+	}
+
+	if len(intercepted) != 1 || intercepted[0] != "/test.v1.EchoService/Reset" {
+		t.Errorf("intercepted %v, want only Reset", intercepted)
+	}
+}
+
+func TestReplayUnfinished(t *testing.T) {
+	hash := []byte("hash")
+
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		want      codes.Code
+	}{
+		{"in progress", time.Now(), codes.Aborted},
+		{"abandoned", time.Now().Add(-claimTTL - time.Second), codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := replay(context.Background(), Key{RequestHash: hash, UpdatedAt: tt.updatedAt}, hash)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("replay() code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	_, err := replay(context.Background(), Key{RequestHash: []byte("other")}, hash)
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("replay() with another request code = %s, want %s", got, codes.InvalidArgument)
+	}
+}
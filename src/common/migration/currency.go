@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/smallbiznis/common/money"
+	"gorm.io/gorm"
+)
+
+// BaseCurrency returns the SQL expression of the currency the amounts of a
+// row of table were stored in before currencies were configurable: the
+// currency of the country of the organization owning the row, from the
+// countries seeded with the database, or money.DefaultCurrency. It is the
+// base currency the organization service gave those organizations, read
+// from the database the services share, so services don't depend on the
+// organization service being up to start.
+func BaseCurrency(tx *gorm.DB, table string) string {
+	if !tx.Migrator().HasTable("organizations") || !tx.Migrator().HasTable("countries") {
+		return fmt.Sprintf("'%s'", money.DefaultCurrency)
+	}
+
+	return fmt.Sprintf(`COALESCE((SELECT NULLIF(countries.currency_code, '') FROM organizations
+		JOIN countries ON countries.country_code = organizations.country_id
+		WHERE organizations.id = %s.organization_id), '%s')`, table, money.DefaultCurrency)
+}
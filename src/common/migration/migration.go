@@ -0,0 +1,148 @@
+// Package migration runs the data migrations AutoMigrate can't express,
+// such as converting decimal amounts to money.Money, once per database.
+//
+//	err := migration.Once(db, "transaction/0001-money", func(tx *gorm.DB) error {
+//		return migration.ConvertMoney(tx, "orders", "currency", "total_amount")
+//	})
+package migration
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smallbiznis/common/money"
+	"gorm.io/gorm"
+)
+
+// Migration is a data migration that ran. The services share the database,
+// so versions are prefixed with the service owning the migration.
+type Migration struct {
+	Version   string    `gorm:"column:version;primaryKey" json:"version"`
+	AppliedAt time.Time `gorm:"column:applied_at" json:"applied_at"`
+}
+
+func (Migration) TableName() string {
+	return "data_migrations"
+}
+
+// Once runs fn in a transaction and records version with it, unless version
+// is recorded already. Replicas starting together wait for the first one to
+// finish, so fn runs once even then.
+func Once(db *gorm.DB, version string, fn func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&Migration{}); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", version).Error; err != nil {
+			return err
+		}
+
+		var applied int64
+		if err := tx.Model(&Migration{}).Where(&Migration{Version: version}).Count(&applied).Error; err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			return nil
+		}
+
+		if err := fn(tx); err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+
+		return tx.Create(&Migration{Version: version, AppliedAt: time.Now()}).Error
+	})
+}
+
+// ConvertMoney replaces the decimal columns of table with bigint minor units
+// of the currency selected by the SQL expression currency, in one UPDATE.
+// Amounts are rounded half away from zero to the minor unit, like
+// money.NewMoney. Columns already converted are left alone.
+func ConvertMoney(tx *gorm.DB, table, currency string, columns ...string) error {
+	columns = DecimalColumns(tx, table, columns)
+	if len(columns) == 0 {
+		return nil
+	}
+
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s_minor bigint NOT NULL DEFAULT 0", table, column)).Error; err != nil {
+			return err
+		}
+
+		set[i] = fmt.Sprintf("%s_minor = ROUND(COALESCE(%s, 0)::numeric * power(10::numeric, %s))", column, column, MinorUnits(currency))
+	}
+
+	if err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s", table, strings.Join(set, ", "))).Error; err != nil {
+		return err
+	}
+
+	return ReplaceColumns(tx, table, columns)
+}
+
+// MinorUnits returns the SQL expression of the number of decimals of the
+// currency selected by the SQL expression currency, like money.MinorUnits.
+func MinorUnits(currency string) string {
+	exponents := money.Exponents()
+
+	codes := make([]string, 0, len(exponents))
+	for code := range exponents {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CASE %s", currency)
+	for _, code := range codes {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", code, exponents[code])
+	}
+	b.WriteString(" ELSE 2 END")
+
+	return b.String()
+}
+
+// DecimalColumns returns the columns of table still stored as decimals.
+func DecimalColumns(tx *gorm.DB, table string, columns []string) []string {
+	if !tx.Migrator().HasTable(table) {
+		return nil
+	}
+
+	types, err := tx.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil
+	}
+
+	decimal := map[string]bool{}
+	for _, t := range types {
+		switch strings.ToLower(t.DatabaseTypeName()) {
+		case "numeric", "decimal", "real", "float4", "float8", "double precision":
+			decimal[t.Name()] = true
+		}
+	}
+
+	var result []string
+	for _, column := range columns {
+		if decimal[column] {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// ReplaceColumns drops the decimal columns of table and moves their
+// converted _minor columns in their place.
+func ReplaceColumns(tx *gorm.DB, table string, columns []string) error {
+	for _, column := range columns {
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column)).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s_minor TO %s", table, column, column)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMinorUnits(t *testing.T) {
+	expr := MinorUnits("orders.currency")
+
+	for _, want := range []string{
+		"CASE orders.currency WHEN 'BHD' THEN 3 WHEN 'CLP' THEN 0",
+		" WHEN 'JPY' THEN 0 ",
+		" ELSE 2 END",
+	} {
+		if !strings.Contains(expr, want) {
+			t.Errorf("MinorUnits() = %q, want it to contain %q", expr, want)
+		}
+	}
+
+	if strings.Contains(expr, "'IDR'") {
+		t.Errorf("MinorUnits() = %q, want currencies with two decimals left to ELSE", expr)
+	}
+}
@@ -0,0 +1,222 @@
+// Package money holds the amounts, percentages and exchange rates every
+// service stores and exchanges, as exact integers. Service domains alias
+// its types, so an amount means the same thing on both ends of a call.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts whose organization has no
+// country currency.
+const DefaultCurrency = "IDR"
+
+// Money is an amount in the minor unit of its currency, such as cents for
+// USD or sen for IDR: Rp 15.000.000,50 is 1500000050. Amounts are exact
+// integers, so sums and comparisons never drift. The currency is the ISO
+// 4217 code of Countries.CurrencyCode, kept by the record owning the amount.
+//
+// Rounding: every operation producing a fraction of a minor unit (tax,
+// percentages, proportional shares, conversion from the decimal protos)
+// rounds half away from zero to the minor unit, once per line. Totals are
+// sums of rounded lines, so the same order always yields the same receipt.
+type Money int64
+
+// Rate is a percentage with four decimals: 110000 is 11%, 88750 is 8.875%.
+type Rate int64
+
+// rateScale is the number of Rate units in one percent.
+const rateScale = 10000
+
+// HundredPercent is the Rate of 100%.
+const HundredPercent Rate = 100 * rateScale
+
+// minorUnits lists the ISO 4217 currencies whose minor unit isn't the
+// hundredth.
+var minorUnits = map[string]int{
+	"BHD": 3,
+	"CLP": 0,
+	"IQD": 3,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"PYG": 0,
+	"TND": 3,
+	"UGX": 0,
+	"VND": 0,
+	"XAF": 0,
+	"XOF": 0,
+}
+
+// Exponents returns the currencies whose minor unit isn't the hundredth,
+// with their number of decimals. Every other currency has two.
+func Exponents() map[string]int {
+	return maps.Clone(minorUnits)
+}
+
+// MinorUnits returns the number of decimals of currency.
+func MinorUnits(currency string) int {
+	if n, ok := minorUnits[currency]; ok {
+		return n
+	}
+	return 2
+}
+
+// NewMoney converts a decimal amount of currency, as carried by the protos,
+// rounding half away from zero to the minor unit.
+func NewMoney(amount float64, currency string) Money {
+	return Money(math.Round(amount * math.Pow10(MinorUnits(currency))))
+}
+
+// ErrInvalidAmount is returned by Parse for strings that aren't decimal
+// amounts of the currency.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Parse converts a decimal amount of currency, such as "15000000.50",
+// exactly. Amounts with more decimals than the currency has are rejected
+// rather than rounded.
+func Parse(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+
+	sign := int64(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	n := MinorUnits(currency)
+	if whole == "" || len(fraction) > n || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, ErrInvalidAmount
+	}
+
+	v, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", n-len(fraction)), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidAmount
+	}
+
+	return Money(sign * v), nil
+}
+
+// Float32 returns m as a decimal amount of currency, for the float fields
+// of the go-genproto messages. float32 is exact only up to 2^24 minor
+// units, so services exchange amounts as Money over rpc, never through
+// these fields.
+func (m Money) Float32(currency string) float32 {
+	return float32(float64(m) / math.Pow10(MinorUnits(currency)))
+}
+
+// Format returns m as a decimal amount of currency followed by its code,
+// such as "15000.50 IDR", for messages.
+func (m Money) Format(currency string) string {
+	sign, v := "", int64(m)
+	if v < 0 {
+		sign, v = "-", -v
+	}
+
+	n := MinorUnits(currency)
+	if n == 0 {
+		return fmt.Sprintf("%s%d %s", sign, v, currency)
+	}
+
+	unit := int64(math.Pow10(n))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, v/unit, n, v%unit, currency)
+}
+
+// Mul returns m times n.
+func (m Money) Mul(n int32) Money {
+	return m * Money(n)
+}
+
+// MulDiv returns m × num / den rounded half away from zero, without
+// overflowing on the intermediate product.
+func (m Money) MulDiv(num, den int64) Money {
+	if den == 0 {
+		return 0
+	}
+
+	p := new(big.Int).Mul(big.NewInt(int64(m)), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		p.Neg(p)
+		d.Neg(d)
+	}
+
+	q, r := new(big.Int).QuoRem(p, d, new(big.Int))
+	// Round half away from zero: |2r| >= den moves q one unit outwards.
+	if new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(d) >= 0 {
+		q.Add(q, big.NewInt(int64(p.Sign())))
+	}
+
+	return Money(q.Int64())
+}
+
+// Percent returns rate percent of m, rounded half away from zero.
+func (m Money) Percent(rate Rate) Money {
+	return m.MulDiv(int64(rate), 100*rateScale)
+}
+
+// NewRate converts a decimal percentage, as carried by the protos, rounding
+// half away from zero to four decimals.
+func NewRate(percent float64) Rate {
+	return Rate(math.Round(percent * rateScale))
+}
+
+// Float32 returns r as a decimal percentage, for the float fields of the
+// go-genproto messages.
+func (r Rate) Float32() float32 {
+	return float32(float64(r) / rateScale)
+}
+
+// ExchangeRate is the value of one unit of a selling currency in the base
+// currency of an organization, with eight decimals: at 1 IDR = 0.0000833
+// SGD the IDR rate of a SGD organization is 8330.
+type ExchangeRate int64
+
+// exchangeRateScale is the number of ExchangeRate units in one.
+const exchangeRateScale = 100000000
+
+// BaseRate is the exchange rate of the base currency to itself.
+const BaseRate ExchangeRate = exchangeRateScale
+
+// NewExchangeRate converts a decimal exchange rate, rounding half away from
+// zero to eight decimals.
+func NewExchangeRate(rate float64) ExchangeRate {
+	return ExchangeRate(math.Round(rate * exchangeRateScale))
+}
+
+// Float64 returns r as a decimal exchange rate.
+func (r ExchangeRate) Float64() float64 {
+	return float64(r) / exchangeRateScale
+}
+
+// ToBase converts m from currency to the base currency at rate, rounding
+// half away from zero.
+func (m Money) ToBase(currency, base string, rate ExchangeRate) Money {
+	if currency == base {
+		return m
+	}
+	return m.MulDiv(int64(rate)*pow10(MinorUnits(base)), exchangeRateScale*pow10(MinorUnits(currency)))
+}
+
+// FromBase converts m from the base currency to currency at rate, rounding
+// half away from zero.
+func (m Money) FromBase(currency, base string, rate ExchangeRate) Money {
+	if currency == base {
+		return m
+	}
+	return m.MulDiv(exchangeRateScale*pow10(MinorUnits(currency)), int64(rate)*pow10(MinorUnits(base)))
+}
+
+func pow10(n int) int64 {
+	return int64(math.Pow10(n))
+}
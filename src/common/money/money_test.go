@@ -0,0 +1,168 @@
+package money
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        Money
+		num, den int64
+		want     Money
+	}{
+		{"exact", 1000, 3, 4, 750},
+		{"rounds down below half", 10, 1, 3, 3},
+		{"rounds half up", 5, 1, 2, 3},
+		{"rounds half away from zero", -5, 1, 2, -3},
+		{"rounds down below half negative", -10, 1, 3, -3},
+		{"negative denominator", 5, 1, -2, -3},
+		{"negative numerator and denominator", 5, -1, -2, 3},
+		{"zero denominator", 100, 1, 0, 0},
+		{"zero amount", 0, 7, 3, 0},
+		{"intermediate product overflows int64", math.MaxInt64 / 2, 4, 4, math.MaxInt64 / 2},
+		{"large share", 1500000050, 2, 3, 1000000033},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.MulDiv(tt.num, tt.den); got != tt.want {
+				t.Errorf("%d.MulDiv(%d, %d) = %d, want %d", tt.m, tt.num, tt.den, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Money
+		rate Rate
+		want Money
+	}{
+		{"whole percent", 10000, NewRate(11), 1100},
+		{"fractional percent", 10000, NewRate(8.875), 888},
+		{"rounds half away from zero", 50, NewRate(1), 1},
+		{"rounds down below half", 49, NewRate(1), 0},
+		{"negative amount", -50, NewRate(1), -1},
+		{"zero rate", 10000, 0, 0},
+		{"hundred percent", 12345, NewRate(100), 12345},
+		{"large amount", 1500000050, NewRate(11), 165000006},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Percent(tt.rate); got != tt.want {
+				t.Errorf("%d.Percent(%d) = %d, want %d", tt.m, tt.rate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMoney(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     Money
+	}{
+		{15000000.50, "IDR", 1500000050},
+		{0.005, "USD", 1},
+		{-0.005, "USD", -1},
+		{1500, "JPY", 1500},
+		{1.2345, "KWD", 1235},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("NewMoney(%v, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m        Money
+		currency string
+		want     string
+	}{
+		{1500000050, "IDR", "15000000.50 IDR"},
+		{-5, "USD", "-0.05 USD"},
+		{1500, "JPY", "1500 JPY"},
+		{1235, "KWD", "1.235 KWD"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Format(tt.currency); got != tt.want {
+			t.Errorf("%d.Format(%s) = %q, want %q", tt.m, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	// 1 SGD = 12000 IDR: the SGD rate of an IDR organization is 12000.
+	rate := NewExchangeRate(12000)
+
+	tests := []struct {
+		name     string
+		m        Money
+		currency string
+		base     string
+		toBase   Money
+		fromBase Money
+	}{
+		{"base currency", 1000, "IDR", "IDR", 1000, 1000},
+		{"selling currency", 1050, "SGD", "IDR", 12600000, 1050},
+		{"no minor unit", 100, "JPY", "JPY", 100, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := tt.m.ToBase(tt.currency, tt.base, rate)
+			if base != tt.toBase {
+				t.Errorf("ToBase = %d, want %d", base, tt.toBase)
+			}
+
+			if got := base.FromBase(tt.currency, tt.base, rate); got != tt.fromBase {
+				t.Errorf("FromBase = %d, want %d", got, tt.fromBase)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s        string
+		currency string
+		want     Money
+		err      bool
+	}{
+		{"15000000.50", "IDR", 1500000050, false},
+		{"15000000.5", "IDR", 1500000050, false},
+		{"15000000", "IDR", 1500000000, false},
+		{"-0.05", "USD", -5, false},
+		{" 12.34 ", "USD", 1234, false},
+		{"1500", "JPY", 1500, false},
+		{"1.235", "KWD", 1235, false},
+		{"0.001", "USD", 0, true},
+		{"1.5", "JPY", 0, true},
+		{"", "USD", 0, true},
+		{".5", "USD", 0, true},
+		{"1.2.3", "USD", 0, true},
+		{"--1", "USD", 0, true},
+		{"1e3", "USD", 0, true},
+		{"99999999999999999999", "USD", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.s, tt.currency)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse(%q, %s) error = %v, want error %v", tt.s, tt.currency, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Parse(%q, %s) = %d, want %d", tt.s, tt.currency, got, tt.want)
+		}
+	}
+}
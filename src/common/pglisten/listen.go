@@ -0,0 +1,90 @@
+// Package pglisten subscribes to Postgres NOTIFY channels, which the
+// services use to publish events to each other.
+package pglisten
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+)
+
+// retryInterval is how long Listen waits before reconnecting after an error.
+const retryInterval = 5 * time.Second
+
+// Option configures Listen.
+type Option func(*listener)
+
+// CatchUp has Listen call catchUp every time it starts listening, after
+// LISTEN took effect, to handle the events published while it wasn't. Events
+// published meanwhile may be handled twice, so handlers must be idempotent.
+func CatchUp(catchUp func(context.Context) error) Option {
+	return func(l *listener) {
+		l.catchUp = catchUp
+	}
+}
+
+type listener struct {
+	channel string
+	handle  func(context.Context, string) error
+	catchUp func(context.Context) error
+}
+
+// Listen subscribes to a Postgres NOTIFY channel and calls handle with the
+// payload of every notification until ctx is cancelled. Lost connections are
+// retried.
+func Listen(ctx context.Context, db *sql.DB, channel string, handle func(context.Context, string) error, opts ...Option) {
+	l := &listener{channel: channel, handle: handle}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	for {
+		if err := l.listen(ctx, db); err != nil && ctx.Err() == nil {
+			zap.L().Error("listen failed", zap.String("channel", channel), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (l *listener) listen(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn := driverConn.(*stdlib.Conn).Conn()
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+l.channel); err != nil {
+			return err
+		}
+
+		// A failed catch up is retried with the next connection, so the
+		// events it missed aren't lost for good.
+		if l.catchUp != nil {
+			if err := l.catchUp(ctx); err != nil {
+				return err
+			}
+		}
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := l.handle(ctx, n.Payload); err != nil {
+				zap.L().Error("failed handle notification", zap.String("channel", l.channel), zap.Error(err))
+			}
+		}
+	})
+}
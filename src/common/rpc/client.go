@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Invoke calls a method of the service named service on conn, encoding req
+// and decoding the response into resp the way Service does. Fields of the
+// response resp doesn't have are ignored, so servers can add fields.
+func Invoke(ctx context.Context, conn grpc.ClientConnInterface, service, method string, req, resp any, opts ...grpc.CallOption) error {
+	in, err := Encode(req)
+	if err != nil {
+		return err
+	}
+
+	out := new(structpb.Struct)
+	if err = conn.Invoke(ctx, "/"+service+"/"+method, in, out, opts...); err != nil {
+		return err
+	}
+
+	return decode(out, resp, false)
+}
@@ -0,0 +1,79 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Path returns the HTTP path the named method is served at by
+// RegisterHandlerFromEndpoint.
+func (s *Service) Path(name string) string {
+	return "/v1/rpc/" + s.name + "/" + name
+}
+
+// RegisterHandlerFromEndpoint serves every method on mux as a POST of its
+// JSON request to Path, forwarded to the gRPC server at endpoint the way
+// the generated gateways forward theirs, interceptors and metadata
+// included.
+func (s *Service) RegisterHandlerFromEndpoint(ctx context.Context, mux *runtime.ServeMux, endpoint string, opts []grpc.DialOption) (err error) {
+	conn, err := grpc.NewClient(endpoint, opts...)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for _, m := range s.methods {
+		if err = mux.HandlePath(http.MethodPost, s.Path(m.name), s.forward(mux, conn, m.name)); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) forward(mux *runtime.ServeMux, conn *grpc.ClientConn, name string) runtime.HandlerFunc {
+	fullMethod := s.FullMethod(name)
+	path := s.Path(name)
+
+	return func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		inbound, outbound := runtime.MarshalerForRequest(mux, r)
+
+		ctx, err := runtime.AnnotateContext(ctx, mux, r, fullMethod, runtime.WithHTTPPathPattern(path))
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		in := new(structpb.Struct)
+		if err = inbound.NewDecoder(r.Body).Decode(in); err != nil && err != io.EOF {
+			runtime.HTTPError(ctx, mux, outbound, w, r, status.Errorf(codes.InvalidArgument, "%v", err))
+			return
+		}
+
+		var md runtime.ServerMetadata
+		out := new(structpb.Struct)
+		err = conn.Invoke(ctx, fullMethod, in, out, grpc.Header(&md.HeaderMD), grpc.Trailer(&md.TrailerMD))
+		ctx = runtime.NewServerMetadataContext(ctx, md)
+		if err != nil {
+			runtime.HTTPError(ctx, mux, outbound, w, r, err)
+			return
+		}
+
+		runtime.ForwardResponseMessage(ctx, mux, outbound, w, r, out)
+	}
+}
@@ -0,0 +1,168 @@
+// Package rpc serves gRPC services whose messages have no generated Go
+// types. Requests and responses travel as google.protobuf.Struct and are
+// decoded into and encoded from plain Go structs with encoding/json, so a
+// service is declared with ordinary handler functions:
+//
+//	svc := rpc.NewService("smallbiznis.inventory.v1.LedgerService")
+//	rpc.Query(svc, "ListMovement", handler.ListMovement)
+//	rpc.Command(svc, "Transfer", handler.Transfer)
+//
+// Numbers cross the wire as JSON numbers, exact up to 2^53.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Service is a gRPC service built from handler functions.
+type Service struct {
+	name    string
+	methods []method
+}
+
+type method struct {
+	name    string
+	command bool
+	handle  func(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+// NewService returns an empty service with the fully qualified name, such
+// as "smallbiznis.inventory.v1.LedgerService".
+func NewService(name string) *Service {
+	return &Service{name: name}
+}
+
+// Name returns the fully qualified name of the service.
+func (s *Service) Name() string {
+	return s.name
+}
+
+// Query adds a method that only reads.
+func Query[Req, Resp any](s *Service, name string, fn func(context.Context, *Req) (*Resp, error)) {
+	add(s, name, false, fn)
+}
+
+// Command adds a method that changes state. Commands are the methods
+// Commands lists, for interceptors that only apply to writes.
+func Command[Req, Resp any](s *Service, name string, fn func(context.Context, *Req) (*Resp, error)) {
+	add(s, name, true, fn)
+}
+
+func add[Req, Resp any](s *Service, name string, command bool, fn func(context.Context, *Req) (*Resp, error)) {
+	s.methods = append(s.methods, method{
+		name:    name,
+		command: command,
+		handle: func(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+			req := new(Req)
+			if err := Decode(in, req); err != nil {
+				return nil, err
+			}
+
+			resp, err := fn(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			return Encode(resp)
+		},
+	})
+}
+
+// FullMethod returns the full gRPC method name of the named method, as
+// seen by interceptors.
+func (s *Service) FullMethod(name string) string {
+	return "/" + s.name + "/" + name
+}
+
+// Commands returns the full method names of the commands.
+func (s *Service) Commands() (names []string) {
+	for _, m := range s.methods {
+		if m.command {
+			names = append(names, s.FullMethod(m.name))
+		}
+	}
+	return
+}
+
+// Desc returns the descriptor to register the service with. Its handlers
+// don't use the implementation passed to RegisterService, which may be nil.
+func (s *Service) Desc() *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: s.name,
+		HandlerType: (*any)(nil),
+		Streams:     []grpc.StreamDesc{},
+	}
+
+	for _, m := range s.methods {
+		m := m
+		fullMethod := s.FullMethod(m.name)
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: m.name,
+			Handler: func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+
+				if interceptor == nil {
+					return m.handle(ctx, in)
+				}
+
+				info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+				return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+					return m.handle(ctx, req.(*structpb.Struct))
+				})
+			},
+		})
+	}
+
+	return desc
+}
+
+// Register registers the service on srv.
+func (s *Service) Register(srv grpc.ServiceRegistrar) {
+	srv.RegisterService(s.Desc(), nil)
+}
+
+// Decode decodes a request into v, rejecting unknown fields.
+func Decode(in *structpb.Struct, v any) error {
+	return decode(in, v, true)
+}
+
+func decode(in *structpb.Struct, v any, strict bool) error {
+	b, err := protojson.Marshal(in)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err = dec.Decode(v); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// Encode encodes v, which must encode as a JSON object, as a message.
+func Encode(v any) (*structpb.Struct, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	out := new(structpb.Struct)
+	if err = protojson.Unmarshal(b, out); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
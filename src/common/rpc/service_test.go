@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type echoRequest struct {
+	Text  string `json:"text"`
+	Times int64  `json:"times"`
+}
+
+type echoResponse struct {
+	Text string `json:"text"`
+}
+
+func newTestService() *Service {
+	svc := NewService("test.v1.EchoService")
+	Query(svc, "Echo", func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		if req.Times <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "times must be positive")
+		}
+		return &echoResponse{Text: strings.Repeat(req.Text, int(req.Times))}, nil
+	})
+	Command(svc, "Reset", func(ctx context.Context, req *struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	})
+	return svc
+}
+
+func serve(t *testing.T, svc *Service) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	svc.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func TestService(t *testing.T) {
+	svc := newTestService()
+
+	conn, err := grpc.NewClient(serve(t, svc), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name string
+		req  map[string]any
+		want string
+		code codes.Code
+	}{
+		{name: "ok", req: map[string]any{"text": "ab", "times": 2}, want: "abab"},
+		{name: "handler error", req: map[string]any{"text": "ab"}, code: codes.InvalidArgument},
+		{name: "unknown field", req: map[string]any{"txt": "ab", "times": 1}, code: codes.InvalidArgument},
+		{name: "wrong type", req: map[string]any{"text": 1, "times": 1}, code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := structpb.NewStruct(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out := new(structpb.Struct)
+			err = conn.Invoke(context.Background(), svc.FullMethod("Echo"), in, out)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code = %v, want %v (%v)", code, tt.code, err)
+			}
+
+			if err == nil && out.Fields["text"].GetStringValue() != tt.want {
+				t.Errorf("text = %q, want %q", out.Fields["text"].GetStringValue(), tt.want)
+			}
+		})
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	svc := newTestService()
+
+	conn, err := grpc.NewClient(serve(t, svc), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var resp struct{}
+	if err := Invoke(context.Background(), conn, svc.Name(), "Echo", &echoRequest{Text: "ab", Times: 2}, &resp); err != nil {
+		t.Fatalf("Invoke() with fewer response fields = %v", err)
+	}
+
+	var echo echoResponse
+	if err := Invoke(context.Background(), conn, svc.Name(), "Echo", &echoRequest{Text: "ab", Times: 3}, &echo); err != nil {
+		t.Fatal(err)
+	}
+	if echo.Text != "ababab" {
+		t.Errorf("Text = %q, want %q", echo.Text, "ababab")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	got := newTestService().Commands()
+	if len(got) != 1 || got[0] != "/test.v1.EchoService/Reset" {
+		t.Errorf("Commands() = %v", got)
+	}
+}
+
+func TestRegisterHandlerFromEndpoint(t *testing.T) {
+	svc := newTestService()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := runtime.NewServeMux()
+	if err := svc.RegisterHandlerFromEndpoint(ctx, mux, serve(t, svc), []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "ok", body: `{"text":"ab","times":2}`, status: http.StatusOK, want: `"abab"`},
+		{name: "handler error", body: `{"text":"ab"}`, status: http.StatusBadRequest},
+		{name: "malformed", body: `{`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, svc.Path("Echo"), strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", w.Body, tt.want)
+			}
+		})
+	}
+}
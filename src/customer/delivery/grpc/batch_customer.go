@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/customer/domain"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchCustomers is the most customers BatchGetCustomer returns at once.
+const maxBatchCustomers = 500
+
+// BatchCustomerService serves customers by ID in batches, for the services
+// listing their orders and carts, as an rpc.Service.
+type BatchCustomerService struct {
+	customerRepository domain.ICustomerRepository
+}
+
+func NewBatchCustomerService(customerRepository domain.ICustomerRepository) *BatchCustomerService {
+	return &BatchCustomerService{
+		customerRepository: customerRepository,
+	}
+}
+
+// Service returns the batch methods as smallbiznis.customer.v1.BatchCustomerService.
+func (svc *BatchCustomerService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.customer.v1.BatchCustomerService")
+	rpc.Query(s, "BatchGetCustomer", svc.BatchGetCustomer)
+	return s
+}
+
+type BatchGetCustomerRequest struct {
+	CustomerIDs []string `json:"customer_ids"`
+}
+
+type BatchGetCustomerResponse struct {
+	Data domain.Customers `json:"data"`
+}
+
+// BatchGetCustomer returns the customers with the given IDs. IDs without a
+// customer are left out.
+func (svc *BatchCustomerService) BatchGetCustomer(ctx context.Context, req *BatchGetCustomerRequest) (*BatchGetCustomerResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("BatchGetCustomer")
+
+	if len(req.CustomerIDs) == 0 {
+		return &BatchGetCustomerResponse{}, nil
+	}
+
+	if len(req.CustomerIDs) > maxBatchCustomers {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d customer_ids are allowed", maxBatchCustomers)
+	}
+
+	customers, err := svc.customerRepository.FindByIDs(ctx, req.CustomerIDs)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &BatchGetCustomerResponse{
+		Data: customers,
+	}, nil
+}
@@ -58,5 +58,6 @@ func (m Customers) ToProto() (data []*customer.Customer) {
 type ICustomerRepository interface {
 	Find(context.Context, pagination.Pagination, Customer) (Customers, int64, error)
 	FindOne(context.Context, Customer) (*Customer, error)
+	FindByIDs(context.Context, []string) (Customers, error)
 	Save(context.Context, Customer) (*Customer, error)
 }
@@ -5,8 +5,10 @@ go 1.22
 toolchain go1.22.7
 
 require (
+	github.com/google/uuid v1.6.0
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.22.0
 	github.com/lib/pq v1.10.9
+	github.com/smallbiznis/common v0.0.0-00010101000000-000000000000
 	github.com/smallbiznis/go-genproto v0.0.0-20241031025101-0e85b74eb010
 	github.com/smallbiznis/go-lib v0.0.0-20240914084120-a17d92ee2db5
 	github.com/uptrace/opentelemetry-go-extra/otelgorm v0.3.1
@@ -23,7 +25,6 @@ require (
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
 	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/pgx/v5 v5.5.5 // indirect
@@ -50,3 +51,5 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20240814211410-ddb44dafa142 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
+
+replace github.com/smallbiznis/common => ../common
@@ -7,6 +7,8 @@ import (
 	"syscall"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/rpc"
 	grpchandler "github.com/smallbiznis/customer/delivery/grpc"
 	"github.com/smallbiznis/customer/infrastructure"
 	"github.com/smallbiznis/customer/repository"
@@ -20,6 +22,7 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
 )
 
 func NewZapLogger() fxevent.Logger {
@@ -37,8 +40,11 @@ func NewHttpServer(mux *runtime.ServeMux) *http.Server {
 	}
 }
 
-func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.CustomerService) {
-	customer.RegisterCustomerServiceServer(srv, svc)
+// RegisterServiceServer registers the service with calls to the methods
+// changing state carrying an idempotency-key header handled at most once.
+func RegisterServiceServer(srv *grpc.Server, db *gorm.DB, svc *grpchandler.CustomerService) {
+	srv.RegisterService(idempotency.Wrap(&customer.CustomerService_ServiceDesc, idempotency.NewInterceptor(db),
+		idempotency.Methods(&customer.CustomerService_ServiceDesc, "CreateCustomer", "UpdateCustomer", "DeleteCustomer", "CreateAddress", "UpdateAddress", "DeleteAddress")...), svc)
 }
 
 func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
@@ -49,6 +55,33 @@ func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
 	return customer.RegisterCustomerServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
 }
 
+// RPCServices are the services go-genproto has no messages for, served as
+// rpc.Services.
+type RPCServices struct {
+	fx.In
+	BatchCustomer *grpchandler.BatchCustomerService
+}
+
+// RegisterRPCServices registers the RPC services on the gRPC server and the
+// HTTP gateway.
+func RegisterRPCServices(srv *grpc.Server, mux *runtime.ServeMux, services RPCServices) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	for _, svc := range []*rpc.Service{
+		services.BatchCustomer.Service(),
+	} {
+		svc.Register(srv)
+
+		if err := svc.RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -76,12 +109,14 @@ func main() {
 			repository.NewCustomerRepository,
 			repository.NewAddressRepository,
 			grpchandler.NewCustomerService,
+			grpchandler.NewBatchCustomerService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
 		fx.Invoke(
 			RegisterServiceServer,
 			StartHTTPServer,
 			RegisterServiceHandlerFromEndpoint,
+			RegisterRPCServices,
 		),
 		server.GrpcServerInvoke,
 	)
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"github.com/smallbiznis/common/idempotency"
 	"github.com/smallbiznis/customer/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
+		&idempotency.Key{},
 		&domain.Customer{},
 		&domain.Addreses{},
 	)
@@ -53,6 +53,17 @@ func (r *customerRepository) FindOne(ctx context.Context, f domain.Customer) (or
 	return
 }
 
+// FindByIDs returns the customers with the given IDs, in one query.
+func (r *customerRepository) FindByIDs(ctx context.Context, ids []string) (customers domain.Customers, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.Customer{}).
+		Where("customer_id IN ?", ids).
+		Find(&customers).Error; err != nil {
+		return
+	}
+
+	return
+}
+
 func (r *customerRepository) Save(ctx context.Context, d domain.Customer) (org *domain.Customer, err error) {
 	if err = r.db.WithContext(ctx).Model(&domain.Customer{}).Create(&d).Error; err != nil {
 		return
@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AlertService serves low-stock alerts as an rpc.Service.
+type AlertService struct {
+	alertService *service.AlertService
+}
+
+func NewAlertService(alertService *service.AlertService) *AlertService {
+	return &AlertService{
+		alertService: alertService,
+	}
+}
+
+// Service returns the alert methods as smallbiznis.inventory.v1.AlertService.
+func (svc *AlertService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.AlertService")
+	rpc.Query(s, "ListLowStock", svc.ListLowStock)
+	rpc.Query(s, "ListLowStockEvent", svc.ListLowStockEvent)
+	return s
+}
+
+type ListLowStockRequest struct {
+	OrganizationID string `json:"organization_id"`
+	LocationID     string `json:"location_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListLowStockResponse struct {
+	TotalData int32                 `json:"total_data"`
+	Data      domain.InventoryItems `json:"data"`
+}
+
+func (svc *AlertService) ListLowStock(ctx context.Context, req *ListLowStockRequest) (*ListLowStockResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListLowStock")
+
+	items, count, err := svc.alertService.ListLowStock(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID, req.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListLowStockResponse{
+		TotalData: int32(count),
+		Data:      items,
+	}, nil
+}
+
+// ListLowStockEventRequest pages through low-stock events: the first page
+// starts at CreatedAt, the next ones after the last event of the previous
+// page, given by its created_at and low_stock_event_id.
+type ListLowStockEventRequest struct {
+	CreatedAt time.Time `json:"created_at"`
+	AfterID   string    `json:"after_id"`
+	Size      int32     `json:"size"`
+}
+
+type ListLowStockEventResponse struct {
+	Data domain.LowStockEvents `json:"data"`
+}
+
+func (svc *AlertService) ListLowStockEvent(ctx context.Context, req *ListLowStockEventRequest) (*ListLowStockEventResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListLowStockEvent")
+
+	events, err := svc.alertService.ListLowStockEvent(ctx, req.CreatedAt, req.AfterID, int(req.Size))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListLowStockEventResponse{
+		Data: events,
+	}, nil
+}
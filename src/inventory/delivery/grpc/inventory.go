@@ -2,11 +2,13 @@ package grpc
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
 	"github.com/smallbiznis/go-lib/pkg/pagination"
 	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -18,15 +20,18 @@ type InventoryService struct {
 	inventory.UnimplementedServiceServer
 	db                  *gorm.DB
 	inventoryRepository domain.IInventoryItemRepository
+	ledgerService       *service.LedgerService
 }
 
 func NewInventoryService(
 	db *gorm.DB,
 	inventoryRepository domain.IInventoryItemRepository,
+	ledgerService *service.LedgerService,
 ) *InventoryService {
 	return &InventoryService{
 		db:                  db,
 		inventoryRepository: inventoryRepository,
+		ledgerService:       ledgerService,
 	}
 }
 
@@ -85,7 +90,7 @@ func (svc *InventoryService) CreateInventory(ctx context.Context, req *inventory
 	exist, err := svc.inventoryRepository.FindOne(ctx, domain.InventoryItem{
 		OrganizationID: req.OrganizationId,
 		LocationID:     req.LocationId,
-		ItemID:         req.LocationId,
+		ItemID:         req.ItemId,
 	})
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -101,10 +106,11 @@ func (svc *InventoryService) CreateInventory(ctx context.Context, req *inventory
 		LocationID:     req.LocationId,
 		ItemID:         req.ItemId,
 		Quantity:       req.Quantity,
+		ReorderLevel:   req.ReorderLevel,
 	}
 
-	if _, err := svc.inventoryRepository.Save(ctx, newInventory); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	if _, err := svc.ledgerService.Open(ctx, newInventory); err != nil {
+		return nil, err
 	}
 
 	return svc.GetInventory(ctx, &inventory.GetInventoryRequest{
@@ -118,23 +124,57 @@ func (svc *InventoryService) UpdateInventory(ctx context.Context, req *inventory
 
 	span.SetName("UpdateInventoryItem")
 
-	return &inventory.Inventory{}, nil
+	exist, err := svc.inventoryRepository.FindOne(ctx, domain.InventoryItem{
+		ID: req.InventoryItemId,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if exist == nil {
+		return nil, status.Error(codes.InvalidArgument, "inventory not found")
+	}
+
+	if req.ReorderLevel != exist.ReorderLevel {
+		if err := svc.db.WithContext(ctx).Model(exist).
+			Update("reorder_level", req.ReorderLevel).Error; err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if delta := req.Quantity - exist.Quantity; delta != 0 {
+		if _, err := svc.ledgerService.Move(ctx, domain.StockMovement{
+			InventoryItemID: exist.ID,
+			Reason:          domain.CountCorrection,
+			Quantity:        delta,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc.GetInventory(ctx, &inventory.GetInventoryRequest{
+		InventoryItemId: exist.ID,
+	})
 }
 
 func (svc *InventoryService) ReservedStock(ctx context.Context, req *inventory.ReservedStockRequest) (*emptypb.Empty, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
 
-	exist, err := svc.GetInventory(ctx, &inventory.GetInventoryRequest{InventoryItemId: req.InventoryItemId})
-	if err != nil {
+	span.SetName("ReservedStock")
+
+	if req.Body == nil || req.Body.ReservedStock <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "reserved_stock must be greater than zero")
+	}
+
+	if _, err := svc.GetInventory(ctx, &inventory.GetInventoryRequest{InventoryItemId: req.InventoryItemId}); err != nil {
 		return nil, err
 	}
 
-	if _, err := svc.inventoryRepository.Update(ctx, domain.InventoryItem{
-		ID:               exist.InventoryItemId,
-		OrganizationID:   exist.OrganizationId,
-		ItemID:           exist.ItemId,
-		Quantity:         exist.Quantity,
-		ReservedQuantity: exist.ReservedQuantity + req.Body.ReservedStock,
-	}); err != nil {
+	if err := svc.inventoryRepository.Reserve(ctx, req.InventoryItemId, req.Body.ReservedStock); err != nil {
+		if errors.Is(err, domain.ErrInsufficientStock) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -142,19 +182,23 @@ func (svc *InventoryService) ReservedStock(ctx context.Context, req *inventory.R
 }
 
 func (svc *InventoryService) ReleaseStock(ctx context.Context, req *inventory.ReleaseStockRequest) (*emptypb.Empty, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
 
-	exist, err := svc.GetInventory(ctx, &inventory.GetInventoryRequest{InventoryItemId: req.InventoryItemId})
-	if err != nil {
+	span.SetName("ReleaseStock")
+
+	if req.Body == nil || req.Body.ReservedStock <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "reserved_stock must be greater than zero")
+	}
+
+	if _, err := svc.GetInventory(ctx, &inventory.GetInventoryRequest{InventoryItemId: req.InventoryItemId}); err != nil {
 		return nil, err
 	}
 
-	if _, err := svc.inventoryRepository.Update(ctx, domain.InventoryItem{
-		ID:               exist.InventoryItemId,
-		OrganizationID:   exist.OrganizationId,
-		ItemID:           exist.ItemId,
-		Quantity:         exist.Quantity,
-		ReservedQuantity: exist.ReservedQuantity + req.Body.ReservedStock,
-	}); err != nil {
+	if err := svc.inventoryRepository.Release(ctx, req.InventoryItemId, req.Body.ReservedStock); err != nil {
+		if errors.Is(err, domain.ErrInvalidRelease) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
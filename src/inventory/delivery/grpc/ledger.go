@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LedgerService serves the stock movement ledger. go-genproto has no
+// messages for it yet, so it is served as an rpc.Service.
+type LedgerService struct {
+	ledgerService *service.LedgerService
+}
+
+func NewLedgerService(ledgerService *service.LedgerService) *LedgerService {
+	return &LedgerService{
+		ledgerService: ledgerService,
+	}
+}
+
+// Service returns the methods of the ledger as smallbiznis.inventory.v1.LedgerService.
+func (svc *LedgerService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.LedgerService")
+	rpc.Query(s, "ListMovement", svc.ListMovement)
+	rpc.Query(s, "OnHandAt", svc.OnHandAt)
+	return s
+}
+
+type ListMovementRequest struct {
+	InventoryItemID string `json:"inventory_item_id"`
+	Page            int32  `json:"page"`
+	Size            int32  `json:"size"`
+}
+
+type ListMovementResponse struct {
+	TotalData int32                 `json:"total_data"`
+	Data      domain.StockMovements `json:"data"`
+}
+
+func (svc *LedgerService) ListMovement(ctx context.Context, req *ListMovementRequest) (*ListMovementResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListMovement")
+
+	if req.InventoryItemID == "" {
+		return nil, status.Error(codes.InvalidArgument, "inventory_item_id is required")
+	}
+
+	movements, count, err := svc.ledgerService.ListMovement(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.InventoryItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListMovementResponse{
+		TotalData: int32(count),
+		Data:      movements,
+	}, nil
+}
+
+// OnHandAtRequest asks for the on-hand quantity at At, now when it is zero.
+type OnHandAtRequest struct {
+	InventoryItemID string    `json:"inventory_item_id"`
+	At              time.Time `json:"at"`
+}
+
+type OnHandAtResponse struct {
+	InventoryItemID string    `json:"inventory_item_id"`
+	At              time.Time `json:"at"`
+	Quantity        int32     `json:"quantity"`
+}
+
+func (svc *LedgerService) OnHandAt(ctx context.Context, req *OnHandAtRequest) (*OnHandAtResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("OnHandAt")
+
+	if req.InventoryItemID == "" {
+		return nil, status.Error(codes.InvalidArgument, "inventory_item_id is required")
+	}
+
+	at := req.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	quantity, err := svc.ledgerService.OnHandAt(ctx, req.InventoryItemID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OnHandAtResponse{
+		InventoryItemID: req.InventoryItemID,
+		At:              at,
+		Quantity:        quantity,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LotService serves lot and expiry tracking as an rpc.Service.
+type LotService struct {
+	lotService *service.LotService
+}
+
+func NewLotService(lotService *service.LotService) *LotService {
+	return &LotService{
+		lotService: lotService,
+	}
+}
+
+// Service returns the lot methods as smallbiznis.inventory.v1.LotService.
+func (svc *LotService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.LotService")
+	rpc.Command(s, "TrackLots", svc.TrackLots)
+	rpc.Query(s, "ListLot", svc.ListLot)
+	rpc.Query(s, "ListExpiringLots", svc.ListExpiringLots)
+	return s
+}
+
+type TrackLotsRequest struct {
+	InventoryItemID string `json:"inventory_item_id"`
+}
+
+func (svc *LotService) TrackLots(ctx context.Context, req *TrackLotsRequest) (*domain.InventoryItem, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("TrackLots")
+
+	return svc.lotService.TrackLots(ctx, req.InventoryItemID)
+}
+
+type ListLotRequest struct {
+	OrganizationID  string `json:"organization_id"`
+	LocationID      string `json:"location_id"`
+	InventoryItemID string `json:"inventory_item_id"`
+	Page            int32  `json:"page"`
+	Size            int32  `json:"size"`
+}
+
+type ListLotResponse struct {
+	TotalData int32                `json:"total_data"`
+	Data      domain.InventoryLots `json:"data"`
+}
+
+func (svc *LotService) ListLot(ctx context.Context, req *ListLotRequest) (*ListLotResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListLot")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	lots, count, err := svc.lotService.ListLot(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.InventoryLot{
+		OrganizationID:  req.OrganizationID,
+		LocationID:      req.LocationID,
+		InventoryItemID: req.InventoryItemID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListLotResponse{
+		TotalData: int32(count),
+		Data:      lots,
+	}, nil
+}
+
+// ListExpiringLotsRequest asks for the lots of a location, every location
+// when LocationID is empty, that expire within Days.
+type ListExpiringLotsRequest struct {
+	OrganizationID string `json:"organization_id"`
+	LocationID     string `json:"location_id"`
+	Days           int32  `json:"days"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+func (svc *LotService) ListExpiringLots(ctx context.Context, req *ListExpiringLotsRequest) (*ListLotResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListExpiringLots")
+
+	lots, count, err := svc.lotService.ListExpiringLots(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID, req.LocationID, req.Days)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListLotResponse{
+		TotalData: int32(count),
+		Data:      lots,
+	}, nil
+}
@@ -0,0 +1,301 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PurchaseService serves suppliers, purchase orders and goods receipts as
+// an rpc.Service.
+type PurchaseService struct {
+	purchaseService *service.PurchaseService
+}
+
+func NewPurchaseService(purchaseService *service.PurchaseService) *PurchaseService {
+	return &PurchaseService{
+		purchaseService: purchaseService,
+	}
+}
+
+// Service returns the purchasing methods as smallbiznis.inventory.v1.PurchaseService.
+func (svc *PurchaseService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.PurchaseService")
+	rpc.Query(s, "ListSupplier", svc.ListSupplier)
+	rpc.Query(s, "GetSupplier", svc.GetSupplier)
+	rpc.Command(s, "CreateSupplier", svc.CreateSupplier)
+	rpc.Command(s, "UpdateSupplier", svc.UpdateSupplier)
+	rpc.Command(s, "DeleteSupplier", svc.DeleteSupplier)
+	rpc.Query(s, "ListPurchaseOrder", svc.ListPurchaseOrder)
+	rpc.Query(s, "GetPurchaseOrder", svc.GetPurchaseOrder)
+	rpc.Command(s, "CreatePurchaseOrder", svc.CreatePurchaseOrder)
+	rpc.Command(s, "SubmitPurchaseOrder", svc.SubmitPurchaseOrder)
+	rpc.Command(s, "ReceivePurchaseOrder", svc.ReceivePurchaseOrder)
+	rpc.Command(s, "CancelPurchaseOrder", svc.CancelPurchaseOrder)
+	return s
+}
+
+type ListSupplierRequest struct {
+	OrganizationID string `json:"organization_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListSupplierResponse struct {
+	TotalData int32            `json:"total_data"`
+	Data      domain.Suppliers `json:"data"`
+}
+
+func (svc *PurchaseService) ListSupplier(ctx context.Context, req *ListSupplierRequest) (*ListSupplierResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListSupplier")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	suppliers, count, err := svc.purchaseService.ListSupplier(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.Supplier{
+		OrganizationID: req.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListSupplierResponse{
+		TotalData: int32(count),
+		Data:      suppliers,
+	}, nil
+}
+
+type GetSupplierRequest struct {
+	SupplierID string `json:"supplier_id"`
+}
+
+func (svc *PurchaseService) GetSupplier(ctx context.Context, req *GetSupplierRequest) (*domain.Supplier, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetSupplier")
+
+	return svc.purchaseService.GetSupplier(ctx, req.SupplierID)
+}
+
+type SupplierRequest struct {
+	SupplierID     string `json:"supplier_id"`
+	OrganizationID string `json:"organization_id"`
+	Name           string `json:"name"`
+	ContactName    string `json:"contact_name"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	Address        string `json:"address"`
+	Note           string `json:"note"`
+}
+
+func (req *SupplierRequest) supplier() domain.Supplier {
+	return domain.Supplier{
+		ID:             req.SupplierID,
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+		ContactName:    req.ContactName,
+		Email:          req.Email,
+		Phone:          req.Phone,
+		Address:        req.Address,
+		Note:           req.Note,
+	}
+}
+
+func (svc *PurchaseService) CreateSupplier(ctx context.Context, req *SupplierRequest) (*domain.Supplier, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateSupplier")
+
+	return svc.purchaseService.CreateSupplier(ctx, req.supplier())
+}
+
+func (svc *PurchaseService) UpdateSupplier(ctx context.Context, req *SupplierRequest) (*domain.Supplier, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("UpdateSupplier")
+
+	return svc.purchaseService.UpdateSupplier(ctx, req.supplier())
+}
+
+func (svc *PurchaseService) DeleteSupplier(ctx context.Context, req *GetSupplierRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("DeleteSupplier")
+
+	if err := svc.purchaseService.DeleteSupplier(ctx, req.SupplierID); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
+
+type ListPurchaseOrderRequest struct {
+	OrganizationID string                     `json:"organization_id"`
+	SupplierID     string                     `json:"supplier_id"`
+	Status         domain.PurchaseOrderStatus `json:"status"`
+	Page           int32                      `json:"page"`
+	Size           int32                      `json:"size"`
+}
+
+type ListPurchaseOrderResponse struct {
+	TotalData int32                 `json:"total_data"`
+	Data      domain.PurchaseOrders `json:"data"`
+}
+
+func (svc *PurchaseService) ListPurchaseOrder(ctx context.Context, req *ListPurchaseOrderRequest) (*ListPurchaseOrderResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListPurchaseOrder")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	orders, count, err := svc.purchaseService.ListPurchaseOrder(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.PurchaseOrder{
+		OrganizationID: req.OrganizationID,
+		SupplierID:     req.SupplierID,
+		Status:         req.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListPurchaseOrderResponse{
+		TotalData: int32(count),
+		Data:      orders,
+	}, nil
+}
+
+type GetPurchaseOrderRequest struct {
+	PurchaseOrderID string `json:"purchase_order_id"`
+}
+
+func (svc *PurchaseService) GetPurchaseOrder(ctx context.Context, req *GetPurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetPurchaseOrder")
+
+	return svc.purchaseService.GetPurchaseOrder(ctx, req.PurchaseOrderID)
+}
+
+type CreatePurchaseOrderRequest struct {
+	OrganizationID string                           `json:"organization_id"`
+	SupplierID     string                           `json:"supplier_id"`
+	LocationID     string                           `json:"location_id"`
+	Reference      string                           `json:"reference"`
+	Note           string                           `json:"note"`
+	ExpectedAt     *time.Time                       `json:"expected_at"`
+	Lines          []CreatePurchaseOrderLineRequest `json:"lines"`
+}
+
+// CreatePurchaseOrderLineRequest orders a variant. A zero UnitCost defaults
+// to the current cost of the variant.
+type CreatePurchaseOrderLineRequest struct {
+	VariantID string  `json:"variant_id"`
+	Quantity  int32   `json:"quantity"`
+	UnitCost  float32 `json:"unit_cost"`
+}
+
+func (svc *PurchaseService) CreatePurchaseOrder(ctx context.Context, req *CreatePurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreatePurchaseOrder")
+
+	order := domain.PurchaseOrder{
+		OrganizationID: req.OrganizationID,
+		SupplierID:     req.SupplierID,
+		LocationID:     req.LocationID,
+		Reference:      req.Reference,
+		Note:           req.Note,
+		ExpectedAt:     req.ExpectedAt,
+	}
+
+	for _, line := range req.Lines {
+		order.Lines = append(order.Lines, domain.PurchaseOrderLine{
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+			UnitCost:  line.UnitCost,
+		})
+	}
+
+	return svc.purchaseService.CreatePurchaseOrder(ctx, order)
+}
+
+// SubmitPurchaseOrder marks a draft purchase order as sent to the supplier.
+func (svc *PurchaseService) SubmitPurchaseOrder(ctx context.Context, req *GetPurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SubmitPurchaseOrder")
+
+	return svc.purchaseService.SubmitPurchaseOrder(ctx, req.PurchaseOrderID)
+}
+
+type ReceivePurchaseOrderRequest struct {
+	PurchaseOrderID string                `json:"purchase_order_id"`
+	Receipts        []GoodsReceiptRequest `json:"receipts"`
+}
+
+// GoodsReceiptRequest receives goods against a purchase order line. A zero
+// UnitCost defaults to the expected cost of the line.
+type GoodsReceiptRequest struct {
+	PurchaseOrderLineID string     `json:"purchase_order_line_id"`
+	Quantity            int32      `json:"quantity"`
+	UnitCost            float32    `json:"unit_cost"`
+	LotNumber           string     `json:"lot_number"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+}
+
+func (svc *PurchaseService) ReceivePurchaseOrder(ctx context.Context, req *ReceivePurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ReceivePurchaseOrder")
+
+	var receipts []domain.GoodsReceipt
+	for _, receipt := range req.Receipts {
+		receipts = append(receipts, domain.GoodsReceipt{
+			PurchaseOrderLineID: receipt.PurchaseOrderLineID,
+			Quantity:            receipt.Quantity,
+			UnitCost:            receipt.UnitCost,
+			LotNumber:           receipt.LotNumber,
+			ExpiresAt:           receipt.ExpiresAt,
+		})
+	}
+
+	return svc.purchaseService.ReceivePurchaseOrder(ctx, req.PurchaseOrderID, receipts)
+}
+
+// CancelPurchaseOrder cancels a purchase order that hasn't received any goods.
+func (svc *PurchaseService) CancelPurchaseOrder(ctx context.Context, req *GetPurchaseOrderRequest) (*domain.PurchaseOrder, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CancelPurchaseOrder")
+
+	return svc.purchaseService.CancelPurchaseOrder(ctx, req.PurchaseOrderID)
+}
@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecipeService serves the recipes of menu variants as an rpc.Service.
+type RecipeService struct {
+	recipeService *service.RecipeService
+}
+
+func NewRecipeService(recipeService *service.RecipeService) *RecipeService {
+	return &RecipeService{
+		recipeService: recipeService,
+	}
+}
+
+// Service returns the recipe methods as smallbiznis.inventory.v1.RecipeService.
+func (svc *RecipeService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.RecipeService")
+	rpc.Query(s, "GetRecipe", svc.GetRecipe)
+	rpc.Command(s, "SetRecipe", svc.SetRecipe)
+	rpc.Query(s, "Portions", svc.Portions)
+	return s
+}
+
+type GetRecipeRequest struct {
+	VariantID string `json:"variant_id"`
+}
+
+type RecipeResponse struct {
+	VariantID string             `json:"variant_id"`
+	Lines     domain.RecipeLines `json:"lines"`
+}
+
+func (svc *RecipeService) GetRecipe(ctx context.Context, req *GetRecipeRequest) (*RecipeResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetRecipe")
+
+	if req.VariantID == "" {
+		return nil, status.Error(codes.InvalidArgument, "variant_id is required")
+	}
+
+	lines, err := svc.recipeService.GetRecipe(ctx, req.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecipeResponse{
+		VariantID: req.VariantID,
+		Lines:     lines,
+	}, nil
+}
+
+// SetRecipeRequest replaces the recipe of a menu variant. Empty Lines
+// remove it.
+type SetRecipeRequest struct {
+	OrganizationID string              `json:"organization_id"`
+	VariantID      string              `json:"variant_id"`
+	Lines          []RecipeLineRequest `json:"lines"`
+}
+
+type RecipeLineRequest struct {
+	IngredientItemID string `json:"ingredient_item_id"`
+	Quantity         int32  `json:"quantity"`
+}
+
+func (svc *RecipeService) SetRecipe(ctx context.Context, req *SetRecipeRequest) (*RecipeResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetRecipe")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	var lines domain.RecipeLines
+	for _, line := range req.Lines {
+		lines = append(lines, domain.RecipeLine{
+			IngredientItemID: line.IngredientItemID,
+			Quantity:         line.Quantity,
+		})
+	}
+
+	result, err := svc.recipeService.SetRecipe(ctx, req.OrganizationID, req.VariantID, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecipeResponse{
+		VariantID: req.VariantID,
+		Lines:     result,
+	}, nil
+}
+
+type PortionsRequest struct {
+	VariantID  string `json:"variant_id"`
+	LocationID string `json:"location_id"`
+}
+
+type PortionsResponse struct {
+	VariantID  string `json:"variant_id"`
+	LocationID string `json:"location_id"`
+	Portions   int32  `json:"portions"`
+}
+
+// Portions returns how many units of a menu variant the ingredients in
+// stock at a location make.
+func (svc *RecipeService) Portions(ctx context.Context, req *PortionsRequest) (*PortionsResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("Portions")
+
+	if req.LocationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "location_id is required")
+	}
+
+	portions, err := svc.recipeService.Portions(ctx, req.VariantID, req.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PortionsResponse{
+		VariantID:  req.VariantID,
+		LocationID: req.LocationID,
+		Portions:   portions,
+	}, nil
+}
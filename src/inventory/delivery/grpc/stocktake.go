@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StocktakeService serves stocktake count sessions as an rpc.Service.
+type StocktakeService struct {
+	stocktakeService *service.StocktakeService
+}
+
+func NewStocktakeService(stocktakeService *service.StocktakeService) *StocktakeService {
+	return &StocktakeService{
+		stocktakeService: stocktakeService,
+	}
+}
+
+// Service returns the stocktake methods as smallbiznis.inventory.v1.StocktakeService.
+func (svc *StocktakeService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.StocktakeService")
+	rpc.Query(s, "ListStocktake", svc.ListStocktake)
+	rpc.Query(s, "GetStocktake", svc.GetStocktake)
+	rpc.Command(s, "OpenStocktake", svc.OpenStocktake)
+	rpc.Command(s, "RecordCount", svc.RecordCount)
+	rpc.Query(s, "ReviewStocktake", svc.ReviewStocktake)
+	rpc.Command(s, "PostStocktake", svc.PostStocktake)
+	rpc.Command(s, "CancelStocktake", svc.CancelStocktake)
+	return s
+}
+
+type ListStocktakeRequest struct {
+	OrganizationID string                 `json:"organization_id"`
+	LocationID     string                 `json:"location_id"`
+	Status         domain.StocktakeStatus `json:"status"`
+	Page           int32                  `json:"page"`
+	Size           int32                  `json:"size"`
+}
+
+type ListStocktakeResponse struct {
+	TotalData int32             `json:"total_data"`
+	Data      domain.Stocktakes `json:"data"`
+}
+
+func (svc *StocktakeService) ListStocktake(ctx context.Context, req *ListStocktakeRequest) (*ListStocktakeResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListStocktake")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	stocktakes, count, err := svc.stocktakeService.ListStocktake(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.Stocktake{
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+		Status:         req.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListStocktakeResponse{
+		TotalData: int32(count),
+		Data:      stocktakes,
+	}, nil
+}
+
+type GetStocktakeRequest struct {
+	StocktakeID string `json:"stocktake_id"`
+}
+
+func (svc *StocktakeService) GetStocktake(ctx context.Context, req *GetStocktakeRequest) (*domain.Stocktake, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetStocktake")
+
+	return svc.stocktakeService.GetStocktake(ctx, req.StocktakeID)
+}
+
+type OpenStocktakeRequest struct {
+	OrganizationID string `json:"organization_id"`
+	LocationID     string `json:"location_id"`
+	Note           string `json:"note"`
+}
+
+func (svc *StocktakeService) OpenStocktake(ctx context.Context, req *OpenStocktakeRequest) (*domain.Stocktake, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("OpenStocktake")
+
+	return svc.stocktakeService.OpenStocktake(ctx, domain.Stocktake{
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+		Note:           req.Note,
+	})
+}
+
+// RecordCountRequest carries the counts of one device. Counting an item
+// again from the same device replaces the previous count.
+type RecordCountRequest struct {
+	StocktakeID string             `json:"stocktake_id"`
+	DeviceID    string             `json:"device_id"`
+	CountedBy   string             `json:"counted_by"`
+	Counts      []CountLineRequest `json:"counts"`
+}
+
+type CountLineRequest struct {
+	InventoryItemID string `json:"inventory_item_id"`
+	Quantity        int32  `json:"quantity"`
+}
+
+func (svc *StocktakeService) RecordCount(ctx context.Context, req *RecordCountRequest) (*domain.Stocktake, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RecordCount")
+
+	var counts domain.StocktakeCounts
+	for _, line := range req.Counts {
+		counts = append(counts, domain.StocktakeCount{
+			InventoryItemID: line.InventoryItemID,
+			Quantity:        line.Quantity,
+		})
+	}
+
+	return svc.stocktakeService.RecordCount(ctx, req.StocktakeID, req.DeviceID, req.CountedBy, counts)
+}
+
+type ReviewStocktakeResponse struct {
+	StocktakeID string                    `json:"stocktake_id"`
+	Data        domain.StocktakeVariances `json:"data"`
+}
+
+// ReviewStocktake lists the counted items whose counted quantity differs
+// from their current quantity.
+func (svc *StocktakeService) ReviewStocktake(ctx context.Context, req *GetStocktakeRequest) (*ReviewStocktakeResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ReviewStocktake")
+
+	variances, err := svc.stocktakeService.ReviewStocktake(ctx, req.StocktakeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReviewStocktakeResponse{
+		StocktakeID: req.StocktakeID,
+		Data:        variances,
+	}, nil
+}
+
+// PostStocktake books the variances as count corrections and closes the
+// stocktake.
+func (svc *StocktakeService) PostStocktake(ctx context.Context, req *GetStocktakeRequest) (*domain.Stocktake, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("PostStocktake")
+
+	return svc.stocktakeService.PostStocktake(ctx, req.StocktakeID)
+}
+
+func (svc *StocktakeService) CancelStocktake(ctx context.Context, req *GetStocktakeRequest) (*domain.Stocktake, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CancelStocktake")
+
+	return svc.stocktakeService.CancelStocktake(ctx, req.StocktakeID)
+}
@@ -0,0 +1,158 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"github.com/smallbiznis/inventory/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TransferService serves stock transfers between locations as an
+// rpc.Service.
+type TransferService struct {
+	transferService *service.TransferService
+}
+
+func NewTransferService(transferService *service.TransferService) *TransferService {
+	return &TransferService{
+		transferService: transferService,
+	}
+}
+
+// Service returns the transfer methods as smallbiznis.inventory.v1.TransferService.
+func (svc *TransferService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.inventory.v1.TransferService")
+	rpc.Query(s, "ListTransfer", svc.ListTransfer)
+	rpc.Query(s, "GetTransfer", svc.GetTransfer)
+	rpc.Command(s, "CreateTransfer", svc.CreateTransfer)
+	rpc.Command(s, "ShipTransfer", svc.ShipTransfer)
+	rpc.Command(s, "ReceiveTransfer", svc.ReceiveTransfer)
+	rpc.Command(s, "CancelTransfer", svc.CancelTransfer)
+	return s
+}
+
+type ListTransferRequest struct {
+	OrganizationID string                `json:"organization_id"`
+	Status         domain.TransferStatus `json:"status"`
+	Page           int32                 `json:"page"`
+	Size           int32                 `json:"size"`
+}
+
+type ListTransferResponse struct {
+	TotalData int32                 `json:"total_data"`
+	Data      domain.StockTransfers `json:"data"`
+}
+
+func (svc *TransferService) ListTransfer(ctx context.Context, req *ListTransferRequest) (*ListTransferResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListTransfer")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	transfers, count, err := svc.transferService.ListTransfer(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.StockTransfer{
+		OrganizationID: req.OrganizationID,
+		Status:         req.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListTransferResponse{
+		TotalData: int32(count),
+		Data:      transfers,
+	}, nil
+}
+
+type GetTransferRequest struct {
+	TransferID string `json:"transfer_id"`
+}
+
+func (svc *TransferService) GetTransfer(ctx context.Context, req *GetTransferRequest) (*domain.StockTransfer, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetTransfer")
+
+	return svc.transferService.GetTransfer(ctx, req.TransferID)
+}
+
+type CreateTransferRequest struct {
+	OrganizationID        string                      `json:"organization_id"`
+	SourceLocationID      string                      `json:"source_location_id"`
+	DestinationLocationID string                      `json:"destination_location_id"`
+	Note                  string                      `json:"note"`
+	Lines                 []CreateTransferLineRequest `json:"lines"`
+}
+
+type CreateTransferLineRequest struct {
+	ItemID   string `json:"item_id"`
+	Quantity int32  `json:"quantity"`
+}
+
+func (svc *TransferService) CreateTransfer(ctx context.Context, req *CreateTransferRequest) (*domain.StockTransfer, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateTransfer")
+
+	transfer := domain.StockTransfer{
+		OrganizationID:        req.OrganizationID,
+		SourceLocationID:      req.SourceLocationID,
+		DestinationLocationID: req.DestinationLocationID,
+		Note:                  req.Note,
+	}
+
+	for _, line := range req.Lines {
+		transfer.Lines = append(transfer.Lines, domain.TransferLine{
+			ItemID:   line.ItemID,
+			Quantity: line.Quantity,
+		})
+	}
+
+	return svc.transferService.CreateTransfer(ctx, transfer)
+}
+
+// ShipTransfer dispatches a draft transfer from its source location.
+func (svc *TransferService) ShipTransfer(ctx context.Context, req *GetTransferRequest) (*domain.StockTransfer, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ShipTransfer")
+
+	return svc.transferService.ShipTransfer(ctx, req.TransferID)
+}
+
+type ReceiveTransferRequest struct {
+	TransferID string                   `json:"transfer_id"`
+	Receipts   []domain.TransferReceipt `json:"receipts"`
+}
+
+func (svc *TransferService) ReceiveTransfer(ctx context.Context, req *ReceiveTransferRequest) (*domain.StockTransfer, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ReceiveTransfer")
+
+	return svc.transferService.ReceiveTransfer(ctx, req.TransferID, req.Receipts)
+}
+
+func (svc *TransferService) CancelTransfer(ctx context.Context, req *GetTransferRequest) (*domain.StockTransfer, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CancelTransfer")
+
+	return svc.transferService.CancelTransfer(ctx, req.TransferID)
+}
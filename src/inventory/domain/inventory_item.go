@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
@@ -10,16 +11,25 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidRelease    = errors.New("release exceeds reserved quantity")
+)
+
 type InventoryItem struct {
-	ID               string    `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" uri:"id" json:"id"`
-	OrganizationID   string    `gorm:"column:organization_id;type:uuid;" json:"organization_id"`
-	LocationID       string    `gorm:"column:location_id;type:uuid;" json:"location_id"`
-	ItemID           string    `gorm:"column:item_id;type:uuid" json:"item_id"`
-	Quantity         int32     `gorm:"column:quantity" json:"quantity"`
-	ReservedQuantity int32     `gorm:"column:reserved_quantity" json:"reserved_quantity"`
-	ReorderLevel     int32     `gorm:"column:reorder_level" json:"reorder_level"`
-	CreatedAt        time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`
+	ID               string `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" uri:"id" json:"id"`
+	OrganizationID   string `gorm:"column:organization_id;type:uuid;" json:"organization_id"`
+	LocationID       string `gorm:"column:location_id;type:uuid;" json:"location_id"`
+	ItemID           string `gorm:"column:item_id;type:uuid" json:"item_id"`
+	Quantity         int32  `gorm:"column:quantity" json:"quantity"`
+	ReservedQuantity int32  `gorm:"column:reserved_quantity" json:"reserved_quantity"`
+	ReorderLevel     int32  `gorm:"column:reorder_level" json:"reorder_level"`
+	TrackLots        bool   `gorm:"column:track_lots" json:"track_lots"`
+	// Unlinked marks an item the ledger created for a variant at a new
+	// location that isn't in the variant's InventoryItemIds yet.
+	Unlinked  bool      `gorm:"column:unlinked;index" json:"-"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
 
 func (m *InventoryItem) BeforeCreate(tx *gorm.DB) (err error) {
@@ -35,6 +45,17 @@ func (m *InventoryItem) BeforeUpdate(tx *gorm.DB) (err error) {
 	return
 }
 
+// Available is the stock that isn't reserved yet.
+func (m *InventoryItem) Available() int32 {
+	return m.Quantity - m.ReservedQuantity
+}
+
+// IsLowStock reports whether available stock is below the reorder level.
+// A zero reorder level disables low-stock detection.
+func (m *InventoryItem) IsLowStock() bool {
+	return m.ReorderLevel > 0 && m.Available() < m.ReorderLevel
+}
+
 func (m *InventoryItem) ToProto() *inventory.Inventory {
 	return &inventory.Inventory{
 		InventoryItemId:  m.ID,
@@ -43,6 +64,7 @@ func (m *InventoryItem) ToProto() *inventory.Inventory {
 		ItemId:           m.ItemID,
 		Quantity:         m.Quantity,
 		ReservedQuantity: m.ReservedQuantity,
+		ReorderLevel:     m.ReorderLevel,
 		CreatedAt:        timestamppb.New(m.CreatedAt),
 		UpdatedAt:        timestamppb.New(m.UpdatedAt),
 	}
@@ -63,4 +85,14 @@ type IInventoryItemRepository interface {
 	Save(context.Context, InventoryItem) (*InventoryItem, error)
 	Update(context.Context, InventoryItem) (*InventoryItem, error)
 	Delete(context.Context, InventoryItem) error
+	FindLowStock(context.Context, pagination.Pagination, InventoryItem) (InventoryItems, int64, error)
+	// FindLowStockEvents lists up to limit low-stock events, oldest first,
+	// starting after the CreatedAt and ID of after, or at its CreatedAt when
+	// it has no ID.
+	FindLowStockEvents(ctx context.Context, after LowStockEvent, limit int) (LowStockEvents, error)
+	// FindUnlinked lists up to limit items not linked to their variant yet.
+	FindUnlinked(ctx context.Context, limit int) (InventoryItems, error)
+	MarkLinked(ctx context.Context, ids ...string) error
+	Reserve(ctx context.Context, id string, quantity int32) error
+	Release(ctx context.Context, id string, quantity int32) error
 }
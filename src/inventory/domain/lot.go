@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLotsNotTracked = errors.New("inventory item doesn't track lots")
+	ErrUnknownLot     = errors.New("lot not found for inventory item")
+)
+
+// InventoryLot is the sub-balance of a lot-tracked inventory item received
+// in one batch. The lots of an item always sum to its Quantity and
+// ReservedQuantity. Stock booked without a lot goes to the item's lot with
+// an empty LotNumber, which has no expiry.
+type InventoryLot struct {
+	ID               string     `gorm:"column:lot_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"lot_id"`
+	OrganizationID   string     `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	LocationID       string     `gorm:"column:location_id;type:uuid" json:"location_id"`
+	InventoryItemID  string     `gorm:"column:inventory_item_id;type:uuid;uniqueIndex:idx_inventory_lot_number" json:"inventory_item_id"`
+	LotNumber        string     `gorm:"column:lot_number;uniqueIndex:idx_inventory_lot_number" json:"lot_number"`
+	ExpiresAt        *time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+	Quantity         int32      `gorm:"column:quantity" json:"quantity"`
+	ReservedQuantity int32      `gorm:"column:reserved_quantity" json:"reserved_quantity"`
+	CreatedAt        time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *InventoryLot) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *InventoryLot) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// Available is the lot stock that isn't reserved yet.
+func (m *InventoryLot) Available() int32 {
+	return m.Quantity - m.ReservedQuantity
+}
+
+// IsExpired reports whether the lot is past its expiry date at t.
+func (m *InventoryLot) IsExpired(t time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(t)
+}
+
+type InventoryLots []InventoryLot
+
+type ILotRepository interface {
+	Find(context.Context, pagination.Pagination, InventoryLot) (InventoryLots, int64, error)
+	// FindExpiring lists lots with stock left that expire before the given
+	// time, soonest first. Already expired lots are included.
+	FindExpiring(ctx context.Context, p pagination.Pagination, f InventoryLot, before time.Time) (InventoryLots, int64, error)
+	// Track turns on lot tracking for an inventory item. Its current stock
+	// becomes the item's lot without lot number.
+	Track(ctx context.Context, inventoryItemID string) (*InventoryItem, error)
+}
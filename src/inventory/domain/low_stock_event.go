@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LowStockChannel is the Postgres NOTIFY channel low-stock events are
+// published on. The payload is the JSON encoded LowStockEvent.
+const LowStockChannel = "inventory_low_stock"
+
+// LowStockEvent is emitted when the available quantity of an inventory item
+// drops below its reorder level. Rows are kept, and subscribers that were
+// offline catch up on them through AlertService.ListLowStockEvent.
+type LowStockEvent struct {
+	ID              string    `gorm:"column:low_stock_event_id;type:uuid;default:uuid_generate_v4();primaryKey;index:idx_low_stock_event_created_at,priority:2" json:"low_stock_event_id"`
+	OrganizationID  string    `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	LocationID      string    `gorm:"column:location_id;type:uuid" json:"location_id"`
+	InventoryItemID string    `gorm:"column:inventory_item_id;type:uuid" json:"inventory_item_id"`
+	ItemID          string    `gorm:"column:item_id;type:uuid" json:"item_id"`
+	Available       int32     `gorm:"column:available" json:"available"`
+	ReorderLevel    int32     `gorm:"column:reorder_level" json:"reorder_level"`
+	CreatedAt       time.Time `gorm:"column:created_at;index:idx_low_stock_event_created_at,priority:1" json:"created_at"`
+}
+
+func (m *LowStockEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type LowStockEvents []LowStockEvent
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// OrderFulfilledChannel is the outbox channel the transaction service
+// publishes fulfilled orders on.
+const OrderFulfilledChannel = "transaction_order_fulfilled"
+
+// OrderFulfilledEvent is the payload of an order fulfilled notification
+// published by the transaction service.
+type OrderFulfilledEvent struct {
+	OrderID        string                   `json:"order_id"`
+	OrganizationID string                   `json:"organization_id"`
+	LocationID     string                   `json:"location_id"`
+	Items          []OrderFulfilledLineItem `json:"items"`
+}
+
+// OrderFulfilledLineItem is a sold order line. ReservedQuantity units of it
+// were reserved on InventoryItemID when the order was created and are sold
+// out of that reservation.
+type OrderFulfilledLineItem struct {
+	VariantID        string `json:"variant_id"`
+	Quantity         int32  `json:"quantity"`
+	InventoryItemID  string `json:"inventory_item_id,omitempty"`
+	ReservedQuantity int32  `json:"reserved_quantity,omitempty"`
+}
+
+// OrderReturnedChannel is the outbox channel the transaction service
+// publishes returned items to restock on.
+const OrderReturnedChannel = "transaction_order_returned"
+
+// OrderReturnedEvent is the payload of an order returned notification: the
+// items of a refund to put back into stock at LocationID.
+type OrderReturnedEvent struct {
+	OrderRefundID  string                   `json:"order_refund_id"`
+	OrderID        string                   `json:"order_id"`
+	OrganizationID string                   `json:"organization_id"`
+	LocationID     string                   `json:"location_id"`
+	Items          []OrderFulfilledLineItem `json:"items"`
+}
+
+// OutboxServiceName is the transaction service serving the events it
+// published that weren't acknowledged yet.
+const OutboxServiceName = "smallbiznis.transaction.v1.OutboxService"
+
+// OutboxEvent is an event published by the transaction service. Its
+// notification carries only its ID; Payload is the JSON encoded event.
+type OutboxEvent struct {
+	ID        string    `json:"event_id"`
+	Channel   string    `json:"channel"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PendingEventRequest struct {
+	Channel string `json:"channel"`
+	Size    int32  `json:"size"`
+}
+
+type PendingEventPage struct {
+	Data []OutboxEvent `json:"data"`
+}
+
+type AckEventRequest struct {
+	EventID string `json:"event_id"`
+}
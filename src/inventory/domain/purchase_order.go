@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPurchaseOrderStatus = errors.New("purchase order status doesn't allow this operation")
+	ErrUnknownPurchaseOrderLine   = errors.New("purchase order line not found")
+)
+
+type PurchaseOrderStatus string
+
+var (
+	PurchaseOrderDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderOrdered           PurchaseOrderStatus = "ordered"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+	PurchaseOrderCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+func (m PurchaseOrderStatus) String() string {
+	if m == PurchaseOrderDraft ||
+		m == PurchaseOrderOrdered ||
+		m == PurchaseOrderPartiallyReceived ||
+		m == PurchaseOrderReceived ||
+		m == PurchaseOrderCancelled {
+		return string(m)
+	}
+	return ""
+}
+
+// PurchaseOrder orders item variants from a supplier, to be received at one
+// location of the organization.
+type PurchaseOrder struct {
+	ID             string              `gorm:"column:purchase_order_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"purchase_order_id"`
+	OrganizationID string              `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	SupplierID     string              `gorm:"column:supplier_id;type:uuid;index" json:"supplier_id"`
+	LocationID     string              `gorm:"column:location_id;type:uuid" json:"location_id"`
+	Status         PurchaseOrderStatus `gorm:"column:status" json:"status"`
+	Reference      string              `gorm:"column:reference" json:"reference"`
+	Note           string              `gorm:"column:note" json:"note"`
+	TotalCost      float32             `gorm:"column:total_cost" json:"total_cost"`
+	Lines          PurchaseOrderLines  `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+	ExpectedAt     *time.Time          `gorm:"column:expected_at" json:"expected_at"`
+	OrderedAt      *time.Time          `gorm:"column:ordered_at" json:"ordered_at"`
+	ReceivedAt     *time.Time          `gorm:"column:received_at" json:"received_at"`
+	CreatedAt      time.Time           `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time           `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt      `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *PurchaseOrder) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *PurchaseOrder) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type PurchaseOrders []PurchaseOrder
+
+// PurchaseOrderLine is one variant on a purchase order. UnitCost is the cost
+// expected when ordering; the cost actually paid is kept on each receipt.
+type PurchaseOrderLine struct {
+	ID               string    `gorm:"column:purchase_order_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"purchase_order_line_id"`
+	PurchaseOrderID  string    `gorm:"column:purchase_order_id;type:uuid" json:"purchase_order_id"`
+	VariantID        string    `gorm:"column:variant_id;type:uuid" json:"variant_id"`
+	InventoryItemID  *string   `gorm:"column:inventory_item_id;type:uuid;default:NULL" json:"inventory_item_id"`
+	Quantity         int32     `gorm:"column:quantity" json:"quantity"`
+	ReceivedQuantity int32     `gorm:"column:received_quantity" json:"received_quantity"`
+	UnitCost         float32   `gorm:"column:unit_cost" json:"unit_cost"`
+	CreatedAt        time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *PurchaseOrderLine) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *PurchaseOrderLine) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type PurchaseOrderLines []PurchaseOrderLine
+
+// GoodsReceipt is a quantity of a purchase order line received at the
+// purchase order location, at the cost actually paid. OnHandBefore is the
+// organization wide quantity of the variant before the receipt, used to
+// average the variant cost. Lot-tracked items are received into the lot
+// LotNumber, created with ExpiresAt when new.
+type GoodsReceipt struct {
+	ID                  string     `gorm:"column:goods_receipt_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"goods_receipt_id"`
+	PurchaseOrderID     string     `gorm:"column:purchase_order_id;type:uuid;index" json:"purchase_order_id"`
+	PurchaseOrderLineID string     `gorm:"column:purchase_order_line_id;type:uuid" json:"purchase_order_line_id"`
+	VariantID           string     `gorm:"column:variant_id;type:uuid" json:"variant_id"`
+	Quantity            int32      `gorm:"column:quantity" json:"quantity"`
+	UnitCost            float32    `gorm:"column:unit_cost" json:"unit_cost"`
+	LotNumber           string     `gorm:"column:lot_number" json:"lot_number"`
+	ExpiresAt           *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	OnHandBefore        int32      `gorm:"column:on_hand_before" json:"on_hand_before"`
+	CreatedAt           time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *GoodsReceipt) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type GoodsReceipts []GoodsReceipt
+
+// WeightedAverageCost averages the current cost of onHand units with the
+// cost of the received units. When nothing is on hand the received cost
+// replaces the current one.
+func WeightedAverageCost(currentCost float32, onHand int32, receivedCost float32, received int32) float32 {
+	if onHand <= 0 {
+		return receivedCost
+	}
+
+	total := float64(onHand) + float64(received)
+	return float32((float64(currentCost)*float64(onHand) + float64(receivedCost)*float64(received)) / total)
+}
+
+type IPurchaseOrderRepository interface {
+	Find(context.Context, pagination.Pagination, PurchaseOrder) (PurchaseOrders, int64, error)
+	FindOne(context.Context, PurchaseOrder) (*PurchaseOrder, error)
+	Save(context.Context, PurchaseOrder) (*PurchaseOrder, error)
+	// Submit marks a draft purchase order as ordered from the supplier.
+	Submit(ctx context.Context, id string) (*PurchaseOrder, error)
+	// Receive books the receipts as stock at the purchase order location and
+	// returns the saved receipts. A receipt without unit cost is booked at the
+	// line cost.
+	Receive(ctx context.Context, id string, receipts []GoodsReceipt) (*PurchaseOrder, GoodsReceipts, error)
+	// Cancel cancels a purchase order that hasn't received anything yet.
+	Cancel(ctx context.Context, id string) (*PurchaseOrder, error)
+}
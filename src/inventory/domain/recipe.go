@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrIngredientNotStocked = errors.New("recipe ingredient isn't stocked at location")
+
+// RecipeLine is one ingredient of the recipe of a menu variant: Quantity
+// units of the ingredient variant IngredientItemID are consumed per unit of
+// VariantID sold. Ingredients are matched with inventory items through
+// InventoryItem.ItemID at the location the order is fulfilled from.
+type RecipeLine struct {
+	ID               string    `gorm:"column:recipe_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"recipe_line_id"`
+	OrganizationID   string    `gorm:"column:organization_id;type:uuid" json:"organization_id"`
+	VariantID        string    `gorm:"column:variant_id;type:uuid;uniqueIndex:idx_recipe_line_ingredient" json:"variant_id"`
+	IngredientItemID string    `gorm:"column:ingredient_item_id;type:uuid;uniqueIndex:idx_recipe_line_ingredient" json:"ingredient_item_id"`
+	Quantity         int32     `gorm:"column:quantity" json:"quantity"`
+	CreatedAt        time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *RecipeLine) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *RecipeLine) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type RecipeLines []RecipeLine
+
+// Usage sums the quantity of every ingredient needed to make quantities,
+// keyed by variant.
+func (m RecipeLines) Usage(quantities map[string]int32) map[string]int32 {
+	usage := make(map[string]int32)
+	for _, line := range m {
+		if qty := quantities[line.VariantID]; qty > 0 {
+			usage[line.IngredientItemID] += line.Quantity * qty
+		}
+	}
+	return usage
+}
+
+type IRecipeRepository interface {
+	// Find returns the recipe lines of the given variants.
+	Find(ctx context.Context, variantIDs ...string) (RecipeLines, error)
+	// Replace replaces the whole recipe of a variant.
+	Replace(ctx context.Context, variantID string, lines RecipeLines) (RecipeLines, error)
+	// Consume books the sale of an order at a location: sold quantities,
+	// keyed by inventory item, are taken out of their reservation, and the
+	// ingredient usage, keyed by ingredient variant, out of the free stock.
+	// An order is consumed at most once.
+	Consume(ctx context.Context, orderID, organizationID, locationID string, sold, usage map[string]int32) error
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+var ErrNegativeStock = errors.New("movement would make stock negative")
+
+type MovementReason string
+
+var (
+	Receive         MovementReason = "receive"
+	Sale            MovementReason = "sale"
+	Return          MovementReason = "return"
+	Damage          MovementReason = "damage"
+	CountCorrection MovementReason = "count_correction"
+	Transfer        MovementReason = "transfer"
+)
+
+func (m MovementReason) String() string {
+	if m == Receive ||
+		m == Sale ||
+		m == Return ||
+		m == Damage ||
+		m == CountCorrection ||
+		m == Transfer {
+		return string(m)
+	}
+	return ""
+}
+
+// StockMovement is an append-only ledger entry for a change of
+// InventoryItem.Quantity. Quantity is the signed delta and QuantityAfter the
+// on-hand quantity once the movement was applied. For lot-tracked items
+// LotID is the lot the movement applied to; a movement without lot is split
+// over the lots first-expiry-first-out.
+type StockMovement struct {
+	ID              string         `gorm:"column:stock_movement_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"stock_movement_id"`
+	OrganizationID  string         `gorm:"column:organization_id;type:uuid" json:"organization_id"`
+	LocationID      string         `gorm:"column:location_id;type:uuid" json:"location_id"`
+	InventoryItemID string         `gorm:"column:inventory_item_id;type:uuid;index:idx_stock_movement_item_created" json:"inventory_item_id"`
+	LotID           *string        `gorm:"column:lot_id;type:uuid;default:NULL" json:"lot_id"`
+	Reason          MovementReason `gorm:"column:reason" json:"reason"`
+	Quantity        int32          `gorm:"column:quantity" json:"quantity"`
+	QuantityAfter   int32          `gorm:"column:quantity_after" json:"quantity_after"`
+	ReferenceType   string         `gorm:"column:reference_type" json:"reference_type"`
+	ReferenceID     string         `gorm:"column:reference_id" json:"reference_id"`
+	Note            string         `gorm:"column:note" json:"note"`
+	CreatedAt       time.Time      `gorm:"column:created_at;index:idx_stock_movement_item_created" json:"created_at"`
+}
+
+func (m *StockMovement) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type StockMovements []StockMovement
+
+type IStockMovementRepository interface {
+	Find(context.Context, pagination.Pagination, StockMovement) (StockMovements, int64, error)
+	// Open creates an inventory item and records its initial quantity as a
+	// receive movement in one database transaction.
+	Open(context.Context, InventoryItem) (*InventoryItem, error)
+	// Record applies every movement to its inventory item and appends it to
+	// the ledger, all in one database transaction.
+	Record(context.Context, ...StockMovement) (StockMovements, error)
+	// Restock books returned quantities, keyed by item, as return movements
+	// at a location, creating the inventory items the location doesn't stock
+	// yet. A reference is restocked at most once.
+	Restock(ctx context.Context, referenceType, referenceID, organizationID, locationID string, quantities map[string]int32) (StockMovements, error)
+	// OnHandAt returns the on-hand quantity of an inventory item at a point in time.
+	OnHandAt(ctx context.Context, inventoryItemID string, at time.Time) (int32, error)
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidStocktakeStatus = errors.New("stocktake status doesn't allow this operation")
+	ErrStocktakeInProgress    = errors.New("location already has an open stocktake")
+	ErrItemNotAtLocation      = errors.New("inventory item isn't stocked at stocktake location")
+)
+
+type StocktakeStatus string
+
+var (
+	StocktakeOpen      StocktakeStatus = "open"
+	StocktakePosted    StocktakeStatus = "posted"
+	StocktakeCancelled StocktakeStatus = "cancelled"
+)
+
+func (m StocktakeStatus) String() string {
+	if m == StocktakeOpen ||
+		m == StocktakePosted ||
+		m == StocktakeCancelled {
+		return string(m)
+	}
+	return ""
+}
+
+// Stocktake is a count session for one location. Staff record counted
+// quantities while it is open; posting it books the variances as count
+// correction movements.
+type Stocktake struct {
+	ID             string          `gorm:"column:stocktake_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"stocktake_id"`
+	OrganizationID string          `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	LocationID     string          `gorm:"column:location_id;type:uuid" json:"location_id"`
+	Status         StocktakeStatus `gorm:"column:status" json:"status"`
+	Note           string          `gorm:"column:note" json:"note"`
+	Counts         StocktakeCounts `gorm:"foreignKey:StocktakeID" json:"counts"`
+	PostedAt       *time.Time      `gorm:"column:posted_at" json:"posted_at"`
+	CreatedAt      time.Time       `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt  `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Stocktake) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Stocktake) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type Stocktakes []Stocktake
+
+// StocktakeCount is the quantity of an inventory item counted from one
+// device. Several devices can count the same item, e.g. on different
+// shelves; the counted quantity of the item is the sum over devices, and a
+// recount from a device replaces its previous count.
+type StocktakeCount struct {
+	ID              string    `gorm:"column:stocktake_count_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"stocktake_count_id"`
+	StocktakeID     string    `gorm:"column:stocktake_id;type:uuid;uniqueIndex:idx_stocktake_count_device" json:"stocktake_id"`
+	InventoryItemID string    `gorm:"column:inventory_item_id;type:uuid;uniqueIndex:idx_stocktake_count_device" json:"inventory_item_id"`
+	DeviceID        string    `gorm:"column:device_id;uniqueIndex:idx_stocktake_count_device" json:"device_id"`
+	CountedBy       string    `gorm:"column:counted_by" json:"counted_by"`
+	Quantity        int32     `gorm:"column:quantity" json:"quantity"`
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *StocktakeCount) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+type StocktakeCounts []StocktakeCount
+
+// StocktakeVariance compares the counted quantity of an inventory item with
+// its quantity on record.
+type StocktakeVariance struct {
+	InventoryItemID string `json:"inventory_item_id"`
+	ItemID          string `json:"item_id"`
+	Expected        int32  `json:"expected"`
+	Counted         int32  `json:"counted"`
+	Variance        int32  `json:"variance"`
+}
+
+type StocktakeVariances []StocktakeVariance
+
+type IStocktakeRepository interface {
+	Find(context.Context, pagination.Pagination, Stocktake) (Stocktakes, int64, error)
+	FindOne(context.Context, Stocktake) (*Stocktake, error)
+	// Open saves a new open stocktake, unless its location already has one.
+	Open(context.Context, Stocktake) (*Stocktake, error)
+	// Count saves the counts of an open stocktake, replacing earlier counts of
+	// the same item from the same device.
+	Count(ctx context.Context, id string, counts StocktakeCounts) error
+	// Variances lists counted items whose counted quantity differs from their
+	// quantity on record.
+	Variances(ctx context.Context, id string) (StocktakeVariances, error)
+	// Post books the variances as count correction movements and closes the stocktake.
+	Post(ctx context.Context, id string) (*Stocktake, error)
+	Cancel(ctx context.Context, id string) (*Stocktake, error)
+}
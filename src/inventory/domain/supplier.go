@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// Supplier is a wholesaler an organization purchases stock from.
+type Supplier struct {
+	ID             string         `gorm:"column:supplier_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"supplier_id"`
+	OrganizationID string         `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	Name           string         `gorm:"column:name" json:"name"`
+	ContactName    string         `gorm:"column:contact_name" json:"contact_name"`
+	Email          string         `gorm:"column:email" json:"email"`
+	Phone          string         `gorm:"column:phone" json:"phone"`
+	Address        string         `gorm:"column:address" json:"address"`
+	Note           string         `gorm:"column:note" json:"note"`
+	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Supplier) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Supplier) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type Suppliers []Supplier
+
+type ISupplierRepository interface {
+	Find(context.Context, pagination.Pagination, Supplier) (Suppliers, int64, error)
+	FindOne(context.Context, Supplier) (*Supplier, error)
+	Save(context.Context, Supplier) (*Supplier, error)
+	Update(context.Context, Supplier) (*Supplier, error)
+	Delete(context.Context, Supplier) error
+}
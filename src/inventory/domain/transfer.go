@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTransferStatus = errors.New("transfer status doesn't allow this operation")
+	ErrOverReceipt           = errors.New("received quantity exceeds expected quantity")
+	ErrUnknownTransferLine   = errors.New("transfer line not found")
+)
+
+type TransferStatus string
+
+var (
+	TransferDraft     TransferStatus = "draft"
+	TransferInTransit TransferStatus = "in_transit"
+	TransferReceived  TransferStatus = "received"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+func (m TransferStatus) String() string {
+	if m == TransferDraft ||
+		m == TransferInTransit ||
+		m == TransferReceived ||
+		m == TransferCancelled {
+		return string(m)
+	}
+	return ""
+}
+
+// StockTransfer moves stock between two locations of the same organization.
+// Shipping debits the source location, receiving (possibly in several
+// partial receipts) credits the destination.
+type StockTransfer struct {
+	ID                    string         `gorm:"column:transfer_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"transfer_id"`
+	OrganizationID        string         `gorm:"column:organization_id;type:uuid" json:"organization_id"`
+	SourceLocationID      string         `gorm:"column:source_location_id;type:uuid" json:"source_location_id"`
+	DestinationLocationID string         `gorm:"column:destination_location_id;type:uuid" json:"destination_location_id"`
+	Status                TransferStatus `gorm:"column:status" json:"status"`
+	Note                  string         `gorm:"column:note" json:"note"`
+	Lines                 TransferLines  `gorm:"foreignKey:TransferID" json:"lines"`
+	ShippedAt             *time.Time     `gorm:"column:shipped_at" json:"shipped_at"`
+	ReceivedAt            *time.Time     `gorm:"column:received_at" json:"received_at"`
+	CreatedAt             time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt             time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *StockTransfer) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *StockTransfer) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type StockTransfers []StockTransfer
+
+type TransferLine struct {
+	ID                         string    `gorm:"column:transfer_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"transfer_line_id"`
+	TransferID                 string    `gorm:"column:transfer_id;type:uuid" json:"transfer_id"`
+	ItemID                     string    `gorm:"column:item_id;type:uuid" json:"item_id"`
+	SourceInventoryItemID      string    `gorm:"column:source_inventory_item_id;type:uuid" json:"source_inventory_item_id"`
+	DestinationInventoryItemID *string   `gorm:"column:destination_inventory_item_id;type:uuid;default:NULL" json:"destination_inventory_item_id"`
+	Quantity                   int32     `gorm:"column:quantity" json:"quantity"`
+	ReceivedQuantity           int32     `gorm:"column:received_quantity" json:"received_quantity"`
+	CreatedAt                  time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt                  time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *TransferLine) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *TransferLine) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type TransferLines []TransferLine
+
+// TransferReceipt is the quantity received for one transfer line.
+type TransferReceipt struct {
+	TransferLineID string `json:"transfer_line_id"`
+	Quantity       int32  `json:"quantity"`
+}
+
+type ITransferRepository interface {
+	Find(context.Context, pagination.Pagination, StockTransfer) (StockTransfers, int64, error)
+	FindOne(context.Context, StockTransfer) (*StockTransfer, error)
+	Save(context.Context, StockTransfer) (*StockTransfer, error)
+	// Ship debits the source location for every line and marks the transfer in transit.
+	Ship(ctx context.Context, id string) (*StockTransfer, error)
+	// Receive credits the destination location with the received quantities and
+	// marks the transfer received once every line is fully received.
+	Receive(ctx context.Context, id string, receipts []TransferReceipt) (*StockTransfer, error)
+	// Cancel cancels a draft transfer.
+	Cancel(ctx context.Context, id string) (*StockTransfer, error)
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/pglisten"
+	"github.com/smallbiznis/common/rpc"
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
 	"github.com/smallbiznis/go-lib/pkg/env"
 	"github.com/smallbiznis/go-lib/pkg/logger"
@@ -15,10 +19,13 @@ import (
 	grpchandler "github.com/smallbiznis/inventory/delivery/grpc"
 	"github.com/smallbiznis/inventory/infrastructure"
 	"github.com/smallbiznis/inventory/repository"
+	"github.com/smallbiznis/inventory/service"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
 )
 
 func NewZapLogger() fxevent.Logger {
@@ -36,8 +43,11 @@ func NewHttpServer(mux *runtime.ServeMux) *http.Server {
 	}
 }
 
-func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.InventoryService) {
-	inventory.RegisterServiceServer(srv, svc)
+// RegisterServiceServer registers the service with calls to the methods
+// changing state carrying an idempotency-key header handled at most once.
+func RegisterServiceServer(srv *grpc.Server, db *gorm.DB, svc *grpchandler.InventoryService) {
+	srv.RegisterService(idempotency.Wrap(&inventory.Service_ServiceDesc, idempotency.NewInterceptor(db),
+		idempotency.Methods(&inventory.Service_ServiceDesc, "CreateInventory", "UpdateInventory", "ReservedStock", "ReleaseStock")...), svc)
 }
 
 func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
@@ -47,6 +57,45 @@ func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
 	return inventory.RegisterServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
 }
 
+// RPCServices are the services go-genproto has no messages for, served as
+// rpc.Services.
+type RPCServices struct {
+	fx.In
+	Ledger    *grpchandler.LedgerService
+	Transfer  *grpchandler.TransferService
+	Alert     *grpchandler.AlertService
+	Purchase  *grpchandler.PurchaseService
+	Stocktake *grpchandler.StocktakeService
+	Lot       *grpchandler.LotService
+	Recipe    *grpchandler.RecipeService
+}
+
+// RegisterRPCServices registers the RPC services on the gRPC server and the
+// HTTP gateway, with the same idempotency handling as the inventory service.
+func RegisterRPCServices(srv *grpc.Server, mux *runtime.ServeMux, db *gorm.DB, services RPCServices) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	for _, svc := range []*rpc.Service{
+		services.Ledger.Service(),
+		services.Transfer.Service(),
+		services.Alert.Service(),
+		services.Purchase.Service(),
+		services.Stocktake.Service(),
+		services.Lot.Service(),
+		services.Recipe.Service(),
+	} {
+		srv.RegisterService(idempotency.Wrap(svc.Desc(), idempotency.NewInterceptor(db), svc.Commands()...), nil)
+
+		if err := svc.RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -62,13 +111,106 @@ func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 func NewOrganizationServiceClient() (organization.ServiceClient, error) {
 	conn, err := grpc.NewClient(env.Lookup("ORGANIZATION_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
-		fmt.Print(err.Error())
 		return nil, err
 	}
 
 	return organization.NewServiceClient(conn), nil
 }
 
+func NewTransactionConn() (service.TransactionConn, error) {
+	return grpc.NewClient(env.Lookup("TRANSACTION_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func NewItemServiceClient() (item.ServiceClient, error) {
+	conn, err := grpc.NewClient(env.Lookup("ITEM_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+
+	return item.NewServiceClient(conn), nil
+}
+
+// SubscribeOutbox handles the events the transaction service publishes
+// through its outbox, such as fulfilled orders whose sale is booked and
+// returned items to restock, for as long as the app runs. Each
+// notification, each reconnect and every minute the pending events are
+// replayed.
+func SubscribeOutbox(lc fx.Lifecycle, db *gorm.DB, consumer *service.OutboxConsumer) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			for _, channel := range consumer.Channels() {
+				channel := channel
+				replay := func(ctx context.Context) error {
+					return consumer.Replay(ctx, channel)
+				}
+
+				go pglisten.Listen(ctx, sqlDB, channel, func(ctx context.Context, _ string) error {
+					return replay(ctx)
+				}, pglisten.CatchUp(replay))
+			}
+
+			go func() {
+				ticker := time.NewTicker(time.Minute)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						for _, channel := range consumer.Channels() {
+							if err := consumer.Replay(ctx, channel); err != nil {
+								zap.L().Error("failed replay outbox", zap.String("channel", channel), zap.Error(err))
+							}
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// StartVariantLinker periodically links the inventory items the ledger
+// created for variants at new locations whose linking failed.
+func StartVariantLinker(lc fx.Lifecycle, svc *service.VariantLinkService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(time.Minute)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						svc.LinkOrLog(ctx)
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
 func main() {
 	app := fx.New(
 		fx.WithLogger(NewZapLogger),
@@ -77,13 +219,36 @@ func main() {
 		otelcol.Resource,
 		otelcol.TraceProvider,
 		server.GrpcServerProvider,
-		fx.Provide(NewOrganizationServiceClient),
+		fx.Provide(NewOrganizationServiceClient, NewItemServiceClient, NewTransactionConn),
 		fx.Provide(
 			repository.NewInventoryRepository,
+			repository.NewStockMovementRepository,
+			repository.NewTransferRepository,
+			repository.NewSupplierRepository,
+			repository.NewPurchaseOrderRepository,
+			repository.NewStocktakeRepository,
+			repository.NewLotRepository,
+			repository.NewRecipeRepository,
+			service.NewLedgerService,
+			service.NewAlertService,
+			service.NewTransferService,
+			service.NewPurchaseService,
+			service.NewStocktakeService,
+			service.NewLotService,
+			service.NewRecipeService,
+			service.NewVariantLinkService,
+			service.NewOutboxConsumer,
 			grpchandler.NewInventoryService,
+			grpchandler.NewLedgerService,
+			grpchandler.NewTransferService,
+			grpchandler.NewAlertService,
+			grpchandler.NewPurchaseService,
+			grpchandler.NewStocktakeService,
+			grpchandler.NewLotService,
+			grpchandler.NewRecipeService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
-		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint),
+		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint, RegisterRPCServices, SubscribeOutbox, StartVariantLinker),
 		server.GrpcServerInvoke,
 	)
 
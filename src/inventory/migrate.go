@@ -1,12 +1,48 @@
 package main
 
 import (
+	"github.com/smallbiznis/common/idempotency"
 	"github.com/smallbiznis/inventory/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
+		&idempotency.Key{},
 		&domain.InventoryItem{},
-	)
+		&domain.StockMovement{},
+		&domain.LowStockEvent{},
+		&domain.StockTransfer{},
+		&domain.TransferLine{},
+		&domain.Supplier{},
+		&domain.PurchaseOrder{},
+		&domain.PurchaseOrderLine{},
+		&domain.GoodsReceipt{},
+		&domain.Stocktake{},
+		&domain.StocktakeCount{},
+		&domain.InventoryLot{},
+		&domain.RecipeLine{},
+	); err != nil {
+		return err
+	}
+
+	return Migrate(db)
+}
+
+func Migrate(db *gorm.DB) (err error) {
+	return db.Transaction(func(tx *gorm.DB) (err error) {
+		// A location has at most one open stocktake.
+		if err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktake_open_location
+			ON stocktakes (location_id) WHERE status = 'open' AND deleted_at IS NULL`).Error; err != nil {
+			return
+		}
+
+		// Book the stock of inventory items created before the ledger existed
+		// as an opening movement, so that the ledger sums to Quantity.
+		return tx.Exec(`INSERT INTO stock_movements (organization_id, location_id, inventory_item_id, reason, quantity, quantity_after, note, created_at)
+			SELECT i.organization_id, i.location_id, i.id, ?, i.quantity, i.quantity, 'opening balance', i.created_at
+			FROM inventory_items i
+			WHERE i.quantity <> 0
+			AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.inventory_item_id = i.id)`, domain.CountCorrection).Error
+	})
 }
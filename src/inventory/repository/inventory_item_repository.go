@@ -8,6 +8,7 @@ import (
 	"github.com/smallbiznis/go-lib/pkg/pagination"
 	"github.com/smallbiznis/inventory/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type inventoryItemRepository struct {
@@ -37,6 +38,39 @@ func (r *inventoryItemRepository) Find(ctx context.Context, p pagination.Paginat
 	return
 }
 
+// FindLowStock lists inventory items whose available quantity is below their reorder level.
+func (r *inventoryItemRepository) FindLowStock(ctx context.Context, p pagination.Pagination, f domain.InventoryItem) (inventory domain.InventoryItems, count int64, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.InventoryItem{}).
+		Where(&f).
+		Where("reorder_level > 0 AND quantity - reserved_quantity < reorder_level").
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("quantity - reserved_quantity - reorder_level ASC").
+		Find(&inventory).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *inventoryItemRepository) FindLowStockEvents(ctx context.Context, after domain.LowStockEvent, limit int) (events domain.LowStockEvents, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.LowStockEvent{})
+	if after.ID != "" {
+		stmt = stmt.Where("(created_at, low_stock_event_id) > (?, ?)", after.CreatedAt, after.ID)
+	} else {
+		stmt = stmt.Where("created_at >= ?", after.CreatedAt)
+	}
+
+	if err = stmt.
+		Order("created_at ASC, low_stock_event_id ASC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return
+	}
+
+	return
+}
+
 func (r *inventoryItemRepository) FindOne(ctx context.Context, f domain.InventoryItem) (org *domain.InventoryItem, err error) {
 	if err = r.db.WithContext(ctx).Model(&domain.InventoryItem{}).Where(&f).First(&org).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -67,3 +101,72 @@ func (r *inventoryItemRepository) Update(ctx context.Context, d domain.Inventory
 func (r *inventoryItemRepository) Delete(ctx context.Context, org domain.InventoryItem) (err error) {
 	return r.db.WithContext(ctx).Model(&domain.InventoryItem{}).Delete(&org).Error
 }
+
+func (r *inventoryItemRepository) FindUnlinked(ctx context.Context, limit int) (inventory domain.InventoryItems, err error) {
+	err = r.db.WithContext(ctx).Model(&domain.InventoryItem{}).
+		Where("unlinked").
+		Order("item_id ASC").
+		Limit(limit).
+		Find(&inventory).Error
+	return
+}
+
+func (r *inventoryItemRepository) MarkLinked(ctx context.Context, ids ...string) (err error) {
+	return r.db.WithContext(ctx).Model(&domain.InventoryItem{}).
+		Where("id IN ?", ids).
+		Update("unlinked", false).Error
+}
+
+// Reserve atomically adds quantity to reserved_quantity, provided enough
+// unreserved stock is left, on the unexpired lots first-expiry-first-out for
+// lot-tracked items. It returns domain.ErrInsufficientStock otherwise.
+func (r *inventoryItemRepository) Reserve(ctx context.Context, id string, quantity int32) (err error) {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var inv domain.InventoryItem
+		stmt := tx.Model(&inv).
+			Clauses(clause.Returning{}).
+			Where("id = ? AND quantity - reserved_quantity >= ?", id, quantity).
+			Update("reserved_quantity", gorm.Expr("reserved_quantity + ?", quantity))
+		if err = stmt.Error; err != nil {
+			return
+		}
+
+		if stmt.RowsAffected == 0 {
+			return domain.ErrInsufficientStock
+		}
+
+		if err = reserveLots(tx, inv, quantity); err != nil {
+			return
+		}
+
+		return emitLowStock(tx, inv, inv.Available()+quantity)
+	})
+}
+
+// Release atomically subtracts quantity from reserved_quantity. It returns
+// domain.ErrInvalidRelease when less than quantity is reserved.
+func (r *inventoryItemRepository) Release(ctx context.Context, id string, quantity int32) (err error) {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		_, err = releaseReserved(tx, id, quantity)
+		return
+	})
+}
+
+// releaseReserved subtracts quantity from the reserved quantity of an
+// inventory item and of its lots, returning the updated item.
+func releaseReserved(tx *gorm.DB, id string, quantity int32) (inv domain.InventoryItem, err error) {
+	stmt := tx.Model(&inv).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND reserved_quantity >= ?", id, quantity).
+		Update("reserved_quantity", gorm.Expr("reserved_quantity - ?", quantity))
+	if err = stmt.Error; err != nil {
+		return
+	}
+
+	if stmt.RowsAffected == 0 {
+		return inv, domain.ErrInvalidRelease
+	}
+
+	err = releaseLots(tx, inv, quantity)
+	return
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fefo orders lots first-expiry-first-out. Lots without expiry go last.
+const fefo = "expires_at ASC NULLS LAST, created_at ASC"
+
+type lotRepository struct {
+	db *gorm.DB
+}
+
+func NewLotRepository(db *gorm.DB) domain.ILotRepository {
+	return &lotRepository{db}
+}
+
+func (r *lotRepository) Find(ctx context.Context, p pagination.Pagination, f domain.InventoryLot) (lots domain.InventoryLots, count int64, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.InventoryLot{}).
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate())
+
+	if p.SortBy != "" && p.OrderBy != "" {
+		stmt.Order(fmt.Sprintf("%s %s", p.SortBy, p.OrderBy))
+	} else {
+		stmt.Order(fefo)
+	}
+
+	if err = stmt.Find(&lots).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *lotRepository) FindExpiring(ctx context.Context, p pagination.Pagination, f domain.InventoryLot, before time.Time) (lots domain.InventoryLots, count int64, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.InventoryLot{}).
+		Where(&f).
+		Where("quantity > 0 AND expires_at <= ?", before).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order(fefo).
+		Find(&lots).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *lotRepository) Track(ctx context.Context, inventoryItemID string) (inv *domain.InventoryItem, err error) {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.InventoryItem{ID: inventoryItemID}).
+			First(&inv).Error; err != nil {
+			return
+		}
+
+		if inv.TrackLots {
+			return
+		}
+
+		if err = tx.Model(inv).Update("track_lots", true).Error; err != nil {
+			return
+		}
+
+		if inv.Quantity == 0 {
+			return
+		}
+
+		return tx.Create(&domain.InventoryLot{
+			OrganizationID:   inv.OrganizationID,
+			LocationID:       inv.LocationID,
+			InventoryItemID:  inv.ID,
+			Quantity:         inv.Quantity,
+			ReservedQuantity: inv.ReservedQuantity,
+		}).Error
+	})
+	return
+}
+
+// applyLots applies movement m to the lots of inv, which must be locked by
+// the caller. It returns the movements to append to the ledger: m itself,
+// or one movement per lot when a debit without lot is split first-expiry-
+// first-out.
+func applyLots(tx *gorm.DB, inv domain.InventoryItem, m domain.StockMovement) (parts domain.StockMovements, err error) {
+	if !inv.TrackLots {
+		if m.LotID != nil {
+			return nil, domain.ErrLotsNotTracked
+		}
+		return domain.StockMovements{m}, nil
+	}
+
+	if m.Quantity == 0 {
+		return domain.StockMovements{m}, nil
+	}
+
+	if m.LotID != nil || m.Quantity > 0 {
+		var lot *domain.InventoryLot
+		if m.LotID != nil {
+			lot, err = lockLot(tx, inv.ID, *m.LotID)
+		} else {
+			lot, err = lotFor(tx, inv, "", nil)
+		}
+		if err != nil {
+			return
+		}
+
+		if lot.Quantity+m.Quantity < lot.ReservedQuantity {
+			return nil, domain.ErrInsufficientStock
+		}
+
+		if err = tx.Model(lot).Update("quantity", gorm.Expr("quantity + ?", m.Quantity)).Error; err != nil {
+			return
+		}
+
+		m.LotID = &lot.ID
+		return domain.StockMovements{m}, nil
+	}
+
+	var lots domain.InventoryLots
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_item_id = ? AND quantity - reserved_quantity > 0", inv.ID).
+		Order(fefo).
+		Find(&lots).Error; err != nil {
+		return
+	}
+
+	remaining := -m.Quantity
+	for i := range lots {
+		take := min(remaining, lots[i].Available())
+		if err = tx.Model(&lots[i]).Update("quantity", gorm.Expr("quantity - ?", take)).Error; err != nil {
+			return
+		}
+
+		part := m
+		part.LotID = &lots[i].ID
+		part.Quantity = -take
+		parts = append(parts, part)
+
+		remaining -= take
+		if remaining == 0 {
+			return
+		}
+	}
+
+	return nil, domain.ErrInsufficientStock
+}
+
+// reserveLots reserves quantity on the unexpired lots of inv,
+// first-expiry-first-out.
+func reserveLots(tx *gorm.DB, inv domain.InventoryItem, quantity int32) (err error) {
+	if !inv.TrackLots {
+		return
+	}
+
+	var lots domain.InventoryLots
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_item_id = ? AND quantity - reserved_quantity > 0", inv.ID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order(fefo).
+		Find(&lots).Error; err != nil {
+		return
+	}
+
+	remaining := quantity
+	for i := range lots {
+		if remaining == 0 {
+			return
+		}
+
+		take := min(remaining, lots[i].Available())
+		if err = tx.Model(&lots[i]).Update("reserved_quantity", gorm.Expr("reserved_quantity + ?", take)).Error; err != nil {
+			return
+		}
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		return domain.ErrInsufficientStock
+	}
+
+	return
+}
+
+// releaseLots releases quantity from the reserved lots of inv, latest expiry
+// first, so the earliest expiring stock stays reserved.
+func releaseLots(tx *gorm.DB, inv domain.InventoryItem, quantity int32) (err error) {
+	if !inv.TrackLots {
+		return
+	}
+
+	var lots domain.InventoryLots
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("inventory_item_id = ? AND reserved_quantity > 0", inv.ID).
+		Order("expires_at DESC NULLS FIRST, created_at DESC").
+		Find(&lots).Error; err != nil {
+		return
+	}
+
+	remaining := quantity
+	for i := range lots {
+		if remaining == 0 {
+			return
+		}
+
+		take := min(remaining, lots[i].ReservedQuantity)
+		if err = tx.Model(&lots[i]).Update("reserved_quantity", gorm.Expr("reserved_quantity - ?", take)).Error; err != nil {
+			return
+		}
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		return domain.ErrInvalidRelease
+	}
+
+	return
+}
+
+// lockLot loads a lot of an inventory item, locked until tx ends.
+func lockLot(tx *gorm.DB, inventoryItemID, id string) (lot *domain.InventoryLot, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.InventoryLot{ID: id, InventoryItemID: inventoryItemID}).
+		First(&lot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrUnknownLot
+		}
+		return
+	}
+
+	return
+}
+
+// lotFor returns the lot of inv with lotNumber, creating it with expiresAt
+// when it doesn't exist yet.
+func lotFor(tx *gorm.DB, inv domain.InventoryItem, lotNumber string, expiresAt *time.Time) (lot *domain.InventoryLot, err error) {
+	if !inv.TrackLots {
+		return nil, domain.ErrLotsNotTracked
+	}
+
+	lot = &domain.InventoryLot{}
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(map[string]interface{}{"inventory_item_id": inv.ID, "lot_number": lotNumber}).
+		Attrs(domain.InventoryLot{
+			OrganizationID:  inv.OrganizationID,
+			LocationID:      inv.LocationID,
+			InventoryItemID: inv.ID,
+			LotNumber:       lotNumber,
+			ExpiresAt:       expiresAt,
+		}).
+		FirstOrCreate(lot).Error
+	return
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"encoding/json"
+
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+)
+
+// emitLowStock records and publishes a low-stock event when a stock change
+// took inv from at or above its reorder level to below it. Events are only
+// emitted on the crossing, not on every change while stock stays low.
+// It must run in the transaction that changed the stock, so the event is
+// published if and only if the change commits.
+func emitLowStock(tx *gorm.DB, inv domain.InventoryItem, availableBefore int32) (err error) {
+	if !inv.IsLowStock() || availableBefore < inv.ReorderLevel {
+		return
+	}
+
+	event := domain.LowStockEvent{
+		OrganizationID:  inv.OrganizationID,
+		LocationID:      inv.LocationID,
+		InventoryItemID: inv.ID,
+		ItemID:          inv.ItemID,
+		Available:       inv.Available(),
+		ReorderLevel:    inv.ReorderLevel,
+	}
+
+	if err = tx.Create(&event).Error; err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	return tx.Exec("SELECT pg_notify(?, ?)", domain.LowStockChannel, string(payload)).Error
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type purchaseOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewPurchaseOrderRepository(db *gorm.DB) domain.IPurchaseOrderRepository {
+	return &purchaseOrderRepository{db}
+}
+
+func (r *purchaseOrderRepository) Find(ctx context.Context, p pagination.Pagination, f domain.PurchaseOrder) (orders domain.PurchaseOrders, count int64, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.PurchaseOrder{}).
+		Preload("Lines").
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate())
+
+	if p.SortBy != "" && p.OrderBy != "" {
+		stmt.Order(fmt.Sprintf("%s %s", p.SortBy, p.OrderBy))
+	} else {
+		stmt.Order("updated_at DESC")
+	}
+
+	if err = stmt.Find(&orders).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *purchaseOrderRepository) FindOne(ctx context.Context, f domain.PurchaseOrder) (order *domain.PurchaseOrder, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.PurchaseOrder{}).
+		Preload("Lines").
+		Where(&f).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return
+	}
+
+	return
+}
+
+func (r *purchaseOrderRepository) Save(ctx context.Context, d domain.PurchaseOrder) (order *domain.PurchaseOrder, err error) {
+	if err = r.db.WithContext(ctx).Create(&d).Error; err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.PurchaseOrder{ID: d.ID})
+}
+
+func (r *purchaseOrderRepository) Submit(ctx context.Context, id string) (order *domain.PurchaseOrder, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		po, err := lockPurchaseOrder(tx, id, domain.PurchaseOrderDraft)
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+		return tx.Model(po).Updates(domain.PurchaseOrder{
+			Status:    domain.PurchaseOrderOrdered,
+			OrderedAt: &now,
+		}).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.PurchaseOrder{ID: id})
+}
+
+func (r *purchaseOrderRepository) Receive(ctx context.Context, id string, receipts []domain.GoodsReceipt) (order *domain.PurchaseOrder, saved domain.GoodsReceipts, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		po, err := lockPurchaseOrder(tx, id, domain.PurchaseOrderOrdered, domain.PurchaseOrderPartiallyReceived)
+		if err != nil {
+			return
+		}
+
+		lines := make(map[string]*domain.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			lines[po.Lines[i].ID] = &po.Lines[i]
+		}
+
+		for _, receipt := range receipts {
+			line, ok := lines[receipt.PurchaseOrderLineID]
+			if !ok {
+				return domain.ErrUnknownPurchaseOrderLine
+			}
+
+			if line.ReceivedQuantity+receipt.Quantity > line.Quantity {
+				return domain.ErrOverReceipt
+			}
+
+			if line.InventoryItemID == nil {
+				inv, err := inventoryItemAt(tx, po.OrganizationID, po.LocationID, line.VariantID)
+				if err != nil {
+					return err
+				}
+				line.InventoryItemID = &inv.ID
+			}
+
+			var onHand int32
+			if err = tx.Model(&domain.InventoryItem{}).
+				Select("COALESCE(SUM(quantity), 0)").
+				Where(&domain.InventoryItem{OrganizationID: po.OrganizationID, ItemID: line.VariantID}).
+				Scan(&onHand).Error; err != nil {
+				return
+			}
+
+			movement := domain.StockMovement{
+				InventoryItemID: *line.InventoryItemID,
+				Reason:          domain.Receive,
+				Quantity:        receipt.Quantity,
+				ReferenceType:   "purchase_order",
+				ReferenceID:     po.ID,
+			}
+
+			if receipt.LotNumber != "" {
+				var inv domain.InventoryItem
+				if err = tx.Where(&domain.InventoryItem{ID: *line.InventoryItemID}).First(&inv).Error; err != nil {
+					return
+				}
+
+				lot, err := lotFor(tx, inv, receipt.LotNumber, receipt.ExpiresAt)
+				if err != nil {
+					return err
+				}
+				movement.LotID = &lot.ID
+			}
+
+			if _, err = recordMovements(tx, movement); err != nil {
+				return
+			}
+
+			line.ReceivedQuantity += receipt.Quantity
+			if err = tx.Model(line).Updates(map[string]interface{}{
+				"inventory_item_id": line.InventoryItemID,
+				"received_quantity": line.ReceivedQuantity,
+			}).Error; err != nil {
+				return
+			}
+
+			unitCost := receipt.UnitCost
+			if unitCost == 0 {
+				unitCost = line.UnitCost
+			}
+
+			newReceipt := domain.GoodsReceipt{
+				PurchaseOrderID:     po.ID,
+				PurchaseOrderLineID: line.ID,
+				VariantID:           line.VariantID,
+				Quantity:            receipt.Quantity,
+				UnitCost:            unitCost,
+				LotNumber:           receipt.LotNumber,
+				ExpiresAt:           receipt.ExpiresAt,
+				OnHandBefore:        onHand,
+			}
+			if err = tx.Create(&newReceipt).Error; err != nil {
+				return
+			}
+
+			saved = append(saved, newReceipt)
+		}
+
+		updates := domain.PurchaseOrder{Status: domain.PurchaseOrderReceived}
+		for _, line := range po.Lines {
+			if line.ReceivedQuantity < line.Quantity {
+				updates.Status = domain.PurchaseOrderPartiallyReceived
+				break
+			}
+		}
+
+		if updates.Status == domain.PurchaseOrderReceived {
+			now := time.Now()
+			updates.ReceivedAt = &now
+		}
+
+		return tx.Model(po).Updates(updates).Error
+	}); err != nil {
+		return
+	}
+
+	order, err = r.FindOne(ctx, domain.PurchaseOrder{ID: id})
+	return
+}
+
+func (r *purchaseOrderRepository) Cancel(ctx context.Context, id string) (order *domain.PurchaseOrder, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		po, err := lockPurchaseOrder(tx, id, domain.PurchaseOrderDraft, domain.PurchaseOrderOrdered)
+		if err != nil {
+			return
+		}
+
+		return tx.Model(po).Update("status", domain.PurchaseOrderCancelled).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.PurchaseOrder{ID: id})
+}
+
+// lockPurchaseOrder loads a purchase order with its lines, locked until tx
+// ends, and checks it is in one of the expected statuses.
+func lockPurchaseOrder(tx *gorm.DB, id string, expected ...domain.PurchaseOrderStatus) (order *domain.PurchaseOrder, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines").
+		Where(&domain.PurchaseOrder{ID: id}).
+		First(&order).Error; err != nil {
+		return
+	}
+
+	if !slices.Contains(expected, order.Status) {
+		return nil, domain.ErrInvalidPurchaseOrderStatus
+	}
+
+	return
+}
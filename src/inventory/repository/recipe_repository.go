@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+)
+
+type recipeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecipeRepository(db *gorm.DB) domain.IRecipeRepository {
+	return &recipeRepository{db}
+}
+
+func (r *recipeRepository) Find(ctx context.Context, variantIDs ...string) (lines domain.RecipeLines, err error) {
+	if err = r.db.WithContext(ctx).
+		Where("variant_id IN ?", variantIDs).
+		Order("created_at ASC").
+		Find(&lines).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *recipeRepository) Replace(ctx context.Context, variantID string, lines domain.RecipeLines) (result domain.RecipeLines, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if err = tx.Where("variant_id = ?", variantID).Delete(&domain.RecipeLine{}).Error; err != nil {
+			return
+		}
+
+		if len(lines) == 0 {
+			return
+		}
+
+		return tx.Create(&lines).Error
+	}); err != nil {
+		return
+	}
+
+	return r.Find(ctx, variantID)
+}
+
+func (r *recipeRepository) Consume(ctx context.Context, orderID, organizationID, locationID string, sold, usage map[string]int32) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var consumed int64
+		if err = tx.Model(&domain.StockMovement{}).
+			Where(&domain.StockMovement{ReferenceType: "order", ReferenceID: orderID, Reason: domain.Sale}).
+			Count(&consumed).Error; err != nil {
+			return
+		}
+
+		if consumed > 0 {
+			return
+		}
+
+		var movements domain.StockMovements
+
+		// Sort inventory items and ingredients so concurrent orders lock
+		// inventory items in the same order.
+		for _, id := range sortedKeys(sold) {
+			if _, err = releaseReserved(tx, id, sold[id]); err != nil {
+				return
+			}
+
+			movements = append(movements, domain.StockMovement{
+				InventoryItemID: id,
+				Reason:          domain.Sale,
+				Quantity:        -sold[id],
+				ReferenceType:   "order",
+				ReferenceID:     orderID,
+			})
+		}
+
+		for _, ingredient := range sortedKeys(usage) {
+			var inv domain.InventoryItem
+			if err = tx.Where(&domain.InventoryItem{
+				OrganizationID: organizationID,
+				LocationID:     locationID,
+				ItemID:         ingredient,
+			}).First(&inv).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return domain.ErrIngredientNotStocked
+				}
+				return
+			}
+
+			movements = append(movements, domain.StockMovement{
+				InventoryItemID: inv.ID,
+				Reason:          domain.Sale,
+				Quantity:        -usage[ingredient],
+				ReferenceType:   "order",
+				ReferenceID:     orderID,
+			})
+		}
+
+		_, err = recordMovements(tx, movements...)
+		return
+	})
+}
+
+func sortedKeys(m map[string]int32) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type stockMovementRepository struct {
+	db *gorm.DB
+}
+
+func NewStockMovementRepository(db *gorm.DB) domain.IStockMovementRepository {
+	return &stockMovementRepository{db}
+}
+
+func (r *stockMovementRepository) Find(ctx context.Context, p pagination.Pagination, f domain.StockMovement) (movements domain.StockMovements, count int64, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.StockMovement{}).
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("created_at DESC").
+		Find(&movements).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *stockMovementRepository) Open(ctx context.Context, d domain.InventoryItem) (inv *domain.InventoryItem, err error) {
+	quantity := d.Quantity
+	d.Quantity = 0
+
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if err = tx.Create(&d).Error; err != nil {
+			return
+		}
+
+		if quantity == 0 {
+			return
+		}
+
+		_, err = recordMovements(tx, domain.StockMovement{
+			InventoryItemID: d.ID,
+			Reason:          domain.Receive,
+			Quantity:        quantity,
+			Note:            "opening balance",
+		})
+		return
+	}); err != nil {
+		return
+	}
+
+	d.Quantity = quantity
+	return &d, nil
+}
+
+func (r *stockMovementRepository) Record(ctx context.Context, movements ...domain.StockMovement) (result domain.StockMovements, err error) {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		result, err = recordMovements(tx, movements...)
+		return
+	})
+	return
+}
+
+func (r *stockMovementRepository) Restock(ctx context.Context, referenceType, referenceID, organizationID, locationID string, quantities map[string]int32) (result domain.StockMovements, err error) {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var restocked int64
+		if err = tx.Model(&domain.StockMovement{}).
+			Where(&domain.StockMovement{ReferenceType: referenceType, ReferenceID: referenceID, Reason: domain.Return}).
+			Count(&restocked).Error; err != nil {
+			return
+		}
+
+		if restocked > 0 {
+			return
+		}
+
+		var movements domain.StockMovements
+		for _, itemID := range sortedKeys(quantities) {
+			inv, err := inventoryItemAt(tx, organizationID, locationID, itemID)
+			if err != nil {
+				return err
+			}
+
+			movements = append(movements, domain.StockMovement{
+				InventoryItemID: inv.ID,
+				Reason:          domain.Return,
+				Quantity:        quantities[itemID],
+				ReferenceType:   referenceType,
+				ReferenceID:     referenceID,
+			})
+		}
+
+		result, err = recordMovements(tx, movements...)
+		return
+	})
+	return
+}
+
+// recordMovements locks each inventory item, applies the movement delta to
+// the item and its lots and appends the movement to the ledger. It must run
+// inside a transaction.
+func recordMovements(tx *gorm.DB, movements ...domain.StockMovement) (result domain.StockMovements, err error) {
+	for _, m := range movements {
+		var inv domain.InventoryItem
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.InventoryItem{ID: m.InventoryItemID}).
+			First(&inv).Error; err != nil {
+			return
+		}
+
+		after := inv.Quantity + m.Quantity
+		if after < 0 {
+			return nil, domain.ErrNegativeStock
+		}
+
+		if after < inv.ReservedQuantity {
+			return nil, domain.ErrInsufficientStock
+		}
+
+		parts, err := applyLots(tx, inv, m)
+		if err != nil {
+			return nil, err
+		}
+
+		availableBefore := inv.Available()
+		if err = tx.Model(&inv).Update("quantity", after).Error; err != nil {
+			return nil, err
+		}
+
+		quantity := inv.Quantity
+		inv.Quantity = after
+		if err = emitLowStock(tx, inv, availableBefore); err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts {
+			quantity += part.Quantity
+			part.OrganizationID = inv.OrganizationID
+			part.LocationID = inv.LocationID
+			part.QuantityAfter = quantity
+			if err = tx.Create(&part).Error; err != nil {
+				return nil, err
+			}
+
+			result = append(result, part)
+		}
+	}
+
+	return
+}
+
+func (r *stockMovementRepository) OnHandAt(ctx context.Context, inventoryItemID string, at time.Time) (quantity int32, err error) {
+	err = r.db.WithContext(ctx).Model(&domain.StockMovement{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("inventory_item_id = ? AND created_at <= ?", inventoryItemID, at).
+		Scan(&quantity).Error
+	return
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type stocktakeRepository struct {
+	db *gorm.DB
+}
+
+func NewStocktakeRepository(db *gorm.DB) domain.IStocktakeRepository {
+	return &stocktakeRepository{db}
+}
+
+func (r *stocktakeRepository) Find(ctx context.Context, p pagination.Pagination, f domain.Stocktake) (stocktakes domain.Stocktakes, count int64, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.Stocktake{}).
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate())
+
+	if p.SortBy != "" && p.OrderBy != "" {
+		stmt.Order(fmt.Sprintf("%s %s", p.SortBy, p.OrderBy))
+	} else {
+		stmt.Order("updated_at DESC")
+	}
+
+	if err = stmt.Find(&stocktakes).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *stocktakeRepository) FindOne(ctx context.Context, f domain.Stocktake) (stocktake *domain.Stocktake, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.Stocktake{}).
+		Preload("Counts").
+		Where(&f).First(&stocktake).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return
+	}
+
+	return
+}
+
+func (r *stocktakeRepository) Open(ctx context.Context, d domain.Stocktake) (stocktake *domain.Stocktake, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var open int64
+		if err = tx.Model(&domain.Stocktake{}).
+			Where(&domain.Stocktake{LocationID: d.LocationID, Status: domain.StocktakeOpen}).
+			Count(&open).Error; err != nil {
+			return
+		}
+
+		if open > 0 {
+			return domain.ErrStocktakeInProgress
+		}
+
+		d.Status = domain.StocktakeOpen
+		return tx.Create(&d).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.Stocktake{ID: d.ID})
+}
+
+func (r *stocktakeRepository) Count(ctx context.Context, id string, counts domain.StocktakeCounts) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		s, err := lockStocktake(tx, id)
+		if err != nil {
+			return
+		}
+
+		ids := make([]string, 0, len(counts))
+		for i := range counts {
+			counts[i].StocktakeID = s.ID
+			ids = append(ids, counts[i].InventoryItemID)
+		}
+
+		var stocked []string
+		if err = tx.Model(&domain.InventoryItem{}).
+			Where("id IN ? AND location_id = ?", ids, s.LocationID).
+			Pluck("id", &stocked).Error; err != nil {
+			return
+		}
+
+		found := make(map[string]bool, len(stocked))
+		for _, id := range stocked {
+			found[id] = true
+		}
+
+		for _, c := range counts {
+			if !found[c.InventoryItemID] {
+				return domain.ErrItemNotAtLocation
+			}
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "stocktake_id"}, {Name: "inventory_item_id"}, {Name: "device_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "counted_by", "updated_at"}),
+		}).Create(&counts).Error
+	})
+}
+
+func (r *stocktakeRepository) Variances(ctx context.Context, id string) (variances domain.StocktakeVariances, err error) {
+	return stocktakeVariances(r.db.WithContext(ctx), id)
+}
+
+func (r *stocktakeRepository) Post(ctx context.Context, id string) (stocktake *domain.Stocktake, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		s, err := lockStocktake(tx, id)
+		if err != nil {
+			return
+		}
+
+		// Lock the counted items first so the variances stay valid until
+		// they are booked.
+		var items domain.InventoryItems
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN (?)", tx.Model(&domain.StocktakeCount{}).Select("inventory_item_id").Where("stocktake_id = ?", s.ID)).
+			Find(&items).Error; err != nil {
+			return
+		}
+
+		variances, err := stocktakeVariances(tx, s.ID)
+		if err != nil {
+			return
+		}
+
+		for _, v := range variances {
+			if _, err = recordMovements(tx, domain.StockMovement{
+				InventoryItemID: v.InventoryItemID,
+				Reason:          domain.CountCorrection,
+				Quantity:        v.Variance,
+				ReferenceType:   "stocktake",
+				ReferenceID:     s.ID,
+			}); err != nil {
+				return
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(s).Updates(domain.Stocktake{
+			Status:   domain.StocktakePosted,
+			PostedAt: &now,
+		}).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.Stocktake{ID: id})
+}
+
+func (r *stocktakeRepository) Cancel(ctx context.Context, id string) (stocktake *domain.Stocktake, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		s, err := lockStocktake(tx, id)
+		if err != nil {
+			return
+		}
+
+		return tx.Model(s).Update("status", domain.StocktakeCancelled).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.Stocktake{ID: id})
+}
+
+// lockStocktake loads a stocktake, locked until tx ends, and checks it is
+// still open.
+func lockStocktake(tx *gorm.DB, id string) (stocktake *domain.Stocktake, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.Stocktake{ID: id}).
+		First(&stocktake).Error; err != nil {
+		return
+	}
+
+	if stocktake.Status != domain.StocktakeOpen {
+		return nil, domain.ErrInvalidStocktakeStatus
+	}
+
+	return
+}
+
+// stocktakeVariances sums the counts of every counted item and compares them
+// with the item quantity, largest differences first.
+func stocktakeVariances(db *gorm.DB, id string) (variances domain.StocktakeVariances, err error) {
+	err = db.Table("stocktake_counts c").
+		Select(`c.inventory_item_id, i.item_id, i.quantity AS expected,
+			SUM(c.quantity) AS counted, SUM(c.quantity) - i.quantity AS variance`).
+		Joins("JOIN inventory_items i ON i.id = c.inventory_item_id").
+		Where("c.stocktake_id = ?", id).
+		Group("c.inventory_item_id, i.item_id, i.quantity").
+		Having("SUM(c.quantity) <> i.quantity").
+		Order("ABS(SUM(c.quantity) - i.quantity) DESC").
+		Scan(&variances).Error
+	return
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+)
+
+type supplierRepository struct {
+	db *gorm.DB
+}
+
+func NewSupplierRepository(db *gorm.DB) domain.ISupplierRepository {
+	return &supplierRepository{db}
+}
+
+func (r *supplierRepository) Find(ctx context.Context, p pagination.Pagination, f domain.Supplier) (suppliers domain.Suppliers, count int64, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.Supplier{}).
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate())
+
+	if p.SortBy != "" && p.OrderBy != "" {
+		stmt.Order(fmt.Sprintf("%s %s", p.SortBy, p.OrderBy))
+	} else {
+		stmt.Order("name ASC")
+	}
+
+	if err = stmt.Find(&suppliers).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *supplierRepository) FindOne(ctx context.Context, f domain.Supplier) (supplier *domain.Supplier, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.Supplier{}).Where(&f).First(&supplier).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return
+	}
+
+	return
+}
+
+func (r *supplierRepository) Save(ctx context.Context, d domain.Supplier) (supplier *domain.Supplier, err error) {
+	if err = r.db.WithContext(ctx).Create(&d).Error; err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.Supplier{ID: d.ID})
+}
+
+func (r *supplierRepository) Update(ctx context.Context, d domain.Supplier) (supplier *domain.Supplier, err error) {
+	if err = r.db.WithContext(ctx).Save(&d).Error; err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.Supplier{ID: d.ID})
+}
+
+func (r *supplierRepository) Delete(ctx context.Context, d domain.Supplier) (err error) {
+	return r.db.WithContext(ctx).Delete(&d).Error
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type transferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) domain.ITransferRepository {
+	return &transferRepository{db}
+}
+
+func (r *transferRepository) Find(ctx context.Context, p pagination.Pagination, f domain.StockTransfer) (transfers domain.StockTransfers, count int64, err error) {
+	stmt := r.db.WithContext(ctx).Model(&domain.StockTransfer{}).
+		Preload("Lines").
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate())
+
+	if p.SortBy != "" && p.OrderBy != "" {
+		stmt.Order(fmt.Sprintf("%s %s", p.SortBy, p.OrderBy))
+	} else {
+		stmt.Order("updated_at DESC")
+	}
+
+	if err = stmt.Find(&transfers).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *transferRepository) FindOne(ctx context.Context, f domain.StockTransfer) (transfer *domain.StockTransfer, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.StockTransfer{}).
+		Preload("Lines").
+		Where(&f).First(&transfer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return
+	}
+
+	return
+}
+
+func (r *transferRepository) Save(ctx context.Context, d domain.StockTransfer) (transfer *domain.StockTransfer, err error) {
+	if err = r.db.WithContext(ctx).Create(&d).Error; err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.StockTransfer{ID: d.ID})
+}
+
+func (r *transferRepository) Ship(ctx context.Context, id string) (transfer *domain.StockTransfer, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		t, err := lockTransfer(tx, id, domain.TransferDraft)
+		if err != nil {
+			return
+		}
+
+		for _, line := range t.Lines {
+			if _, err = recordMovements(tx, domain.StockMovement{
+				InventoryItemID: line.SourceInventoryItemID,
+				Reason:          domain.Transfer,
+				Quantity:        -line.Quantity,
+				ReferenceType:   "transfer",
+				ReferenceID:     t.ID,
+			}); err != nil {
+				return
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(t).Updates(domain.StockTransfer{
+			Status:    domain.TransferInTransit,
+			ShippedAt: &now,
+		}).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.StockTransfer{ID: id})
+}
+
+func (r *transferRepository) Receive(ctx context.Context, id string, receipts []domain.TransferReceipt) (transfer *domain.StockTransfer, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		t, err := lockTransfer(tx, id, domain.TransferInTransit)
+		if err != nil {
+			return
+		}
+
+		lines := make(map[string]*domain.TransferLine, len(t.Lines))
+		for i := range t.Lines {
+			lines[t.Lines[i].ID] = &t.Lines[i]
+		}
+
+		for _, receipt := range receipts {
+			line, ok := lines[receipt.TransferLineID]
+			if !ok {
+				return domain.ErrUnknownTransferLine
+			}
+
+			if line.ReceivedQuantity+receipt.Quantity > line.Quantity {
+				return domain.ErrOverReceipt
+			}
+
+			if line.DestinationInventoryItemID == nil {
+				dest, err := destinationItem(tx, t, line.ItemID)
+				if err != nil {
+					return err
+				}
+				line.DestinationInventoryItemID = &dest.ID
+			}
+
+			if _, err = recordMovements(tx, domain.StockMovement{
+				InventoryItemID: *line.DestinationInventoryItemID,
+				Reason:          domain.Transfer,
+				Quantity:        receipt.Quantity,
+				ReferenceType:   "transfer",
+				ReferenceID:     t.ID,
+			}); err != nil {
+				return
+			}
+
+			line.ReceivedQuantity += receipt.Quantity
+			if err = tx.Model(line).Updates(map[string]interface{}{
+				"destination_inventory_item_id": line.DestinationInventoryItemID,
+				"received_quantity":             line.ReceivedQuantity,
+			}).Error; err != nil {
+				return
+			}
+		}
+
+		for _, line := range t.Lines {
+			if line.ReceivedQuantity < line.Quantity {
+				return
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(t).Updates(domain.StockTransfer{
+			Status:     domain.TransferReceived,
+			ReceivedAt: &now,
+		}).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.StockTransfer{ID: id})
+}
+
+func (r *transferRepository) Cancel(ctx context.Context, id string) (transfer *domain.StockTransfer, err error) {
+	if err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		t, err := lockTransfer(tx, id, domain.TransferDraft)
+		if err != nil {
+			return
+		}
+
+		return tx.Model(t).Update("status", domain.TransferCancelled).Error
+	}); err != nil {
+		return
+	}
+
+	return r.FindOne(ctx, domain.StockTransfer{ID: id})
+}
+
+// lockTransfer loads a transfer with its lines, locked until tx ends, and
+// checks it is in the expected status.
+func lockTransfer(tx *gorm.DB, id string, expected domain.TransferStatus) (transfer *domain.StockTransfer, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Lines").
+		Where(&domain.StockTransfer{ID: id}).
+		First(&transfer).Error; err != nil {
+		return
+	}
+
+	if transfer.Status != expected {
+		return nil, domain.ErrInvalidTransferStatus
+	}
+
+	return
+}
+
+// destinationItem returns the inventory item of itemID at the transfer
+// destination, creating an empty one when the location doesn't stock it yet.
+func destinationItem(tx *gorm.DB, t *domain.StockTransfer, itemID string) (*domain.InventoryItem, error) {
+	return inventoryItemAt(tx, t.OrganizationID, t.DestinationLocationID, itemID)
+}
+
+// inventoryItemAt returns the inventory item of itemID at a location,
+// creating an empty one when the location doesn't stock it yet. Created
+// items are unlinked until VariantLinkService adds them to the variant.
+func inventoryItemAt(tx *gorm.DB, organizationID, locationID, itemID string) (inv *domain.InventoryItem, err error) {
+	inv = &domain.InventoryItem{
+		OrganizationID: organizationID,
+		LocationID:     locationID,
+		ItemID:         itemID,
+	}
+
+	err = tx.Where(inv).Attrs(domain.InventoryItem{Unlinked: true}).FirstOrCreate(inv).Error
+	return
+}
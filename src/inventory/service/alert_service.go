@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AlertService struct {
+	inventoryRepository domain.IInventoryItemRepository
+}
+
+func NewAlertService(
+	inventoryRepository domain.IInventoryItemRepository,
+) *AlertService {
+	return &AlertService{
+		inventoryRepository: inventoryRepository,
+	}
+}
+
+// ListLowStock lists the inventory items of an organization, optionally
+// narrowed to one location, whose available quantity is below their reorder
+// level. The most depleted items come first.
+func (svc *AlertService) ListLowStock(ctx context.Context, p pagination.Pagination, organizationID, locationID string) (domain.InventoryItems, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListLowStock")
+	defer span.End()
+
+	if organizationID == "" {
+		return nil, 0, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	items, count, err := svc.inventoryRepository.FindLowStock(ctx, p, domain.InventoryItem{
+		OrganizationID: organizationID,
+		LocationID:     locationID,
+	})
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return items, count, nil
+}
+
+// maxLowStockEvents is the most low-stock events ListLowStockEvent returns at once.
+const maxLowStockEvents = 500
+
+// ListLowStockEvent lists up to size low-stock events of every organization
+// in the order they were emitted, for subscribers catching up on the events
+// they missed. Events start after the one emitted at createdAt with afterID,
+// or at createdAt when afterID is empty.
+func (svc *AlertService) ListLowStockEvent(ctx context.Context, createdAt time.Time, afterID string, size int) (domain.LowStockEvents, error) {
+	ctx, span := tracer.Start(ctx, "ListLowStockEvent")
+	defer span.End()
+
+	if size <= 0 || size > maxLowStockEvents {
+		size = maxLowStockEvents
+	}
+
+	events, err := svc.inventoryRepository.FindLowStockEvents(ctx, domain.LowStockEvent{
+		ID:        afterID,
+		CreatedAt: createdAt,
+	}, size)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return events, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// LedgerService is the only path through which InventoryItem.Quantity
+// changes, so the stock movement ledger always explains the current stock.
+type LedgerService struct {
+	movementRepository domain.IStockMovementRepository
+	linkService        *VariantLinkService
+}
+
+func NewLedgerService(
+	movementRepository domain.IStockMovementRepository,
+	linkService *VariantLinkService,
+) *LedgerService {
+	return &LedgerService{
+		movementRepository: movementRepository,
+		linkService:        linkService,
+	}
+}
+
+// Open creates an inventory item whose initial quantity is booked as a receipt.
+func (svc *LedgerService) Open(ctx context.Context, inv domain.InventoryItem) (*domain.InventoryItem, error) {
+	ctx, span := tracer.Start(ctx, "OpenInventoryItem")
+	defer span.End()
+
+	if inv.Quantity < 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity can't be negative")
+	}
+
+	result, err := svc.movementRepository.Open(ctx, inv)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return result, nil
+}
+
+// Move applies stock movements atomically: either all of them are booked or none.
+func (svc *LedgerService) Move(ctx context.Context, movements ...domain.StockMovement) (domain.StockMovements, error) {
+	ctx, span := tracer.Start(ctx, "MoveStock")
+	defer span.End()
+
+	for _, m := range movements {
+		if m.Reason.String() == "" {
+			return nil, status.Error(codes.InvalidArgument, "invalid movement reason")
+		}
+
+		if m.Quantity == 0 {
+			return nil, status.Error(codes.InvalidArgument, "movement quantity can't be zero")
+		}
+	}
+
+	result, err := svc.movementRepository.Record(ctx, movements...)
+	if err != nil {
+		return nil, movementError(err)
+	}
+
+	return result, nil
+}
+
+// ListMovement lists the movements of an inventory item, newest first.
+func (svc *LedgerService) ListMovement(ctx context.Context, p pagination.Pagination, inventoryItemID string) (domain.StockMovements, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListMovement")
+	defer span.End()
+
+	movements, count, err := svc.movementRepository.Find(ctx, p, domain.StockMovement{
+		InventoryItemID: inventoryItemID,
+	})
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return movements, count, nil
+}
+
+// OnHandAt returns the on-hand quantity of an inventory item at the given time.
+func (svc *LedgerService) OnHandAt(ctx context.Context, inventoryItemID string, at time.Time) (int32, error) {
+	ctx, span := tracer.Start(ctx, "OnHandAt")
+	defer span.End()
+
+	quantity, err := svc.movementRepository.OnHandAt(ctx, inventoryItemID, at)
+	if err != nil {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return quantity, nil
+}
+
+// RestockReturn puts the items of a refund back into stock at the location
+// they were returned to. payload is the JSON encoded domain.OrderReturnedEvent.
+func (svc *LedgerService) RestockReturn(ctx context.Context, payload string) error {
+	ctx, span := tracer.Start(ctx, "RestockReturn")
+	defer span.End()
+
+	var event domain.OrderReturnedEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return err
+	}
+
+	quantities := make(map[string]int32, len(event.Items))
+	for _, line := range event.Items {
+		if line.Quantity > 0 {
+			quantities[line.VariantID] += line.Quantity
+		}
+	}
+
+	if len(quantities) == 0 {
+		return nil
+	}
+
+	if _, err := svc.movementRepository.Restock(ctx, "order_refund", event.OrderRefundID, event.OrganizationID, event.LocationID, quantities); err != nil {
+		return err
+	}
+
+	svc.linkService.LinkOrLog(ctx)
+	return nil
+}
+
+func movementError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.InvalidArgument, "inventory not found")
+	case errors.Is(err, domain.ErrUnknownLot):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrNegativeStock),
+		errors.Is(err, domain.ErrInsufficientStock),
+		errors.Is(err, domain.ErrLotsNotTracked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LotService manages lot and expiry tracking of perishable inventory.
+type LotService struct {
+	itemConn            item.ServiceClient
+	inventoryRepository domain.IInventoryItemRepository
+	lotRepository       domain.ILotRepository
+}
+
+func NewLotService(
+	itemConn item.ServiceClient,
+	inventoryRepository domain.IInventoryItemRepository,
+	lotRepository domain.ILotRepository,
+) *LotService {
+	return &LotService{
+		itemConn:            itemConn,
+		inventoryRepository: inventoryRepository,
+		lotRepository:       lotRepository,
+	}
+}
+
+// TrackLots turns on lot tracking for an inventory item. Only physical items
+// have lots; menu items are made to order.
+func (svc *LotService) TrackLots(ctx context.Context, inventoryItemID string) (*domain.InventoryItem, error) {
+	ctx, span := tracer.Start(ctx, "TrackLots")
+	defer span.End()
+
+	inv, err := svc.inventoryRepository.FindOne(ctx, domain.InventoryItem{ID: inventoryItemID})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if inv == nil {
+		return nil, status.Error(codes.InvalidArgument, "inventory not found")
+	}
+
+	itemType, err := variantItemType(ctx, svc.itemConn, inv.ItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if itemType != item.Type_physical {
+		return nil, status.Error(codes.FailedPrecondition, "only physical items can track lots")
+	}
+
+	inv, err = svc.lotRepository.Track(ctx, inventoryItemID)
+	if err != nil {
+		return nil, movementError(err)
+	}
+
+	return inv, nil
+}
+
+func (svc *LotService) ListLot(ctx context.Context, p pagination.Pagination, f domain.InventoryLot) (domain.InventoryLots, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListLot")
+	defer span.End()
+
+	lots, count, err := svc.lotRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return lots, count, nil
+}
+
+// ListExpiringLots lists the lots of a location with stock left that expire
+// within days, already expired lots first.
+func (svc *LotService) ListExpiringLots(ctx context.Context, p pagination.Pagination, organizationID, locationID string, days int32) (domain.InventoryLots, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListExpiringLots")
+	defer span.End()
+
+	if organizationID == "" {
+		return nil, 0, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if days < 0 {
+		return nil, 0, status.Error(codes.InvalidArgument, "days can't be negative")
+	}
+
+	before := time.Now().AddDate(0, 0, int(days))
+	lots, count, err := svc.lotRepository.FindExpiring(ctx, p, domain.InventoryLot{
+		OrganizationID: organizationID,
+		LocationID:     locationID,
+	}, before)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return lots, count, nil
+}
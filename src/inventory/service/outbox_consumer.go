@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/inventory/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// pendingEventPageSize is how many pending events Replay asks for at once.
+const pendingEventPageSize = 100
+
+// TransactionConn is the connection to the transaction service.
+type TransactionConn grpc.ClientConnInterface
+
+// OutboxConsumer handles the events the transaction service publishes
+// through its outbox. A notification only tells that events are pending:
+// they are read back from the transaction service and acknowledged once
+// handled, so events published while inventory was down or failed to
+// handle are replayed.
+type OutboxConsumer struct {
+	transactionConn TransactionConn
+	handlers        map[string]func(context.Context, string) error
+
+	// mu serializes replays, which the handlers' duplicate checks rely on.
+	mu sync.Mutex
+}
+
+func NewOutboxConsumer(
+	transactionConn TransactionConn,
+	recipeService *RecipeService,
+	ledgerService *LedgerService,
+) *OutboxConsumer {
+	return &OutboxConsumer{
+		transactionConn: transactionConn,
+		handlers: map[string]func(context.Context, string) error{
+			domain.OrderFulfilledChannel: recipeService.ConsumeOrder,
+			domain.OrderReturnedChannel:  ledgerService.RestockReturn,
+		},
+	}
+}
+
+// Channels returns the outbox channels the consumer handles.
+func (c *OutboxConsumer) Channels() (channels []string) {
+	for channel := range c.handlers {
+		channels = append(channels, channel)
+	}
+	return
+}
+
+// Replay handles the pending events of channel, oldest first, and
+// acknowledges each one handled. An event that fails is logged and left
+// pending for the next replay.
+func (c *OutboxConsumer) Replay(ctx context.Context, channel string) error {
+	ctx, span := tracer.Start(ctx, "ReplayOutbox")
+	defer span.End()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	handle := c.handlers[channel]
+	req := domain.PendingEventRequest{
+		Channel: channel,
+		Size:    pendingEventPageSize,
+	}
+
+	for {
+		var page domain.PendingEventPage
+		if err := rpc.Invoke(ctx, c.transactionConn, domain.OutboxServiceName, "ListPendingEvent", &req, &page); err != nil {
+			return err
+		}
+
+		var acked int
+		for _, event := range page.Data {
+			if err := handle(ctx, event.Payload); err != nil {
+				zap.L().Error("failed handle outbox event", zap.String("channel", channel), zap.String("event_id", event.ID), zap.Error(err))
+				continue
+			}
+
+			if err := rpc.Invoke(ctx, c.transactionConn, domain.OutboxServiceName, "AckEvent", &domain.AckEventRequest{EventID: event.ID}, &struct{}{}); err != nil {
+				return err
+			}
+			acked++
+		}
+
+		// A page of failing events would be listed again, so stop there.
+		if len(page.Data) < pendingEventPageSize || acked == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// PurchaseService manages suppliers and the purchase orders placed with
+// them. Receiving goods books the stock through the ledger and averages the
+// received cost into Variant.Cost.
+type PurchaseService struct {
+	organizationConn        organization.ServiceClient
+	itemConn                item.ServiceClient
+	supplierRepository      domain.ISupplierRepository
+	purchaseOrderRepository domain.IPurchaseOrderRepository
+	linkService             *VariantLinkService
+}
+
+func NewPurchaseService(
+	organizationConn organization.ServiceClient,
+	itemConn item.ServiceClient,
+	supplierRepository domain.ISupplierRepository,
+	purchaseOrderRepository domain.IPurchaseOrderRepository,
+	linkService *VariantLinkService,
+) *PurchaseService {
+	return &PurchaseService{
+		organizationConn:        organizationConn,
+		itemConn:                itemConn,
+		supplierRepository:      supplierRepository,
+		purchaseOrderRepository: purchaseOrderRepository,
+		linkService:             linkService,
+	}
+}
+
+func (svc *PurchaseService) ListSupplier(ctx context.Context, p pagination.Pagination, f domain.Supplier) (domain.Suppliers, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListSupplier")
+	defer span.End()
+
+	suppliers, count, err := svc.supplierRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return suppliers, count, nil
+}
+
+func (svc *PurchaseService) GetSupplier(ctx context.Context, id string) (*domain.Supplier, error) {
+	ctx, span := tracer.Start(ctx, "GetSupplier")
+	defer span.End()
+
+	supplier, err := svc.supplierRepository.FindOne(ctx, domain.Supplier{ID: id})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if supplier == nil {
+		return nil, status.Error(codes.InvalidArgument, "supplier not found")
+	}
+
+	return supplier, nil
+}
+
+func (svc *PurchaseService) CreateSupplier(ctx context.Context, req domain.Supplier) (*domain.Supplier, error) {
+	ctx, span := tracer.Start(ctx, "CreateSupplier")
+	defer span.End()
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	req.ID = uuid.NewString()
+	supplier, err := svc.supplierRepository.Save(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return supplier, nil
+}
+
+func (svc *PurchaseService) UpdateSupplier(ctx context.Context, req domain.Supplier) (*domain.Supplier, error) {
+	ctx, span := tracer.Start(ctx, "UpdateSupplier")
+	defer span.End()
+
+	exist, err := svc.GetSupplier(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	exist.Name = req.Name
+	exist.ContactName = req.ContactName
+	exist.Email = req.Email
+	exist.Phone = req.Phone
+	exist.Address = req.Address
+	exist.Note = req.Note
+
+	supplier, err := svc.supplierRepository.Update(ctx, *exist)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return supplier, nil
+}
+
+func (svc *PurchaseService) DeleteSupplier(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "DeleteSupplier")
+	defer span.End()
+
+	exist, err := svc.GetSupplier(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.supplierRepository.Delete(ctx, *exist); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+func (svc *PurchaseService) ListPurchaseOrder(ctx context.Context, p pagination.Pagination, f domain.PurchaseOrder) (domain.PurchaseOrders, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListPurchaseOrder")
+	defer span.End()
+
+	orders, count, err := svc.purchaseOrderRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return orders, count, nil
+}
+
+func (svc *PurchaseService) GetPurchaseOrder(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	ctx, span := tracer.Start(ctx, "GetPurchaseOrder")
+	defer span.End()
+
+	order, err := svc.purchaseOrderRepository.FindOne(ctx, domain.PurchaseOrder{ID: id})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if order == nil {
+		return nil, status.Error(codes.InvalidArgument, "purchase order not found")
+	}
+
+	return order, nil
+}
+
+// CreatePurchaseOrder saves a draft purchase order. The supplier and the
+// receiving location must belong to the organization. Lines without an
+// expected cost default to the current variant cost.
+func (svc *PurchaseService) CreatePurchaseOrder(ctx context.Context, req domain.PurchaseOrder) (*domain.PurchaseOrder, error) {
+	ctx, span := tracer.Start(ctx, "CreatePurchaseOrder")
+	defer span.End()
+
+	if len(req.Lines) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "lines can't be empty")
+	}
+
+	supplier, err := svc.GetSupplier(ctx, req.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+
+	if supplier.OrganizationID != req.OrganizationID {
+		return nil, status.Error(codes.InvalidArgument, "supplier doesn't belong to organization")
+	}
+
+	if err := validateLocations(ctx, svc.organizationConn, req.OrganizationID, req.LocationID); err != nil {
+		return nil, err
+	}
+
+	newOrder := domain.PurchaseOrder{
+		ID:             uuid.NewString(),
+		OrganizationID: req.OrganizationID,
+		SupplierID:     req.SupplierID,
+		LocationID:     req.LocationID,
+		Status:         domain.PurchaseOrderDraft,
+		Reference:      req.Reference,
+		Note:           req.Note,
+		ExpectedAt:     req.ExpectedAt,
+	}
+
+	for _, line := range req.Lines {
+		if line.Quantity <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		if line.UnitCost < 0 {
+			return nil, status.Error(codes.InvalidArgument, "unit_cost can't be negative")
+		}
+
+		variant, err := svc.itemConn.GetVariant(ctx, &item.GetVariantRequest{
+			VariantId: line.VariantID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		unitCost := line.UnitCost
+		if unitCost == 0 {
+			unitCost = variant.Cost
+		}
+
+		newOrder.Lines = append(newOrder.Lines, domain.PurchaseOrderLine{
+			PurchaseOrderID: newOrder.ID,
+			VariantID:       line.VariantID,
+			Quantity:        line.Quantity,
+			UnitCost:        unitCost,
+		})
+		newOrder.TotalCost += unitCost * float32(line.Quantity)
+	}
+
+	order, err := svc.purchaseOrderRepository.Save(ctx, newOrder)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return order, nil
+}
+
+// SubmitPurchaseOrder marks a draft purchase order as sent to the supplier.
+func (svc *PurchaseService) SubmitPurchaseOrder(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	ctx, span := tracer.Start(ctx, "SubmitPurchaseOrder")
+	defer span.End()
+
+	order, err := svc.purchaseOrderRepository.Submit(ctx, id)
+	if err != nil {
+		return nil, purchaseOrderError(err)
+	}
+
+	return order, nil
+}
+
+// ReceivePurchaseOrder books received goods as stock at the purchase order
+// location and updates the cost of the received variants with a weighted
+// average. It can be called several times for partial deliveries.
+func (svc *PurchaseService) ReceivePurchaseOrder(ctx context.Context, id string, receipts []domain.GoodsReceipt) (*domain.PurchaseOrder, error) {
+	ctx, span := tracer.Start(ctx, "ReceivePurchaseOrder")
+	defer span.End()
+
+	if len(receipts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "receipts can't be empty")
+	}
+
+	for _, receipt := range receipts {
+		if receipt.Quantity <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		if receipt.UnitCost < 0 {
+			return nil, status.Error(codes.InvalidArgument, "unit_cost can't be negative")
+		}
+	}
+
+	order, saved, err := svc.purchaseOrderRepository.Receive(ctx, id, receipts)
+	if err != nil {
+		return nil, purchaseOrderError(err)
+	}
+
+	// The stock is already committed, so a failed cost update is logged
+	// rather than failing the receipt, which would invite a double receipt.
+	if err := svc.updateCost(ctx, saved); err != nil {
+		zap.L().Error("failed update variant cost", zap.String("purchase_order_id", id), zap.Error(err))
+	}
+
+	svc.linkService.LinkOrLog(ctx)
+
+	return order, nil
+}
+
+// CancelPurchaseOrder cancels a purchase order that hasn't received any goods.
+func (svc *PurchaseService) CancelPurchaseOrder(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	ctx, span := tracer.Start(ctx, "CancelPurchaseOrder")
+	defer span.End()
+
+	order, err := svc.purchaseOrderRepository.Cancel(ctx, id)
+	if err != nil {
+		return nil, purchaseOrderError(err)
+	}
+
+	return order, nil
+}
+
+// updateCost averages the cost of each receipt into the cost of its variant,
+// in receipt order.
+func (svc *PurchaseService) updateCost(ctx context.Context, receipts domain.GoodsReceipts) (err error) {
+	variants := make(map[string]*item.Variant)
+	var order []string
+
+	for _, receipt := range receipts {
+		variant, ok := variants[receipt.VariantID]
+		if !ok {
+			variant, err = svc.itemConn.GetVariant(ctx, &item.GetVariantRequest{
+				VariantId: receipt.VariantID,
+			})
+			if err != nil {
+				return
+			}
+			variants[receipt.VariantID] = variant
+			order = append(order, receipt.VariantID)
+		}
+
+		variant.Cost = domain.WeightedAverageCost(variant.Cost, receipt.OnHandBefore, receipt.UnitCost, receipt.Quantity)
+	}
+
+	for _, id := range order {
+		if _, err = svc.itemConn.UpdateVariant(ctx, variants[id]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func purchaseOrderError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.InvalidArgument, "purchase order not found")
+	case errors.Is(err, domain.ErrUnknownPurchaseOrderLine):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrInvalidPurchaseOrderStatus),
+		errors.Is(err, domain.ErrOverReceipt):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return movementError(err)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecipeService manages the recipes of menu variants and consumes their
+// ingredients from stock when orders are fulfilled.
+type RecipeService struct {
+	itemConn            item.ServiceClient
+	inventoryRepository domain.IInventoryItemRepository
+	recipeRepository    domain.IRecipeRepository
+}
+
+func NewRecipeService(
+	itemConn item.ServiceClient,
+	inventoryRepository domain.IInventoryItemRepository,
+	recipeRepository domain.IRecipeRepository,
+) *RecipeService {
+	return &RecipeService{
+		itemConn:            itemConn,
+		inventoryRepository: inventoryRepository,
+		recipeRepository:    recipeRepository,
+	}
+}
+
+func (svc *RecipeService) GetRecipe(ctx context.Context, variantID string) (domain.RecipeLines, error) {
+	ctx, span := tracer.Start(ctx, "GetRecipe")
+	defer span.End()
+
+	lines, err := svc.recipeRepository.Find(ctx, variantID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return lines, nil
+}
+
+// SetRecipe replaces the recipe of a menu variant. Ingredients must be
+// variants of physical items. An empty recipe removes it.
+func (svc *RecipeService) SetRecipe(ctx context.Context, organizationID, variantID string, lines domain.RecipeLines) (domain.RecipeLines, error) {
+	ctx, span := tracer.Start(ctx, "SetRecipe")
+	defer span.End()
+
+	if err := svc.checkItemType(ctx, variantID, item.Type_menu); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(lines))
+	newLines := make(domain.RecipeLines, 0, len(lines))
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		if seen[line.IngredientItemID] {
+			return nil, status.Errorf(codes.InvalidArgument, "ingredient %s is listed twice", line.IngredientItemID)
+		}
+		seen[line.IngredientItemID] = true
+
+		if err := svc.checkItemType(ctx, line.IngredientItemID, item.Type_physical); err != nil {
+			return nil, err
+		}
+
+		newLines = append(newLines, domain.RecipeLine{
+			OrganizationID:   organizationID,
+			VariantID:        variantID,
+			IngredientItemID: line.IngredientItemID,
+			Quantity:         line.Quantity,
+		})
+	}
+
+	result, err := svc.recipeRepository.Replace(ctx, variantID, newLines)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return result, nil
+}
+
+// Portions returns how many units of a menu variant can be made at a
+// location from the available stock of its ingredients.
+func (svc *RecipeService) Portions(ctx context.Context, variantID, locationID string) (int32, error) {
+	ctx, span := tracer.Start(ctx, "Portions")
+	defer span.End()
+
+	lines, err := svc.GetRecipe(ctx, variantID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(lines) == 0 {
+		return 0, status.Error(codes.FailedPrecondition, "variant has no recipe")
+	}
+
+	portions := int32(math.MaxInt32)
+	for _, line := range lines {
+		inv, err := svc.inventoryRepository.FindOne(ctx, domain.InventoryItem{
+			LocationID: locationID,
+			ItemID:     line.IngredientItemID,
+		})
+		if err != nil {
+			return 0, status.Error(codes.Internal, err.Error())
+		}
+
+		if inv == nil || inv.Available() <= 0 {
+			return 0, nil
+		}
+
+		portions = min(portions, inv.Available()/line.Quantity)
+	}
+
+	return portions, nil
+}
+
+// ConsumeOrder books the sale of a fulfilled order at the location it was
+// fulfilled from: the stock its lines reserved and the recipe ingredients
+// of its menu items. payload is the JSON encoded domain.OrderFulfilledEvent.
+func (svc *RecipeService) ConsumeOrder(ctx context.Context, payload string) error {
+	ctx, span := tracer.Start(ctx, "ConsumeOrder")
+	defer span.End()
+
+	var event domain.OrderFulfilledEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return err
+	}
+
+	sold := make(map[string]int32)
+	quantities := make(map[string]int32, len(event.Items))
+	variantIDs := make([]string, 0, len(event.Items))
+	for _, line := range event.Items {
+		if line.InventoryItemID != "" && line.ReservedQuantity > 0 {
+			sold[line.InventoryItemID] += line.ReservedQuantity
+		}
+
+		if _, ok := quantities[line.VariantID]; !ok {
+			variantIDs = append(variantIDs, line.VariantID)
+		}
+		quantities[line.VariantID] += line.Quantity
+	}
+
+	lines, err := svc.recipeRepository.Find(ctx, variantIDs...)
+	if err != nil {
+		return err
+	}
+
+	usage := lines.Usage(quantities)
+	if len(usage) == 0 && len(sold) == 0 {
+		return nil
+	}
+
+	if len(usage) > 0 && event.LocationID == "" {
+		return errors.New("order has no location to consume ingredients from")
+	}
+
+	return svc.recipeRepository.Consume(ctx, event.OrderID, event.OrganizationID, event.LocationID, sold, usage)
+}
+
+// checkItemType checks through the item service that variantID belongs to
+// an item of type t.
+func (svc *RecipeService) checkItemType(ctx context.Context, variantID string, t item.Type) error {
+	itemType, err := variantItemType(ctx, svc.itemConn, variantID)
+	if err != nil {
+		return err
+	}
+
+	if itemType != t {
+		return status.Errorf(codes.InvalidArgument, "variant %s isn't a %s item", variantID, t)
+	}
+
+	return nil
+}
+
+// variantItemType looks up the type of the item variantID belongs to.
+func variantItemType(ctx context.Context, itemConn item.ServiceClient, variantID string) (item.Type, error) {
+	variant, err := itemConn.GetVariant(ctx, &item.GetVariantRequest{
+		VariantId: variantID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	product, err := itemConn.GetItem(ctx, &item.GetItemRequest{
+		ItemId: variant.ItemId,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return product.Type, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// StocktakeService runs count sessions: open one for a location, record
+// counts from staff devices, review the variances and post them to the
+// stock ledger.
+type StocktakeService struct {
+	organizationConn    organization.ServiceClient
+	stocktakeRepository domain.IStocktakeRepository
+}
+
+func NewStocktakeService(
+	organizationConn organization.ServiceClient,
+	stocktakeRepository domain.IStocktakeRepository,
+) *StocktakeService {
+	return &StocktakeService{
+		organizationConn:    organizationConn,
+		stocktakeRepository: stocktakeRepository,
+	}
+}
+
+func (svc *StocktakeService) ListStocktake(ctx context.Context, p pagination.Pagination, f domain.Stocktake) (domain.Stocktakes, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListStocktake")
+	defer span.End()
+
+	stocktakes, count, err := svc.stocktakeRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return stocktakes, count, nil
+}
+
+func (svc *StocktakeService) GetStocktake(ctx context.Context, id string) (*domain.Stocktake, error) {
+	ctx, span := tracer.Start(ctx, "GetStocktake")
+	defer span.End()
+
+	stocktake, err := svc.stocktakeRepository.FindOne(ctx, domain.Stocktake{ID: id})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if stocktake == nil {
+		return nil, status.Error(codes.InvalidArgument, "stocktake not found")
+	}
+
+	return stocktake, nil
+}
+
+// OpenStocktake starts a count session for a location of the organization.
+// A location has at most one open stocktake.
+func (svc *StocktakeService) OpenStocktake(ctx context.Context, req domain.Stocktake) (*domain.Stocktake, error) {
+	ctx, span := tracer.Start(ctx, "OpenStocktake")
+	defer span.End()
+
+	if err := validateLocations(ctx, svc.organizationConn, req.OrganizationID, req.LocationID); err != nil {
+		return nil, err
+	}
+
+	stocktake, err := svc.stocktakeRepository.Open(ctx, domain.Stocktake{
+		ID:             uuid.NewString(),
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+		Note:           req.Note,
+	})
+	if err != nil {
+		return nil, stocktakeError(err)
+	}
+
+	return stocktake, nil
+}
+
+// RecordCount saves the quantities counted on one device. Counting an item
+// again from the same device replaces the previous count.
+func (svc *StocktakeService) RecordCount(ctx context.Context, id, deviceID, countedBy string, counts domain.StocktakeCounts) (*domain.Stocktake, error) {
+	ctx, span := tracer.Start(ctx, "RecordCount")
+	defer span.End()
+
+	if deviceID == "" {
+		return nil, status.Error(codes.InvalidArgument, "device_id is required")
+	}
+
+	if len(counts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "counts can't be empty")
+	}
+
+	seen := make(map[string]bool, len(counts))
+	for i, c := range counts {
+		if c.Quantity < 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity can't be negative")
+		}
+
+		if seen[c.InventoryItemID] {
+			return nil, status.Errorf(codes.InvalidArgument, "inventory item %s is counted twice", c.InventoryItemID)
+		}
+		seen[c.InventoryItemID] = true
+
+		counts[i].DeviceID = deviceID
+		counts[i].CountedBy = countedBy
+	}
+
+	if err := svc.stocktakeRepository.Count(ctx, id, counts); err != nil {
+		return nil, stocktakeError(err)
+	}
+
+	return svc.GetStocktake(ctx, id)
+}
+
+// ReviewStocktake lists the counted items whose counted quantity differs from
+// their current quantity.
+func (svc *StocktakeService) ReviewStocktake(ctx context.Context, id string) (domain.StocktakeVariances, error) {
+	ctx, span := tracer.Start(ctx, "ReviewStocktake")
+	defer span.End()
+
+	if _, err := svc.GetStocktake(ctx, id); err != nil {
+		return nil, err
+	}
+
+	variances, err := svc.stocktakeRepository.Variances(ctx, id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return variances, nil
+}
+
+// PostStocktake books every variance as a count correction movement and
+// closes the stocktake. Variances are taken at posting time.
+func (svc *StocktakeService) PostStocktake(ctx context.Context, id string) (*domain.Stocktake, error) {
+	ctx, span := tracer.Start(ctx, "PostStocktake")
+	defer span.End()
+
+	stocktake, err := svc.stocktakeRepository.Post(ctx, id)
+	if err != nil {
+		return nil, stocktakeError(err)
+	}
+
+	return stocktake, nil
+}
+
+func (svc *StocktakeService) CancelStocktake(ctx context.Context, id string) (*domain.Stocktake, error) {
+	ctx, span := tracer.Start(ctx, "CancelStocktake")
+	defer span.End()
+
+	stocktake, err := svc.stocktakeRepository.Cancel(ctx, id)
+	if err != nil {
+		return nil, stocktakeError(err)
+	}
+
+	return stocktake, nil
+}
+
+func stocktakeError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.InvalidArgument, "stocktake not found")
+	case errors.Is(err, domain.ErrItemNotAtLocation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrInvalidStocktakeStatus),
+		errors.Is(err, domain.ErrStocktakeInProgress):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return movementError(err)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/inventory/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxLocations is the page size used when looking up locations.
+const maxLocations = 100
+
+type TransferService struct {
+	organizationConn    organization.ServiceClient
+	inventoryRepository domain.IInventoryItemRepository
+	transferRepository  domain.ITransferRepository
+	linkService         *VariantLinkService
+}
+
+func NewTransferService(
+	organizationConn organization.ServiceClient,
+	inventoryRepository domain.IInventoryItemRepository,
+	transferRepository domain.ITransferRepository,
+	linkService *VariantLinkService,
+) *TransferService {
+	return &TransferService{
+		organizationConn:    organizationConn,
+		inventoryRepository: inventoryRepository,
+		transferRepository:  transferRepository,
+		linkService:         linkService,
+	}
+}
+
+func (svc *TransferService) ListTransfer(ctx context.Context, p pagination.Pagination, f domain.StockTransfer) (domain.StockTransfers, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListTransfer")
+	defer span.End()
+
+	transfers, count, err := svc.transferRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return transfers, count, nil
+}
+
+func (svc *TransferService) GetTransfer(ctx context.Context, id string) (*domain.StockTransfer, error) {
+	ctx, span := tracer.Start(ctx, "GetTransfer")
+	defer span.End()
+
+	transfer, err := svc.transferRepository.FindOne(ctx, domain.StockTransfer{ID: id})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if transfer == nil {
+		return nil, status.Error(codes.InvalidArgument, "transfer not found")
+	}
+
+	return transfer, nil
+}
+
+// CreateTransfer saves a draft transfer. Both locations must belong to the
+// transfer organization and every line must be stocked at the source.
+func (svc *TransferService) CreateTransfer(ctx context.Context, req domain.StockTransfer) (*domain.StockTransfer, error) {
+	ctx, span := tracer.Start(ctx, "CreateTransfer")
+	defer span.End()
+
+	if req.SourceLocationID == req.DestinationLocationID {
+		return nil, status.Error(codes.InvalidArgument, "source and destination location must differ")
+	}
+
+	if len(req.Lines) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "lines can't be empty")
+	}
+
+	if err := validateLocations(ctx, svc.organizationConn, req.OrganizationID, req.SourceLocationID, req.DestinationLocationID); err != nil {
+		return nil, err
+	}
+
+	newTransfer := domain.StockTransfer{
+		ID:                    uuid.NewString(),
+		OrganizationID:        req.OrganizationID,
+		SourceLocationID:      req.SourceLocationID,
+		DestinationLocationID: req.DestinationLocationID,
+		Status:                domain.TransferDraft,
+		Note:                  req.Note,
+	}
+
+	for _, line := range req.Lines {
+		if line.Quantity <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		source, err := svc.inventoryRepository.FindOne(ctx, domain.InventoryItem{
+			OrganizationID: req.OrganizationID,
+			LocationID:     req.SourceLocationID,
+			ItemID:         line.ItemID,
+		})
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if source == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "item %s isn't stocked at source location", line.ItemID)
+		}
+
+		newTransfer.Lines = append(newTransfer.Lines, domain.TransferLine{
+			TransferID:            newTransfer.ID,
+			ItemID:                line.ItemID,
+			SourceInventoryItemID: source.ID,
+			Quantity:              line.Quantity,
+		})
+	}
+
+	transfer, err := svc.transferRepository.Save(ctx, newTransfer)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return transfer, nil
+}
+
+// ShipTransfer debits the source location and puts the transfer in transit.
+func (svc *TransferService) ShipTransfer(ctx context.Context, id string) (*domain.StockTransfer, error) {
+	ctx, span := tracer.Start(ctx, "ShipTransfer")
+	defer span.End()
+
+	transfer, err := svc.transferRepository.Ship(ctx, id)
+	if err != nil {
+		return nil, transferError(err)
+	}
+
+	return transfer, nil
+}
+
+// ReceiveTransfer credits the destination location with the received
+// quantities. It can be called several times for partial receipts.
+func (svc *TransferService) ReceiveTransfer(ctx context.Context, id string, receipts []domain.TransferReceipt) (*domain.StockTransfer, error) {
+	ctx, span := tracer.Start(ctx, "ReceiveTransfer")
+	defer span.End()
+
+	if len(receipts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "receipts can't be empty")
+	}
+
+	for _, receipt := range receipts {
+		if receipt.Quantity <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+	}
+
+	transfer, err := svc.transferRepository.Receive(ctx, id, receipts)
+	if err != nil {
+		return nil, transferError(err)
+	}
+
+	svc.linkService.LinkOrLog(ctx)
+
+	return transfer, nil
+}
+
+// CancelTransfer cancels a transfer that hasn't been shipped yet.
+func (svc *TransferService) CancelTransfer(ctx context.Context, id string) (*domain.StockTransfer, error) {
+	ctx, span := tracer.Start(ctx, "CancelTransfer")
+	defer span.End()
+
+	transfer, err := svc.transferRepository.Cancel(ctx, id)
+	if err != nil {
+		return nil, transferError(err)
+	}
+
+	return transfer, nil
+}
+
+// validateLocations checks through the organization service that every
+// location exists and belongs to organizationID.
+func validateLocations(ctx context.Context, organizationConn organization.ServiceClient, organizationID string, locationIDs ...string) error {
+	locations, err := organizationConn.ListLocation(ctx, &organization.ListLocationRequest{
+		OrganizationId: organizationID,
+		LocationIds:    locationIDs,
+		Page:           1,
+		Size:           maxLocations,
+	})
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(locations.Data))
+	for _, loc := range locations.Data {
+		if loc.OrganizationId == organizationID {
+			found[loc.LocationId] = true
+		}
+	}
+
+	for _, id := range locationIDs {
+		if !found[id] {
+			return status.Errorf(codes.InvalidArgument, "location %s doesn't belong to organization", id)
+		}
+	}
+
+	return nil
+}
+
+func transferError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.InvalidArgument, "transfer not found")
+	case errors.Is(err, domain.ErrUnknownTransferLine):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrInvalidTransferStatus),
+		errors.Is(err, domain.ErrOverReceipt):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return movementError(err)
+}
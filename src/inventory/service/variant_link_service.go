@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
+	"github.com/smallbiznis/inventory/domain"
+	"go.uber.org/zap"
+)
+
+// linkBatchSize is the number of unlinked inventory items linked per call.
+const linkBatchSize = 100
+
+// VariantLinkService adds the inventory items the ledger creates when a
+// variant is first stocked at a location, by a receipt, transfer or return,
+// to Variant.InventoryItemIds in the item service. The other services find
+// the stock of a variant through that list.
+type VariantLinkService struct {
+	itemConn            item.ServiceClient
+	inventoryRepository domain.IInventoryItemRepository
+}
+
+func NewVariantLinkService(
+	itemConn item.ServiceClient,
+	inventoryRepository domain.IInventoryItemRepository,
+) *VariantLinkService {
+	return &VariantLinkService{
+		itemConn:            itemConn,
+		inventoryRepository: inventoryRepository,
+	}
+}
+
+// Link links a batch of unlinked inventory items to their variants and
+// returns the number linked. Items stay unlinked until the item service
+// accepted them, so a failed call is retried by the next one.
+func (svc *VariantLinkService) Link(ctx context.Context) (linked int, err error) {
+	ctx, span := tracer.Start(ctx, "LinkVariants")
+	defer span.End()
+
+	items, err := svc.inventoryRepository.FindUnlinked(ctx, linkBatchSize)
+	if err != nil {
+		return
+	}
+
+	byVariant := make(map[string]domain.InventoryItems)
+	var variants []string
+	for _, inv := range items {
+		if _, ok := byVariant[inv.ItemID]; !ok {
+			variants = append(variants, inv.ItemID)
+		}
+		byVariant[inv.ItemID] = append(byVariant[inv.ItemID], inv)
+	}
+
+	for _, variantID := range variants {
+		if err = svc.linkVariant(ctx, variantID, byVariant[variantID]); err != nil {
+			return
+		}
+		linked += len(byVariant[variantID])
+	}
+
+	return
+}
+
+// LinkOrLog links unlinked inventory items after a stock change that may
+// have created some. The change is already committed, so a failure is only
+// logged and left to the periodic Link.
+func (svc *VariantLinkService) LinkOrLog(ctx context.Context) {
+	if _, err := svc.Link(ctx); err != nil {
+		zap.L().Error("failed link inventory items to variants", zap.Error(err))
+	}
+}
+
+func (svc *VariantLinkService) linkVariant(ctx context.Context, variantID string, items domain.InventoryItems) error {
+	variant, err := svc.itemConn.GetVariant(ctx, &item.GetVariantRequest{
+		VariantId: variantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	linked := make(map[string]bool, len(variant.Inventories))
+	for _, inv := range variant.Inventories {
+		linked[inv.InventoryItemId] = true
+	}
+
+	var ids []string
+	var missing bool
+	for _, inv := range items {
+		ids = append(ids, inv.ID)
+		if linked[inv.ID] {
+			continue
+		}
+
+		missing = true
+		variant.Inventories = append(variant.Inventories, &inventory.Inventory{
+			InventoryItemId: inv.ID,
+			OrganizationId:  inv.OrganizationID,
+			LocationId:      inv.LocationID,
+			ItemId:          inv.ItemID,
+		})
+	}
+
+	if missing {
+		if _, err = svc.itemConn.UpdateVariant(ctx, variant); err != nil {
+			return err
+		}
+	}
+
+	return svc.inventoryRepository.MarkLinked(ctx, ids...)
+}
@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/item/domain"
+	"google.golang.org/grpc"
+)
+
+// OrganizationConn is the connection to the organization service, for its
+// rpc.Services.
+type OrganizationConn grpc.ClientConnInterface
+
+// currencyServiceName is the organization service serving the currencies
+// of organizations.
+const currencyServiceName = "smallbiznis.organization.v1.CurrencyService"
+
+// ListCurrency returns the base and selling currencies of an organization
+// from the organization service.
+func ListCurrency(ctx context.Context, organizationConn OrganizationConn, organizationID string) (domain.OrganizationCurrencies, error) {
+	req := struct {
+		OrganizationID string `json:"organization_id"`
+	}{
+		OrganizationID: organizationID,
+	}
+
+	var resp struct {
+		Data domain.OrganizationCurrencies `json:"data"`
+	}
+	if err := rpc.Invoke(ctx, organizationConn, currencyServiceName, "ListCurrency", &req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
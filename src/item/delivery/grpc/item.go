@@ -23,29 +23,32 @@ import (
 
 type ItemService struct {
 	item.UnimplementedServiceServer
-	db                *gorm.DB
-	organizationConn  organization.ServiceClient
-	inventoryConn     inventory.ServiceClient
-	optionRepository  domain.IOptionRepository
-	itemRepository    domain.IItemRepository
-	variantRepository domain.IVariantRepository
+	db                  *gorm.DB
+	organizationConn    organization.ServiceClient
+	organizationRPCConn OrganizationConn
+	inventoryConn       inventory.ServiceClient
+	optionRepository    domain.IOptionRepository
+	itemRepository      domain.IItemRepository
+	variantRepository   domain.IVariantRepository
 }
 
 func NewItemService(
 	db *gorm.DB,
 	organizationConn organization.ServiceClient,
+	organizationRPCConn OrganizationConn,
 	inventoryConn inventory.ServiceClient,
 	optionRepository domain.IOptionRepository,
 	itemRepository domain.IItemRepository,
 	variantRepository domain.IVariantRepository,
 ) *ItemService {
 	return &ItemService{
-		db:                db,
-		organizationConn:  organizationConn,
-		inventoryConn:     inventoryConn,
-		optionRepository:  optionRepository,
-		itemRepository:    itemRepository,
-		variantRepository: variantRepository,
+		db:                  db,
+		organizationConn:    organizationConn,
+		organizationRPCConn: organizationRPCConn,
+		inventoryConn:       inventoryConn,
+		optionRepository:    optionRepository,
+		itemRepository:      itemRepository,
+		variantRepository:   variantRepository,
 	}
 }
 
@@ -99,10 +102,10 @@ func (svc *ItemService) ListItem(ctx context.Context, req *item.ListItemRequest)
 				Barcode:        v.Barcode,
 				Title:          v.Title,
 				Taxable:        v.Taxable,
-				Price:          v.Price,
-				CompareAtPrice: v.CompareAtPrice,
-				Cost:           v.Cost,
-				Profit:         v.Profit,
+				Price:          v.Price.Float32(v.Currency),
+				CompareAtPrice: v.CompareAtPrice.Float32(v.Currency),
+				Cost:           v.Cost.Float32(v.Currency),
+				Profit:         v.Profit.Float32(v.Currency),
 				Margin:         v.Margin,
 				Weight:         v.Weight,
 				WeightUnit:     item.WeightUnit(item.WeightUnit_value[v.WeightUnit]),
@@ -175,10 +178,10 @@ func (svc *ItemService) GetItem(ctx context.Context, req *item.GetItemRequest) (
 			Barcode:        v.Barcode,
 			Title:          v.Title,
 			Taxable:        v.Taxable,
-			Price:          v.Price,
-			CompareAtPrice: v.CompareAtPrice,
-			Cost:           v.Cost,
-			Profit:         v.Profit,
+			Price:          v.Price.Float32(v.Currency),
+			CompareAtPrice: v.CompareAtPrice.Float32(v.Currency),
+			Cost:           v.Cost.Float32(v.Currency),
+			Profit:         v.Profit.Float32(v.Currency),
 			Margin:         v.Margin,
 			Weight:         v.Weight,
 			WeightUnit:     item.WeightUnit(item.WeightUnit_value[v.WeightUnit]),
@@ -221,6 +224,11 @@ func (svc *ItemService) AddItem(ctx context.Context, req *item.AddItemRequest) (
 		return nil, status.Error(codes.InvalidArgument, "invalid organization")
 	}
 
+	currency, err := svc.baseCurrency(ctx, organization)
+	if err != nil {
+		return nil, err
+	}
+
 	newSlug := slug.Make(req.Title)
 	exist, err := svc.itemRepository.FindOne(ctx, domain.Item{
 		OrganizationID: organization.Id,
@@ -297,11 +305,12 @@ func (svc *ItemService) AddItem(ctx context.Context, req *item.AddItemRequest) (
 					ItemID:         newProduct.ID,
 					Title:          variant.Title,
 					Taxable:        variant.Taxable,
-					Price:          variant.Price,
-					CompareAtPrice: variant.CompareAtPrice,
-					Cost:           variant.Cost,
+					Currency:       currency,
+					Price:          domain.NewMoney(float64(variant.Price), currency),
+					CompareAtPrice: domain.NewMoney(float64(variant.CompareAtPrice), currency),
+					Cost:           domain.NewMoney(float64(variant.Cost), currency),
 					Barcode:        variant.Barcode,
-					Profit:         variant.Profit,
+					Profit:         domain.NewMoney(float64(variant.Profit), currency),
 					Margin:         variant.Margin,
 					Weight:         variant.Weight,
 					WeightUnit:     variant.WeightUnit.String(),
@@ -434,6 +443,11 @@ func (svc *ItemService) UpdateItem(ctx context.Context, req *item.Item) (*item.I
 		return nil, status.Error(codes.InvalidArgument, "invalid organization")
 	}
 
+	currency, err := svc.baseCurrency(ctx, organization)
+	if err != nil {
+		return nil, err
+	}
+
 	exist, err := svc.itemRepository.FindOne(ctx, domain.Item{
 		ID:             req.ItemId,
 		OrganizationID: req.OrganizationId,
@@ -506,11 +520,12 @@ func (svc *ItemService) UpdateItem(ctx context.Context, req *item.Item) (*item.I
 					ItemID:         variant.ItemId,
 					Title:          variant.Title,
 					Taxable:        variant.Taxable,
-					Price:          variant.Price,
-					CompareAtPrice: variant.CompareAtPrice,
-					Cost:           variant.Cost,
+					Currency:       currency,
+					Price:          domain.NewMoney(float64(variant.Price), currency),
+					CompareAtPrice: domain.NewMoney(float64(variant.CompareAtPrice), currency),
+					Cost:           domain.NewMoney(float64(variant.Cost), currency),
 					Barcode:        variant.Barcode,
-					Profit:         variant.Profit,
+					Profit:         domain.NewMoney(float64(variant.Profit), currency),
 					Margin:         variant.Margin,
 					Weight:         variant.Weight,
 					WeightUnit:     variant.WeightUnit.String(),
@@ -557,3 +572,21 @@ func (svc *ItemService) DeleteItem(ctx context.Context, req *item.DeleteItemrequ
 
 	return &emptypb.Empty{}, nil
 }
+
+// baseCurrency is the currency variants of org are priced in: its base
+// currency, which defaults to the currency of its country.
+func (svc *ItemService) baseCurrency(ctx context.Context, org *organization.Organization) (string, error) {
+	currencies, err := ListCurrency(ctx, svc.organizationRPCConn, org.Id)
+	if err != nil {
+		return "", err
+	}
+
+	if base := currencies.Base(); base != nil {
+		return base.Currency, nil
+	}
+
+	if org.Country != nil && org.Country.CurrencyCode != "" {
+		return org.Country.CurrencyCode, nil
+	}
+	return domain.DefaultCurrency, nil
+}
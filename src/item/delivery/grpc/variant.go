@@ -2,13 +2,16 @@ package grpc
 
 import (
 	"context"
+	"slices"
 
+	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
 	"github.com/smallbiznis/go-lib/pkg/pagination"
 	"github.com/smallbiznis/item/domain"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/gorm/clause"
 )
 
 func (svc *ItemService) ListVariant(ctx context.Context, req *item.ListVariantRequest) (*item.ListVariantResponse, error) {
@@ -52,14 +55,171 @@ func (svc *ItemService) GetVariant(ctx context.Context, req *item.GetVariantRequ
 		return nil, status.Error(codes.InvalidArgument, "variant not found")
 	}
 
-	return variant.ToProto(), nil
+	result := variant.ToProto()
+	for _, id := range variant.InventoryItemIds {
+		inv, err := svc.inventoryConn.GetInventory(ctx, &inventory.GetInventoryRequest{
+			InventoryItemId: id,
+		})
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		result.Inventories = append(result.Inventories, inv)
+	}
+
+	return result, nil
 }
 
 func (svc *ItemService) UpdateVariant(ctx context.Context, req *item.Variant) (*item.Variant, error) {
 	span := trace.SpanFromContext(ctx)
 	defer span.End()
 
-	span.SetName("GetVariant")
+	span.SetName("UpdateVariant")
+
+	exist, err := svc.variantRepository.FindOne(ctx, domain.Variant{
+		ID: req.VariantId,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if exist == nil {
+		return nil, status.Error(codes.InvalidArgument, "variant not found")
+	}
+
+	exist.SKU = req.Sku
+	exist.Title = req.Title
+	exist.Taxable = req.Taxable
+	if exist.Currency == "" {
+		exist.Currency = domain.DefaultCurrency
+	}
+	exist.Price = domain.NewMoney(float64(req.Price), exist.Currency)
+	exist.CompareAtPrice = domain.NewMoney(float64(req.CompareAtPrice), exist.Currency)
+	exist.Cost = domain.NewMoney(float64(req.Cost), exist.Currency)
+	exist.Barcode = req.Barcode
+	exist.Profit = domain.NewMoney(float64(req.Profit), exist.Currency)
+	exist.Margin = req.Margin
+	exist.Weight = req.Weight
+	exist.WeightUnit = req.WeightUnit.String()
+	exist.Attributes = req.Attributes
+
+	// Inventory items the inventory service created for the variant at a
+	// new location are linked by listing them in Inventories.
+	for _, inv := range req.Inventories {
+		if inv.InventoryItemId != "" && !slices.Contains(exist.InventoryItemIds, inv.InventoryItemId) {
+			exist.InventoryItemIds = append(exist.InventoryItemIds, inv.InventoryItemId)
+		}
+	}
+
+	if _, err := svc.variantRepository.Update(ctx, *exist); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return svc.GetVariant(ctx, &item.GetVariantRequest{
+		VariantId: req.VariantId,
+	})
+}
+
+// ListVariantPrice returns the prices of a variant in the selling currencies
+// of its organization.
+func (svc *ItemService) ListVariantPrice(ctx context.Context, variantID string) (domain.VariantPrices, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListVariantPrice")
+
+	var prices domain.VariantPrices
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.VariantPrice{VariantID: variantID}).
+		Order("currency ASC").
+		Find(&prices).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return prices, nil
+}
+
+// SetVariantPrice sets the price of a variant in a selling currency of its
+// organization, instead of converting its base price. In the variant's
+// currency it sets the base price.
+func (svc *ItemService) SetVariantPrice(ctx context.Context, variantID, currency string, price domain.Money) (*domain.VariantPrice, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetVariantPrice")
+
+	variant, err := svc.variantRepository.FindOne(ctx, domain.Variant{
+		ID: variantID,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if variant == nil {
+		return nil, status.Error(codes.InvalidArgument, "variant not found")
+	}
+
+	if price < 0 {
+		return nil, status.Error(codes.InvalidArgument, "price can't be negative")
+	}
+
+	if currency == variant.Currency {
+		if err := svc.db.WithContext(ctx).Model(&domain.Variant{}).
+			Where(&domain.Variant{ID: variant.ID}).
+			Update("price", price).Error; err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		return &domain.VariantPrice{
+			VariantID: variant.ID,
+			Currency:  currency,
+			Price:     price,
+		}, nil
+	}
+
+	currencies, err := ListCurrency(ctx, svc.organizationRPCConn, variant.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(currencies, func(c domain.OrganizationCurrency) bool { return !c.Base && c.Currency == currency }) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s isn't a selling currency of the organization", currency)
+	}
+
+	variantPrice := domain.VariantPrice{
+		VariantID: variant.ID,
+		Currency:  currency,
+		Price:     price,
+	}
+
+	if err := svc.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "variant_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+	}).Create(&variantPrice).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &variantPrice, nil
+}
+
+// RemoveVariantPrice goes back to converting the base price of a variant in
+// currency.
+func (svc *ItemService) RemoveVariantPrice(ctx context.Context, variantID, currency string) error {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveVariantPrice")
+
+	result := svc.db.WithContext(ctx).
+		Where(&domain.VariantPrice{VariantID: variantID, Currency: currency}).
+		Delete(&domain.VariantPrice{})
+	if result.Error != nil {
+		return status.Error(codes.Internal, result.Error.Error())
+	}
+
+	if result.RowsAffected == 0 {
+		return status.Error(codes.InvalidArgument, "variant price not found")
+	}
 
-	return nil, status.Error(codes.Unimplemented, "Unimplemented")
+	return nil
 }
@@ -0,0 +1,129 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/item/domain"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// VariantPriceService serves the prices of variants as an rpc.Service, in
+// the minor unit of their currency, for the services selling them.
+type VariantPriceService struct {
+	itemService *ItemService
+}
+
+func NewVariantPriceService(itemService *ItemService) *VariantPriceService {
+	return &VariantPriceService{
+		itemService: itemService,
+	}
+}
+
+// Service returns the variant price methods as smallbiznis.item.v1.VariantPriceService.
+func (svc *VariantPriceService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.item.v1.VariantPriceService")
+	rpc.Query(s, "GetVariantPrice", svc.GetVariantPrice)
+	rpc.Query(s, "ListVariantPrice", svc.ListVariantPrice)
+	rpc.Command(s, "SetVariantPrice", svc.SetVariantPrice)
+	rpc.Command(s, "RemoveVariantPrice", svc.RemoveVariantPrice)
+	return s
+}
+
+type VariantPriceRequest struct {
+	VariantID string       `json:"variant_id"`
+	Currency  string       `json:"currency"`
+	Price     domain.Money `json:"price"`
+}
+
+// VariantPriceResponse is the base price of a variant, and its price in the
+// requested currency when one was set. Without one, the base price is
+// converted at the exchange rate of the sale.
+type VariantPriceResponse struct {
+	VariantID      string        `json:"variant_id"`
+	OrganizationID string        `json:"organization_id"`
+	Taxable        bool          `json:"taxable"`
+	BaseCurrency   string        `json:"base_currency"`
+	BasePrice      domain.Money  `json:"base_price"`
+	Currency       string        `json:"currency"`
+	Price          *domain.Money `json:"price"`
+}
+
+func (svc *VariantPriceService) GetVariantPrice(ctx context.Context, req *VariantPriceRequest) (*VariantPriceResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetVariantPrice")
+
+	if req.VariantID == "" {
+		return nil, status.Error(codes.InvalidArgument, "variant_id is required")
+	}
+
+	variant, err := svc.itemService.variantRepository.FindOne(ctx, domain.Variant{
+		ID: req.VariantID,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if variant == nil {
+		return nil, status.Error(codes.InvalidArgument, "variant not found")
+	}
+
+	resp := VariantPriceResponse{
+		VariantID:      variant.ID,
+		OrganizationID: variant.OrganizationID,
+		Taxable:        variant.Taxable,
+		BaseCurrency:   variant.Currency,
+		BasePrice:      variant.Price,
+		Currency:       req.Currency,
+	}
+
+	if req.Currency == "" || req.Currency == variant.Currency {
+		resp.Currency = variant.Currency
+		resp.Price = &variant.Price
+		return &resp, nil
+	}
+
+	prices, err := svc.itemService.ListVariantPrice(ctx, variant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range prices {
+		if prices[i].Currency == req.Currency {
+			resp.Price = &prices[i].Price
+		}
+	}
+
+	return &resp, nil
+}
+
+type ListVariantPriceResponse struct {
+	Data domain.VariantPrices `json:"data"`
+}
+
+func (svc *VariantPriceService) ListVariantPrice(ctx context.Context, req *VariantPriceRequest) (*ListVariantPriceResponse, error) {
+	prices, err := svc.itemService.ListVariantPrice(ctx, req.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListVariantPriceResponse{
+		Data: prices,
+	}, nil
+}
+
+func (svc *VariantPriceService) SetVariantPrice(ctx context.Context, req *VariantPriceRequest) (*domain.VariantPrice, error) {
+	return svc.itemService.SetVariantPrice(ctx, req.VariantID, req.Currency, req.Price)
+}
+
+func (svc *VariantPriceService) RemoveVariantPrice(ctx context.Context, req *VariantPriceRequest) (*struct{}, error) {
+	if err := svc.itemService.RemoveVariantPrice(ctx, req.VariantID, req.Currency); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
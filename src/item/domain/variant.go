@@ -11,6 +11,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// Variant is a sellable version of an item. Prices are Money in Currency,
+// the base currency of the organization when the variant was created;
+// prices in its selling currencies are VariantPrices.
 type Variant struct {
 	ID               string         `gorm:"column:variant_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"variant_id"`
 	OrganizationID   string         `gorm:"column:organization_id;type:uuid;" json:"organization_id"`
@@ -19,11 +22,12 @@ type Variant struct {
 	SKU              string         `gorm:"column:sku" json:"sku"`
 	Title            string         `gorm:"column:title" json:"title"`
 	Taxable          bool           `gorm:"column:taxable" json:"taxable"`
-	Price            float32        `gorm:"column:price" json:"price"`
-	CompareAtPrice   float32        `gorm:"column:compare_at_price" json:"compare_at_price"`
-	Cost             float32        `gorm:"column:cost" json:"cost"`
+	Currency         string         `gorm:"column:currency;size:3" json:"currency"`
+	Price            Money          `gorm:"column:price" json:"price"`
+	CompareAtPrice   Money          `gorm:"column:compare_at_price" json:"compare_at_price"`
+	Cost             Money          `gorm:"column:cost" json:"cost"`
 	Barcode          string         `gorm:"column:barcode" json:"barcode"`
-	Profit           float32        `gorm:"column:profit" json:"profit"`
+	Profit           Money          `gorm:"column:profit" json:"profit"`
 	Margin           float32        `gorm:"column:margin" json:"margin"`
 	Weight           float32        `gorm:"column:weight" json:"weight"`
 	WeightUnit       string         `gorm:"column:weight_unit" json:"weight_unit"`
@@ -55,11 +59,11 @@ func (m *Variant) ToProto() *item.Variant {
 		Sku:            m.SKU,
 		Title:          m.Title,
 		Taxable:        m.Taxable,
-		Price:          m.Price,
-		CompareAtPrice: m.CompareAtPrice,
-		Cost:           m.Cost,
+		Price:          m.Price.Float32(m.Currency),
+		CompareAtPrice: m.CompareAtPrice.Float32(m.Currency),
+		Cost:           m.Cost.Float32(m.Currency),
 		Barcode:        m.Barcode,
-		Profit:         m.Profit,
+		Profit:         m.Profit.Float32(m.Currency),
 		Margin:         m.Margin,
 		Weight:         m.Weight,
 		WeightUnit:     item.WeightUnit(item.WeightUnit_value[m.WeightUnit]),
@@ -71,6 +75,32 @@ func (m *Variant) ToProto() *item.Variant {
 
 type Variants []Variant
 
+// VariantPrice is the price of a variant in a selling currency of its
+// organization. Variants without a price in a selling currency are sold at
+// their base price converted at the currency's exchange rate.
+type VariantPrice struct {
+	VariantID string    `gorm:"column:variant_id;type:uuid;primaryKey" json:"variant_id"`
+	Currency  string    `gorm:"column:currency;size:3;primaryKey" json:"currency"`
+	Price     Money     `gorm:"column:price" json:"price"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *VariantPrice) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *VariantPrice) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type VariantPrices []VariantPrice
+
 func (m Variants) ToProto() (data []*item.Variant) {
 	for _, v := range m {
 		data = append(data, v.ToProto())
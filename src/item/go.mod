@@ -8,6 +8,7 @@ require (
 	github.com/gosimple/slug v1.14.0
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.20.0
 	github.com/lib/pq v1.10.9
+	github.com/smallbiznis/common v0.0.0-00010101000000-000000000000
 	github.com/smallbiznis/go-genproto v0.0.0-20241225151014-43e57c0abab3
 	github.com/smallbiznis/go-lib v0.0.0-20241224204217-519b98a9e1e2
 	github.com/uptrace/opentelemetry-go-extra/otelgorm v0.3.1
@@ -53,3 +54,5 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20240814211410-ddb44dafa142 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
+
+replace github.com/smallbiznis/common => ../common
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/rpc"
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
@@ -20,6 +22,7 @@ import (
 	"go.uber.org/fx/fxevent"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
 )
 
 func NewZapLogger() fxevent.Logger {
@@ -37,8 +40,11 @@ func NewHttpServer(mux *runtime.ServeMux) *http.Server {
 	}
 }
 
-func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.ItemService) {
-	item.RegisterServiceServer(srv, svc)
+// RegisterServiceServer registers the service with calls to the methods
+// changing state carrying an idempotency-key header handled at most once.
+func RegisterServiceServer(srv *grpc.Server, db *gorm.DB, svc *grpchandler.ItemService) {
+	srv.RegisterService(idempotency.Wrap(&item.Service_ServiceDesc, idempotency.NewInterceptor(db),
+		idempotency.Methods(&item.Service_ServiceDesc, "AddItem", "UpdateItem", "DeleteItem", "UpdateVariant")...), svc)
 }
 
 func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
@@ -48,6 +54,33 @@ func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
 	return item.RegisterServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
 }
 
+// RPCServices are the services go-genproto has no messages for, served as
+// rpc.Services.
+type RPCServices struct {
+	fx.In
+	VariantPrice *grpchandler.VariantPriceService
+}
+
+// RegisterRPCServices registers the RPC services on the gRPC server and the
+// HTTP gateway, with the same idempotency handling as the item service.
+func RegisterRPCServices(srv *grpc.Server, mux *runtime.ServeMux, db *gorm.DB, services RPCServices) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	for _, svc := range []*rpc.Service{
+		services.VariantPrice.Service(),
+	} {
+		srv.RegisterService(idempotency.Wrap(svc.Desc(), idempotency.NewInterceptor(db), svc.Commands()...), nil)
+
+		if err := svc.RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -70,6 +103,12 @@ func NewOrganizationServiceClient() (organization.ServiceClient, error) {
 	return organization.NewServiceClient(conn), nil
 }
 
+// NewOrganizationConn connects to the organization service, for its
+// rpc.Services.
+func NewOrganizationConn() (grpchandler.OrganizationConn, error) {
+	return grpc.NewClient(env.Lookup("ORGANIZATION_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
 func NewInventoryServiceClient() (inventory.ServiceClient, error) {
 	conn, err := grpc.NewClient(env.Lookup("INVENTORY_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -83,19 +122,20 @@ func NewInventoryServiceClient() (inventory.ServiceClient, error) {
 func main() {
 	app := fx.New(
 		fx.Provide(infrastructure.NewGorm, infrastructure.NewElastic),
+		fx.Provide(NewOrganizationServiceClient, NewOrganizationConn, NewInventoryServiceClient),
 		fx.Invoke(Automigrate),
 		otelcol.Resource,
 		otelcol.TraceProvider,
 		server.GrpcServerProvider,
-		fx.Provide(NewOrganizationServiceClient, NewInventoryServiceClient),
 		fx.Provide(
 			repository.NewOptionRepository,
 			repository.NewItemRepository,
 			repository.NewVariantRepository,
 			grpchandler.NewItemService,
+			grpchandler.NewVariantPriceService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
-		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint),
+		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint, RegisterRPCServices),
 		server.GrpcServerInvoke,
 	)
 
@@ -1,16 +1,49 @@
 package main
 
 import (
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/migration"
 	"github.com/smallbiznis/item/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
+	// Prices must be converted before AutoMigrate would cast the decimal
+	// columns to bigint and truncate them.
+	if err := migrateMoney(db); err != nil {
+		return err
+	}
+
 	return db.AutoMigrate(
+		&idempotency.Key{},
 		&domain.Option{},
 		&domain.OptionValue{},
 		&domain.Item{},
 		&domain.ItemOption{},
 		&domain.Variant{},
+		&domain.VariantPrice{},
 	)
 }
+
+// migrateMoney converts the decimal prices stored before Money to minor
+// units of the organization's base currency, once, with the rounding of
+// domain.NewMoney.
+func migrateMoney(db *gorm.DB) error {
+	return migration.Once(db, "item/0001-money", func(tx *gorm.DB) (err error) {
+		if !tx.Migrator().HasTable(&domain.Variant{}) {
+			return
+		}
+
+		if !tx.Migrator().HasColumn(&domain.Variant{}, "Currency") {
+			if err = tx.Migrator().AddColumn(&domain.Variant{}, "Currency"); err != nil {
+				return
+			}
+
+			if err = tx.Exec("UPDATE variants SET currency = " + migration.BaseCurrency(tx, "variants")).Error; err != nil {
+				return
+			}
+		}
+
+		return migration.ConvertMoney(tx, "variants", "currency", "price", "compare_at_price", "cost", "profit")
+	})
+}
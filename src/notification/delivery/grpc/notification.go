@@ -1 +1,65 @@
 package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/notification/domain"
+	"github.com/smallbiznis/notification/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NotificationService serves the notifications of store owners as an
+// rpc.Service.
+type NotificationService struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationService(notificationService *service.NotificationService) *NotificationService {
+	return &NotificationService{
+		notificationService: notificationService,
+	}
+}
+
+// Service returns the notification methods as smallbiznis.notification.v1.NotificationService.
+func (svc *NotificationService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.notification.v1.NotificationService")
+	rpc.Query(s, "ListNotification", svc.ListNotification)
+	return s
+}
+
+type ListNotificationRequest struct {
+	OrganizationID string                  `json:"organization_id"`
+	Type           domain.NotificationType `json:"type"`
+	Page           int32                   `json:"page"`
+	Size           int32                   `json:"size"`
+}
+
+type ListNotificationResponse struct {
+	TotalData int32                `json:"total_data"`
+	Data      domain.Notifications `json:"data"`
+}
+
+func (svc *NotificationService) ListNotification(ctx context.Context, req *ListNotificationRequest) (*ListNotificationResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListNotification")
+
+	notifications, count, err := svc.notificationService.ListNotification(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.Notification{
+		OrganizationID: req.OrganizationID,
+		Type:           req.Type,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListNotificationResponse{
+		TotalData: int32(count),
+		Data:      notifications,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// LowStockChannel is the Postgres NOTIFY channel the inventory service
+// publishes low-stock events on.
+const LowStockChannel = "inventory_low_stock"
+
+// LowStockEvent is the payload of a low-stock notification published by the
+// inventory service.
+type LowStockEvent struct {
+	ID              string    `json:"low_stock_event_id"`
+	OrganizationID  string    `json:"organization_id"`
+	LocationID      string    `json:"location_id"`
+	InventoryItemID string    `json:"inventory_item_id"`
+	ItemID          string    `json:"item_id"`
+	Available       int32     `json:"available"`
+	ReorderLevel    int32     `json:"reorder_level"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// AlertServiceName is the inventory service serving low-stock events.
+const AlertServiceName = "smallbiznis.inventory.v1.AlertService"
+
+// LowStockEventRequest pages through the low-stock events of the inventory
+// service: the first page starts at CreatedAt, the next ones after the
+// CreatedAt and ID of the last event of the previous page.
+type LowStockEventRequest struct {
+	CreatedAt time.Time `json:"created_at"`
+	AfterID   string    `json:"after_id,omitempty"`
+	Size      int32     `json:"size"`
+}
+
+type LowStockEventPage struct {
+	Data []LowStockEvent `json:"data"`
+}
@@ -1 +1,49 @@
 package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+type NotificationType string
+
+var (
+	LowStock NotificationType = "low_stock"
+)
+
+type Notification struct {
+	ID             string           `gorm:"column:notification_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"notification_id"`
+	OrganizationID string           `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	Type           NotificationType `gorm:"column:type" json:"type"`
+	Title          string           `gorm:"column:title" json:"title"`
+	Body           string           `gorm:"column:body" json:"body"`
+	ReferenceID    string           `gorm:"column:reference_id" json:"reference_id"`
+	ReadAt         *time.Time       `gorm:"column:read_at" json:"read_at"`
+	CreatedAt      time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time        `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt   `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Notification) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Notification) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type Notifications []Notification
+
+type INotificationRepository interface {
+	Find(context.Context, pagination.Pagination, Notification) (Notifications, int64, error)
+	FindOne(context.Context, Notification) (*Notification, error)
+	Save(context.Context, Notification) (*Notification, error)
+}
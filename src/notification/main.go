@@ -5,14 +5,21 @@ import (
 	"net/http"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/pglisten"
 	"github.com/smallbiznis/go-lib/pkg/env"
 	"github.com/smallbiznis/go-lib/pkg/logger"
 	"github.com/smallbiznis/go-lib/pkg/otelcol"
 	"github.com/smallbiznis/go-lib/pkg/server"
+	grpchandler "github.com/smallbiznis/notification/delivery/grpc"
+	"github.com/smallbiznis/notification/domain"
 	"github.com/smallbiznis/notification/infrastructure"
 	"github.com/smallbiznis/notification/repository"
+	"github.com/smallbiznis/notification/service"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
 )
 
 func NewZapLogger() fxevent.Logger {
@@ -30,16 +37,46 @@ func NewHttpServer(mux *runtime.ServeMux) *http.Server {
 	}
 }
 
-// func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.OrganizationServiceSever) {
-// 	organization.RegisterServiceServer(srv, svc)
-// }
+// RegisterServiceServer registers the notification service, which
+// go-genproto has no messages for, as an rpc.Service.
+func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.NotificationService) {
+	svc.Service().Register(srv)
+}
+
+func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux, svc *grpchandler.NotificationService) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	return svc.Service().RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
+}
+
+func NewInventoryConn() (service.InventoryConn, error) {
+	return grpc.NewClient(env.Lookup("INVENTORY_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+// SubscribeLowStock listens for low-stock events published by the inventory
+// service for as long as the app runs, catching up on the events published
+// while it wasn't listening.
+func SubscribeLowStock(lc fx.Lifecycle, db *gorm.DB, svc *service.NotificationService) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
 
-// func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
-// 	opts := []grpc.DialOption{
-// 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-// 	}
-// 	return organization.RegisterServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
-// }
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go pglisten.Listen(ctx, sqlDB, domain.LowStockChannel, svc.NotifyLowStock, pglisten.CatchUp(svc.CatchUpLowStock))
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
+	return nil
+}
 
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
@@ -61,14 +98,18 @@ func main() {
 		otelcol.Resource,
 		otelcol.TraceProvider,
 		server.GrpcServerProvider,
+		fx.Provide(NewInventoryConn),
 		fx.Provide(
-			repository.NewNotificaionRepository,
+			repository.NewNotificationRepository,
+			service.NewNotificationService,
+			grpchandler.NewNotificationService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
 		fx.Invoke(
-			// RegisterServiceServer,
+			RegisterServiceServer,
 			StartHTTPServer,
-			// RegisterServiceHandlerFromEndpoint,
+			SubscribeLowStock,
+			RegisterServiceHandlerFromEndpoint,
 		),
 		server.GrpcServerInvoke,
 	)
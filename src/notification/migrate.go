@@ -1,11 +1,14 @@
 package main
 
 import (
+	"github.com/smallbiznis/notification/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
-	return db.AutoMigrate()
+	return db.AutoMigrate(
+		&domain.Notification{},
+	)
 }
 
 func Migrate(db *gorm.DB) (err error) {
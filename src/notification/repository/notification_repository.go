@@ -1,3 +1,50 @@
 package repository
 
-func NewNotificaionRepository() {}
+import (
+	"context"
+	"errors"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/notification/domain"
+	"gorm.io/gorm"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) domain.INotificationRepository {
+	return &notificationRepository{db}
+}
+
+func (r *notificationRepository) Find(ctx context.Context, p pagination.Pagination, f domain.Notification) (notifications domain.Notifications, count int64, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.Notification{}).
+		Where(&f).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("created_at DESC").
+		Find(&notifications).Error; err != nil {
+		return
+	}
+
+	return
+}
+
+func (r *notificationRepository) FindOne(ctx context.Context, f domain.Notification) (notification *domain.Notification, err error) {
+	if err = r.db.WithContext(ctx).Model(&domain.Notification{}).Where(&f).First(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return
+	}
+
+	return
+}
+
+func (r *notificationRepository) Save(ctx context.Context, d domain.Notification) (notification *domain.Notification, err error) {
+	if err = r.db.WithContext(ctx).Create(&d).Error; err != nil {
+		return
+	}
+
+	return &d, nil
+}
@@ -1,3 +1,138 @@
 package service
 
-func NewNotificationService() {}
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/notification/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// catchUpMargin is how long before the latest low-stock notification
+// CatchUpLowStock starts, for events emitted while the ones before it were
+// being handled. Without any notification it starts that long ago.
+const catchUpMargin = time.Hour
+
+// lowStockEventPageSize is how many events CatchUpLowStock asks for at once.
+const lowStockEventPageSize = 100
+
+// InventoryConn is the connection to the inventory service.
+type InventoryConn grpc.ClientConnInterface
+
+type NotificationService struct {
+	notificationRepository domain.INotificationRepository
+	inventoryConn          InventoryConn
+}
+
+func NewNotificationService(
+	notificationRepository domain.INotificationRepository,
+	inventoryConn InventoryConn,
+) *NotificationService {
+	return &NotificationService{
+		notificationRepository: notificationRepository,
+		inventoryConn:          inventoryConn,
+	}
+}
+
+func (svc *NotificationService) ListNotification(ctx context.Context, p pagination.Pagination, f domain.Notification) (domain.Notifications, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListNotification")
+	defer span.End()
+
+	if f.OrganizationID == "" {
+		return nil, 0, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	notifications, count, err := svc.notificationRepository.Find(ctx, p, f)
+	if err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return notifications, count, nil
+}
+
+// NotifyLowStock turns a low-stock event published by the inventory service
+// into a notification for the store owner. Events already handled are
+// ignored, so a redelivered payload doesn't notify twice.
+func (svc *NotificationService) NotifyLowStock(ctx context.Context, payload string) error {
+	ctx, span := tracer.Start(ctx, "NotifyLowStock")
+	defer span.End()
+
+	var event domain.LowStockEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return err
+	}
+
+	return svc.notifyLowStock(ctx, event)
+}
+
+// CatchUpLowStock notifies the low-stock events the inventory service
+// emitted while the service wasn't listening, reading them back from the
+// inventory service.
+func (svc *NotificationService) CatchUpLowStock(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "CatchUpLowStock")
+	defer span.End()
+
+	latest, _, err := svc.notificationRepository.Find(ctx, pagination.Pagination{Page: 1, Size: 1}, domain.Notification{
+		Type: domain.LowStock,
+	})
+	if err != nil {
+		return err
+	}
+
+	req := domain.LowStockEventRequest{
+		CreatedAt: time.Now().Add(-catchUpMargin),
+		Size:      lowStockEventPageSize,
+	}
+	if len(latest) > 0 {
+		req.CreatedAt = latest[0].CreatedAt.Add(-catchUpMargin)
+	}
+
+	for {
+		var page domain.LowStockEventPage
+		if err = rpc.Invoke(ctx, svc.inventoryConn, domain.AlertServiceName, "ListLowStockEvent", &req, &page); err != nil {
+			return err
+		}
+
+		for _, event := range page.Data {
+			if err = svc.notifyLowStock(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		if len(page.Data) < lowStockEventPageSize {
+			return nil
+		}
+
+		last := page.Data[len(page.Data)-1]
+		req.CreatedAt, req.AfterID = last.CreatedAt, last.ID
+	}
+}
+
+func (svc *NotificationService) notifyLowStock(ctx context.Context, event domain.LowStockEvent) error {
+	exist, err := svc.notificationRepository.FindOne(ctx, domain.Notification{
+		Type:        domain.LowStock,
+		ReferenceID: event.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if exist != nil {
+		return nil
+	}
+
+	_, err = svc.notificationRepository.Save(ctx, domain.Notification{
+		OrganizationID: event.OrganizationID,
+		Type:           domain.LowStock,
+		Title:          "Low stock",
+		Body:           fmt.Sprintf("Item %s has %d available, below its reorder level of %d.", event.ItemID, event.Available, event.ReorderLevel),
+		ReferenceID:    event.ID,
+	})
+	return err
+}
@@ -0,0 +1,7 @@
+package service
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts the spans of service methods as children of the span of
+// the call they are made in.
+var tracer = otel.Tracer("github.com/smallbiznis/notification/service")
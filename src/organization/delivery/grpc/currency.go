@@ -0,0 +1,253 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/organization/domain"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CurrencyService serves the base and selling currencies of the
+// organizations as an rpc.Service, for the services pricing in them.
+type CurrencyService struct {
+	organizationService *OrganizationServiceSever
+}
+
+func NewCurrencyService(organizationService *OrganizationServiceSever) *CurrencyService {
+	return &CurrencyService{
+		organizationService: organizationService,
+	}
+}
+
+// Service returns the currency methods as smallbiznis.organization.v1.CurrencyService.
+func (svc *CurrencyService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.organization.v1.CurrencyService")
+	rpc.Query(s, "ListCurrency", svc.ListCurrency)
+	rpc.Command(s, "SetBaseCurrency", svc.SetBaseCurrency)
+	rpc.Command(s, "SetExchangeRate", svc.SetExchangeRate)
+	rpc.Command(s, "RemoveCurrency", svc.RemoveCurrency)
+	return s
+}
+
+// CurrencyRequest selects a currency of an organization. ExchangeRate is
+// the value of one unit of Currency in the base currency, with eight
+// decimals: 1 SGD = 12000 IDR is 1200000000000.
+type CurrencyRequest struct {
+	OrganizationID string              `json:"organization_id"`
+	Currency       string              `json:"currency"`
+	ExchangeRate   domain.ExchangeRate `json:"exchange_rate"`
+}
+
+type ListCurrencyResponse struct {
+	Data domain.OrganizationCurrencies `json:"data"`
+}
+
+func (svc *CurrencyService) ListCurrency(ctx context.Context, req *CurrencyRequest) (*ListCurrencyResponse, error) {
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	currencies, err := svc.organizationService.ListCurrency(ctx, req.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListCurrencyResponse{
+		Data: currencies,
+	}, nil
+}
+
+func (svc *CurrencyService) SetBaseCurrency(ctx context.Context, req *CurrencyRequest) (*domain.OrganizationCurrency, error) {
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.organizationService.SetBaseCurrency(ctx, req.OrganizationID, req.Currency)
+}
+
+func (svc *CurrencyService) SetExchangeRate(ctx context.Context, req *CurrencyRequest) (*domain.OrganizationCurrency, error) {
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.organizationService.SetExchangeRate(ctx, req.OrganizationID, req.Currency, req.ExchangeRate)
+}
+
+func (svc *CurrencyService) RemoveCurrency(ctx context.Context, req *CurrencyRequest) (*struct{}, error) {
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if err := svc.organizationService.RemoveCurrency(ctx, req.OrganizationID, req.Currency); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
+
+// ListCurrency returns the base and selling currencies of an organization.
+func (srv *OrganizationServiceSever) ListCurrency(ctx context.Context, organizationID string) (domain.OrganizationCurrencies, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListCurrency")
+
+	var currencies domain.OrganizationCurrencies
+	if err := srv.db.WithContext(ctx).
+		Where(&domain.OrganizationCurrency{OrganizationID: organizationID}).
+		Order("base DESC, currency ASC").
+		Find(&currencies).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return currencies, nil
+}
+
+// SetBaseCurrency changes the base currency of an organization. Exchange
+// rates are relative to the base currency, so the organization must not
+// have selling currencies left.
+func (srv *OrganizationServiceSever) SetBaseCurrency(ctx context.Context, organizationID, currency string) (*domain.OrganizationCurrency, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetBaseCurrency")
+
+	if err := srv.checkCurrency(ctx, currency); err != nil {
+		return nil, err
+	}
+
+	base := domain.OrganizationCurrency{
+		OrganizationID: organizationID,
+		Currency:       currency,
+		Base:           true,
+		ExchangeRate:   domain.BaseRate,
+	}
+
+	if err := srv.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var currencies domain.OrganizationCurrencies
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.OrganizationCurrency{OrganizationID: organizationID}).
+			Find(&currencies).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		for _, c := range currencies {
+			if !c.Base {
+				return status.Error(codes.FailedPrecondition, "remove the selling currencies before changing the base currency")
+			}
+		}
+
+		if err = tx.Where(&domain.OrganizationCurrency{OrganizationID: organizationID}).
+			Delete(&domain.OrganizationCurrency{}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Create(&base).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return &base, nil
+}
+
+// SetExchangeRate adds a selling currency to an organization, or changes its
+// exchange rate: the value of one unit of currency in the base currency.
+// Orders already placed keep the rate they were sold at.
+func (srv *OrganizationServiceSever) SetExchangeRate(ctx context.Context, organizationID, currency string, exchangeRate domain.ExchangeRate) (*domain.OrganizationCurrency, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetExchangeRate")
+
+	if err := srv.checkCurrency(ctx, currency); err != nil {
+		return nil, err
+	}
+
+	if exchangeRate <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "exchange rate must be greater than zero")
+	}
+
+	selling := domain.OrganizationCurrency{
+		OrganizationID: organizationID,
+		Currency:       currency,
+		ExchangeRate:   exchangeRate,
+	}
+
+	if err := srv.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var exist domain.OrganizationCurrency
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.OrganizationCurrency{OrganizationID: organizationID, Currency: currency}).
+			First(&exist).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if err = tx.Create(&selling).Error; err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+				return nil
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if exist.Base {
+			return status.Error(codes.InvalidArgument, "the base currency has no exchange rate")
+		}
+
+		exist.ExchangeRate = exchangeRate
+		if err = tx.Save(&exist).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		selling = exist
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return &selling, nil
+}
+
+// RemoveCurrency stops an organization from selling in currency.
+func (srv *OrganizationServiceSever) RemoveCurrency(ctx context.Context, organizationID, currency string) error {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveCurrency")
+
+	result := srv.db.WithContext(ctx).
+		Where("organization_id = ? AND currency = ? AND NOT base", organizationID, currency).
+		Delete(&domain.OrganizationCurrency{})
+	if result.Error != nil {
+		return status.Error(codes.Internal, result.Error.Error())
+	}
+
+	if result.RowsAffected == 0 {
+		return status.Error(codes.InvalidArgument, "selling currency not found")
+	}
+
+	return nil
+}
+
+// checkCurrency reports whether currency is the currency of a known country.
+func (srv *OrganizationServiceSever) checkCurrency(ctx context.Context, currency string) error {
+	country, err := srv.countryRepo.FindOne(ctx, domain.Countries{
+		CurrencyCode: currency,
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if country == nil {
+		return status.Error(codes.InvalidArgument, domain.ErrUnknownCurrency.Error())
+	}
+
+	return nil
+}
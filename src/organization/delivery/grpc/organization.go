@@ -143,10 +143,15 @@ func (srv *OrganizationServiceSever) CreateOrg(ctx context.Context, req *organiz
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	if country == nil {
+		return nil, status.Error(codes.InvalidArgument, "country not found")
+	}
+
 	newOrg := domain.Organization{
 		ID:             uuid.NewString(),
 		OrganizationID: slug.Make(req.Title),
 		CountryID:      country.CountryCode,
+		Country:        *country,
 		Title:          req.Title,
 		Status:         domain.OrganizationStatus(organization.Organization_ACTIVE.String()),
 	}
@@ -157,7 +162,7 @@ func (srv *OrganizationServiceSever) CreateOrg(ctx context.Context, req *organiz
 			OrganizationId: newOrg.ID,
 			CountryId:      newOrg.CountryID,
 			Type:           organization.TaxType_VAT,
-			Rate:           0.11,
+			Rate:           domain.DefaultVATRate,
 		}); err != nil {
 			zap.L().Error("failed create tax rule", zap.Error(err))
 			return err
@@ -208,6 +213,15 @@ func (srv *OrganizationServiceSever) CreateOrg(ctx context.Context, req *organiz
 			return err
 		}
 
+		if err := tx.Create(&domain.OrganizationCurrency{
+			OrganizationID: newOrg.ID,
+			Currency:       newOrg.Currency(),
+			Base:           true,
+			ExchangeRate:   domain.BaseRate,
+		}).Error; err != nil {
+			return err
+		}
+
 		return
 	}); err != nil {
 		fmt.Printf("failed create organization: %v\n", err)
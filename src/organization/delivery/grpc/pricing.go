@@ -0,0 +1,105 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/organization/domain"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PricingService serves what organizations charge on top of item prices,
+// their tax rules and shipping rates, as an rpc.Service. Rates and prices
+// are exact: Rate and Money in the minor unit of the rate's currency.
+type PricingService struct {
+	taxRepository          domain.ITaxRulesRepository
+	shippingRateRepository domain.IShippingRateRepository
+}
+
+func NewPricingService(
+	taxRepository domain.ITaxRulesRepository,
+	shippingRateRepository domain.IShippingRateRepository,
+) *PricingService {
+	return &PricingService{
+		taxRepository:          taxRepository,
+		shippingRateRepository: shippingRateRepository,
+	}
+}
+
+// Service returns the pricing methods as smallbiznis.organization.v1.PricingService.
+func (svc *PricingService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.organization.v1.PricingService")
+	rpc.Query(s, "ListTaxRule", svc.ListTaxRule)
+	rpc.Query(s, "GetShippingRate", svc.GetShippingRate)
+	return s
+}
+
+type ListTaxRuleRequest struct {
+	OrganizationID string `json:"organization_id"`
+	CountryID      string `json:"country_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListTaxRuleResponse struct {
+	TotalData int32           `json:"total_data"`
+	Data      domain.TaxRules `json:"data"`
+}
+
+func (svc *PricingService) ListTaxRule(ctx context.Context, req *ListTaxRuleRequest) (*ListTaxRuleResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListTaxRule")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	rules, count, err := svc.taxRepository.Find(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, domain.TaxRule{
+		OrganizationID: req.OrganizationID,
+		CountryID:      req.CountryID,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &ListTaxRuleResponse{
+		TotalData: int32(count),
+		Data:      rules,
+	}, nil
+}
+
+type ShippingRateRequest struct {
+	ShippingRateID string `json:"shipping_rate_id"`
+}
+
+func (svc *PricingService) GetShippingRate(ctx context.Context, req *ShippingRateRequest) (*domain.ShippingRate, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetShippingRate")
+
+	if req.ShippingRateID == "" {
+		return nil, status.Error(codes.InvalidArgument, "shipping_rate_id is required")
+	}
+
+	rate, err := svc.shippingRateRepository.FindOne(ctx, domain.ShippingRate{
+		ID: req.ShippingRateID,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if rate == nil {
+		return nil, status.Error(codes.InvalidArgument, "shipping rate not found")
+	}
+
+	return rate, nil
+}
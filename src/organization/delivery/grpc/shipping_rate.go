@@ -49,13 +49,25 @@ func (srv *OrganizationServiceSever) GetShippingRate(ctx context.Context, req *o
 
 func (srv *OrganizationServiceSever) CreateShippingRate(ctx context.Context, req *organization.ShippingRate) (*organization.ShippingRate, error) {
 
+	org, err := srv.organizationRepo.FindOne(ctx, domain.Organization{
+		ID: req.OrganizationId,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if org == nil {
+		return nil, status.Error(codes.InvalidArgument, "organization not found")
+	}
+
 	newShippingRate := domain.ShippingRate{
 		ID:             uuid.NewString(),
 		OrganizationID: req.OrganizationId,
 		Type:           req.Type.String(),
 		Name:           req.Name,
 		Description:    req.Description,
-		Price:          req.Price,
+		Currency:       org.Currency(),
+		Price:          domain.NewMoney(float64(req.Price), org.Currency()),
 	}
 
 	if _, err := srv.shippingRateRepository.Save(ctx, newShippingRate); err != nil {
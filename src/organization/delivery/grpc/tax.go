@@ -57,7 +57,7 @@ func (svc *OrganizationServiceSever) CreateTaxRule(ctx context.Context, req *org
 		OrganizationID: req.OrganizationId,
 		CountryID:      req.CountryId,
 		Type:           req.Type.String(),
-		Rate:           req.Rate,
+		Rate:           domain.NewRate(float64(req.Rate)),
 	})
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -72,7 +72,7 @@ func (svc *OrganizationServiceSever) CreateTaxRule(ctx context.Context, req *org
 		OrganizationID: req.OrganizationId,
 		CountryID:      req.CountryId,
 		Type:           req.Type.String(),
-		Rate:           req.Rate,
+		Rate:           domain.NewRate(float64(req.Rate)),
 	}
 
 	if _, err := svc.taxRepository.Save(ctx, newTax); err != nil {
@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/env"
+	"github.com/smallbiznis/organization/domain"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// TimezoneService serves the timezones the organizations and their
+// locations keep their hours in, such as the daily windows of promotions,
+// as an rpc.Service. A location without a timezone follows its
+// organization, and an organization without one DEFAULT_TIMEZONE.
+type TimezoneService struct {
+	db *gorm.DB
+}
+
+func NewTimezoneService(db *gorm.DB) *TimezoneService {
+	return &TimezoneService{
+		db: db,
+	}
+}
+
+// Service returns the timezone methods as smallbiznis.organization.v1.TimezoneService.
+func (svc *TimezoneService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.organization.v1.TimezoneService")
+	rpc.Query(s, "GetTimezone", svc.GetTimezone)
+	rpc.Command(s, "SetTimezone", svc.SetTimezone)
+	return s
+}
+
+// TimezoneRequest selects a location of an organization, or the
+// organization itself when LocationID is empty.
+type TimezoneRequest struct {
+	OrganizationID string `json:"organization_id"`
+	LocationID     string `json:"location_id"`
+}
+
+type TimezoneResponse struct {
+	Timezone string `json:"timezone"`
+}
+
+func (svc *TimezoneService) GetTimezone(ctx context.Context, req *TimezoneRequest) (*TimezoneResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetTimezone")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if req.LocationID != "" {
+		var loc domain.Location
+		if err := svc.db.WithContext(ctx).
+			Where(&domain.Location{ID: req.LocationID, OrganizationID: req.OrganizationID}).
+			First(&loc).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, status.Error(codes.InvalidArgument, "location not found")
+			}
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if loc.Timezone != "" {
+			return &TimezoneResponse{Timezone: loc.Timezone}, nil
+		}
+	}
+
+	var org domain.Organization
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.Organization{ID: req.OrganizationID}).
+		First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "organization not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if org.Timezone != "" {
+		return &TimezoneResponse{Timezone: org.Timezone}, nil
+	}
+
+	return &TimezoneResponse{Timezone: env.Lookup("DEFAULT_TIMEZONE", "Asia/Jakarta")}, nil
+}
+
+// SetTimezoneRequest sets the IANA timezone, such as Asia/Jakarta, of an
+// organization or one of its locations. An empty Timezone makes a location
+// follow its organization again.
+type SetTimezoneRequest struct {
+	OrganizationID string `json:"organization_id"`
+	LocationID     string `json:"location_id"`
+	Timezone       string `json:"timezone"`
+}
+
+func (svc *TimezoneService) SetTimezone(ctx context.Context, req *SetTimezoneRequest) (*TimezoneResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetTimezone")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if req.Timezone == "" && req.LocationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "timezone is required")
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid timezone %q", req.Timezone)
+	}
+
+	stmt := svc.db.WithContext(ctx).Model(&domain.Organization{}).
+		Where(&domain.Organization{ID: req.OrganizationID})
+	if req.LocationID != "" {
+		stmt = svc.db.WithContext(ctx).Model(&domain.Location{}).
+			Where(&domain.Location{ID: req.LocationID, OrganizationID: req.OrganizationID})
+	}
+
+	result := stmt.Update("timezone", req.Timezone)
+	if result.Error != nil {
+		return nil, status.Error(codes.Internal, result.Error.Error())
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, status.Error(codes.InvalidArgument, "organization or location not found")
+	}
+
+	return svc.GetTimezone(ctx, &TimezoneRequest{
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+	})
+}
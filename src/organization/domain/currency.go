@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+)
+
+// OrganizationCurrency is a currency an organization sells in. One of them
+// is the base currency, the currency prices, promotions, stored value and
+// reports are kept in. The others are selling currencies, whose
+// ExchangeRate, maintained by hand, converts base prices and converts
+// order amounts back to base. The base currency's rate is BaseRate.
+type OrganizationCurrency struct {
+	OrganizationID string       `gorm:"column:organization_id;type:uuid;primaryKey" json:"organization_id"`
+	Currency       string       `gorm:"column:currency;size:3;primaryKey" json:"currency"`
+	Base           bool         `gorm:"column:base" json:"base"`
+	ExchangeRate   ExchangeRate `gorm:"column:exchange_rate" json:"exchange_rate"`
+	CreatedAt      time.Time    `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time    `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *OrganizationCurrency) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *OrganizationCurrency) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type OrganizationCurrencies []OrganizationCurrency
+
+// Base returns the base currency, if any.
+func (m OrganizationCurrencies) Base() *OrganizationCurrency {
+	for i := range m {
+		if m[i].Base {
+			return &m[i]
+		}
+	}
+	return nil
+}
@@ -43,6 +43,7 @@ type Location struct {
 	District       District  `gorm:"foreignKey:DistrictID" json:"-"`
 	Address        string    `gorm:"column:address" json:"address"`
 	PostalCode     string    `gorm:"column:postal_code" json:"postal_code"`
+	Timezone       string    `gorm:"column:timezone" json:"timezone"`
 
 	IsDefault bool           `gorm:"column:is_default"`
 	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
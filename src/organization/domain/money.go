@@ -0,0 +1,25 @@
+package domain
+
+import "github.com/smallbiznis/common/money"
+
+// DefaultCurrency is the currency of amounts whose organization has no
+// country currency.
+const DefaultCurrency = money.DefaultCurrency
+
+// Money, Rate and ExchangeRate are the exact amounts, percentages and
+// exchange rates shared by every service; see package money for their
+// units and rounding.
+type (
+	Money        = money.Money
+	Rate         = money.Rate
+	ExchangeRate = money.ExchangeRate
+)
+
+const BaseRate = money.BaseRate
+
+var (
+	MinorUnits      = money.MinorUnits
+	NewMoney        = money.NewMoney
+	NewRate         = money.NewRate
+	NewExchangeRate = money.NewExchangeRate
+)
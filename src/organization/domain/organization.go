@@ -26,20 +26,22 @@ func (m OrganizationStatus) String() organization.Organization_Status {
 }
 
 type Organization struct {
-	ID                    string             `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	OrganizationID        string             `gorm:"column:organization_id" uri:"organization_id" json:"organization_id"`
-	StripeCustomerID      *string            `gorm:"column:stripe_customer_id" json:"stripe_customer_id"`
-	StripeSubscriptionID  *string            `gorm:"column:stripe_subscription_id" json:"stripe_subscription_id"`
-	StripePaymentMethodID *string            `gorm:"column:stripe_payment_method_id" json:"stripe_payment_method_id"`
-	LogoUrl               string             `gorm:"column:logo_url" json:"logo_url"`
-	Title                 string             `gorm:"column:title" form:"title" json:"title" validate:"required,max=50"`
-	CountryID             string             `gorm:"column:country_id" json:"country_id"`
-	Country               Countries          `gorm:"->;foreignKey:CountryID" json:"country"`
-	IsDefault             bool               `gorm:"column:is_default" json:"-"`
-	Status                OrganizationStatus `gorm:"column:status" json:"status"`
-	CreatedAt             time.Time          `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt             time.Time          `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt             gorm.DeletedAt     `gorm:"column:deleted_at" json:"-"`
+	ID                    string                 `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	OrganizationID        string                 `gorm:"column:organization_id" uri:"organization_id" json:"organization_id"`
+	StripeCustomerID      *string                `gorm:"column:stripe_customer_id" json:"stripe_customer_id"`
+	StripeSubscriptionID  *string                `gorm:"column:stripe_subscription_id" json:"stripe_subscription_id"`
+	StripePaymentMethodID *string                `gorm:"column:stripe_payment_method_id" json:"stripe_payment_method_id"`
+	LogoUrl               string                 `gorm:"column:logo_url" json:"logo_url"`
+	Title                 string                 `gorm:"column:title" form:"title" json:"title" validate:"required,max=50"`
+	CountryID             string                 `gorm:"column:country_id" json:"country_id"`
+	Timezone              string                 `gorm:"column:timezone" json:"timezone"`
+	Country               Countries              `gorm:"->;foreignKey:CountryID" json:"country"`
+	Currencies            OrganizationCurrencies `gorm:"->;foreignKey:OrganizationID" json:"currencies"`
+	IsDefault             bool                   `gorm:"column:is_default" json:"-"`
+	Status                OrganizationStatus     `gorm:"column:status" json:"status"`
+	CreatedAt             time.Time              `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt             time.Time              `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt             gorm.DeletedAt         `gorm:"column:deleted_at" json:"-"`
 }
 
 func (m *Organization) BeforeCreate(tx *gorm.DB) (err error) {
@@ -84,6 +86,19 @@ func (m *Organization) ToProto() *organization.Organization {
 	return org
 }
 
+// Currency returns the base currency of the organization, which defaults to
+// the currency of its country.
+func (m *Organization) Currency() string {
+	if base := m.Currencies.Base(); base != nil {
+		return base.Currency
+	}
+
+	if m.Country.CurrencyCode != "" {
+		return m.Country.CurrencyCode
+	}
+	return DefaultCurrency
+}
+
 type Organizations []Organization
 
 func (m Organizations) ToProto() (data []*organization.Organization) {
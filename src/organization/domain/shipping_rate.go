@@ -9,13 +9,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// ShippingRate is a price charged for shipping orders, in Currency, the
+// base currency of the organization when the rate was created.
 type ShippingRate struct {
 	ID             string         `gorm:"column:shipping_rate_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"shipping_rate_id"`
 	OrganizationID string         `gorm:"column:organization_id" json:"organization_id"`
 	Type           string         `gorm:"column:type" json:"type"`
 	Name           string         `gorm:"column:name" json:"name"`
 	Description    string         `gorm:"column:description" json:"description"`
-	Price          float32        `gorm:"column:price" json:"price"`
+	Currency       string         `gorm:"column:currency;size:3" json:"currency"`
+	Price          Money          `gorm:"column:price" json:"price"`
 	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
@@ -41,7 +44,7 @@ func (m *ShippingRate) ToProto() *organization.ShippingRate {
 		Type:           organization.ShippingRate_RateType(organization.ShippingRate_RateType_value[m.Type]),
 		Name:           m.Name,
 		Description:    m.Description,
-		Price:          m.Price,
+		Price:          m.Price.Float32(m.Currency),
 	}
 }
 
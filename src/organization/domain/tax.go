@@ -10,13 +10,18 @@ import (
 	"gorm.io/gorm"
 )
 
+// DefaultVATRate is the VAT percentage organizations are created with.
+const DefaultVATRate = 11
+
+// TaxRule is a tax charged on the taxable lines of orders, Rate being a
+// percentage: 110000 is 11%.
 type TaxRule struct {
 	ID             string         `gorm:"column:tax_rule_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"tax_rule_id"`
 	OrganizationID string         `gorm:"column:organization_id;type:uuid;" json:"organization_id"`
 	CountryID      string         `gorm:"column:country_id" json:"country_id"`
 	Country        Countries      `gorm:"->;foreignKey:CountryID" json:"country"`
 	Type           string         `gorm:"column:type;" json:"type"`
-	Rate           float32        `gorm:"column:rate;" json:"rate"`
+	Rate           Rate           `gorm:"column:rate;" json:"rate"`
 	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
@@ -40,7 +45,7 @@ func (m *TaxRule) ToProto() *organization.TaxRule {
 		TaxId:          m.ID,
 		OrganizationId: m.OrganizationID,
 		CountryId:      m.CountryID,
-		Rate:           m.Rate,
+		Rate:           m.Rate.Float32(),
 		CreatedAt:      timestamppb.New(m.CreatedAt),
 		UpdatedAt:      timestamppb.New(m.UpdatedAt),
 	}
@@ -6,8 +6,10 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/gosimple/slug v1.14.0
 	github.com/grpc-ecosystem/grpc-gateway/v2 v2.20.0
+	github.com/smallbiznis/common v0.0.0-00010101000000-000000000000
 	github.com/smallbiznis/go-genproto v0.0.0-20241210194725-95b1e9c2f077
 	github.com/smallbiznis/go-lib v0.0.0-20241023032916-1c0aab5351fa
+	github.com/stripe/stripe-go/v80 v80.2.0
 	github.com/uptrace/opentelemetry-go-extra/otelgorm v0.3.1
 	go.opentelemetry.io/otel/trace v1.28.0
 	go.uber.org/fx v1.22.2
@@ -47,7 +49,6 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.2 // indirect
-	github.com/stripe/stripe-go/v80 v80.2.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.12 // indirect
 	github.com/uptrace/opentelemetry-go-extra/otelsql v0.3.1 // indirect
@@ -72,3 +73,5 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240701130421-f6361c86f094 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/smallbiznis/common => ../common
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/rpc"
 	"github.com/smallbiznis/go-genproto/smallbiznis/balance/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
 	"github.com/smallbiznis/go-lib/pkg/env"
@@ -60,6 +61,37 @@ func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
 	return organization.RegisterServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
 }
 
+// RPCServices are the services go-genproto has no messages for, served as
+// rpc.Services.
+type RPCServices struct {
+	fx.In
+	Timezone *grpchandler.TimezoneService
+	Currency *grpchandler.CurrencyService
+	Pricing  *grpchandler.PricingService
+}
+
+// RegisterRPCServices registers the RPC services on the gRPC server and the
+// HTTP gateway.
+func RegisterRPCServices(srv *grpc.Server, mux *runtime.ServeMux, services RPCServices) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	for _, svc := range []*rpc.Service{
+		services.Timezone.Service(),
+		services.Currency.Service(),
+		services.Pricing.Service(),
+	} {
+		svc.Register(srv)
+
+		if err := svc.RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -91,9 +123,12 @@ func main() {
 			repository.NewRulesRepository,
 			repository.NewShippingRateRepository,
 			grpchandler.NewOrganizationServiceServer,
+			grpchandler.NewTimezoneService,
+			grpchandler.NewCurrencyService,
+			grpchandler.NewPricingService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
-		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint),
+		fx.Invoke(RegisterServiceServer, StartHTTPServer, RegisterServiceHandlerFromEndpoint, RegisterRPCServices),
 		server.GrpcServerInvoke,
 	)
 
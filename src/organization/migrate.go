@@ -6,24 +6,94 @@ import (
 	"strings"
 
 	"github.com/gosimple/slug"
+	"github.com/smallbiznis/common/migration"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
 	"github.com/smallbiznis/go-lib/pkg/env"
 	"github.com/smallbiznis/organization/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
+	// Rates and prices must be converted before AutoMigrate would cast the
+	// decimal columns to bigint and truncate them.
+	if err := migrateMoney(db); err != nil {
+		return err
+	}
+
 	return db.AutoMigrate(
 		&domain.Countries{},
 		&domain.Province{},
 		&domain.Regencies{},
 		&domain.District{},
 		&domain.Organization{},
+		&domain.OrganizationCurrency{},
 		&domain.Location{},
 		&domain.TaxRule{},
 		&domain.ShippingRate{},
 	)
 }
 
+// organizationCurrency is the currency of the organization owning a row of
+// table, from its country.
+func organizationCurrency(table string) string {
+	return fmt.Sprintf(`COALESCE((SELECT countries.currency_code FROM organizations
+		JOIN countries ON countries.country_code = organizations.country_id
+		WHERE organizations.id = %s.organization_id), '%s')`, table, domain.DefaultCurrency)
+}
+
+// migrateMoney converts the decimal tax rates and shipping prices stored
+// before Rate and Money, once.
+func migrateMoney(db *gorm.DB) error {
+	if err := migration.Once(db, "organization/0001-tax-rate", migrateTaxRate); err != nil {
+		return err
+	}
+
+	return migration.Once(db, "organization/0002-shipping-rate-money", func(tx *gorm.DB) (err error) {
+		if !tx.Migrator().HasTable(&domain.ShippingRate{}) {
+			return
+		}
+
+		if !tx.Migrator().HasColumn(&domain.ShippingRate{}, "Currency") {
+			if err = tx.Migrator().AddColumn(&domain.ShippingRate{}, "Currency"); err != nil {
+				return
+			}
+
+			if err = tx.Exec("UPDATE shipping_rates SET currency = " + organizationCurrency("shipping_rates")).Error; err != nil {
+				return
+			}
+		}
+
+		return migration.ConvertMoney(tx, "shipping_rates", "currency", "price")
+	})
+}
+
+// legacyVATRate is the VAT organizations were created with while it was
+// seeded as a fraction, 0.11 meaning 11%.
+const legacyVATRate = 0.11
+
+// migrateTaxRate converts the decimal tax rates to Rate. Rates are taken as
+// percentages, except the VAT rules seeded with legacyVATRate, which become
+// DefaultVATRate. Rates already converted are left alone.
+func migrateTaxRate(tx *gorm.DB) error {
+	if len(migration.DecimalColumns(tx, "tax_rules", []string{"rate"})) == 0 {
+		return nil
+	}
+
+	if err := tx.Exec("ALTER TABLE tax_rules ADD COLUMN rate_minor bigint NOT NULL DEFAULT 0").Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`UPDATE tax_rules SET rate_minor = ROUND(CASE
+			WHEN type = ? AND rate::numeric(10, 4) = ? THEN ?
+			ELSE COALESCE(rate, 0)
+		END * ?)`,
+		organization.TaxType_VAT.String(), legacyVATRate, domain.DefaultVATRate, int64(domain.NewRate(1))).Error; err != nil {
+		return err
+	}
+
+	return migration.ReplaceColumns(tx, "tax_rules", []string{"rate"})
+}
+
 func Migrate(db *gorm.DB) (err error) {
 	return db.Transaction(func(tx *gorm.DB) (err error) {
 
@@ -47,8 +117,21 @@ func Migrate(db *gorm.DB) (err error) {
 					}
 				}
 			}
+		}
+
+		// An organization has one base currency.
+		if err = tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_currency_base
+			ON organization_currencies (organization_id) WHERE base`).Error; err != nil {
 			return
 		}
-		return
+
+		// Organizations created before currencies were configurable sell in
+		// the currency of their country.
+		return tx.Exec(`INSERT INTO organization_currencies (organization_id, currency, base, exchange_rate, created_at, updated_at)
+			SELECT o.id, COALESCE(NULLIF(c.currency_code, ''), ?), true, ?, now(), now()
+			FROM organizations o
+			LEFT JOIN countries c ON c.country_code = o.country_id
+			WHERE NOT EXISTS (SELECT 1 FROM organization_currencies oc WHERE oc.organization_id = o.id AND oc.base)`,
+			domain.DefaultCurrency, domain.BaseRate).Error
 	})
 }
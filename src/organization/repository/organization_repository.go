@@ -21,7 +21,7 @@ func NewOrganizationRepository(db *gorm.DB) domain.IOrganizationRepository {
 }
 
 func (r *organizationRepository) Find(ctx context.Context, p pagination.Pagination, f domain.Organization) (orgs domain.Organizations, count int64, err error) {
-	stmt := r.db.WithContext(ctx).Model(&domain.Organization{}).Preload("Country").
+	stmt := r.db.WithContext(ctx).Model(&domain.Organization{}).Preload("Country").Preload("Currencies").
 		Where(&f).
 		Count(&count).
 		Scopes(p.Paginate())
@@ -40,7 +40,7 @@ func (r *organizationRepository) Find(ctx context.Context, p pagination.Paginati
 }
 
 func (r *organizationRepository) FindOne(ctx context.Context, f domain.Organization) (org *domain.Organization, err error) {
-	if err = r.db.WithContext(ctx).Model(&domain.Organization{}).Preload("Country").Where(&f).First(&org).Error; err != nil {
+	if err = r.db.WithContext(ctx).Model(&domain.Organization{}).Preload("Country").Preload("Currencies").Where(&f).First(&org).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
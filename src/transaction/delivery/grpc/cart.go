@@ -0,0 +1,232 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CartService serves the storefront carts, their checkout and the
+// abandoned cart queries as an rpc.Service. Carts are priced again on
+// every change; amounts are in the minor unit of the cart currency.
+type CartService struct {
+	cartService *service.CartService
+}
+
+func NewCartService(cartService *service.CartService) *CartService {
+	return &CartService{
+		cartService: cartService,
+	}
+}
+
+// Service returns the cart methods as smallbiznis.transaction.v1.CartService.
+func (svc *CartService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.CartService")
+	rpc.Query(s, "GetCart", svc.GetCart)
+	rpc.Query(s, "ListAbandonedCart", svc.ListAbandonedCart)
+	rpc.Command(s, "CreateCart", svc.CreateCart)
+	rpc.Command(s, "AddCartLine", svc.AddCartLine)
+	rpc.Command(s, "SetCartLineQuantity", svc.SetCartLineQuantity)
+	rpc.Command(s, "RemoveCartLine", svc.RemoveCartLine)
+	rpc.Command(s, "SetCartCustomer", svc.SetCartCustomer)
+	rpc.Command(s, "SetCartShippingRate", svc.SetCartShippingRate)
+	rpc.Command(s, "ApplyCartCoupon", svc.ApplyCartCoupon)
+	rpc.Command(s, "RemoveCartCoupon", svc.RemoveCartCoupon)
+	rpc.Command(s, "CheckoutCart", svc.CheckoutCart)
+	return s
+}
+
+type CartRequest struct {
+	CartID string `json:"cart_id"`
+}
+
+func (svc *CartService) GetCart(ctx context.Context, req *CartRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetCart")
+
+	return svc.cartService.GetCart(ctx, req.CartID)
+}
+
+// ListAbandonedCartRequest selects the carts of known customers left
+// without change for IdleHours.
+type ListAbandonedCartRequest struct {
+	OrganizationID string `json:"organization_id"`
+	IdleHours      int32  `json:"idle_hours"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListCartResponse struct {
+	TotalData int32        `json:"total_data"`
+	Data      domain.Carts `json:"data"`
+}
+
+func (svc *CartService) ListAbandonedCart(ctx context.Context, req *ListAbandonedCartRequest) (*ListCartResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListAbandonedCart")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if req.IdleHours <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "idle_hours must be greater than zero")
+	}
+
+	carts, count, err := svc.cartService.ListAbandonedCart(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID, time.Now().Add(-time.Duration(req.IdleHours)*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListCartResponse{
+		TotalData: int32(count),
+		Data:      carts,
+	}, nil
+}
+
+type CreateCartRequest struct {
+	OrganizationID string  `json:"organization_id"`
+	LocationID     *string `json:"location_id"`
+	CustomerID     *string `json:"customer_id"`
+	Currency       string  `json:"currency"`
+}
+
+func (svc *CartService) CreateCart(ctx context.Context, req *CreateCartRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateCart")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.cartService.CreateCart(ctx, domain.Cart{
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+		CustomerID:     req.CustomerID,
+		Currency:       req.Currency,
+	})
+}
+
+// CartLineRequest selects a line of a cart by LineID, or the variant to add
+// by VariantID.
+type CartLineRequest struct {
+	CartID    string `json:"cart_id"`
+	LineID    string `json:"line_id"`
+	VariantID string `json:"variant_id"`
+	Quantity  int32  `json:"quantity"`
+}
+
+func (svc *CartService) AddCartLine(ctx context.Context, req *CartLineRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AddCartLine")
+
+	if req.VariantID == "" {
+		return nil, status.Error(codes.InvalidArgument, "variant_id is required")
+	}
+
+	return svc.cartService.AddCartLine(ctx, req.CartID, req.VariantID, req.Quantity)
+}
+
+func (svc *CartService) SetCartLineQuantity(ctx context.Context, req *CartLineRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetCartLineQuantity")
+
+	return svc.cartService.SetCartLineQuantity(ctx, req.CartID, req.LineID, req.Quantity)
+}
+
+func (svc *CartService) RemoveCartLine(ctx context.Context, req *CartLineRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveCartLine")
+
+	return svc.cartService.RemoveCartLine(ctx, req.CartID, req.LineID)
+}
+
+type SetCartCustomerRequest struct {
+	CartID            string `json:"cart_id"`
+	CustomerID        string `json:"customer_id"`
+	BillingAddressID  string `json:"billing_address_id"`
+	ShippingAddressID string `json:"shipping_address_id"`
+}
+
+func (svc *CartService) SetCartCustomer(ctx context.Context, req *SetCartCustomerRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetCartCustomer")
+
+	return svc.cartService.SetCartCustomer(ctx, req.CartID, req.CustomerID, req.BillingAddressID, req.ShippingAddressID)
+}
+
+type SetCartShippingRateRequest struct {
+	CartID         string `json:"cart_id"`
+	ShippingRateID string `json:"shipping_rate_id"`
+}
+
+func (svc *CartService) SetCartShippingRate(ctx context.Context, req *SetCartShippingRateRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SetCartShippingRate")
+
+	return svc.cartService.SetCartShippingRate(ctx, req.CartID, req.ShippingRateID)
+}
+
+type CartCouponRequest struct {
+	CartID string `json:"cart_id"`
+	Code   string `json:"code"`
+}
+
+func (svc *CartService) ApplyCartCoupon(ctx context.Context, req *CartCouponRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ApplyCartCoupon")
+
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	return svc.cartService.ApplyCartCoupon(ctx, req.CartID, req.Code)
+}
+
+func (svc *CartService) RemoveCartCoupon(ctx context.Context, req *CartCouponRequest) (*domain.Cart, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveCartCoupon")
+
+	return svc.cartService.RemoveCartCoupon(ctx, req.CartID, req.Code)
+}
+
+// CheckoutCart places the order of a cart, recorded against the x-user-id
+// metadata.
+func (svc *CartService) CheckoutCart(ctx context.Context, req *CartRequest) (*domain.Order, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CheckoutCart")
+
+	return svc.cartService.CheckoutCart(ctx, req.CartID, actorFromContext(ctx))
+}
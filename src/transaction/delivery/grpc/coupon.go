@@ -0,0 +1,178 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CouponService serves the coupon codes of coupon-only promotions as an
+// rpc.Service.
+type CouponService struct {
+	couponService *service.CouponService
+}
+
+func NewCouponService(couponService *service.CouponService) *CouponService {
+	return &CouponService{
+		couponService: couponService,
+	}
+}
+
+// Service returns the coupon methods as smallbiznis.transaction.v1.CouponService.
+func (svc *CouponService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.CouponService")
+	rpc.Query(s, "ListCoupon", svc.ListCoupon)
+	rpc.Command(s, "CreateCoupon", svc.CreateCoupon)
+	rpc.Command(s, "GenerateCoupons", svc.GenerateCoupons)
+	rpc.Command(s, "DeactivateCoupon", svc.DeactivateCoupon)
+	rpc.Command(s, "ApplyCoupon", svc.ApplyCoupon)
+	return s
+}
+
+type ListCouponRequest struct {
+	OrganizationID string `json:"organization_id"`
+	PromotionID    string `json:"promotion_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListCouponResponse struct {
+	TotalData int32          `json:"total_data"`
+	Data      domain.Coupons `json:"data"`
+}
+
+func (svc *CouponService) ListCoupon(ctx context.Context, req *ListCouponRequest) (*ListCouponResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListCoupon")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	coupons, count, err := svc.couponService.ListCoupon(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID, req.PromotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListCouponResponse{
+		TotalData: int32(count),
+		Data:      coupons,
+	}, nil
+}
+
+// CouponRequest is a coupon of a coupon-only promotion. Zero limits are
+// unlimited.
+type CouponRequest struct {
+	OrganizationID string     `json:"organization_id"`
+	PromotionID    string     `json:"promotion_id"`
+	Code           string     `json:"code"`
+	MaxRedemptions int32      `json:"max_redemptions"`
+	MaxPerCustomer int32      `json:"max_per_customer"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+func (req *CouponRequest) coupon() domain.Coupon {
+	return domain.Coupon{
+		OrganizationID: req.OrganizationID,
+		PromotionID:    req.PromotionID,
+		Code:           req.Code,
+		MaxRedemptions: req.MaxRedemptions,
+		MaxPerCustomer: req.MaxPerCustomer,
+		ExpiresAt:      req.ExpiresAt,
+	}
+}
+
+func (svc *CouponService) CreateCoupon(ctx context.Context, req *CouponRequest) (*domain.Coupon, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateCoupon")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.couponService.CreateCoupon(ctx, req.coupon())
+}
+
+// GenerateCouponsRequest generates Count coupons with random codes starting
+// with Prefix, all taking the promotion, limits and expiry of Coupon.
+type GenerateCouponsRequest struct {
+	Coupon CouponRequest `json:"coupon"`
+	Prefix string        `json:"prefix"`
+	Count  int32         `json:"count"`
+}
+
+type GenerateCouponsResponse struct {
+	Data domain.Coupons `json:"data"`
+}
+
+func (svc *CouponService) GenerateCoupons(ctx context.Context, req *GenerateCouponsRequest) (*GenerateCouponsResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GenerateCoupons")
+
+	if req.Coupon.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	coupons, err := svc.couponService.GenerateCoupons(ctx, req.Coupon.coupon(), req.Prefix, int(req.Count))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GenerateCouponsResponse{
+		Data: coupons,
+	}, nil
+}
+
+type DeactivateCouponRequest struct {
+	OrganizationID string `json:"organization_id"`
+	CouponID       string `json:"coupon_id"`
+}
+
+func (svc *CouponService) DeactivateCoupon(ctx context.Context, req *DeactivateCouponRequest) (*domain.Coupon, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("DeactivateCoupon")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.couponService.DeactivateCoupon(ctx, req.OrganizationID, req.CouponID)
+}
+
+type ApplyCouponRequest struct {
+	OrderID string `json:"order_id"`
+	Code    string `json:"code"`
+}
+
+// ApplyCoupon attaches a coupon code to an order nothing was paid for yet
+// and returns the order priced again.
+func (svc *CouponService) ApplyCoupon(ctx context.Context, req *ApplyCouponRequest) (*domain.Order, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ApplyCoupon")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	return svc.couponService.ApplyCoupon(ctx, req.OrderID, req.Code)
+}
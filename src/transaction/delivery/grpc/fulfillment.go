@@ -0,0 +1,177 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FulfillmentService serves the fulfillments of orders and their shipments
+// as an rpc.Service. Changes are recorded against the x-user-id metadata.
+type FulfillmentService struct {
+	fulfillmentService *service.FulfillmentService
+}
+
+func NewFulfillmentService(fulfillmentService *service.FulfillmentService) *FulfillmentService {
+	return &FulfillmentService{
+		fulfillmentService: fulfillmentService,
+	}
+}
+
+// Service returns the fulfillment methods as smallbiznis.transaction.v1.FulfillmentService.
+func (svc *FulfillmentService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.FulfillmentService")
+	rpc.Query(s, "ListFulfillment", svc.ListFulfillment)
+	rpc.Query(s, "GetFulfillment", svc.GetFulfillment)
+	rpc.Command(s, "CreateFulfillment", svc.CreateFulfillment)
+	rpc.Command(s, "CancelFulfillment", svc.CancelFulfillment)
+	rpc.Command(s, "ShipFulfillment", svc.ShipFulfillment)
+	rpc.Command(s, "AddTrackingEvent", svc.AddTrackingEvent)
+	rpc.Command(s, "RefreshTracking", svc.RefreshTracking)
+	return s
+}
+
+type ListFulfillmentResponse struct {
+	Data domain.OrderFulfillments `json:"data"`
+}
+
+func (svc *FulfillmentService) ListFulfillment(ctx context.Context, req *OrderRequest) (*ListFulfillmentResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListFulfillment")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	fulfillments, err := svc.fulfillmentService.ListFulfillment(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListFulfillmentResponse{
+		Data: fulfillments,
+	}, nil
+}
+
+type FulfillmentRequest struct {
+	FulfillmentID string `json:"fulfillment_id"`
+}
+
+func (svc *FulfillmentService) GetFulfillment(ctx context.Context, req *FulfillmentRequest) (*domain.OrderFulfillment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetFulfillment")
+
+	return svc.fulfillmentService.GetFulfillment(ctx, req.FulfillmentID)
+}
+
+// CreateFulfillmentRequest picks Items of an order at LocationID, or at the
+// order location when it is empty.
+type CreateFulfillmentRequest struct {
+	OrderID    string                   `json:"order_id"`
+	LocationID string                   `json:"location_id"`
+	Items      []FulfillmentItemRequest `json:"items"`
+}
+
+type FulfillmentItemRequest struct {
+	OrderItemID string `json:"order_item_id"`
+	Quantity    int32  `json:"quantity"`
+}
+
+func (svc *FulfillmentService) CreateFulfillment(ctx context.Context, req *CreateFulfillmentRequest) (*domain.OrderFulfillment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateFulfillment")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	var items domain.OrderFulfillmentItems
+	for _, item := range req.Items {
+		items = append(items, domain.OrderFulfillmentItem{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	return svc.fulfillmentService.CreateFulfillment(ctx, domain.OrderFulfillment{
+		OrderID:    req.OrderID,
+		LocationID: req.LocationID,
+		Items:      items,
+	}, actorFromContext(ctx))
+}
+
+func (svc *FulfillmentService) CancelFulfillment(ctx context.Context, req *FulfillmentRequest) (*domain.OrderFulfillment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CancelFulfillment")
+
+	return svc.fulfillmentService.CancelFulfillment(ctx, req.FulfillmentID)
+}
+
+// ShipFulfillmentRequest books the shipment of a fulfillment. Waybill is
+// required by couriers that don't issue waybill numbers, such as manual.
+type ShipFulfillmentRequest struct {
+	FulfillmentID  string `json:"fulfillment_id"`
+	ShippingRateID string `json:"shipping_rate_id"`
+	CourierID      string `json:"courier_id"`
+	Waybill        string `json:"waybill"`
+}
+
+func (svc *FulfillmentService) ShipFulfillment(ctx context.Context, req *ShipFulfillmentRequest) (*domain.OrderFulfillment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ShipFulfillment")
+
+	return svc.fulfillmentService.Ship(ctx, req.FulfillmentID, req.ShippingRateID, req.CourierID, req.Waybill, actorFromContext(ctx))
+}
+
+func (svc *FulfillmentService) AddTrackingEvent(ctx context.Context, req *domain.ShippingHistory) (*domain.ShippingHistory, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AddTrackingEvent")
+
+	if req.OrderShippingID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_shipping_id is required")
+	}
+
+	return svc.fulfillmentService.AddTrackingEvent(ctx, *req, actorFromContext(ctx))
+}
+
+type RefreshTrackingRequest struct {
+	OrderShippingID string `json:"order_shipping_id"`
+}
+
+type RefreshTrackingResponse struct {
+	Data domain.ShippingHistories `json:"data"`
+}
+
+// RefreshTracking pulls the tracking events of a shipment from its courier.
+func (svc *FulfillmentService) RefreshTracking(ctx context.Context, req *RefreshTrackingRequest) (*RefreshTrackingResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RefreshTracking")
+
+	history, err := svc.fulfillmentService.RefreshTracking(ctx, req.OrderShippingID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshTrackingResponse{
+		Data: history,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	actorMetadataKey  = "x-user-id"
+	reasonMetadataKey = "x-reason"
+	systemActor       = "system"
+)
+
+// IdempotentHeaders are the metadata the handlers act on besides the
+// request, which a retry must repeat to get the stored response back.
+var IdempotentHeaders = []string{actorMetadataKey, reasonMetadataKey}
+
+// actorFromContext returns the caller recorded against order changes, taken
+// from the x-user-id metadata (Grpc-Metadata-X-User-Id through the gateway).
+func actorFromContext(ctx context.Context) string {
+	if actor := metadataValue(ctx, actorMetadataKey); actor != "" {
+		return actor
+	}
+	return systemActor
+}
+
+// reasonFromContext returns the optional x-reason metadata explaining a change.
+func reasonFromContext(ctx context.Context) string {
+	return metadataValue(ctx, reasonMetadataKey)
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NumberingService serves the order numbering of organizations as an
+// rpc.Service.
+type NumberingService struct {
+	numberingService *service.NumberingService
+}
+
+func NewNumberingService(numberingService *service.NumberingService) *NumberingService {
+	return &NumberingService{
+		numberingService: numberingService,
+	}
+}
+
+// Service returns the numbering methods as smallbiznis.transaction.v1.NumberingService.
+func (svc *NumberingService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.NumberingService")
+	rpc.Query(s, "GetOrderNumbering", svc.GetOrderNumbering)
+	rpc.Command(s, "ConfigureOrderNumbering", svc.ConfigureOrderNumbering)
+	return s
+}
+
+type OrganizationRequest struct {
+	OrganizationID string `json:"organization_id"`
+}
+
+func (svc *NumberingService) GetOrderNumbering(ctx context.Context, req *OrganizationRequest) (*domain.OrderNumberSequence, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetOrderNumbering")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.numberingService.GetOrderNumbering(ctx, req.OrganizationID)
+}
+
+// ConfigureOrderNumberingRequest sets the prefix, yearly reset and padding
+// of an organization's order numbers.
+type ConfigureOrderNumberingRequest struct {
+	OrganizationID string `json:"organization_id"`
+	Prefix         string `json:"prefix"`
+	ResetYearly    bool   `json:"reset_yearly"`
+	Padding        int32  `json:"padding"`
+}
+
+func (svc *NumberingService) ConfigureOrderNumbering(ctx context.Context, req *ConfigureOrderNumberingRequest) (*domain.OrderNumberSequence, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ConfigureOrderNumbering")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.numberingService.ConfigureOrderNumbering(ctx, domain.OrderNumberSequence{
+		OrganizationID: req.OrganizationID,
+		Prefix:         req.Prefix,
+		ResetYearly:    req.ResetYearly,
+		Padding:        req.Padding,
+	})
+}
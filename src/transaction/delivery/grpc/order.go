@@ -2,16 +2,18 @@ package grpc
 
 import (
 	"context"
+	"strings"
 
-	"github.com/bwmarrin/snowflake"
 	"github.com/google/uuid"
+	"github.com/smallbiznis/common/rpc"
 	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/transaction/v1"
-	"github.com/smallbiznis/go-lib/pkg/pagination"
 	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
@@ -24,6 +26,10 @@ type TransactionService struct {
 	customerConn     customer.CustomerServiceClient
 	inventoryConn    inventory.ServiceClient
 	orderRepository  domain.IOrderRepository
+	pricingService   *service.PricingService
+	orderService     *service.OrderService
+	orderEditService *service.OrderEditService
+	paymentService   *service.PaymentService
 }
 
 func NewTransactionService(
@@ -32,6 +38,10 @@ func NewTransactionService(
 	customerConn customer.CustomerServiceClient,
 	inventoryConn inventory.ServiceClient,
 	orderRepository domain.IOrderRepository,
+	pricingService *service.PricingService,
+	orderService *service.OrderService,
+	orderEditService *service.OrderEditService,
+	paymentService *service.PaymentService,
 ) *TransactionService {
 	return &TransactionService{
 		db:               db,
@@ -39,6 +49,10 @@ func NewTransactionService(
 		customerConn:     customerConn,
 		inventoryConn:    inventoryConn,
 		orderRepository:  orderRepository,
+		pricingService:   pricingService,
+		orderService:     orderService,
+		orderEditService: orderEditService,
+		paymentService:   paymentService,
 	}
 }
 
@@ -48,55 +62,24 @@ func (svc *TransactionService) ListOrder(ctx context.Context, req *transaction.L
 
 	span.SetName("ListOrder")
 
-	f := domain.Order{
+	// ListOrderRequest has no fields for the filters, sort and cursors of
+	// SearchOrder, and ListOrderResponse none for the next cursor.
+	orders, count, _, err := searchOrder(ctx, svc.orderRepository, domain.OrderFilter{
 		OrganizationID: req.OrganizationId,
-	}
-
-	if req.Status != "" {
-		f.Status = req.Status
-	}
-
-	orders, count, err := svc.orderRepository.Find(ctx, pagination.Pagination{
-		Page: int(req.Page),
-		Size: int(req.Size),
-	}, f)
+		Status:         domain.OrderStatus(req.Status),
+	}, req.Page, req.Size)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, err
 	}
 
-	var newOrders []*transaction.Order
-	for _, v := range orders {
-		// cust, err := svc.customerConn.GetCustomer(ctx, &customer.GetCustomerRequest{
-		// 	CustomerId: v.CustomerID,
-		// })
-		// if err != nil {
-		// 	return nil, err
-		// }
-
-		// addr, err := svc.customerConn.GetAddress(ctx, &customer.Address{
-		// 	AddressId:  v.ShippingAddressID,
-		// 	CustomerId: v.CustomerID,
-		// })
-		// if err != nil {
-		// 	return nil, err
-		// }
-
-		newOrders = append(newOrders, &transaction.Order{
-			OrderId:        v.ID,
-			OrderNo:        v.OrderNo,
-			OrganizationId: v.OrganizationID,
-			SubTotal:       v.SubTotal,
-			TaxAmount:      v.TaxAmount,
-			TotalAmount:    v.TotalAmount,
-			OrderItems:     v.OrderItems.ToProto(),
-			CreatedAt:      v.ToProto().CreatedAt,
-			UpdatedAt:      v.ToProto().UpdatedAt,
-		})
+	data, err := orders.ToProto()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
 	return &transaction.ListOrderResponse{
 		TotalData: int32(count),
-		Data:      newOrders,
+		Data:      data,
 	}, nil
 }
 
@@ -122,7 +105,12 @@ func (svc *TransactionService) GetOrder(ctx context.Context, req *transaction.Ge
 		return nil, status.Error(codes.InvalidArgument, "order not found")
 	}
 
-	return order.ToProto(), nil
+	data, err := order.ToProto()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return data, nil
 }
 
 func (svc *TransactionService) CreateOrder(ctx context.Context, req *transaction.CreateOrderRequest) (*transaction.Order, error) {
@@ -131,23 +119,100 @@ func (svc *TransactionService) CreateOrder(ctx context.Context, req *transaction
 
 	span.SetName("CreateOrder")
 
-	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
-		OrganizationId: req.OrganizationId,
+	newOrder := CreateOrderRequest{
+		OrganizationID:    req.OrganizationId,
+		OrderNo:           req.OrderNo,
+		CustomerID:        req.CustomerId,
+		BillingAddressID:  req.BillingAddressId,
+		ShippingAddressID: req.ShippingAddressId,
+	}
+
+	for _, orderItem := range req.OrderItems {
+		newOrder.OrderItems = append(newOrder.OrderItems, CreateOrderLine{
+			VariantID: orderItem.ItemId,
+			Quantity:  orderItem.Quantity,
+		})
+	}
+
+	if req.PaymentProvider != nil {
+		newOrder.PaymentProviderID = req.PaymentProvider.PaymentProviderId
+	}
+
+	orderID, err := svc.createOrder(ctx, newOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.GetOrder(ctx, &transaction.GetOrderRequest{
+		OrderId: orderID,
 	})
+}
+
+// Service returns the order methods go-genproto has no messages for as
+// smallbiznis.transaction.v1.OrderService.
+func (svc *TransactionService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.OrderService")
+	rpc.Command(s, "CreateOrder", svc.PlaceOrder)
+	return s
+}
+
+type CreateOrderLine struct {
+	VariantID string `json:"variant_id"`
+	Quantity  int32  `json:"quantity"`
+}
+
+// CreateOrderRequest is CreateOrder with what go-genproto's
+// CreateOrderRequest has no fields for: the currency the order is
+// requested in, one of the organization's selling currencies or empty for
+// its base currency, and the coupon codes to redeem.
+type CreateOrderRequest struct {
+	OrganizationID    string            `json:"organization_id"`
+	OrderNo           string            `json:"order_no"`
+	CustomerID        string            `json:"customer_id"`
+	BillingAddressID  string            `json:"billing_address_id"`
+	ShippingAddressID string            `json:"shipping_address_id"`
+	OrderItems        []CreateOrderLine `json:"order_items"`
+	PaymentProviderID string            `json:"payment_provider_id"`
+	Currency          string            `json:"currency"`
+	CouponCodes       []string          `json:"coupon_codes"`
+}
+
+// PlaceOrder serves CreateOrder of smallbiznis.transaction.v1.OrderService.
+func (svc *TransactionService) PlaceOrder(ctx context.Context, req *CreateOrderRequest) (*domain.Order, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("PlaceOrder")
+
+	orderID, err := svc.createOrder(ctx, *req)
 	if err != nil {
 		return nil, err
 	}
 
-	node, err := snowflake.NewNode(1)
+	order, err := svc.orderRepository.FindOne(ctx, domain.Order{ID: orderID})
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	return order, nil
+}
+
+// createOrder prices and places an order, reserving its stock, and opens
+// the payment of req.PaymentProviderID when set. It returns the order ID.
+func (svc *TransactionService) createOrder(ctx context.Context, req CreateOrderRequest) (string, error) {
+	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
+		OrganizationId: req.OrganizationID,
+	})
+	if err != nil {
+		return "", err
+	}
+
 	newOrder := domain.Order{
 		ID:             uuid.NewString(),
 		OrganizationID: org.Id,
-		OrderNo:        node.Generate().String(),
-		Status:         transaction.OrderStatus_created.String(),
+		Status:         domain.OrderCreated,
+		Currency:       strings.ToUpper(strings.TrimSpace(req.Currency)),
+		CouponCodes:    req.CouponCodes,
 	}
 
 	if req.OrderNo != "" {
@@ -156,67 +221,87 @@ func (svc *TransactionService) CreateOrder(ctx context.Context, req *transaction
 			OrderNo:        req.OrderNo,
 		})
 		if err != nil {
-			return nil, status.Error(codes.Internal, err.Error())
+			return "", status.Error(codes.Internal, err.Error())
 		}
 
-		if exist == nil {
-			return nil, status.Error(codes.InvalidArgument, "order_no alredy exist")
+		if exist != nil {
+			return "", status.Error(codes.AlreadyExists, "order_no already exist")
 		}
 
 		newOrder.OrderNo = req.OrderNo
 	}
 
-	if req.CustomerId != "" {
-		newOrder.CustomerID = &req.CustomerId
+	if req.CustomerID != "" {
+		newOrder.CustomerID = &req.CustomerID
 	}
 
-	if req.BillingAddressId != "" {
-		newOrder.BillingAddressID = &req.BillingAddressId
+	if req.BillingAddressID != "" {
+		addr, err := svc.snapshotAddress(ctx, req.CustomerID, req.BillingAddressID)
+		if err != nil {
+			return "", err
+		}
+		newOrder.BillingAddressID = &req.BillingAddressID
+		newOrder.BillingAddress = &domain.OrderBillingAddress{OrderAddress: addr}
 	}
 
-	if req.ShippingAddressId != "" {
-		newOrder.ShippingAddressID = &req.ShippingAddressId
+	if req.ShippingAddressID != "" {
+		addr, err := svc.snapshotAddress(ctx, req.CustomerID, req.ShippingAddressID)
+		if err != nil {
+			return "", err
+		}
+		newOrder.ShippingAddressID = &req.ShippingAddressID
+		newOrder.ShippingAddress = &domain.OrderShippingAddress{OrderAddress: addr}
 	}
 
-	for _, orderItems := range req.OrderItems {
-
-		// variant, err := svc.itemConn.GetVariant(ctx, &item.GetVariantRequest{
-		// 	VariantId: orderItems.ItemId,
-		// })
-		// if err != nil {
-		// 	return nil, err
-		// }
-
-		// orderItemPrice := variant.Price * float32(orderItems.Quantity)
-		// if variant.Taxable {
-		// 	taxAmount := orderItemPrice * 0.11
-		// 	newOrder.TaxAmount += taxAmount
-		// 	newOrder.GrandTotal += taxAmount
-		// }
-
-		// newOrder.SubTotal += orderItemPrice
-		// newOrder.GrandTotal += orderItemPrice
+	for _, orderItem := range req.OrderItems {
 		newOrder.OrderItems = append(newOrder.OrderItems, domain.OrderItem{
-			Quantity: orderItems.Quantity,
+			OrderID:   newOrder.ID,
+			VariantID: orderItem.VariantID,
+			Quantity:  orderItem.Quantity,
 		})
 	}
 
-	if req.PaymentProvider != nil {
-		// current := time.Now()
-		// newOrder.Payment = domain.OrderPayment{
-		// 	OrderID:           newOrder.ID,
-		// 	PaymentProviderID: req.PaymentProvider.PaymentProviderId,
-		// 	Status:            transaction.PaymentStatus_Pending,
-		// }
+	if err := svc.pricingService.PriceOrder(ctx, org, &newOrder); err != nil {
+		return "", err
 	}
 
-	if _, err := svc.orderRepository.Save(ctx, newOrder); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	actor := actorFromContext(ctx)
+	if err := svc.orderService.Create(ctx, newOrder, actor); err != nil {
+		return "", err
 	}
 
-	return svc.GetOrder(ctx, &transaction.GetOrderRequest{
-		OrderId: newOrder.ID,
+	if req.PaymentProviderID != "" {
+		if _, err := svc.paymentService.CreateIntent(ctx, domain.OrderPayment{
+			OrderID:           newOrder.ID,
+			PaymentProviderID: req.PaymentProviderID,
+		}, actor); err != nil {
+			// Don't keep stock reserved for an order that can't be paid.
+			if cancelErr := svc.orderService.Transition(ctx, newOrder.ID, domain.OrderCancelled, actor, "payment failed"); cancelErr != nil {
+				zap.L().Error("failed cancel unpaid order", zap.String("order_id", newOrder.ID), zap.Error(cancelErr))
+			}
+			return "", err
+		}
+	}
+
+	return newOrder.ID, nil
+}
+
+// snapshotAddress copies a customer address from the customer service so
+// the order keeps it as it was when placed.
+func (svc *TransactionService) snapshotAddress(ctx context.Context, customerID, addressID string) (domain.OrderAddress, error) {
+	addr, err := svc.customerConn.GetAddress(ctx, &customer.Address{
+		AddressId:  addressID,
+		CustomerId: customerID,
 	})
+	if err != nil {
+		return domain.OrderAddress{}, err
+	}
+
+	if customerID != "" && addr.CustomerId != customerID {
+		return domain.OrderAddress{}, status.Errorf(codes.InvalidArgument, "address %s doesn't belong to customer", addressID)
+	}
+
+	return domain.NewOrderAddress(addr), nil
 }
 
 func (svc *TransactionService) UpdateOrder(ctx context.Context, req *transaction.Order) (*transaction.Order, error) {
@@ -225,5 +310,74 @@ func (svc *TransactionService) UpdateOrder(ctx context.Context, req *transaction
 
 	span.SetName("UpdateOrder")
 
-	return &transaction.Order{}, nil
+	exist, err := svc.orderRepository.FindOne(ctx, domain.Order{
+		ID: req.OrderId,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if exist == nil {
+		return nil, status.Error(codes.InvalidArgument, "order not found")
+	}
+
+	actor, reason := actorFromContext(ctx), reasonFromContext(ctx)
+
+	if edit := orderEdit(exist, req); len(edit.Lines) > 0 || edit.CustomerID != nil ||
+		edit.BillingAddressID != nil || edit.ShippingAddressID != nil {
+		if _, err := svc.orderEditService.EditOrder(ctx, exist.ID, edit, actor, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nothing transitions back to created, so the zero value means the
+	// caller isn't asking for a status change.
+	next := domain.OrderStatus(req.Status.String())
+	if next != domain.OrderCreated && next != exist.Status {
+		if err := svc.orderService.Transition(ctx, exist.ID, next, actor, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc.GetOrder(ctx, &transaction.GetOrderRequest{
+		OrderId: exist.ID,
+	})
+}
+
+// orderEdit is the edit turning order into req. Order items of req replace
+// those of order: items with an ID set its quantity, items without add
+// their variant and items left out are removed. Empty customer and address
+// IDs, and an empty list of order items, leave the order as it is.
+func orderEdit(order *domain.Order, req *transaction.Order) (edit domain.OrderEdit) {
+	if len(req.OrderItems) > 0 {
+		listed := make(map[string]bool, len(req.OrderItems))
+		for _, orderItem := range req.OrderItems {
+			listed[orderItem.OrderItemId] = true
+			edit.Lines = append(edit.Lines, domain.OrderEditLine{
+				OrderItemID: orderItem.OrderItemId,
+				VariantID:   orderItem.ItemId,
+				Quantity:    orderItem.Quantity,
+			})
+		}
+
+		for _, orderItem := range order.OrderItems {
+			if !listed[orderItem.ID] {
+				edit.Lines = append(edit.Lines, domain.OrderEditLine{OrderItemID: orderItem.ID})
+			}
+		}
+	}
+
+	if req.CustomerId != "" {
+		edit.CustomerID = &req.CustomerId
+	}
+
+	if req.BillingAddressId != "" {
+		edit.BillingAddressID = &req.BillingAddressId
+	}
+
+	if req.ShippingAddressId != "" {
+		edit.ShippingAddressID = &req.ShippingAddressId
+	}
+
+	return
 }
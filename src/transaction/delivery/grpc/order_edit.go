@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OrderEditService serves the edits of placed orders and their revision
+// history as an rpc.Service. Edits are recorded against the x-user-id
+// metadata.
+type OrderEditService struct {
+	orderEditService *service.OrderEditService
+}
+
+func NewOrderEditService(orderEditService *service.OrderEditService) *OrderEditService {
+	return &OrderEditService{
+		orderEditService: orderEditService,
+	}
+}
+
+// Service returns the order edit methods as smallbiznis.transaction.v1.OrderEditService.
+func (svc *OrderEditService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.OrderEditService")
+	rpc.Query(s, "ListOrderRevision", svc.ListOrderRevision)
+	rpc.Command(s, "EditOrder", svc.EditOrder)
+	return s
+}
+
+type ListOrderRevisionResponse struct {
+	Data domain.OrderRevisions `json:"data"`
+}
+
+// ListOrderRevision lists the revisions of an order, oldest first.
+func (svc *OrderEditService) ListOrderRevision(ctx context.Context, req *OrderRequest) (*ListOrderRevisionResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListOrderRevision")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	revisions, err := svc.orderEditService.ListOrderRevision(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListOrderRevisionResponse{
+		Data: revisions,
+	}, nil
+}
+
+// EditOrderLine sets the quantity of the order item OrderItemID, removing
+// it at zero, or adds Quantity of VariantID when OrderItemID is empty.
+type EditOrderLine struct {
+	OrderItemID string `json:"order_item_id"`
+	VariantID   string `json:"variant_id"`
+	Quantity    int32  `json:"quantity"`
+}
+
+// EditOrderRequest changes the listed lines of an order, and its customer
+// and addresses when set. Lines left out are kept as they are.
+type EditOrderRequest struct {
+	OrderID           string          `json:"order_id"`
+	Lines             []EditOrderLine `json:"lines"`
+	CustomerID        *string         `json:"customer_id"`
+	BillingAddressID  *string         `json:"billing_address_id"`
+	ShippingAddressID *string         `json:"shipping_address_id"`
+	Reason            string          `json:"reason"`
+}
+
+func (svc *OrderEditService) EditOrder(ctx context.Context, req *EditOrderRequest) (*domain.Order, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("EditOrder")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	edit := domain.OrderEdit{
+		CustomerID:        req.CustomerID,
+		BillingAddressID:  req.BillingAddressID,
+		ShippingAddressID: req.ShippingAddressID,
+	}
+	for _, line := range req.Lines {
+		edit.Lines = append(edit.Lines, domain.OrderEditLine{
+			OrderItemID: line.OrderItemID,
+			VariantID:   line.VariantID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	return svc.orderEditService.EditOrder(ctx, req.OrderID, edit, actorFromContext(ctx), req.Reason)
+}
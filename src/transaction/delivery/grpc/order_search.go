@@ -0,0 +1,214 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OrderSearchService serves the order listing of ListOrder with what
+// go-genproto's ListOrderRequest and Order messages have no fields for, the
+// filters, sort and cursors of the listing and the customers of the
+// orders, as an rpc.Service.
+type OrderSearchService struct {
+	orderRepository domain.IOrderRepository
+	customerConn    service.CustomerConn
+}
+
+func NewOrderSearchService(
+	orderRepository domain.IOrderRepository,
+	customerConn service.CustomerConn,
+) *OrderSearchService {
+	return &OrderSearchService{
+		orderRepository: orderRepository,
+		customerConn:    customerConn,
+	}
+}
+
+// Service returns the order search methods as smallbiznis.transaction.v1.OrderSearchService.
+func (svc *OrderSearchService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.OrderSearchService")
+	rpc.Query(s, "SearchOrder", svc.SearchOrder)
+	return s
+}
+
+// SearchOrderRequest filters and sorts the orders of an organization. Dates
+// are RFC 3339 timestamps or plain dates, totals decimal amounts in
+// Currency, and Sort a column prefixed with "-" to list it in descending
+// order. Cursor is the NextCursor of the previous page.
+type SearchOrderRequest struct {
+	OrganizationID    string `json:"organization_id"`
+	Status            string `json:"status"`
+	CustomerID        string `json:"customer_id"`
+	LocationID        string `json:"location_id"`
+	PaymentStatus     string `json:"payment_status"`
+	FulfillmentStatus string `json:"fulfillment_status"`
+	CreatedFrom       string `json:"created_from"`
+	CreatedTo         string `json:"created_to"`
+	Currency          string `json:"currency"`
+	MinTotal          string `json:"min_total"`
+	MaxTotal          string `json:"max_total"`
+	OrderNoPrefix     string `json:"order_no_prefix"`
+	VariantID         string `json:"variant_id"`
+	Sort              string `json:"sort"`
+	Cursor            string `json:"cursor"`
+	Page              int32  `json:"page"`
+	Size              int32  `json:"size"`
+}
+
+// SearchOrderResponse is a page of orders with their customers. NextCursor
+// is empty on the last page, and TotalData zero when a cursor was given.
+type SearchOrderResponse struct {
+	TotalData  int32         `json:"total_data"`
+	Data       domain.Orders `json:"data"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+func (svc *OrderSearchService) SearchOrder(ctx context.Context, req *SearchOrderRequest) (*SearchOrderResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("SearchOrder")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	f, err := req.filter()
+	if err != nil {
+		return nil, err
+	}
+
+	orders, count, next, err := searchOrder(ctx, svc.orderRepository, f, req.Page, req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = service.WithCustomers(ctx, svc.customerConn, orders); err != nil {
+		return nil, err
+	}
+
+	resp := SearchOrderResponse{
+		TotalData: int32(count),
+		Data:      orders,
+	}
+
+	if next != nil {
+		resp.NextCursor = next.String()
+	}
+
+	return &resp, nil
+}
+
+// filter returns the order filter of the request.
+func (req *SearchOrderRequest) filter() (f domain.OrderFilter, err error) {
+	f.OrganizationID = req.OrganizationID
+	f.Status = domain.OrderStatus(req.Status)
+	f.CustomerID = req.CustomerID
+	f.LocationID = req.LocationID
+	f.OrderNoPrefix = req.OrderNoPrefix
+	f.VariantID = req.VariantID
+	f.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+
+	if req.PaymentStatus != "" {
+		if f.PaymentStatus = domain.OrderPaymentStatus(req.PaymentStatus); f.PaymentStatus.String() == "" {
+			return f, status.Error(codes.InvalidArgument, "invalid payment_status")
+		}
+	}
+
+	if req.FulfillmentStatus != "" {
+		if f.FulfillmentStatus = domain.OrderFulfillmentStatus(req.FulfillmentStatus); f.FulfillmentStatus.String() == "" {
+			return f, status.Error(codes.InvalidArgument, "invalid fulfillment_status")
+		}
+	}
+
+	if f.CreatedFrom, err = parseTime("created_from", req.CreatedFrom); err != nil {
+		return
+	}
+
+	if f.CreatedTo, err = parseTime("created_to", req.CreatedTo); err != nil {
+		return
+	}
+
+	if f.MinTotal, err = parseMoney("min_total", req.MinTotal, f.Currency); err != nil {
+		return
+	}
+
+	if f.MaxTotal, err = parseMoney("max_total", req.MaxTotal, f.Currency); err != nil {
+		return
+	}
+
+	if req.Sort != "" {
+		f.Descending = strings.HasPrefix(req.Sort, "-")
+		if f.Sort = domain.OrderSort(strings.TrimPrefix(req.Sort, "-")); f.Sort.String() == "" {
+			return f, status.Error(codes.InvalidArgument, "invalid sort")
+		}
+	}
+
+	if req.Cursor != "" {
+		if f.After, err = domain.ParseOrderCursor(req.Cursor); err != nil {
+			return f, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	return
+}
+
+// searchOrder lists the orders matching f, and the cursor of the next page
+// when one follows.
+func searchOrder(ctx context.Context, orderRepository domain.IOrderRepository, f domain.OrderFilter, page, size int32) (domain.Orders, int64, *domain.OrderCursor, error) {
+	orders, count, next, err := orderRepository.Search(ctx, pagination.Pagination{
+		Page: int(page),
+		Size: int(size),
+	}, f)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidOrderCursor) {
+			return nil, 0, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, 0, nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return orders, count, next, nil
+}
+
+func parseTime(field, v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, v); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+		}
+	}
+	return &t, nil
+}
+
+// parseMoney parses the decimal amount v of field in currency, which must
+// be given along with it.
+func parseMoney(field, v, currency string) (*domain.Money, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	if currency == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "%s requires currency", field)
+	}
+
+	money, err := domain.ParseMoney(v, currency)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+
+	return &money, nil
+}
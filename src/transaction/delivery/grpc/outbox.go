@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OutboxService serves the pending outbox events to the services consuming
+// them as an rpc.Service.
+type OutboxService struct {
+	outboxService *service.OutboxService
+}
+
+func NewOutboxService(outboxService *service.OutboxService) *OutboxService {
+	return &OutboxService{
+		outboxService: outboxService,
+	}
+}
+
+// Service returns the outbox methods as smallbiznis.transaction.v1.OutboxService.
+func (svc *OutboxService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.OutboxService")
+	rpc.Query(s, "ListPendingEvent", svc.ListPendingEvent)
+	rpc.Command(s, "AckEvent", svc.AckEvent)
+	return s
+}
+
+type ListPendingEventRequest struct {
+	Channel string `json:"channel"`
+	Size    int32  `json:"size"`
+}
+
+type ListPendingEventResponse struct {
+	Data domain.OutboxEvents `json:"data"`
+}
+
+func (svc *OutboxService) ListPendingEvent(ctx context.Context, req *ListPendingEventRequest) (*ListPendingEventResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListPendingEvent")
+
+	events, err := svc.outboxService.ListPending(ctx, req.Channel, int(req.Size))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListPendingEventResponse{
+		Data: events,
+	}, nil
+}
+
+type AckEventRequest struct {
+	EventID string `json:"event_id"`
+}
+
+func (svc *OutboxService) AckEvent(ctx context.Context, req *AckEventRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AckEvent")
+
+	if err := svc.outboxService.Ack(ctx, req.EventID); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
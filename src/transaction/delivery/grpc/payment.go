@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PaymentService serves the payments of orders as an rpc.Service. Changes
+// are recorded against the x-user-id metadata.
+type PaymentService struct {
+	paymentService *service.PaymentService
+	layawayService *service.LayawayService
+}
+
+func NewPaymentService(paymentService *service.PaymentService, layawayService *service.LayawayService) *PaymentService {
+	return &PaymentService{
+		paymentService: paymentService,
+		layawayService: layawayService,
+	}
+}
+
+// Service returns the payment methods as smallbiznis.transaction.v1.PaymentService.
+func (svc *PaymentService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.PaymentService")
+	rpc.Query(s, "ListPayment", svc.ListPayment)
+	rpc.Query(s, "ListOverduePayment", svc.ListOverduePayment)
+	rpc.Query(s, "GetBalance", svc.GetBalance)
+	rpc.Command(s, "CreatePayment", svc.CreatePayment)
+	rpc.Command(s, "PayBalance", svc.PayBalance)
+	rpc.Command(s, "PlaceLayaway", svc.PlaceLayaway)
+	rpc.Command(s, "CapturePayment", svc.CapturePayment)
+	rpc.Command(s, "VoidPayment", svc.VoidPayment)
+	return s
+}
+
+type OrderRequest struct {
+	OrderID string `json:"order_id"`
+}
+
+type ListPaymentResponse struct {
+	Data domain.OrderPayments `json:"data"`
+}
+
+func (svc *PaymentService) ListPayment(ctx context.Context, req *OrderRequest) (*ListPaymentResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListPayment")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	payments, err := svc.paymentService.ListPayment(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListPaymentResponse{
+		Data: payments,
+	}, nil
+}
+
+type ListOverduePaymentRequest struct {
+	OrganizationID string `json:"organization_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListOverduePaymentResponse struct {
+	TotalData int32                `json:"total_data"`
+	Data      domain.OrderPayments `json:"data"`
+}
+
+func (svc *PaymentService) ListOverduePayment(ctx context.Context, req *ListOverduePaymentRequest) (*ListOverduePaymentResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListOverduePayment")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	payments, count, err := svc.paymentService.ListOverduePayment(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListOverduePaymentResponse{
+		TotalData: int32(count),
+		Data:      payments,
+	}, nil
+}
+
+// GetBalance returns the captured, open and outstanding amounts of an order.
+func (svc *PaymentService) GetBalance(ctx context.Context, req *OrderRequest) (*domain.OrderBalance, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetBalance")
+
+	return svc.paymentService.Balance(ctx, req.OrderID)
+}
+
+// CreatePaymentRequest tenders a payment for an order, which can be paid
+// with several, e.g. part cash and part card. Amounts are in the minor unit
+// of the order currency. Gift cards are tendered with their code as
+// ProviderReference.
+type CreatePaymentRequest struct {
+	OrderID           string               `json:"order_id"`
+	PaymentProviderID string               `json:"payment_provider_id"`
+	Method            domain.PaymentMethod `json:"method"`
+	ProviderReference string               `json:"provider_reference"`
+	Amount            domain.Money         `json:"amount"`
+	Tendered          domain.Money         `json:"tendered"`
+	DueDate           *time.Time           `json:"due_date"`
+}
+
+func (req *CreatePaymentRequest) payment() domain.OrderPayment {
+	return domain.OrderPayment{
+		OrderID:           req.OrderID,
+		PaymentProviderID: req.PaymentProviderID,
+		Method:            req.Method,
+		ProviderReference: req.ProviderReference,
+		Amount:            req.Amount,
+		Tendered:          req.Tendered,
+		DueDate:           req.DueDate,
+	}
+}
+
+func (svc *PaymentService) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*domain.OrderPayment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreatePayment")
+
+	if req.Amount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be greater than zero")
+	}
+
+	return svc.paymentService.CreateIntent(ctx, req.payment(), actorFromContext(ctx))
+}
+
+// PayBalance tenders a payment for the outstanding balance of an order, or
+// as much of it as the tendered cash covers. Amount is ignored.
+func (svc *PaymentService) PayBalance(ctx context.Context, req *CreatePaymentRequest) (*domain.OrderPayment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("PayBalance")
+
+	payment := req.payment()
+	payment.Amount = 0
+
+	return svc.paymentService.CreateIntent(ctx, payment, actorFromContext(ctx))
+}
+
+// PlaceLayawayRequest takes Deposit as the first payment of an order whose
+// stock stays reserved until ExpiresAt.
+type PlaceLayawayRequest struct {
+	Deposit   CreatePaymentRequest `json:"deposit"`
+	ExpiresAt time.Time            `json:"expires_at"`
+}
+
+func (svc *PaymentService) PlaceLayaway(ctx context.Context, req *PlaceLayawayRequest) (*domain.OrderPayment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("PlaceLayaway")
+
+	return svc.layawayService.PlaceLayaway(ctx, req.Deposit.payment(), req.ExpiresAt, actorFromContext(ctx))
+}
+
+type PaymentRequest struct {
+	PaymentID string `json:"order_payment_id"`
+}
+
+func (svc *PaymentService) CapturePayment(ctx context.Context, req *PaymentRequest) (*domain.OrderPayment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CapturePayment")
+
+	return svc.paymentService.Capture(ctx, req.PaymentID, actorFromContext(ctx))
+}
+
+func (svc *PaymentService) VoidPayment(ctx context.Context, req *PaymentRequest) (*domain.OrderPayment, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("VoidPayment")
+
+	return svc.paymentService.Void(ctx, req.PaymentID)
+}
@@ -0,0 +1,219 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PromotionService serves the promotions of organizations and the customer
+// groups they target as an rpc.Service.
+type PromotionService struct {
+	promotionService *service.PromotionService
+}
+
+func NewPromotionService(promotionService *service.PromotionService) *PromotionService {
+	return &PromotionService{
+		promotionService: promotionService,
+	}
+}
+
+// Service returns the promotion methods as smallbiznis.transaction.v1.PromotionService.
+func (svc *PromotionService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.PromotionService")
+	rpc.Query(s, "ListPromotion", svc.ListPromotion)
+	rpc.Command(s, "CreatePromotion", svc.CreatePromotion)
+	rpc.Command(s, "DeactivatePromotion", svc.DeactivatePromotion)
+	rpc.Command(s, "CreateCustomerGroup", svc.CreateCustomerGroup)
+	rpc.Command(s, "AddGroupMembers", svc.AddGroupMembers)
+	rpc.Command(s, "RemoveGroupMember", svc.RemoveGroupMember)
+	return s
+}
+
+type ListPromotionRequest struct {
+	OrganizationID string `json:"organization_id"`
+	All            bool   `json:"all"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+type ListPromotionResponse struct {
+	TotalData int32             `json:"total_data"`
+	Data      domain.Promotions `json:"data"`
+}
+
+func (svc *PromotionService) ListPromotion(ctx context.Context, req *ListPromotionRequest) (*ListPromotionResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListPromotion")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	promotions, count, err := svc.promotionService.ListPromotion(ctx, pagination.Pagination{
+		Page: int(req.Page),
+		Size: int(req.Size),
+	}, req.OrganizationID, req.All)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListPromotionResponse{
+		TotalData: int32(count),
+		Data:      promotions,
+	}, nil
+}
+
+// CreatePromotionRequest creates a promotion. Rate is a percentage with
+// four decimals, Amount and MinSubtotal are in the minor unit of the base
+// currency, and DailyStart and DailyEnd are "15:04" wall clock times in the
+// timezone of the location the order is placed at.
+type CreatePromotionRequest struct {
+	OrganizationID  string                `json:"organization_id"`
+	Name            string                `json:"name"`
+	Type            domain.PromotionType  `json:"type"`
+	Scope           domain.PromotionScope `json:"scope"`
+	Rate            domain.Rate           `json:"rate"`
+	Amount          domain.Money          `json:"amount"`
+	BuyQuantity     int32                 `json:"buy_quantity"`
+	GetQuantity     int32                 `json:"get_quantity"`
+	MinSubtotal     domain.Money          `json:"min_subtotal"`
+	StartsAt        *time.Time            `json:"starts_at"`
+	EndsAt          *time.Time            `json:"ends_at"`
+	DailyStart      string                `json:"daily_start"`
+	DailyEnd        string                `json:"daily_end"`
+	CustomerGroupID *string               `json:"customer_group_id"`
+	CouponOnly      bool                  `json:"coupon_only"`
+	VariantIDs      []string              `json:"variant_ids"`
+	Priority        int32                 `json:"priority"`
+}
+
+func (svc *PromotionService) CreatePromotion(ctx context.Context, req *CreatePromotionRequest) (*domain.Promotion, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreatePromotion")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	var variants domain.PromotionVariants
+	for _, id := range req.VariantIDs {
+		variants = append(variants, domain.PromotionVariant{VariantID: id})
+	}
+
+	return svc.promotionService.CreatePromotion(ctx, domain.Promotion{
+		OrganizationID:  req.OrganizationID,
+		Name:            req.Name,
+		Type:            req.Type,
+		Scope:           req.Scope,
+		Rate:            req.Rate,
+		Amount:          req.Amount,
+		BuyQuantity:     req.BuyQuantity,
+		GetQuantity:     req.GetQuantity,
+		MinSubtotal:     req.MinSubtotal,
+		StartsAt:        req.StartsAt,
+		EndsAt:          req.EndsAt,
+		DailyStart:      req.DailyStart,
+		DailyEnd:        req.DailyEnd,
+		CustomerGroupID: req.CustomerGroupID,
+		CouponOnly:      req.CouponOnly,
+		Variants:        variants,
+		Priority:        req.Priority,
+	})
+}
+
+type PromotionRequest struct {
+	OrganizationID string `json:"organization_id"`
+	PromotionID    string `json:"promotion_id"`
+}
+
+func (svc *PromotionService) DeactivatePromotion(ctx context.Context, req *PromotionRequest) (*domain.Promotion, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("DeactivatePromotion")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.promotionService.DeactivatePromotion(ctx, req.OrganizationID, req.PromotionID)
+}
+
+type CreateCustomerGroupRequest struct {
+	OrganizationID string `json:"organization_id"`
+	Name           string `json:"name"`
+}
+
+func (svc *PromotionService) CreateCustomerGroup(ctx context.Context, req *CreateCustomerGroupRequest) (*domain.CustomerGroup, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("CreateCustomerGroup")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.promotionService.CreateCustomerGroup(ctx, domain.CustomerGroup{
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+	})
+}
+
+type GroupMembersRequest struct {
+	OrganizationID  string   `json:"organization_id"`
+	CustomerGroupID string   `json:"customer_group_id"`
+	CustomerIDs     []string `json:"customer_ids"`
+}
+
+func (svc *PromotionService) AddGroupMembers(ctx context.Context, req *GroupMembersRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AddGroupMembers")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if err := svc.promotionService.AddGroupMembers(ctx, req.OrganizationID, req.CustomerGroupID, req.CustomerIDs...); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
+
+type GroupMemberRequest struct {
+	OrganizationID  string `json:"organization_id"`
+	CustomerGroupID string `json:"customer_group_id"`
+	CustomerID      string `json:"customer_id"`
+}
+
+func (svc *PromotionService) RemoveGroupMember(ctx context.Context, req *GroupMemberRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveGroupMember")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if err := svc.promotionService.RemoveGroupMember(ctx, req.OrganizationID, req.CustomerGroupID, req.CustomerID); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RefundService serves the refunds of orders as an rpc.Service. Refunds are
+// recorded against the x-user-id metadata.
+type RefundService struct {
+	refundService *service.RefundService
+}
+
+func NewRefundService(refundService *service.RefundService) *RefundService {
+	return &RefundService{
+		refundService: refundService,
+	}
+}
+
+// Service returns the refund methods as smallbiznis.transaction.v1.RefundService.
+func (svc *RefundService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.RefundService")
+	rpc.Query(s, "ListRefund", svc.ListRefund)
+	rpc.Command(s, "Refund", svc.Refund)
+	return s
+}
+
+type ListRefundResponse struct {
+	Data domain.OrderRefunds `json:"data"`
+}
+
+func (svc *RefundService) ListRefund(ctx context.Context, req *OrderRequest) (*ListRefundResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListRefund")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	refunds, err := svc.refundService.ListRefund(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListRefundResponse{
+		Data: refunds,
+	}, nil
+}
+
+// RefundRequest returns the quantities of Lines, or Amount of a cancelled
+// order's captured payments when no line is given. Amount is in the minor
+// unit of the order currency.
+type RefundRequest struct {
+	OrderID           string                   `json:"order_id"`
+	OrderPaymentID    string                   `json:"order_payment_id"`
+	Destination       domain.RefundDestination `json:"destination"`
+	RestockLocationID *string                  `json:"restock_location_id"`
+	Amount            domain.Money             `json:"amount"`
+	Reason            string                   `json:"reason"`
+	Lines             []RefundLineRequest      `json:"lines"`
+}
+
+type RefundLineRequest struct {
+	OrderItemID string `json:"order_item_id"`
+	Quantity    int32  `json:"quantity"`
+}
+
+func (svc *RefundService) Refund(ctx context.Context, req *RefundRequest) (*domain.OrderRefund, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("Refund")
+
+	if req.OrderID == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	var lines domain.OrderRefundLines
+	for _, line := range req.Lines {
+		lines = append(lines, domain.OrderRefundLine{
+			OrderItemID: line.OrderItemID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	return svc.refundService.Refund(ctx, domain.OrderRefund{
+		OrderID:           req.OrderID,
+		OrderPaymentID:    req.OrderPaymentID,
+		Destination:       req.Destination,
+		RestockLocationID: req.RestockLocationID,
+		Amount:            req.Amount,
+		Reason:            req.Reason,
+		Lines:             lines,
+	}, actorFromContext(ctx))
+}
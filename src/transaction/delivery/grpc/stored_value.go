@@ -0,0 +1,229 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"github.com/smallbiznis/transaction/service"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StoredValueService serves gift cards and customers' store credit, with
+// their balances and ledger history, as an rpc.Service. Amounts are in the
+// minor unit of the base currency, and changes are recorded against the
+// x-user-id metadata.
+type StoredValueService struct {
+	storedValueService *service.StoredValueService
+}
+
+func NewStoredValueService(storedValueService *service.StoredValueService) *StoredValueService {
+	return &StoredValueService{
+		storedValueService: storedValueService,
+	}
+}
+
+// Service returns the stored value methods as smallbiznis.transaction.v1.StoredValueService.
+func (svc *StoredValueService) Service() *rpc.Service {
+	s := rpc.NewService("smallbiznis.transaction.v1.StoredValueService")
+	rpc.Query(s, "GetGiftCard", svc.GetGiftCard)
+	rpc.Query(s, "ListGiftCardEntry", svc.ListGiftCardEntry)
+	rpc.Command(s, "IssueGiftCard", svc.IssueGiftCard)
+	rpc.Command(s, "AddGiftCardVariant", svc.AddGiftCardVariant)
+	rpc.Command(s, "RemoveGiftCardVariant", svc.RemoveGiftCardVariant)
+	rpc.Query(s, "GetStoreCredit", svc.GetStoreCredit)
+	rpc.Query(s, "ListStoreCreditEntry", svc.ListStoreCreditEntry)
+	rpc.Command(s, "AdjustStoreCredit", svc.AdjustStoreCredit)
+	return s
+}
+
+type GiftCardRequest struct {
+	OrganizationID string `json:"organization_id"`
+	Code           string `json:"code"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+// GetGiftCard returns a gift card and its balance by code.
+func (svc *StoredValueService) GetGiftCard(ctx context.Context, req *GiftCardRequest) (*domain.GiftCard, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetGiftCard")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.storedValueService.GetGiftCard(ctx, req.OrganizationID, req.Code)
+}
+
+type ListStoredValueEntryResponse struct {
+	TotalData int32                     `json:"total_data"`
+	Data      domain.StoredValueEntries `json:"data"`
+}
+
+// ListGiftCardEntry lists the history of a gift card, newest first.
+func (svc *StoredValueService) ListGiftCardEntry(ctx context.Context, req *GiftCardRequest) (*ListStoredValueEntryResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListGiftCardEntry")
+
+	card, err := svc.GetGiftCard(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.listEntry(ctx, req.Page, req.Size, domain.GiftCardAccount, card.ID)
+}
+
+// IssueGiftCardRequest issues a gift card worth InitialAmount. A code is
+// generated when Code is empty.
+type IssueGiftCardRequest struct {
+	OrganizationID string       `json:"organization_id"`
+	Code           string       `json:"code"`
+	InitialAmount  domain.Money `json:"initial_amount"`
+	ExpiresAt      *time.Time   `json:"expires_at"`
+}
+
+func (svc *StoredValueService) IssueGiftCard(ctx context.Context, req *IssueGiftCardRequest) (*domain.GiftCard, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("IssueGiftCard")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	return svc.storedValueService.IssueGiftCard(ctx, domain.GiftCard{
+		OrganizationID: req.OrganizationID,
+		Code:           req.Code,
+		InitialAmount:  req.InitialAmount,
+		ExpiresAt:      req.ExpiresAt,
+	}, actorFromContext(ctx))
+}
+
+type GiftCardVariantRequest struct {
+	OrganizationID string `json:"organization_id"`
+	VariantID      string `json:"variant_id"`
+}
+
+// AddGiftCardVariant makes paid orders of a variant issue gift cards.
+func (svc *StoredValueService) AddGiftCardVariant(ctx context.Context, req *GiftCardVariantRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AddGiftCardVariant")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if err := svc.storedValueService.AddGiftCardVariant(ctx, req.OrganizationID, req.VariantID); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
+
+func (svc *StoredValueService) RemoveGiftCardVariant(ctx context.Context, req *GiftCardVariantRequest) (*struct{}, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("RemoveGiftCardVariant")
+
+	if req.OrganizationID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id is required")
+	}
+
+	if err := svc.storedValueService.RemoveGiftCardVariant(ctx, req.OrganizationID, req.VariantID); err != nil {
+		return nil, err
+	}
+
+	return &struct{}{}, nil
+}
+
+type StoreCreditRequest struct {
+	OrganizationID string `json:"organization_id"`
+	CustomerID     string `json:"customer_id"`
+	Page           int32  `json:"page"`
+	Size           int32  `json:"size"`
+}
+
+// GetStoreCredit returns the store credit balance of a customer.
+func (svc *StoredValueService) GetStoreCredit(ctx context.Context, req *StoreCreditRequest) (*domain.StoreCreditWallet, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("GetStoreCredit")
+
+	if req.OrganizationID == "" || req.CustomerID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id and customer_id are required")
+	}
+
+	return svc.storedValueService.GetStoreCredit(ctx, req.OrganizationID, req.CustomerID)
+}
+
+// ListStoreCreditEntry lists the store credit history of a customer,
+// newest first.
+func (svc *StoredValueService) ListStoreCreditEntry(ctx context.Context, req *StoreCreditRequest) (*ListStoredValueEntryResponse, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("ListStoreCreditEntry")
+
+	wallet, err := svc.GetStoreCredit(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Customers who never had credit have no wallet yet.
+	if wallet.ID == "" {
+		return &ListStoredValueEntryResponse{}, nil
+	}
+
+	return svc.listEntry(ctx, req.Page, req.Size, domain.StoreCreditAccount, wallet.ID)
+}
+
+// AdjustStoreCreditRequest credits Amount to the store credit of a
+// customer, or debits it when negative.
+type AdjustStoreCreditRequest struct {
+	OrganizationID string       `json:"organization_id"`
+	CustomerID     string       `json:"customer_id"`
+	Amount         domain.Money `json:"amount"`
+	Note           string       `json:"note"`
+}
+
+func (svc *StoredValueService) AdjustStoreCredit(ctx context.Context, req *AdjustStoreCreditRequest) (*domain.StoreCreditWallet, error) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetName("AdjustStoreCredit")
+
+	if req.OrganizationID == "" || req.CustomerID == "" {
+		return nil, status.Error(codes.InvalidArgument, "organization_id and customer_id are required")
+	}
+
+	return svc.storedValueService.AdjustStoreCredit(ctx, req.OrganizationID, req.CustomerID, req.Amount, actorFromContext(ctx), req.Note)
+}
+
+func (svc *StoredValueService) listEntry(ctx context.Context, page, size int32, account domain.StoredValueAccount, accountID string) (*ListStoredValueEntryResponse, error) {
+	entries, count, err := svc.storedValueService.ListStoredValueEntry(ctx, pagination.Pagination{
+		Page: int(page),
+		Size: int(size),
+	}, account, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListStoredValueEntryResponse{
+		TotalData: int32(count),
+		Data:      entries,
+	}, nil
+}
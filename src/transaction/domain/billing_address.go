@@ -3,12 +3,16 @@ package domain
 import (
 	"time"
 
+	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
 	"gorm.io/gorm"
 )
 
+// OrderBillingAddress is the billing address snapshot of an order. It is
+// written with the order and never updated: editing the order replaces it.
 type OrderBillingAddress struct {
-	BillingAddressID string         `gorm:"column:billing_address_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"billing_address_id"`
-	OrderID          string         `gorm:"column:order_id" json:"order_id"`
+	BillingAddressID string `gorm:"column:billing_address_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"billing_address_id"`
+	OrderID          string `gorm:"column:order_id;type:uuid;uniqueIndex" json:"order_id"`
+	OrderAddress     `gorm:"embedded"`
 	CreatedAt        time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt        time.Time      `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
@@ -22,7 +26,9 @@ func (m *OrderBillingAddress) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 func (m *OrderBillingAddress) BeforeUpdate(tx *gorm.DB) (err error) {
-	now := time.Now()
-	m.UpdatedAt = now
-	return
+	return ErrOrderAddressImmutable
+}
+
+func (m *OrderBillingAddress) ToProto() *customer.Address {
+	return m.OrderAddress.toProto(m.BillingAddressID, m.CreatedAt)
 }
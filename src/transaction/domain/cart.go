@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CartStatus string
+
+var (
+	// CartOpen carts can still be changed and checked out.
+	CartOpen CartStatus = "open"
+	// CartConverted carts were checked out into OrderID.
+	CartConverted CartStatus = "converted"
+	// CartExpired carts weren't changed for the cart lifetime.
+	CartExpired CartStatus = "expired"
+)
+
+func (m CartStatus) String() string {
+	if m == CartOpen ||
+		m == CartConverted ||
+		m == CartExpired {
+		return string(m)
+	}
+	return ""
+}
+
+// Cart is a draft order built up a change at a time by staff or a
+// storefront. It holds no stock: prices, discounts and totals are worked
+// out again on every change, and the cart only becomes an Order, reserving
+// stock, when it is checked out. Carts expire ExpiresAt, which every change
+// pushes back.
+type Cart struct {
+	ID                string         `gorm:"column:cart_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"cart_id"`
+	OrganizationID    string         `gorm:"column:organization_id;type:uuid;index:idx_cart_organization_status" json:"organization_id"`
+	LocationID        *string        `gorm:"column:location_id;type:uuid;default:NULL" json:"location_id"`
+	CustomerID        *string        `gorm:"column:customer_id;type:uuid;default:NULL" json:"customer_id"`
+	BillingAddressID  *string        `gorm:"column:billing_address_id;type:uuid;default:NULL" json:"billing_address_id"`
+	ShippingAddressID *string        `gorm:"column:shipping_address_id;type:uuid;default:NULL" json:"shipping_address_id"`
+	ShippingRateID    *string        `gorm:"column:shipping_rate_id;type:uuid;default:NULL" json:"shipping_rate_id"`
+	Lines             CartLines      `gorm:"foreignKey:CartID" json:"lines"`
+	Coupons           CartCoupons    `gorm:"foreignKey:CartID" json:"coupons"`
+	Currency          string         `gorm:"column:currency;size:3" json:"currency"`
+	SubTotal          Money          `gorm:"column:sub_total" json:"sub_total"`
+	DiscountAmount    Money          `gorm:"column:discount_amount" json:"discount_amount"`
+	TaxAmount         Money          `gorm:"column:tax_amount" json:"tax_amount"`
+	ShippingAmount    Money          `gorm:"column:shipping_amount" json:"shipping_amount"`
+	TotalAmount       Money          `gorm:"column:total_amount" json:"total_amount"`
+	Status            CartStatus     `gorm:"column:status;index:idx_cart_organization_status" json:"status"`
+	OrderID           *string        `gorm:"column:order_id;type:uuid;default:NULL" json:"order_id"`
+	ExpiresAt         time.Time      `gorm:"column:expires_at;index" json:"expires_at"`
+	CreatedAt         time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Cart) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Cart) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// Order returns the order the cart would be checked out as, unpriced. Its
+// items keep the IDs of the cart lines.
+func (m *Cart) Order() Order {
+	order := Order{
+		OrganizationID:    m.OrganizationID,
+		LocationID:        m.LocationID,
+		CustomerID:        m.CustomerID,
+		BillingAddressID:  m.BillingAddressID,
+		ShippingAddressID: m.ShippingAddressID,
+		ShippingRateID:    m.ShippingRateID,
+		Currency:          m.Currency,
+		Status:            OrderCreated,
+	}
+
+	for _, line := range m.Lines {
+		order.OrderItems = append(order.OrderItems, OrderItem{
+			ID:        line.ID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+		})
+	}
+
+	for _, coupon := range m.Coupons {
+		order.CouponCodes = append(order.CouponCodes, coupon.Code)
+	}
+
+	return order
+}
+
+type Carts []Cart
+
+// CartLine is a variant in a cart, priced like the order item it will
+// become.
+type CartLine struct {
+	ID             string    `gorm:"column:cart_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"cart_line_id"`
+	CartID         string    `gorm:"column:cart_id;type:uuid;index" json:"cart_id"`
+	VariantID      string    `gorm:"column:variant_id;type:uuid" json:"variant_id"`
+	Quantity       int32     `gorm:"column:quantity" json:"quantity"`
+	UnitPrice      Money     `gorm:"column:unit_price" json:"unit_price"`
+	DiscountAmount Money     `gorm:"column:discount_amount" json:"discount_amount"`
+	TotalPrice     Money     `gorm:"column:total_price" json:"total_price"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *CartLine) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *CartLine) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type CartLines []CartLine
+
+// CartCoupon is a coupon code applied to a cart. It is only redeemed when
+// the cart is checked out.
+type CartCoupon struct {
+	CartID    string    `gorm:"column:cart_id;type:uuid;primaryKey" json:"cart_id"`
+	Code      string    `gorm:"column:code;primaryKey" json:"code"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *CartCoupon) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type CartCoupons []CartCoupon
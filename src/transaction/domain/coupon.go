@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownCoupon          = errors.New("unknown coupon code")
+	ErrCouponExpired          = errors.New("coupon code expired")
+	ErrCouponExhausted        = errors.New("coupon code reached its usage limit")
+	ErrCouponCustomerExceeded = errors.New("coupon code reached its usage limit for the customer")
+	ErrCouponCustomerRequired = errors.New("coupon code is limited per customer and needs an order with a customer")
+	ErrCouponNotApplicable    = errors.New("coupon code doesn't apply to the order")
+)
+
+// NormalizeCode returns code the way coupon and gift card codes are
+// stored, so customers can type them in any case.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Coupon is a code unlocking a coupon-only promotion. MaxRedemptions and
+// MaxPerCustomer limit how many orders can use it in total and per customer;
+// zero means unlimited. Coupons limited per customer can't be used by
+// anonymous orders. Redemptions counts the orders using it.
+type Coupon struct {
+	ID             string         `gorm:"column:coupon_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"coupon_id"`
+	OrganizationID string         `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_coupon_organization_code" json:"organization_id"`
+	PromotionID    string         `gorm:"column:promotion_id;type:uuid;index" json:"promotion_id"`
+	Code           string         `gorm:"column:code;uniqueIndex:idx_coupon_organization_code" json:"code"`
+	MaxRedemptions int32          `gorm:"column:max_redemptions" json:"max_redemptions"`
+	MaxPerCustomer int32          `gorm:"column:max_per_customer" json:"max_per_customer"`
+	Redemptions    int32          `gorm:"column:redemptions" json:"redemptions"`
+	ExpiresAt      *time.Time     `gorm:"column:expires_at" json:"expires_at"`
+	Active         bool           `gorm:"column:active" json:"active"`
+	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Coupon) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Coupon) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// Usable reports why the coupon can't be used at t, if it can't.
+func (m *Coupon) Usable(t time.Time) error {
+	if !m.Active {
+		return ErrUnknownCoupon
+	}
+
+	if m.ExpiresAt != nil && !t.Before(*m.ExpiresAt) {
+		return ErrCouponExpired
+	}
+
+	if m.MaxRedemptions > 0 && m.Redemptions >= m.MaxRedemptions {
+		return ErrCouponExhausted
+	}
+
+	return nil
+}
+
+type Coupons []Coupon
+
+type RedemptionStatus string
+
+var (
+	RedemptionRedeemed RedemptionStatus = "redeemed"
+	RedemptionReversed RedemptionStatus = "reversed"
+)
+
+// CouponRedemption is the use of a coupon by an order. It is reversed, and
+// the coupon can be used again, when the order is cancelled or refunded.
+type CouponRedemption struct {
+	ID         string           `gorm:"column:coupon_redemption_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"coupon_redemption_id"`
+	CouponID   string           `gorm:"column:coupon_id;type:uuid;uniqueIndex:idx_coupon_redemption_order" json:"coupon_id"`
+	OrderID    string           `gorm:"column:order_id;type:uuid;uniqueIndex:idx_coupon_redemption_order" json:"order_id"`
+	CustomerID *string          `gorm:"column:customer_id;type:uuid;default:NULL;index" json:"customer_id"`
+	Status     RedemptionStatus `gorm:"column:status" json:"status"`
+	CreatedAt  time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *CouponRedemption) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *CouponRedemption) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type CouponRedemptions []CouponRedemption
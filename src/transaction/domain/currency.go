@@ -0,0 +1,23 @@
+package domain
+
+// OrganizationCurrency is a currency an organization sells in, as served
+// by the CurrencyService of the organization service. The transaction service
+// looks them up to price orders and record their exchange rate.
+type OrganizationCurrency struct {
+	OrganizationID string       `json:"organization_id"`
+	Currency       string       `json:"currency"`
+	Base           bool         `json:"base"`
+	ExchangeRate   ExchangeRate `json:"exchange_rate"`
+}
+
+type OrganizationCurrencies []OrganizationCurrency
+
+// Base returns the base currency, if any.
+func (m OrganizationCurrencies) Base() *OrganizationCurrency {
+	for i := range m {
+		if m[i].Base {
+			return &m[i]
+		}
+	}
+	return nil
+}
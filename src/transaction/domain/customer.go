@@ -0,0 +1,11 @@
+package domain
+
+// Customer is who placed an order, as served by the customer service.
+type Customer struct {
+	ID        string `json:"customer_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+type Customers []Customer
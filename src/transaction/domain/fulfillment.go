@@ -1,18 +1,76 @@
 package domain
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var ErrUnknownCourier = errors.New("unknown courier")
+
+// FulfillmentStatus is the lifecycle of a fulfillment: it is picked at its
+// location, shipped with a courier and delivered, unless it is cancelled
+// before shipping.
+type FulfillmentStatus string
+
+var (
+	FulfillmentPending   FulfillmentStatus = "pending"
+	FulfillmentShipped   FulfillmentStatus = "shipped"
+	FulfillmentDelivered FulfillmentStatus = "delivered"
+	FulfillmentCancelled FulfillmentStatus = "cancelled"
+)
+
+func (m FulfillmentStatus) String() string {
+	if m == FulfillmentPending ||
+		m == FulfillmentShipped ||
+		m == FulfillmentDelivered ||
+		m == FulfillmentCancelled {
+		return string(m)
+	}
+	return ""
+}
+
+// ShippingStatus is the tracking status of a shipment.
+type ShippingStatus string
+
+var (
+	ShippingLabelCreated   ShippingStatus = "label_created"
+	ShippingInTransit      ShippingStatus = "in_transit"
+	ShippingOutForDelivery ShippingStatus = "out_for_delivery"
+	ShippingDelivered      ShippingStatus = "delivered"
+	ShippingFailed         ShippingStatus = "failed"
+	ShippingReturned       ShippingStatus = "returned"
+)
+
+func (m ShippingStatus) String() string {
+	if m == ShippingLabelCreated ||
+		m == ShippingInTransit ||
+		m == ShippingOutForDelivery ||
+		m == ShippingDelivered ||
+		m == ShippingFailed ||
+		m == ShippingReturned {
+		return string(m)
+	}
+	return ""
+}
+
+// OrderFulfillment is a subset of an order's items sent from LocationID.
+// An order is fulfilled once all its items not returned are in delivered
+// fulfillments.
 type OrderFulfillment struct {
-	ID        string         `gorm:"column:fulfillment_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	OrderID   string         `gorm:"column:order_id" json:"order_id"`
-	Status    string         `gorm:"column:status" json:"status"`
-	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+	ID             string                `gorm:"column:fulfillment_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	OrganizationID string                `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	OrderID        string                `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	LocationID     string                `gorm:"column:location_id;type:uuid" json:"location_id"`
+	Status         FulfillmentStatus     `gorm:"column:status" json:"status"`
+	Items          OrderFulfillmentItems `gorm:"foreignKey:OrderFulfillmentID" json:"items"`
+	Shipping       *OrderShipping        `gorm:"foreignKey:OrderFulfillmentID" json:"shipping"`
+	Actor          string                `gorm:"column:actor" json:"actor"`
+	CreatedAt      time.Time             `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time             `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt        `gorm:"column:deleted_at" json:"-"`
 }
 
 func (m *OrderFulfillment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -27,3 +85,34 @@ func (m *OrderFulfillment) BeforeUpdate(tx *gorm.DB) (err error) {
 	m.UpdatedAt = now
 	return
 }
+
+type OrderFulfillments []OrderFulfillment
+
+// OrderFulfillmentItem is the quantity of an order item in a fulfillment.
+type OrderFulfillmentItem struct {
+	ID                 string    `gorm:"column:fulfillment_item_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"fulfillment_item_id"`
+	OrderFulfillmentID string    `gorm:"column:fulfillment_id;type:uuid;index" json:"fulfillment_id"`
+	OrderItemID        string    `gorm:"column:order_item_id;type:uuid" json:"order_item_id"`
+	Quantity           int32     `gorm:"column:quantity" json:"quantity"`
+	CreatedAt          time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *OrderFulfillmentItem) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OrderFulfillmentItems []OrderFulfillmentItem
+
+type ShippingHistories []ShippingHistory
+
+// Courier is the adapter to a shipping carrier.
+type Courier interface {
+	// ID identifies the courier in OrderShipping.CourierID.
+	ID() string
+	// CreateShipment books the shipment with the carrier and returns its
+	// waybill number. waybill is the number entered by staff, if any.
+	CreateShipment(ctx context.Context, shipping OrderShipping, waybill string) (string, error)
+	// Track returns the tracking events the carrier knows of the shipment.
+	Track(ctx context.Context, shipping OrderShipping) (ShippingHistories, error)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "github.com/smallbiznis/common/money"
+
+// DefaultCurrency is the currency of amounts whose organization has no
+// country currency.
+const DefaultCurrency = money.DefaultCurrency
+
+// Money, Rate and ExchangeRate are the exact amounts, percentages and
+// exchange rates shared by every service; see package money for their
+// units and rounding.
+type (
+	Money        = money.Money
+	Rate         = money.Rate
+	ExchangeRate = money.ExchangeRate
+)
+
+const (
+	BaseRate       = money.BaseRate
+	HundredPercent = money.HundredPercent
+)
+
+var (
+	MinorUnits      = money.MinorUnits
+	NewMoney        = money.NewMoney
+	ParseMoney      = money.Parse
+	NewRate         = money.NewRate
+	NewExchangeRate = money.NewExchangeRate
+)
@@ -11,20 +11,31 @@ import (
 )
 
 type Order struct {
-	ID                string                `gorm:"column:order_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_id"`
-	OrganizationID    string                `gorm:"column:organization_id;type:uuid" json:"organization_id"`
-	CustomerID        *string               `gorm:"column:customer_id;type:uuid;default:NULL" json:"customer_id"`
+	ID                string                `gorm:"column:order_id;type:uuid;default:uuid_generate_v4();primaryKey;index:idx_order_organization_created_at,priority:3" json:"order_id"`
+	OrganizationID    string                `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_order_organization_order_no;index:idx_order_organization_created_at,priority:1" json:"organization_id"`
+	LocationID        *string               `gorm:"column:location_id;type:uuid;default:NULL" json:"location_id"`
+	CustomerID        *string               `gorm:"column:customer_id;type:uuid;default:NULL;index" json:"customer_id"`
+	Customer          *Customer             `gorm:"-" json:"customer"`
 	BillingAddressID  *string               `gorm:"column:billing_address_id;type:uuid;default:NULL" json:"billing_address_id"`
 	BillingAddress    *OrderBillingAddress  `gorm:"foreignKey:OrderID" json:"billing_address"`
 	ShippingAddressID *string               `gorm:"column:shipping_address_id;type:uuid;default:NULL" json:"shipping_address_id"`
 	ShippingAddress   *OrderShippingAddress `gorm:"foreignKey:OrderID" json:"shipping_address"`
-	OrderNo           string                `gorm:"column:order_no" json:"order_no"`
+	OrderNo           string                `gorm:"column:order_no;uniqueIndex:idx_order_organization_order_no" json:"order_no"`
 	OrderItems        OrderItems            `gorm:"foreignKey:OrderID" json:"order_items"`
-	SubTotal          float32               `gorm:"column:sub_total" json:"sub_total"`
-	TaxAmount         float32               `gorm:"column:tax_amount" json:"tax_amount"`
-	TotalAmount       float32               `gorm:"column:total_amount" json:"total_amount"`
-	Status            string                `gorm:"column:status" json:"status"`
-	CreatedAt         time.Time             `gorm:"column:created_at" json:"created_at"`
+	Currency          string                `gorm:"column:currency;size:3" json:"currency"`
+	BaseCurrency      string                `gorm:"column:base_currency;size:3" json:"base_currency"`
+	ExchangeRate      ExchangeRate          `gorm:"column:exchange_rate" json:"exchange_rate"`
+	SubTotal          Money                 `gorm:"column:sub_total" json:"sub_total"`
+	DiscountAmount    Money                 `gorm:"column:discount_amount" json:"discount_amount"`
+	Discounts         OrderDiscounts        `gorm:"foreignKey:OrderID" json:"discounts"`
+	CouponCodes       []string              `gorm:"-" json:"coupon_codes"`
+	TaxAmount         Money                 `gorm:"column:tax_amount" json:"tax_amount"`
+	ShippingRateID    *string               `gorm:"column:shipping_rate_id;type:uuid;default:NULL" json:"shipping_rate_id"`
+	ShippingAmount    Money                 `gorm:"column:shipping_amount" json:"shipping_amount"`
+	TotalAmount       Money                 `gorm:"column:total_amount" json:"total_amount"`
+	Status            OrderStatus           `gorm:"column:status" json:"status"`
+	LayawayExpiresAt  *time.Time            `gorm:"column:layaway_expires_at;index" json:"layaway_expires_at"`
+	CreatedAt         time.Time             `gorm:"column:created_at;index:idx_order_organization_created_at,priority:2" json:"created_at"`
 	UpdatedAt         time.Time             `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt         gorm.DeletedAt        `gorm:"column:deleted_at" json:"-"`
 }
@@ -42,37 +53,94 @@ func (m *Order) BeforeUpdate(tx *gorm.DB) (err error) {
 	return
 }
 
-func (m *Order) ToProto() *transaction.Order {
-	return &transaction.Order{
-		OrderId:           m.ID,
-		OrganizationId:    m.OrganizationID,
-		SalesChannelId:    "",
-		CustomerId:        *m.CustomerID,
-		BillingAddressId:  "",
-		ShippingAddressId: "",
-		OrderNo:           m.OrderNo,
-		OrderItems:        m.OrderItems.ToProto(),
-		TaxAmount:         m.TaxAmount,
-		SubTotal:          m.SubTotal,
-		TotalAmount:       m.TotalAmount,
-		Status:            transaction.OrderStatus_created,
-		CreatedAt:         timestamppb.New(m.CreatedAt),
-		UpdatedAt:         timestamppb.New(m.UpdatedAt),
+// ToBase converts amount from the order currency to the organization's base
+// currency at the exchange rate the order was sold at, for reports.
+func (m *Order) ToBase(amount Money) Money {
+	if m.ExchangeRate == 0 {
+		return amount
 	}
+	return amount.ToBase(m.Currency, m.BaseCurrency, m.ExchangeRate)
+}
+
+// FromBase converts amount from the base currency to the order currency at
+// the order's exchange rate.
+func (m *Order) FromBase(amount Money) Money {
+	if m.ExchangeRate == 0 {
+		return amount
+	}
+	return amount.FromBase(m.Currency, m.BaseCurrency, m.ExchangeRate)
+}
+
+// ToProto converts the order, amounts in its currency. Address IDs refer to
+// the customer addresses the snapshots were copied from, while the
+// addresses are the preloaded snapshots, as they were when ordered. It
+// fails when the proto can't carry the order status.
+func (m *Order) ToProto() (*transaction.Order, error) {
+	orderStatus, err := m.Status.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	order := &transaction.Order{
+		OrderId:        m.ID,
+		OrganizationId: m.OrganizationID,
+		SalesChannelId: "",
+		OrderNo:        m.OrderNo,
+		OrderItems:     m.OrderItems.ToProto(m.Currency),
+		TaxAmount:      m.TaxAmount.Float32(m.Currency),
+		SubTotal:       m.SubTotal.Float32(m.Currency),
+		TotalAmount:    m.TotalAmount.Float32(m.Currency),
+		Status:         orderStatus,
+		CreatedAt:      timestamppb.New(m.CreatedAt),
+		UpdatedAt:      timestamppb.New(m.UpdatedAt),
+	}
+
+	if m.CustomerID != nil {
+		order.CustomerId = *m.CustomerID
+	}
+
+	if m.BillingAddressID != nil {
+		order.BillingAddressId = *m.BillingAddressID
+	}
+
+	if m.ShippingAddressID != nil {
+		order.ShippingAddressId = *m.ShippingAddressID
+	}
+
+	if m.BillingAddress != nil {
+		order.BillingAddress = m.BillingAddress.ToProto()
+	}
+
+	if m.ShippingAddress != nil {
+		order.ShippingAddress = m.ShippingAddress.ToProto()
+	}
+
+	return order, nil
 }
 
 type Orders []Order
 
-func (m Orders) ToProto() (data []*transaction.Order) {
+func (m Orders) ToProto() (data []*transaction.Order, err error) {
 	for _, v := range m {
-		data = append(data, v.ToProto())
+		order, err := v.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, order)
 	}
 	return
 }
 
+// ShippingHistory is a tracking event of a shipment, reported by its
+// courier or entered by staff.
 type ShippingHistory struct {
 	ID              string         `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	OrderShippingID string         `gorm:"column:order_shipping_id" json:"order_shipping_id"`
+	OrderShippingID string         `gorm:"column:order_shipping_id;type:uuid;index" json:"order_shipping_id"`
+	Status          ShippingStatus `gorm:"column:status" json:"status"`
+	Description     string         `gorm:"column:description" json:"description"`
+	Location        string         `gorm:"column:location" json:"location"`
+	OccurredAt      time.Time      `gorm:"column:occurred_at" json:"occurred_at"`
+	Actor           string         `gorm:"column:actor" json:"actor"`
 	CreatedAt       time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt       time.Time      `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
@@ -91,14 +159,23 @@ func (m *ShippingHistory) BeforeUpdate(tx *gorm.DB) (err error) {
 	return
 }
 
+// OrderShipping is the shipment of a fulfillment. ShippingMethodID is the
+// organization ShippingRate it is sent with; its name and price are copied
+// so later rate changes don't alter shipped orders.
 type OrderShipping struct {
-	ID               string         `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
-	OrderID          string         `gorm:"column:order_id" json:"order_id"`
-	ShippingMethodID string         `gorm:"column:shipping_method_id" json:"shipping_method_id"`
-	WaybillNumber    string         `gorm:"column:waybill_number" json:"waybill_number"`
-	CreatedAt        time.Time      `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt        time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt        gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+	ID                 string            `gorm:"column:id;type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	OrderID            string            `gorm:"column:order_id" json:"order_id"`
+	OrderFulfillmentID string            `gorm:"column:fulfillment_id;type:uuid;uniqueIndex" json:"fulfillment_id"`
+	ShippingMethodID   string            `gorm:"column:shipping_method_id" json:"shipping_method_id"`
+	ShippingMethodName string            `gorm:"column:shipping_method_name" json:"shipping_method_name"`
+	ShippingPrice      Money             `gorm:"column:shipping_price" json:"shipping_price"`
+	CourierID          string            `gorm:"column:courier_id" json:"courier_id"`
+	WaybillNumber      string            `gorm:"column:waybill_number" json:"waybill_number"`
+	Status             ShippingStatus    `gorm:"column:status" json:"status"`
+	Histories          ShippingHistories `gorm:"foreignKey:OrderShippingID" json:"histories"`
+	CreatedAt          time.Time         `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt          time.Time         `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt          gorm.DeletedAt    `gorm:"column:deleted_at" json:"-"`
 }
 
 func (m *OrderShipping) BeforeCreate(tx *gorm.DB) (err error) {
@@ -116,6 +193,7 @@ func (m *OrderShipping) BeforeUpdate(tx *gorm.DB) (err error) {
 
 type IOrderRepository interface {
 	Find(context.Context, pagination.Pagination, Order) (Orders, int64, error)
+	Search(context.Context, pagination.Pagination, OrderFilter) (Orders, int64, *OrderCursor, error)
 	FindOne(context.Context, Order) (*Order, error)
 	Save(context.Context, Order) (*Order, error)
 	Update(context.Context, Order) (*Order, error)
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var ErrOrderAddressImmutable = errors.New("order address can't be changed")
+
+// OrderAddress is a copy of a customer address taken when the order is
+// placed, so later edits to the customer's address book don't change past
+// orders. CustomerAddressID is the address it was copied from.
+type OrderAddress struct {
+	CustomerAddressID string  `gorm:"column:customer_address_id;type:uuid" json:"customer_address_id"`
+	CustomerID        string  `gorm:"column:customer_id;type:uuid" json:"customer_id"`
+	ContactName       string  `gorm:"column:contact_name" json:"contact_name"`
+	ContactPhone      string  `gorm:"column:contact_phone" json:"contact_phone"`
+	ProvinceID        *string `gorm:"column:province_id;type:uuid;default:NULL" json:"province_id"`
+	CityID            *string `gorm:"column:city_id;type:uuid;default:NULL" json:"city_id"`
+	DistrictID        *string `gorm:"column:district_id;type:uuid;default:NULL" json:"district_id"`
+	Address           string  `gorm:"column:address" json:"address"`
+	PostalCode        string  `gorm:"column:postal_code" json:"postal_code"`
+}
+
+// NewOrderAddress copies an address of the customer service.
+func NewOrderAddress(addr *customer.Address) OrderAddress {
+	snapshot := OrderAddress{
+		CustomerAddressID: addr.AddressId,
+		CustomerID:        addr.CustomerId,
+		ContactName:       addr.ContactName,
+		ContactPhone:      addr.ContactPhone,
+		Address:           addr.Address,
+		PostalCode:        addr.PostalCode,
+	}
+
+	if addr.ProviceId != "" {
+		snapshot.ProvinceID = &addr.ProviceId
+	}
+
+	if addr.CityId != "" {
+		snapshot.CityID = &addr.CityId
+	}
+
+	if addr.DistrictId != "" {
+		snapshot.DistrictID = &addr.DistrictId
+	}
+
+	return snapshot
+}
+
+func (m OrderAddress) toProto(id string, createdAt time.Time) *customer.Address {
+	addr := &customer.Address{
+		AddressId:    id,
+		CustomerId:   m.CustomerID,
+		ContactName:  m.ContactName,
+		ContactPhone: m.ContactPhone,
+		Address:      m.Address,
+		PostalCode:   m.PostalCode,
+		CreatedAt:    timestamppb.New(createdAt),
+		UpdatedAt:    timestamppb.New(createdAt),
+	}
+
+	if m.ProvinceID != nil {
+		addr.ProviceId = *m.ProvinceID
+	}
+
+	if m.CityID != nil {
+		addr.CityId = *m.CityID
+	}
+
+	if m.DistrictID != nil {
+		addr.DistrictId = *m.DistrictID
+	}
+
+	return addr
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderEvent is an append-only record of a single order status transition.
+type OrderEvent struct {
+	ID         string      `gorm:"column:order_event_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_event_id"`
+	OrderID    string      `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	FromStatus OrderStatus `gorm:"column:from_status" json:"from_status"`
+	ToStatus   OrderStatus `gorm:"column:to_status" json:"to_status"`
+	Actor      string      `gorm:"column:actor" json:"actor"`
+	Reason     string      `gorm:"column:reason" json:"reason"`
+	CreatedAt  time.Time   `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *OrderEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OrderEvents []OrderEvent
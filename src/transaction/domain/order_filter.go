@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidOrderCursor = errors.New("invalid order cursor")
+
+// OrderPaymentStatus is how much of an order its captured payments cover.
+type OrderPaymentStatus string
+
+var (
+	Unpaid        OrderPaymentStatus = "unpaid"
+	PartiallyPaid OrderPaymentStatus = "partially_paid"
+	FullyPaid     OrderPaymentStatus = "paid"
+)
+
+func (m OrderPaymentStatus) String() string {
+	if m == Unpaid ||
+		m == PartiallyPaid ||
+		m == FullyPaid {
+		return string(m)
+	}
+	return ""
+}
+
+// OrderFulfillmentStatus is how much of an order has been fulfilled,
+// returned quantities not counting.
+type OrderFulfillmentStatus string
+
+var (
+	Unfulfilled        OrderFulfillmentStatus = "unfulfilled"
+	PartiallyFulfilled OrderFulfillmentStatus = "partially_fulfilled"
+	FullyFulfilled     OrderFulfillmentStatus = "fulfilled"
+)
+
+func (m OrderFulfillmentStatus) String() string {
+	if m == Unfulfilled ||
+		m == PartiallyFulfilled ||
+		m == FullyFulfilled {
+		return string(m)
+	}
+	return ""
+}
+
+// OrderSort is the column orders are listed by. Orders with the same value
+// are listed by ID.
+type OrderSort string
+
+var (
+	SortCreatedAt   OrderSort = "created_at"
+	SortUpdatedAt   OrderSort = "updated_at"
+	SortTotalAmount OrderSort = "total_amount"
+	SortOrderNo     OrderSort = "order_no"
+)
+
+func (m OrderSort) String() string {
+	if m == SortCreatedAt ||
+		m == SortUpdatedAt ||
+		m == SortTotalAmount ||
+		m == SortOrderNo {
+		return string(m)
+	}
+	return ""
+}
+
+// OrderFilter selects and sorts the orders of an organization. Zero fields
+// don't filter. CreatedFrom is inclusive and CreatedTo exclusive; MinTotal
+// and MaxTotal are both inclusive, in Currency. Orders are listed newest
+// first unless Sort says otherwise, and start after the cursor After when
+// it is set.
+type OrderFilter struct {
+	OrganizationID    string
+	Status            OrderStatus
+	CustomerID        string
+	LocationID        string
+	PaymentStatus     OrderPaymentStatus
+	FulfillmentStatus OrderFulfillmentStatus
+	CreatedFrom       *time.Time
+	CreatedTo         *time.Time
+	Currency          string
+	MinTotal          *Money
+	MaxTotal          *Money
+	OrderNoPrefix     string
+	VariantID         string
+	Sort              OrderSort
+	Descending        bool
+	After             *OrderCursor
+}
+
+// OrderCursor is the position of an order in a listing sorted by Sort in
+// the direction of Descending, for the next page to start after.
+type OrderCursor struct {
+	Sort        OrderSort `json:"sort"`
+	Descending  bool      `json:"descending,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	TotalAmount Money     `json:"total_amount,omitempty"`
+	OrderNo     string    `json:"order_no,omitempty"`
+	OrderID     string    `json:"order_id"`
+}
+
+// NewOrderCursor returns the cursor of order in a listing sorted by sort,
+// descending when descending is set.
+func NewOrderCursor(sort OrderSort, descending bool, order Order) OrderCursor {
+	cursor := OrderCursor{Sort: sort, Descending: descending, OrderID: order.ID}
+	switch sort {
+	case SortCreatedAt:
+		cursor.CreatedAt = order.CreatedAt
+	case SortUpdatedAt:
+		cursor.UpdatedAt = order.UpdatedAt
+	case SortTotalAmount:
+		cursor.TotalAmount = order.TotalAmount
+	case SortOrderNo:
+		cursor.OrderNo = order.OrderNo
+	}
+	return cursor
+}
+
+// ParseOrderCursor decodes a cursor encoded by OrderCursor.String.
+func ParseOrderCursor(s string) (*OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidOrderCursor
+	}
+
+	var cursor OrderCursor
+	if err = json.Unmarshal(b, &cursor); err != nil || cursor.Sort.String() == "" || cursor.OrderID == "" {
+		return nil, ErrInvalidOrderCursor
+	}
+
+	return &cursor, nil
+}
+
+// Value is the value of the sort column the cursor is at.
+func (m OrderCursor) Value() any {
+	switch m.Sort {
+	case SortUpdatedAt:
+		return m.UpdatedAt
+	case SortTotalAmount:
+		return m.TotalAmount
+	case SortOrderNo:
+		return m.OrderNo
+	}
+	return m.CreatedAt
+}
+
+// Matches reports whether the cursor was made for a listing sorted by sort
+// in the direction of descending.
+func (m OrderCursor) Matches(sort OrderSort, descending bool) bool {
+	return m.Sort == sort && m.Descending == descending
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (m OrderCursor) String() string {
+	b, _ := json.Marshal(m)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,24 @@
+package domain
+
+// OrderFulfilledChannel is the outbox channel fulfilled orders are published
+// on. The event payload is the JSON encoded OrderFulfilledEvent.
+const OrderFulfilledChannel = "transaction_order_fulfilled"
+
+// OrderFulfilledEvent tells other services which variants an order handed
+// over to the customer, so inventory can book the sale of reserved stock and
+// consume recipe ingredients.
+type OrderFulfilledEvent struct {
+	OrderID        string                   `json:"order_id"`
+	OrganizationID string                   `json:"organization_id"`
+	LocationID     string                   `json:"location_id"`
+	Items          []OrderFulfilledLineItem `json:"items"`
+}
+
+// OrderFulfilledLineItem is a fulfilled order line. ReservedQuantity is the
+// stock the line reserved on InventoryItemID, which is now sold.
+type OrderFulfilledLineItem struct {
+	VariantID        string `json:"variant_id"`
+	Quantity         int32  `json:"quantity"`
+	InventoryItemID  string `json:"inventory_item_id,omitempty"`
+	ReservedQuantity int32  `json:"reserved_quantity,omitempty"`
+}
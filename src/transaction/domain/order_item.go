@@ -9,16 +9,22 @@ import (
 )
 
 type OrderItem struct {
-	ID         string         `gorm:"column:order_item_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_item_id"`
-	OrderID    string         `gorm:"column:order_id;type:uuid" json:"order_id"`
-	Order      Order          `gorm:"foreignKey:OrderID" json:"-"`
-	VariantID  string         `gorm:"column:variant_id;type:uuid" json:"variant_id"`
-	Quantity   int32          `gorm:"column:quantity" json:"quantity"`
-	UnitPrice  float32        `gorm:"column:unit_price" json:"unit_price"`
-	TotalPrice float32        `gorm:"column:total_price" json:"total_price"`
-	CreatedAt  time.Time      `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt  time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+	ID                string         `gorm:"column:order_item_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_item_id"`
+	OrderID           string         `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	Order             Order          `gorm:"foreignKey:OrderID" json:"-"`
+	VariantID         string         `gorm:"column:variant_id;type:uuid;index" json:"variant_id"`
+	Quantity          int32          `gorm:"column:quantity" json:"quantity"`
+	UnitPrice         Money          `gorm:"column:unit_price" json:"unit_price"`
+	DiscountAmount    Money          `gorm:"column:discount_amount" json:"discount_amount"`
+	TotalPrice        Money          `gorm:"column:total_price" json:"total_price"`
+	TaxAmount         Money          `gorm:"column:tax_amount" json:"tax_amount"`
+	InventoryItemID   *string        `gorm:"column:inventory_item_id;type:uuid;default:NULL" json:"inventory_item_id"`
+	ReservedQuantity  int32          `gorm:"column:reserved_quantity" json:"reserved_quantity"`
+	ReturnedQuantity  int32          `gorm:"column:returned_quantity" json:"returned_quantity"`
+	FulfilledQuantity int32          `gorm:"column:fulfilled_quantity" json:"fulfilled_quantity"`
+	CreatedAt         time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
 }
 
 func (m *OrderItem) BeforeCreate(tx *gorm.DB) (err error) {
@@ -34,14 +40,15 @@ func (m *OrderItem) BeforeUpdate(tx *gorm.DB) (err error) {
 	return
 }
 
-func (m *OrderItem) ToProto() *transaction.OrderItem {
+// ToProto converts the order item, amounts in the currency of its order.
+func (m *OrderItem) ToProto(currency string) *transaction.OrderItem {
 	return &transaction.OrderItem{
 		OrderItemId: m.ID,
 		OrderId:     m.OrderID,
 		ItemId:      m.VariantID,
 		Quantity:    m.Quantity,
-		UnitPrice:   m.UnitPrice,
-		TotalPrice:  m.TotalPrice,
+		UnitPrice:   m.UnitPrice.Float32(currency),
+		TotalPrice:  m.TotalPrice.Float32(currency),
 		CreatedAt:   timestamppb.New(m.CreatedAt),
 		UpdatedAt:   timestamppb.New(m.UpdatedAt),
 	}
@@ -49,9 +56,9 @@ func (m *OrderItem) ToProto() *transaction.OrderItem {
 
 type OrderItems []OrderItem
 
-func (m OrderItems) ToProto() (data []*transaction.OrderItem) {
+func (m OrderItems) ToProto(currency string) (data []*transaction.OrderItem) {
 	for _, v := range m {
-		data = append(data, v.ToProto())
+		data = append(data, v.ToProto(currency))
 	}
 	return
 }
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderNumberSequence numbers the orders of an organization, e.g.
+// INV-2026-000123 for prefix INV, yearly reset and a padding of 6. Numbers
+// are allocated in the transaction creating the order, so a rolled back
+// order gives its number back and the sequence has no gaps. ResetYearly
+// restarts numbering at 1 every year and puts the year in the number.
+type OrderNumberSequence struct {
+	OrganizationID string    `gorm:"column:organization_id;type:uuid;primaryKey" json:"organization_id"`
+	Prefix         string    `gorm:"column:prefix" json:"prefix"`
+	ResetYearly    bool      `gorm:"column:reset_yearly" json:"reset_yearly"`
+	Padding        int32     `gorm:"column:padding" json:"padding"`
+	Year           int32     `gorm:"column:year" json:"year"`
+	LastValue      int64     `gorm:"column:last_value" json:"last_value"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *OrderNumberSequence) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *OrderNumberSequence) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// DefaultOrderNumberSequence is used by organizations that haven't
+// configured their numbering.
+func DefaultOrderNumberSequence(organizationID string) OrderNumberSequence {
+	return OrderNumberSequence{
+		OrganizationID: organizationID,
+		Prefix:         "INV",
+		ResetYearly:    true,
+		Padding:        6,
+	}
+}
+
+// Next advances the sequence for an order placed at t and returns the
+// order number.
+func (m *OrderNumberSequence) Next(t time.Time) string {
+	year := int32(t.Year())
+	if m.ResetYearly && m.Year != year {
+		m.LastValue = 0
+	}
+	m.Year = year
+	m.LastValue++
+
+	var parts []string
+	if m.Prefix != "" {
+		parts = append(parts, m.Prefix)
+	}
+
+	if m.ResetYearly {
+		parts = append(parts, fmt.Sprint(year))
+	}
+
+	parts = append(parts, fmt.Sprintf("%0*d", m.Padding, m.LastValue))
+
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderNumberSequenceNext(t *testing.T) {
+	placed := time.Date(2026, time.March, 14, 10, 0, 0, 0, time.UTC)
+	newYear := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		seq       OrderNumberSequence
+		t         time.Time
+		want      string
+		lastValue int64
+	}{
+		{
+			name:      "first order",
+			seq:       DefaultOrderNumberSequence("org"),
+			t:         placed,
+			want:      "INV-2026-000001",
+			lastValue: 1,
+		},
+		{
+			name:      "continues within the year",
+			seq:       OrderNumberSequence{Prefix: "INV", ResetYearly: true, Padding: 6, Year: 2026, LastValue: 122},
+			t:         placed,
+			want:      "INV-2026-000123",
+			lastValue: 123,
+		},
+		{
+			name:      "resets on a new year",
+			seq:       OrderNumberSequence{Prefix: "INV", ResetYearly: true, Padding: 6, Year: 2026, LastValue: 122},
+			t:         newYear,
+			want:      "INV-2027-000001",
+			lastValue: 1,
+		},
+		{
+			name:      "keeps counting across years without reset",
+			seq:       OrderNumberSequence{Prefix: "SO", Padding: 4, Year: 2026, LastValue: 41},
+			t:         newYear,
+			want:      "SO-0042",
+			lastValue: 42,
+		},
+		{
+			name:      "no prefix",
+			seq:       OrderNumberSequence{ResetYearly: true, Padding: 3, Year: 2026, LastValue: 6},
+			t:         placed,
+			want:      "2026-007",
+			lastValue: 7,
+		},
+		{
+			name:      "outgrows padding",
+			seq:       OrderNumberSequence{Prefix: "INV", Padding: 2, LastValue: 99},
+			t:         placed,
+			want:      "INV-100",
+			lastValue: 100,
+		},
+		{
+			name:      "no padding",
+			seq:       OrderNumberSequence{Prefix: "INV", LastValue: 8},
+			t:         placed,
+			want:      "INV-9",
+			lastValue: 9,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := tt.seq
+			if got := seq.Next(tt.t); got != tt.want {
+				t.Errorf("Next() = %q, want %q", got, tt.want)
+			}
+
+			if seq.LastValue != tt.lastValue {
+				t.Errorf("LastValue = %d, want %d", seq.LastValue, tt.lastValue)
+			}
+
+			if seq.Year != int32(tt.t.Year()) {
+				t.Errorf("Year = %d, want %d", seq.Year, tt.t.Year())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderEdit is a change to a placed order. Each line sets the quantity of
+// an order item, zero removing it, or adds a variant when OrderItemID is
+// empty. Nil customer and address IDs are left as they are.
+type OrderEdit struct {
+	Lines             OrderEditLines
+	CustomerID        *string
+	BillingAddressID  *string
+	ShippingAddressID *string
+}
+
+type OrderEditLine struct {
+	OrderItemID string
+	VariantID   string
+	Quantity    int32
+}
+
+type OrderEditLines []OrderEditLine
+
+// OrderRevision is an append-only record of one edit of a placed order. The
+// From and To fields hold the customer, addresses and total before and after
+// the edit, and are equal when it left them alone.
+type OrderRevision struct {
+	ID                    string             `gorm:"column:order_revision_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_revision_id"`
+	OrderID               string             `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	Lines                 OrderRevisionLines `gorm:"foreignKey:OrderRevisionID" json:"lines"`
+	FromCustomerID        *string            `gorm:"column:from_customer_id;type:uuid;default:NULL" json:"from_customer_id"`
+	ToCustomerID          *string            `gorm:"column:to_customer_id;type:uuid;default:NULL" json:"to_customer_id"`
+	FromBillingAddressID  *string            `gorm:"column:from_billing_address_id;type:uuid;default:NULL" json:"from_billing_address_id"`
+	ToBillingAddressID    *string            `gorm:"column:to_billing_address_id;type:uuid;default:NULL" json:"to_billing_address_id"`
+	FromShippingAddressID *string            `gorm:"column:from_shipping_address_id;type:uuid;default:NULL" json:"from_shipping_address_id"`
+	ToShippingAddressID   *string            `gorm:"column:to_shipping_address_id;type:uuid;default:NULL" json:"to_shipping_address_id"`
+	FromTotalAmount       Money              `gorm:"column:from_total_amount" json:"from_total_amount"`
+	ToTotalAmount         Money              `gorm:"column:to_total_amount" json:"to_total_amount"`
+	Actor                 string             `gorm:"column:actor" json:"actor"`
+	Reason                string             `gorm:"column:reason" json:"reason"`
+	CreatedAt             time.Time          `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *OrderRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OrderRevisions []OrderRevision
+
+// OrderRevisionLine is an order item a revision added, removed or changed
+// the quantity of. Added lines have FromQuantity zero, removed lines
+// ToQuantity zero.
+type OrderRevisionLine struct {
+	ID              string `gorm:"column:order_revision_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_revision_line_id"`
+	OrderRevisionID string `gorm:"column:order_revision_id;type:uuid;index" json:"order_revision_id"`
+	OrderItemID     string `gorm:"column:order_item_id;type:uuid" json:"order_item_id"`
+	VariantID       string `gorm:"column:variant_id;type:uuid" json:"variant_id"`
+	FromQuantity    int32  `gorm:"column:from_quantity" json:"from_quantity"`
+	ToQuantity      int32  `gorm:"column:to_quantity" json:"to_quantity"`
+}
+
+type OrderRevisionLines []OrderRevisionLine
@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/transaction/v1"
+)
+
+// ErrOrderStatusUnsupported is returned converting a status that isn't an
+// order status.
+var ErrOrderStatusUnsupported = errors.New("order status not supported by the proto")
+
+type OrderStatus string
+
+var (
+	OrderCreated           OrderStatus = "created"
+	OrderAwaitingPayment   OrderStatus = "awaiting_payment"
+	OrderPaid              OrderStatus = "paid"
+	OrderFulfilling        OrderStatus = "fulfilling"
+	OrderFulfilled         OrderStatus = "fulfilled"
+	OrderCancelled         OrderStatus = "cancelled"
+	OrderRefunded          OrderStatus = "refunded"
+	OrderPartiallyRefunded OrderStatus = "partially_refunded"
+)
+
+// orderTransitions lists, for every status, the statuses an order may move to.
+// Cancelled and refunded are terminal. A partially refunded order can still
+// be fulfilled for the items the customer kept.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderCreated:           {OrderAwaitingPayment, OrderPaid, OrderCancelled},
+	OrderAwaitingPayment:   {OrderPaid, OrderCancelled},
+	OrderPaid:              {OrderFulfilling, OrderFulfilled, OrderPartiallyRefunded, OrderRefunded},
+	OrderFulfilling:        {OrderFulfilled, OrderPartiallyRefunded, OrderRefunded},
+	OrderFulfilled:         {OrderPartiallyRefunded, OrderRefunded},
+	OrderPartiallyRefunded: {OrderFulfilling, OrderFulfilled, OrderRefunded},
+}
+
+func (m OrderStatus) String() string {
+	if m == OrderCreated ||
+		m == OrderAwaitingPayment ||
+		m == OrderPaid ||
+		m == OrderFulfilling ||
+		m == OrderFulfilled ||
+		m == OrderCancelled ||
+		m == OrderRefunded ||
+		m == OrderPartiallyRefunded {
+		return string(m)
+	}
+	return ""
+}
+
+// ToProto converts m to the proto enum. The enum has no value for
+// awaiting_payment and partially_refunded, which are reported as the
+// closest status it has: created, as no payment was captured yet, and
+// paid, as the order keeps a captured payment.
+func (m OrderStatus) ToProto() (transaction.OrderStatus, error) {
+	switch m {
+	case OrderAwaitingPayment:
+		return transaction.OrderStatus_created, nil
+	case OrderPartiallyRefunded:
+		return transaction.OrderStatus_paid, nil
+	case OrderCreated,
+		OrderPaid,
+		OrderFulfilling,
+		OrderFulfilled,
+		OrderCancelled,
+		OrderRefunded:
+		if v, ok := transaction.OrderStatus_value[string(m)]; ok {
+			return transaction.OrderStatus(v), nil
+		}
+	}
+	return transaction.OrderStatus_created, fmt.Errorf("%w: %q", ErrOrderStatusUnsupported, m)
+}
+
+// IsSettled reports whether m is only reached by capturing a payment or
+// settling a refund, and so can't be set by hand.
+func (m OrderStatus) IsSettled() bool {
+	return m == OrderPaid ||
+		m == OrderRefunded ||
+		m == OrderPartiallyRefunded
+}
+
+// CanTransitionTo reports whether an order in status m may move to next.
+func (m OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, v := range orderTransitions[m] {
+		if v == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transition is allowed from m.
+func (m OrderStatus) IsFinal() bool {
+	return len(orderTransitions[m]) == 0
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "testing"
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderCreated, OrderAwaitingPayment, true},
+		{OrderCreated, OrderPaid, true},
+		{OrderCreated, OrderCancelled, true},
+		{OrderCreated, OrderFulfilled, false},
+		{OrderAwaitingPayment, OrderPaid, true},
+		{OrderAwaitingPayment, OrderCreated, false},
+		{OrderPaid, OrderFulfilling, true},
+		{OrderPaid, OrderCancelled, false},
+		{OrderFulfilling, OrderFulfilled, true},
+		{OrderFulfilling, OrderPaid, false},
+		{OrderFulfilled, OrderPartiallyRefunded, true},
+		{OrderFulfilled, OrderFulfilling, false},
+		{OrderPartiallyRefunded, OrderFulfilling, true},
+		{OrderPartiallyRefunded, OrderRefunded, true},
+		{OrderCancelled, OrderCreated, false},
+		{OrderRefunded, OrderPartiallyRefunded, false},
+		{OrderPaid, OrderPaid, false},
+		{OrderStatus("unknown"), OrderPaid, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
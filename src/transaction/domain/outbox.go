@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is an event for another service, saved in the transaction of
+// the change it announces and announced on Channel with its ID as the
+// NOTIFY payload. It stays pending until the consumer acknowledges it, so a
+// consumer that missed the notification replays it from the pending events.
+type OutboxEvent struct {
+	ID        string     `gorm:"column:outbox_event_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"event_id"`
+	Channel   string     `gorm:"column:channel;index:idx_outbox_event_pending,where:acked_at IS NULL" json:"channel"`
+	Payload   string     `gorm:"column:payload;type:jsonb" json:"payload"`
+	CreatedAt time.Time  `gorm:"column:created_at;index:idx_outbox_event_pending,where:acked_at IS NULL" json:"created_at"`
+	AckedAt   *time.Time `gorm:"column:acked_at" json:"acked_at"`
+}
+
+func (m *OutboxEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OutboxEvents []OutboxEvent
@@ -1,49 +1,93 @@
 package domain
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+	ErrPaymentDeclined        = errors.New("payment declined by provider")
+)
+
+// PaymentState is the lifecycle of a payment intent: it is authorized by
+// its provider, then either captured or voided.
 type PaymentState string
 
 var (
-	Unpaid PaymentState = "unpaid"
-	Paid   PaymentState = "paid"
+	PaymentPending    PaymentState = "pending"
+	PaymentAuthorized PaymentState = "authorized"
+	PaymentCaptured   PaymentState = "captured"
+	PaymentVoided     PaymentState = "voided"
+	PaymentFailed     PaymentState = "failed"
 )
 
 func (m PaymentState) String() string {
-	if m == Unpaid ||
-		m == Paid {
+	if m == PaymentPending ||
+		m == PaymentAuthorized ||
+		m == PaymentCaptured ||
+		m == PaymentVoided ||
+		m == PaymentFailed {
 		return string(m)
 	}
 	return ""
 }
 
+// IsOpen reports whether the payment can still be captured or voided.
+func (m PaymentState) IsOpen() bool {
+	return m == PaymentPending || m == PaymentAuthorized
+}
+
 type PaymentMethod string
 
 var (
-	Card         PaymentMethod = "card"
-	BankTransfer PaymentMethod = "bank_transfer"
+	Cash               PaymentMethod = "cash"
+	Card               PaymentMethod = "card"
+	BankTransfer       PaymentMethod = "bank_transfer"
+	GiftCardPayment    PaymentMethod = "gift_card"
+	StoreCreditPayment PaymentMethod = "store_credit"
 )
 
 func (m PaymentMethod) String() string {
-	if m == Card ||
-		m == BankTransfer {
+	if m == Cash ||
+		m == Card ||
+		m == BankTransfer ||
+		m == GiftCardPayment ||
+		m == StoreCreditPayment {
 		return string(m)
 	}
 	return ""
 }
 
+// IsStoredValue reports whether the method spends a gift card or store
+// credit balance kept by the system itself.
+func (m PaymentMethod) IsStoredValue() bool {
+	return m == GiftCardPayment || m == StoreCreditPayment
+}
+
+// OrderPayment is a payment intent for an order, handled by the payment
+// provider PaymentProviderID. An order can be paid with several payments,
+// e.g. part cash and part card. For cash, Tendered is the cash handed over
+// and ChangeDue what goes back to the customer. Date is set once the
+// payment is captured. Gift card payments are requested with the card code
+// as ProviderReference.
 type OrderPayment struct {
-	ID                string         `gorm:"column:order_payment_id" json:"order_payment_id"`
-	OrderID           string         `gorm:"column:order_id;type:uuid" json:"order_id"`
+	ID                string         `gorm:"column:order_payment_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_payment_id"`
+	OrganizationID    string         `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	OrderID           string         `gorm:"column:order_id;type:uuid;index" json:"order_id"`
 	PaymentProviderID string         `gorm:"column:payment_provider_id" json:"payment_provider_id"`
-	Method            string         `gorm:"column:method" json:"method"`
+	ProviderReference string         `gorm:"column:provider_reference" json:"provider_reference"`
+	Method            PaymentMethod  `gorm:"column:method" json:"method"`
+	Amount            Money          `gorm:"column:amount" json:"amount"`
+	Tendered          Money          `gorm:"column:tendered" json:"tendered"`
+	ChangeDue         Money          `gorm:"column:change_due" json:"change_due"`
+	RefundedAmount    Money          `gorm:"column:refunded_amount" json:"refunded_amount"`
 	Date              *time.Time     `gorm:"column:date" json:"date"`
-	DueDate           time.Time      `gorm:"column:due_date" json:"due_date"`
-	Status            string         `gorm:"column:status" json:"status"`
+	DueDate           *time.Time     `gorm:"column:due_date" json:"due_date"`
+	Status            PaymentState   `gorm:"column:status" json:"status"`
 	CreatedAt         time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt         time.Time      `gorm:"column:updated_at" json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
@@ -61,3 +105,50 @@ func (m *OrderPayment) BeforeUpdate(tx *gorm.DB) (err error) {
 	m.UpdatedAt = now
 	return
 }
+
+// Refundable is the captured amount not refunded yet.
+func (m *OrderPayment) Refundable() Money {
+	if m.Status != PaymentCaptured {
+		return 0
+	}
+	return m.Amount - m.RefundedAmount
+}
+
+// IsOverdue reports whether the payment is still open past its due date at t.
+func (m *OrderPayment) IsOverdue(t time.Time) bool {
+	return m.Status.IsOpen() && m.DueDate != nil && m.DueDate.Before(t)
+}
+
+type OrderPayments []OrderPayment
+
+// OrderBalance summarizes the payments of an order. Open is the amount of
+// payments authorized but not captured yet; Outstanding is what still has
+// to be requested.
+type OrderBalance struct {
+	OrderID     string `json:"order_id"`
+	TotalAmount Money  `json:"total_amount"`
+	Captured    Money  `json:"captured"`
+	Open        Money  `json:"open"`
+	Outstanding Money  `json:"outstanding"`
+}
+
+// PaymentResult is the outcome of a payment provider call. Reference is the
+// provider's identifier of the payment.
+type PaymentResult struct {
+	Reference string
+	Status    PaymentState
+}
+
+// PaymentProvider is the adapter to a payment provider. Implementations
+// return ErrPaymentDeclined when the provider refuses the operation.
+type PaymentProvider interface {
+	// ID identifies the provider in CreateOrderRequest.PaymentProvider.
+	ID() string
+	// Supports reports whether the provider handles a payment method.
+	Supports(PaymentMethod) bool
+	Authorize(context.Context, OrderPayment) (PaymentResult, error)
+	Capture(context.Context, OrderPayment) (PaymentResult, error)
+	Void(context.Context, OrderPayment) (PaymentResult, error)
+	// Refund returns amount of a captured payment to the customer.
+	Refund(ctx context.Context, payment OrderPayment, amount Money) (PaymentResult, error)
+}
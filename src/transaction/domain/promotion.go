@@ -0,0 +1,239 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromotionType string
+
+var (
+	// PercentageDiscount takes Rate percent off.
+	PercentageDiscount PromotionType = "percentage"
+	// FixedDiscount takes Amount off, once per order or once per unit.
+	FixedDiscount PromotionType = "fixed"
+	// BuyXGetY takes Rate percent off GetQuantity units of a line for
+	// every BuyQuantity units bought; 100% makes them free.
+	BuyXGetY PromotionType = "buy_x_get_y"
+)
+
+func (m PromotionType) String() string {
+	if m == PercentageDiscount ||
+		m == FixedDiscount ||
+		m == BuyXGetY {
+		return string(m)
+	}
+	return ""
+}
+
+// PromotionScope is what a promotion discounts: every matching order line,
+// or the order subtotal.
+type PromotionScope string
+
+var (
+	LineScope  PromotionScope = "line"
+	OrderScope PromotionScope = "order"
+)
+
+func (m PromotionScope) String() string {
+	if m == LineScope ||
+		m == OrderScope {
+		return string(m)
+	}
+	return ""
+}
+
+// Promotion is a discount an organization gives on orders. It applies to
+// orders placed between StartsAt and EndsAt, and each day between
+// DailyStart and DailyEnd ("15:00"-"17:00" for a happy hour), whose
+// subtotal reaches MinSubtotal. Line promotions only discount the variants
+// in Variants, or every line when Variants is empty. Promotions with a
+// CustomerGroupID only apply to the customers of that group, and
+// CouponOnly promotions to orders with one of their coupon codes. Rate is
+// the percentage of percentage and buy x get y promotions, Amount the
+// discount of fixed ones; amounts are in the base currency.
+type Promotion struct {
+	ID              string            `gorm:"column:promotion_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"promotion_id"`
+	OrganizationID  string            `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	Name            string            `gorm:"column:name" json:"name"`
+	Type            PromotionType     `gorm:"column:type" json:"type"`
+	Scope           PromotionScope    `gorm:"column:scope" json:"scope"`
+	Rate            Rate              `gorm:"column:rate" json:"rate"`
+	Amount          Money             `gorm:"column:amount" json:"amount"`
+	BuyQuantity     int32             `gorm:"column:buy_quantity" json:"buy_quantity"`
+	GetQuantity     int32             `gorm:"column:get_quantity" json:"get_quantity"`
+	MinSubtotal     Money             `gorm:"column:min_subtotal" json:"min_subtotal"`
+	StartsAt        *time.Time        `gorm:"column:starts_at" json:"starts_at"`
+	EndsAt          *time.Time        `gorm:"column:ends_at" json:"ends_at"`
+	DailyStart      string            `gorm:"column:daily_start" json:"daily_start"`
+	DailyEnd        string            `gorm:"column:daily_end" json:"daily_end"`
+	CustomerGroupID *string           `gorm:"column:customer_group_id;type:uuid;default:NULL" json:"customer_group_id"`
+	CouponOnly      bool              `gorm:"column:coupon_only" json:"coupon_only"`
+	Variants        PromotionVariants `gorm:"foreignKey:PromotionID" json:"variants"`
+	Priority        int32             `gorm:"column:priority" json:"priority"`
+	Active          bool              `gorm:"column:active" json:"active"`
+	CreatedAt       time.Time         `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt       time.Time         `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt       gorm.DeletedAt    `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *Promotion) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *Promotion) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// Validate checks that the promotion is consistent.
+func (m *Promotion) Validate() error {
+	if m.Type.String() == "" {
+		return errors.New("invalid promotion type")
+	}
+
+	if m.Scope.String() == "" {
+		return errors.New("invalid promotion scope")
+	}
+
+	if m.Type == FixedDiscount {
+		if m.Amount <= 0 {
+			return errors.New("invalid promotion amount")
+		}
+	} else if m.Rate <= 0 || m.Rate > HundredPercent {
+		return errors.New("invalid promotion rate")
+	}
+
+	if m.Type == BuyXGetY && (m.Scope != LineScope || m.BuyQuantity <= 0 || m.GetQuantity <= 0) {
+		return errors.New("buy x get y needs line scope and buy and get quantities")
+	}
+
+	if m.StartsAt != nil && m.EndsAt != nil && !m.EndsAt.After(*m.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	if (m.DailyStart == "") != (m.DailyEnd == "") {
+		return errors.New("daily_start and daily_end go together")
+	}
+
+	for _, v := range []string{m.DailyStart, m.DailyEnd} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", v); err != nil {
+			return fmt.Errorf("invalid time of day %q", v)
+		}
+	}
+
+	return nil
+}
+
+// ActiveAt reports whether the promotion runs at t. Daily windows are wall
+// clock times in loc, the timezone of the organization or of the location
+// the order is placed at; windows ending before they start span midnight.
+func (m *Promotion) ActiveAt(t time.Time, loc *time.Location) bool {
+	if !m.Active ||
+		(m.StartsAt != nil && t.Before(*m.StartsAt)) ||
+		(m.EndsAt != nil && !t.Before(*m.EndsAt)) {
+		return false
+	}
+
+	if m.DailyStart == "" {
+		return true
+	}
+
+	now := t.In(loc).Format("15:04")
+	if m.DailyStart <= m.DailyEnd {
+		return now >= m.DailyStart && now < m.DailyEnd
+	}
+	return now >= m.DailyStart || now < m.DailyEnd
+}
+
+// AppliesTo reports whether a line promotion discounts variantID.
+func (m *Promotion) AppliesTo(variantID string) bool {
+	if len(m.Variants) == 0 {
+		return true
+	}
+
+	for _, v := range m.Variants {
+		if v.VariantID == variantID {
+			return true
+		}
+	}
+	return false
+}
+
+type Promotions []Promotion
+
+// PromotionVariant restricts a line promotion to a variant.
+type PromotionVariant struct {
+	PromotionID string `gorm:"column:promotion_id;type:uuid;primaryKey" json:"promotion_id"`
+	VariantID   string `gorm:"column:variant_id;type:uuid;primaryKey" json:"variant_id"`
+}
+
+type PromotionVariants []PromotionVariant
+
+// CustomerGroup is a set of customers promotions can target, such as
+// members or staff.
+type CustomerGroup struct {
+	ID             string         `gorm:"column:customer_group_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"customer_group_id"`
+	OrganizationID string         `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	Name           string         `gorm:"column:name" json:"name"`
+	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *CustomerGroup) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *CustomerGroup) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type CustomerGroups []CustomerGroup
+
+type CustomerGroupMember struct {
+	CustomerGroupID string    `gorm:"column:customer_group_id;type:uuid;primaryKey" json:"customer_group_id"`
+	CustomerID      string    `gorm:"column:customer_id;type:uuid;primaryKey;index" json:"customer_id"`
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *CustomerGroupMember) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+// OrderDiscount is a discount applied to an order by a promotion. Line
+// discounts have an OrderItemID; order discounts don't. CouponID is the
+// coupon that unlocked the promotion, if any.
+type OrderDiscount struct {
+	ID          string    `gorm:"column:order_discount_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_discount_id"`
+	OrderID     string    `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	OrderItemID *string   `gorm:"column:order_item_id;type:uuid;default:NULL" json:"order_item_id"`
+	PromotionID string    `gorm:"column:promotion_id;type:uuid;index" json:"promotion_id"`
+	CouponID    *string   `gorm:"column:coupon_id;type:uuid;default:NULL" json:"coupon_id"`
+	Name        string    `gorm:"column:name" json:"name"`
+	Amount      Money     `gorm:"column:amount" json:"amount"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *OrderDiscount) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OrderDiscounts []OrderDiscount
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPromotionActiveAt(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, time.March, 14, hour, minute, 0, 0, wib)
+	}
+
+	startsAt := at(12, 0)
+	endsAt := at(18, 0)
+
+	tests := []struct {
+		name      string
+		promotion Promotion
+		t         time.Time
+		loc       *time.Location
+		want      bool
+	}{
+		{"inactive", Promotion{}, at(12, 0), wib, false},
+		{"no window", Promotion{Active: true}, at(3, 0), wib, true},
+		{"before starts_at", Promotion{Active: true, StartsAt: &startsAt}, at(11, 59), wib, false},
+		{"at starts_at", Promotion{Active: true, StartsAt: &startsAt}, at(12, 0), wib, true},
+		{"at ends_at", Promotion{Active: true, EndsAt: &endsAt}, at(18, 0), wib, false},
+		{"within daily window", Promotion{Active: true, DailyStart: "15:00", DailyEnd: "17:00"}, at(15, 30), wib, true},
+		{"at daily end", Promotion{Active: true, DailyStart: "15:00", DailyEnd: "17:00"}, at(17, 0), wib, false},
+		{"before daily window", Promotion{Active: true, DailyStart: "15:00", DailyEnd: "17:00"}, at(14, 59), wib, false},
+		{"across midnight before midnight", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(23, 30), wib, true},
+		{"across midnight after midnight", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(1, 59), wib, true},
+		{"across midnight at daily end", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(2, 0), wib, false},
+		{"across midnight outside", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(12, 0), wib, false},
+		// 23:30 WIB is 16:30 UTC, outside a 22:00-02:00 window kept in UTC.
+		{"window in the location timezone", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(23, 30), time.UTC, false},
+		// 08:30 WIB is 01:30 UTC, inside it.
+		{"across midnight in another timezone", Promotion{Active: true, DailyStart: "22:00", DailyEnd: "02:00"}, at(8, 30), time.UTC, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promotion.ActiveAt(tt.t, tt.loc); got != tt.want {
+				t.Errorf("ActiveAt(%s, %s) = %v, want %v", tt.t.Format(time.RFC3339), tt.loc, got, tt.want)
+			}
+		})
+	}
+}
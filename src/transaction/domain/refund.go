@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefundDestination string
+
+var (
+	RefundToOriginalPayment RefundDestination = "original_payment"
+	RefundToCash            RefundDestination = "cash"
+	RefundToStoreCredit     RefundDestination = "store_credit"
+)
+
+func (m RefundDestination) String() string {
+	if m == RefundToOriginalPayment ||
+		m == RefundToCash ||
+		m == RefundToStoreCredit {
+		return string(m)
+	}
+	return ""
+}
+
+// RefundState is where a refund stands. Refunds to the original payment are
+// pending while the payment provider is called, and their amount and
+// returned items stay booked until they succeed or fail.
+type RefundState string
+
+var (
+	RefundPending   RefundState = "pending"
+	RefundSucceeded RefundState = "succeeded"
+	RefundFailed    RefundState = "failed"
+)
+
+func (m RefundState) String() string {
+	if m == RefundPending ||
+		m == RefundSucceeded ||
+		m == RefundFailed {
+		return string(m)
+	}
+	return ""
+}
+
+// OrderRefund gives money back for returned order items, or for captured
+// payments of a cancelled order. It is linked to the captured OrderPayment
+// it is refunded against, whatever the destination of the money. When
+// RestockLocationID is set, returned items go back into stock there.
+type OrderRefund struct {
+	ID                string            `gorm:"column:order_refund_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_refund_id"`
+	OrganizationID    string            `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	OrderID           string            `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	OrderPaymentID    string            `gorm:"column:order_payment_id;type:uuid" json:"order_payment_id"`
+	Destination       RefundDestination `gorm:"column:destination" json:"destination"`
+	ProviderReference string            `gorm:"column:provider_reference" json:"provider_reference"`
+	RestockLocationID *string           `gorm:"column:restock_location_id;type:uuid;default:NULL" json:"restock_location_id"`
+	SubTotal          Money             `gorm:"column:sub_total" json:"sub_total"`
+	TaxAmount         Money             `gorm:"column:tax_amount" json:"tax_amount"`
+	Amount            Money             `gorm:"column:amount" json:"amount"`
+	Reason            string            `gorm:"column:reason" json:"reason"`
+	Actor             string            `gorm:"column:actor" json:"actor"`
+	Status            RefundState       `gorm:"column:status;default:succeeded" json:"status"`
+	Lines             OrderRefundLines  `gorm:"foreignKey:OrderRefundID" json:"lines"`
+	CreatedAt         time.Time         `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time         `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt    `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *OrderRefund) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *OrderRefund) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+type OrderRefunds []OrderRefund
+
+// OrderRefundLine is the quantity of an order item returned by a refund.
+type OrderRefundLine struct {
+	ID            string    `gorm:"column:order_refund_line_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"order_refund_line_id"`
+	OrderRefundID string    `gorm:"column:order_refund_id;type:uuid" json:"order_refund_id"`
+	OrderItemID   string    `gorm:"column:order_item_id;type:uuid" json:"order_item_id"`
+	Quantity      int32     `gorm:"column:quantity" json:"quantity"`
+	SubTotal      Money     `gorm:"column:sub_total" json:"sub_total"`
+	TaxAmount     Money     `gorm:"column:tax_amount" json:"tax_amount"`
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *OrderRefundLine) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type OrderRefundLines []OrderRefundLine
+
+// OrderReturnedChannel is the outbox channel returned items to restock are
+// published on. The event payload is the JSON encoded OrderReturnedEvent.
+const OrderReturnedChannel = "transaction_order_returned"
+
+// OrderReturnedEvent asks the inventory service to put returned items back
+// into stock at a location.
+type OrderReturnedEvent struct {
+	OrderRefundID  string                   `json:"order_refund_id"`
+	OrderID        string                   `json:"order_id"`
+	OrganizationID string                   `json:"organization_id"`
+	LocationID     string                   `json:"location_id"`
+	Items          []OrderFulfilledLineItem `json:"items"`
+}
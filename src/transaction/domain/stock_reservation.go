@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockReservation is a change to the stock an order item holds in the
+// inventory service that isn't settled yet.
+//
+// A reservation is recorded before the inventory is called for an order,
+// or an edit of one, not saved yet, and deleted with the order or the
+// revision of the edit saving, so the reservations of orders and edits
+// lost to a crash can be found and released. Reservations of new orders
+// take the ID of their order item.
+//
+// A release (Release set) is recorded along with the change of a saved
+// order that frees stock, and deleted once the inventory service released
+// it, so releases failing after the change committed are retried.
+type StockReservation struct {
+	ID              string    `gorm:"column:stock_reservation_id;type:uuid;primaryKey" json:"stock_reservation_id"`
+	OrderItemID     string    `gorm:"column:order_item_id;type:uuid;index" json:"order_item_id"`
+	OrderID         string    `gorm:"column:order_id;type:uuid;index" json:"order_id"`
+	OrderRevisionID *string   `gorm:"column:order_revision_id;type:uuid;default:NULL;index" json:"order_revision_id"`
+	InventoryItemID string    `gorm:"column:inventory_item_id;type:uuid" json:"inventory_item_id"`
+	Quantity        int32     `gorm:"column:quantity" json:"quantity"`
+	Release         bool      `gorm:"column:release;default:false" json:"release"`
+	CreatedAt       time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (m *StockReservation) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+// ReserveKey and ReleaseKey are the idempotency keys the reservation is
+// made and released with, so either can be retried without reserving or
+// releasing twice.
+func (m StockReservation) ReserveKey() string {
+	return "stock-reservation-reserve-" + m.ID
+}
+
+func (m StockReservation) ReleaseKey() string {
+	return "stock-reservation-release-" + m.ID
+}
+
+type StockReservations []StockReservation
@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownGiftCard     = errors.New("unknown gift card")
+	ErrGiftCardExpired     = errors.New("gift card expired")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// StoredValueAccount is the kind of account a stored value ledger entry
+// moves: a gift card or a customer's store credit wallet.
+type StoredValueAccount string
+
+var (
+	GiftCardAccount    StoredValueAccount = "gift_card"
+	StoreCreditAccount StoredValueAccount = "store_credit"
+)
+
+func (m StoredValueAccount) String() string {
+	if m == GiftCardAccount ||
+		m == StoreCreditAccount {
+		return string(m)
+	}
+	return ""
+}
+
+type StoredValueEntryType string
+
+var (
+	// StoredValueIssue loads a gift card when it is sold or handed out.
+	StoredValueIssue StoredValueEntryType = "issue"
+	// StoredValueRedeem spends balance on an order payment.
+	StoredValueRedeem StoredValueEntryType = "redeem"
+	// StoredValueVoid gives back the balance of a voided payment.
+	StoredValueVoid StoredValueEntryType = "void"
+	// StoredValueRefund credits a refund.
+	StoredValueRefund StoredValueEntryType = "refund"
+	// StoredValueAdjust is a manual correction by staff.
+	StoredValueAdjust StoredValueEntryType = "adjust"
+)
+
+// GiftCard is a prepaid card redeemable on orders with its code. Cards sold
+// on an order keep the order item they were sold with. Amounts are in the
+// organization's base currency.
+type GiftCard struct {
+	ID             string         `gorm:"column:gift_card_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"gift_card_id"`
+	OrganizationID string         `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_gift_card_organization_code" json:"organization_id"`
+	Code           string         `gorm:"column:code;uniqueIndex:idx_gift_card_organization_code" json:"code"`
+	InitialAmount  Money          `gorm:"column:initial_amount" json:"initial_amount"`
+	Balance        Money          `gorm:"column:balance" json:"balance"`
+	OrderID        *string        `gorm:"column:order_id;type:uuid;default:NULL;index" json:"order_id"`
+	OrderItemID    *string        `gorm:"column:order_item_id;type:uuid;default:NULL" json:"order_item_id"`
+	ExpiresAt      *time.Time     `gorm:"column:expires_at" json:"expires_at"`
+	Active         bool           `gorm:"column:active" json:"active"`
+	CreatedAt      time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at" json:"-"`
+}
+
+func (m *GiftCard) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *GiftCard) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// Usable reports why the gift card can't be redeemed at t, if it can't.
+func (m *GiftCard) Usable(t time.Time) error {
+	if !m.Active {
+		return ErrUnknownGiftCard
+	}
+
+	if m.ExpiresAt != nil && !t.Before(*m.ExpiresAt) {
+		return ErrGiftCardExpired
+	}
+
+	return nil
+}
+
+type GiftCards []GiftCard
+
+// GiftCardVariant marks a variant whose sale issues a gift card worth its
+// unit price for every unit paid.
+type GiftCardVariant struct {
+	OrganizationID string    `gorm:"column:organization_id;type:uuid;index" json:"organization_id"`
+	VariantID      string    `gorm:"column:variant_id;type:uuid;primaryKey" json:"variant_id"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (m *GiftCardVariant) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+// StoreCreditWallet holds the store credit of a customer, such as refunds
+// given as credit instead of cash, in the base currency.
+type StoreCreditWallet struct {
+	ID             string    `gorm:"column:store_credit_wallet_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"store_credit_wallet_id"`
+	OrganizationID string    `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_store_credit_wallet_customer" json:"organization_id"`
+	CustomerID     string    `gorm:"column:customer_id;type:uuid;uniqueIndex:idx_store_credit_wallet_customer" json:"customer_id"`
+	Balance        Money     `gorm:"column:balance" json:"balance"`
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (m *StoreCreditWallet) BeforeCreate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return
+}
+
+func (m *StoreCreditWallet) BeforeUpdate(tx *gorm.DB) (err error) {
+	now := time.Now()
+	m.UpdatedAt = now
+	return
+}
+
+// StoredValueEntry is a change to the balance of a gift card or store
+// credit wallet. Amount is positive for credits and negative for debits;
+// Balance is the account balance after the entry. Balances only change
+// through entries, so the history of an account always adds up to it.
+type StoredValueEntry struct {
+	ID             string               `gorm:"column:stored_value_entry_id;type:uuid;default:uuid_generate_v4();primaryKey" json:"stored_value_entry_id"`
+	OrganizationID string               `gorm:"column:organization_id;type:uuid" json:"organization_id"`
+	Account        StoredValueAccount   `gorm:"column:account;index:idx_stored_value_entry_account" json:"account"`
+	AccountID      string               `gorm:"column:account_id;type:uuid;index:idx_stored_value_entry_account" json:"account_id"`
+	Type           StoredValueEntryType `gorm:"column:type" json:"type"`
+	Amount         Money                `gorm:"column:amount" json:"amount"`
+	Balance        Money                `gorm:"column:balance" json:"balance"`
+	OrderID        *string              `gorm:"column:order_id;type:uuid;default:NULL" json:"order_id"`
+	OrderPaymentID *string              `gorm:"column:order_payment_id;type:uuid;default:NULL" json:"order_payment_id"`
+	OrderRefundID  *string              `gorm:"column:order_refund_id;type:uuid;default:NULL" json:"order_refund_id"`
+	Actor          string               `gorm:"column:actor" json:"actor"`
+	Note           string               `gorm:"column:note" json:"note"`
+	CreatedAt      time.Time            `gorm:"column:created_at;index:idx_stored_value_entry_account" json:"created_at"`
+}
+
+func (m *StoredValueEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	m.CreatedAt = time.Now()
+	return
+}
+
+type StoredValueEntries []StoredValueEntry
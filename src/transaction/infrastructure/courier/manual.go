@@ -0,0 +1,32 @@
+package courier
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smallbiznis/transaction/domain"
+)
+
+// ManualCourier is used for shipments booked outside the system, or handed
+// over by staff. The waybill number is entered by hand and tracking events
+// are recorded through the API.
+type ManualCourier struct{}
+
+func NewManualCourier() *ManualCourier {
+	return &ManualCourier{}
+}
+
+func (c *ManualCourier) ID() string {
+	return "manual"
+}
+
+func (c *ManualCourier) CreateShipment(ctx context.Context, shipping domain.OrderShipping, waybill string) (string, error) {
+	if waybill == "" {
+		return "", errors.New("waybill number is required for manual shipments")
+	}
+	return waybill, nil
+}
+
+func (c *ManualCourier) Track(ctx context.Context, shipping domain.OrderShipping) (domain.ShippingHistories, error) {
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/transaction/domain"
+)
+
+// FakeProvider is a card provider for development and tests. It declines
+// every amount whose last two minor digits are 13, such as 10.13, so
+// failure paths can be exercised without a real provider.
+type FakeProvider struct{}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) ID() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Supports(method domain.PaymentMethod) bool {
+	return method == domain.Card
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	if declined(payment.Amount) {
+		return domain.PaymentResult{Status: domain.PaymentFailed}, domain.ErrPaymentDeclined
+	}
+	return domain.PaymentResult{Reference: "fake_" + uuid.NewString(), Status: domain.PaymentAuthorized}, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
+
+func (p *FakeProvider) Void(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentVoided}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, payment domain.OrderPayment, amount domain.Money) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
+
+func declined(amount domain.Money) bool {
+	return amount%100 == 13
+}
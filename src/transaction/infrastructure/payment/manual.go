@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"context"
+
+	"github.com/smallbiznis/transaction/domain"
+)
+
+// ManualProvider records payments taken outside the system, such as cash at
+// the counter, a card terminal that isn't integrated or a bank transfer.
+// Staff capture the payment once the money has been received.
+type ManualProvider struct{}
+
+func NewManualProvider() *ManualProvider {
+	return &ManualProvider{}
+}
+
+func (p *ManualProvider) ID() string {
+	return "manual"
+}
+
+func (p *ManualProvider) Supports(method domain.PaymentMethod) bool {
+	return method.String() != "" && !method.IsStoredValue()
+}
+
+func (p *ManualProvider) Authorize(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ID, Status: domain.PaymentAuthorized}, nil
+}
+
+func (p *ManualProvider) Capture(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
+
+func (p *ManualProvider) Void(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentVoided}, nil
+}
+
+func (p *ManualProvider) Refund(ctx context.Context, payment domain.OrderPayment, amount domain.Money) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
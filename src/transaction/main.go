@@ -3,12 +3,17 @@ package main
 import (
 	"context"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/rpc"
 	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
 	"github.com/smallbiznis/go-genproto/smallbiznis/transaction/v1"
 	"github.com/smallbiznis/go-lib/pkg/env"
@@ -16,13 +21,18 @@ import (
 	"github.com/smallbiznis/go-lib/pkg/otelcol"
 	"github.com/smallbiznis/go-lib/pkg/server"
 	grpchandler "github.com/smallbiznis/transaction/delivery/grpc"
+	"github.com/smallbiznis/transaction/domain"
 	"github.com/smallbiznis/transaction/infrastructure"
+	"github.com/smallbiznis/transaction/infrastructure/courier"
+	"github.com/smallbiznis/transaction/infrastructure/payment"
 	"github.com/smallbiznis/transaction/repository"
+	"github.com/smallbiznis/transaction/service"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
 )
 
 func NewZapLogger() fxevent.Logger {
@@ -48,6 +58,17 @@ func NewOrganizationServiceClient() (organization.ServiceClient, error) {
 	return organization.NewServiceClient(conn), nil
 }
 
+// NewOrganizationConn connects to the organization service, for its
+// rpc.Services.
+func NewOrganizationConn() (service.OrganizationConn, error) {
+	return grpc.NewClient(env.Lookup("ORGANIZATION_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+// NewCustomerConn connects to the customer service, for its rpc.Services.
+func NewCustomerConn() (service.CustomerConn, error) {
+	return grpc.NewClient(env.Lookup("CUSTOMER_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
 func NewCustomerServiceClient() (customer.CustomerServiceClient, error) {
 	conn, err := grpc.NewClient(env.Lookup("CUSTOMER_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
@@ -64,8 +85,47 @@ func NewInventoryServiceClient() (inventory.ServiceClient, error) {
 	return inventory.NewServiceClient(conn), nil
 }
 
-func RegisterServiceServer(srv *grpc.Server, svc *grpchandler.TransactionService) {
-	transaction.RegisterTransactionServiceServer(srv, svc)
+// NewItemConn connects to the item service, for its rpc.Services.
+func NewItemConn() (service.ItemConn, error) {
+	return grpc.NewClient(env.Lookup("ITEM_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func NewItemServiceClient() (item.ServiceClient, error) {
+	conn, err := grpc.NewClient(env.Lookup("ITEM_ADDR", ":4317"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return item.NewServiceClient(conn), nil
+}
+
+// NewPaymentProviders lists the payment provider adapters orders can be paid
+// with. The fake provider is only available outside production.
+func NewPaymentProviders(storedValueService *service.StoredValueService) []domain.PaymentProvider {
+	providers := []domain.PaymentProvider{
+		payment.NewManualProvider(),
+		storedValueService.GiftCardProvider(),
+		storedValueService.StoreCreditProvider(),
+	}
+
+	if os.Getenv("ENV") != "production" {
+		providers = append(providers, payment.NewFakeProvider())
+	}
+
+	return providers
+}
+
+// NewCouriers lists the courier adapters fulfillments can be shipped with.
+func NewCouriers() []domain.Courier {
+	return []domain.Courier{
+		courier.NewManualCourier(),
+	}
+}
+
+// RegisterServiceServer registers the service with calls to the methods
+// changing state carrying an idempotency-key header handled at most once.
+func RegisterServiceServer(srv *grpc.Server, db *gorm.DB, svc *grpchandler.TransactionService) {
+	srv.RegisterService(idempotency.Wrap(&transaction.TransactionService_ServiceDesc, idempotency.NewInterceptor(db, grpchandler.IdempotentHeaders...),
+		idempotency.Methods(&transaction.TransactionService_ServiceDesc, "CreateOrder", "UpdateOrder")...), svc)
 }
 
 func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
@@ -76,6 +136,55 @@ func RegisterServiceHandlerFromEndpoint(mux *runtime.ServeMux) error {
 	return transaction.RegisterTransactionServiceHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts)
 }
 
+// RPCServices are the services go-genproto has no messages for, served as
+// rpc.Services.
+type RPCServices struct {
+	fx.In
+	Order       *grpchandler.TransactionService
+	Outbox      *grpchandler.OutboxService
+	Payment     *grpchandler.PaymentService
+	Refund      *grpchandler.RefundService
+	Fulfillment *grpchandler.FulfillmentService
+	Numbering   *grpchandler.NumberingService
+	Promotion   *grpchandler.PromotionService
+	Coupon      *grpchandler.CouponService
+	StoredValue *grpchandler.StoredValueService
+	Cart        *grpchandler.CartService
+	OrderEdit   *grpchandler.OrderEditService
+	OrderSearch *grpchandler.OrderSearchService
+}
+
+// RegisterRPCServices registers the RPC services on the gRPC server and the
+// HTTP gateway, with the same idempotency handling as the transaction service.
+func RegisterRPCServices(srv *grpc.Server, mux *runtime.ServeMux, db *gorm.DB, services RPCServices) error {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+
+	for _, svc := range []*rpc.Service{
+		services.Order.Service(),
+		services.Outbox.Service(),
+		services.Payment.Service(),
+		services.Refund.Service(),
+		services.Fulfillment.Service(),
+		services.Numbering.Service(),
+		services.Promotion.Service(),
+		services.Coupon.Service(),
+		services.StoredValue.Service(),
+		services.Cart.Service(),
+		services.OrderEdit.Service(),
+		services.OrderSearch.Service(),
+	} {
+		srv.RegisterService(idempotency.Wrap(svc.Desc(), idempotency.NewInterceptor(db, grpchandler.IdempotentHeaders...), svc.Commands()...), nil)
+
+		if err := svc.RegisterHandlerFromEndpoint(context.Background(), mux, env.Lookup("GRPC_PORT", ":4317"), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -88,6 +197,104 @@ func StartHTTPServer(lc fx.Lifecycle, srv *http.Server) {
 	})
 }
 
+// NewCartConfig reads how long carts live after their last change from
+// CART_TTL, a week by default.
+func NewCartConfig() (service.CartConfig, error) {
+	ttl, err := time.ParseDuration(env.Lookup("CART_TTL", "168h"))
+	if err != nil {
+		return service.CartConfig{}, err
+	}
+	return service.CartConfig{TTL: ttl}, nil
+}
+
+// StartCartSweeper periodically marks the carts past their expiry expired.
+func StartCartSweeper(lc fx.Lifecycle, svc *service.CartService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(time.Minute)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						if _, err := svc.ExpireCarts(ctx); err != nil {
+							zap.L().Error("failed expire carts", zap.Error(err))
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
+// StartReservationSweeper periodically releases the stock reserved for
+// orders that were never saved.
+func StartReservationSweeper(lc fx.Lifecycle, svc *service.StockService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(time.Minute)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						if _, err := svc.ReleaseOrphans(ctx); err != nil {
+							zap.L().Error("failed release orphaned stock", zap.Error(err))
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
+// StartLayawaySweeper periodically cancels orders whose layaway expired.
+func StartLayawaySweeper(lc fx.Lifecycle, svc *service.LayawayService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(time.Minute)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						if _, err := svc.ExpireLayaways(ctx); err != nil {
+							zap.L().Error("failed expire layaways", zap.Error(err))
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -101,18 +308,54 @@ func main() {
 		server.GrpcServerProvider,
 		fx.Provide(
 			NewOrganizationServiceClient,
+			NewOrganizationConn,
 			NewCustomerServiceClient,
+			NewCustomerConn,
 			NewInventoryServiceClient,
+			NewItemServiceClient,
+			NewItemConn,
+			NewPaymentProviders,
+			NewCouriers,
+			NewCartConfig,
 		),
 		fx.Provide(
 			repository.NewOrderRepository,
+			service.NewOrderService,
+			service.NewOrderEditService,
+			service.NewPricingService,
+			service.NewStockService,
+			service.NewPaymentService,
+			service.NewLayawayService,
+			service.NewRefundService,
+			service.NewFulfillmentService,
+			service.NewNumberingService,
+			service.NewPromotionService,
+			service.NewCouponService,
+			service.NewStoredValueService,
+			service.NewCartService,
+			service.NewOutboxService,
 			grpchandler.NewTransactionService,
+			grpchandler.NewOutboxService,
+			grpchandler.NewPaymentService,
+			grpchandler.NewRefundService,
+			grpchandler.NewFulfillmentService,
+			grpchandler.NewNumberingService,
+			grpchandler.NewPromotionService,
+			grpchandler.NewCouponService,
+			grpchandler.NewStoredValueService,
+			grpchandler.NewCartService,
+			grpchandler.NewOrderEditService,
+			grpchandler.NewOrderSearchService,
 		),
 		fx.Provide(NewServeMux, NewHttpServer),
 		fx.Invoke(
 			RegisterServiceServer,
 			StartHTTPServer,
 			RegisterServiceHandlerFromEndpoint,
+			RegisterRPCServices,
+			StartLayawaySweeper,
+			StartCartSweeper,
+			StartReservationSweeper,
 		),
 		server.GrpcServerInvoke,
 	)
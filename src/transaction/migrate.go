@@ -1,25 +1,160 @@
 package main
 
 import (
+	"fmt"
+
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/common/migration"
 	"github.com/smallbiznis/transaction/domain"
 	"gorm.io/gorm"
 )
 
 func Automigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// Amounts must be converted before AutoMigrate would cast the decimal
+	// columns to bigint and truncate them.
+	if err := migrateMoney(db); err != nil {
+		return err
+	}
+
+	// Duplicates must be renumbered before AutoMigrate would fail to create
+	// the unique index on order numbers.
+	if err := dedupeOrderNumbers(db); err != nil {
+		return err
+	}
+
+	// Stock reservations need their own keys before AutoMigrate would add
+	// the primary key column empty.
+	if err := keyStockReservations(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(
+		&idempotency.Key{},
 		&domain.Order{},
-		// &domain.OrderBillingAddress{},
-		// &domain.OrderShippingAddress{},
+		&domain.OrderNumberSequence{},
+		&domain.OrderBillingAddress{},
+		&domain.OrderShippingAddress{},
 		&domain.OrderItem{},
-		// &domain.OrderPayment{},
-		// &domain.OrderFulfillment{},
-		// &domain.OrderShipping{},
-		// &domain.ShippingHistory{},
-	)
+		&domain.StockReservation{},
+		&domain.OrderEvent{},
+		&domain.OrderRevision{},
+		&domain.OrderRevisionLine{},
+		&domain.Promotion{},
+		&domain.PromotionVariant{},
+		&domain.CustomerGroup{},
+		&domain.CustomerGroupMember{},
+		&domain.Coupon{},
+		&domain.CouponRedemption{},
+		&domain.OrderDiscount{},
+		&domain.OrderPayment{},
+		&domain.OrderRefund{},
+		&domain.OrderRefundLine{},
+		&domain.GiftCard{},
+		&domain.GiftCardVariant{},
+		&domain.StoreCreditWallet{},
+		&domain.StoredValueEntry{},
+		&domain.Cart{},
+		&domain.CartLine{},
+		&domain.CartCoupon{},
+		&domain.OrderFulfillment{},
+		&domain.OrderFulfillmentItem{},
+		&domain.OrderShipping{},
+		&domain.ShippingHistory{},
+		&domain.OutboxEvent{},
+	); err != nil {
+		return err
+	}
+
+	return Migrate(db)
+}
+
+// keyStockReservations keys the stock reservations, keyed by their order
+// item until edits could reserve for an item again, by their own ID.
+// Existing reservations take the ID of their order item, which their
+// idempotency keys were made from. It runs until the column exists.
+func keyStockReservations(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&domain.StockReservation{}) || db.Migrator().HasColumn(&domain.StockReservation{}, "stock_reservation_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range []string{
+			`ALTER TABLE stock_reservations ADD COLUMN stock_reservation_id uuid`,
+			`UPDATE stock_reservations SET stock_reservation_id = order_item_id`,
+			`ALTER TABLE stock_reservations DROP CONSTRAINT stock_reservations_pkey`,
+			`ALTER TABLE stock_reservations ADD PRIMARY KEY (stock_reservation_id)`,
+		} {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dedupeOrderNumbers renumbers the orders sharing an order number within
+// their organization, numbered by hand or before numbers were allocated
+// under a lock. The oldest order keeps the number; the others get a
+// -DUP-n suffix staff can search for. It runs until the unique index
+// exists.
+func dedupeOrderNumbers(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&domain.Order{}) || db.Migrator().HasIndex(&domain.Order{}, "idx_order_organization_order_no") {
+		return nil
+	}
+
+	return db.Exec(`UPDATE orders SET order_no = orders.order_no || '-DUP-' || duplicates.n
+		FROM (SELECT order_id, ROW_NUMBER() OVER (PARTITION BY organization_id, order_no ORDER BY created_at, order_id) AS n FROM orders) duplicates
+		WHERE duplicates.order_id = orders.order_id AND duplicates.n > 1`).Error
+}
+
+// orderCurrency is the currency of the order of a row of table.
+func orderCurrency(table string) string {
+	return fmt.Sprintf(`COALESCE((SELECT orders.currency FROM orders
+		WHERE orders.order_id = %s.order_id), '%s')`, table, domain.DefaultCurrency)
+}
+
+// migrateMoney converts the decimal amounts of the orders and order items
+// stored before Money to minor units of their currency, once, with the
+// rounding of domain.NewMoney.
+func migrateMoney(db *gorm.DB) error {
+	return migration.Once(db, "transaction/0001-money", func(tx *gorm.DB) (err error) {
+		// Orders own the currency of everything recorded against them.
+		if tx.Migrator().HasTable(&domain.Order{}) && !tx.Migrator().HasColumn(&domain.Order{}, "Currency") {
+			if err = tx.Migrator().AddColumn(&domain.Order{}, "Currency"); err != nil {
+				return
+			}
+
+			if err = tx.Exec("UPDATE orders SET currency = " + migration.BaseCurrency(tx, "orders")).Error; err != nil {
+				return
+			}
+		}
+
+		if err = migration.ConvertMoney(tx, "orders", "currency", "sub_total", "tax_amount", "total_amount"); err != nil {
+			return
+		}
+
+		return migration.ConvertMoney(tx, "order_items", orderCurrency("order_items"), "unit_price", "total_price")
+	})
 }
 
 func Migrate(db *gorm.DB) (err error) {
-	return db.Transaction(func(tx *gorm.DB) (err error) {
+	if err = db.Transaction(func(tx *gorm.DB) (err error) {
+		// Orders placed before selling currencies were in the base currency.
+		return tx.Exec(`UPDATE orders SET base_currency = currency, exchange_rate = ?
+			WHERE exchange_rate IS NULL OR exchange_rate = 0`, domain.BaseRate).Error
+	}); err != nil {
 		return
+	}
+
+	return migrateOrderItemTax(db)
+}
+
+// migrateOrderItemTax spreads the tax of the orders priced before order
+// items kept their own over their items, in proportion to the item totals
+// and rounded per item, once.
+func migrateOrderItemTax(db *gorm.DB) error {
+	return migration.Once(db, "transaction/0002-order-item-tax", func(tx *gorm.DB) error {
+		return tx.Exec(`UPDATE order_items SET tax_amount = ROUND(orders.tax_amount::numeric * order_items.total_price / orders.sub_total)
+			FROM orders WHERE orders.order_id = order_items.order_id AND orders.sub_total > 0`).Error
 	})
 }
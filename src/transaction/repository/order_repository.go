@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/smallbiznis/go-lib/pkg/pagination"
 	"github.com/smallbiznis/transaction/domain"
@@ -25,6 +26,9 @@ func NewOrderRepository(
 func (r *orderRepository) Find(ctx context.Context, p pagination.Pagination, f domain.Order) (orders domain.Orders, count int64, err error) {
 	stmt := r.db.WithContext(ctx).Model(&domain.Order{}).
 		Preload("OrderItems").
+		Preload("BillingAddress").
+		Preload("ShippingAddress").
+		Preload("Discounts").
 		Where(&f).
 		Count(&count).
 		Scopes(p.Paginate())
@@ -42,9 +46,149 @@ func (r *orderRepository) Find(ctx context.Context, p pagination.Pagination, f d
 	return
 }
 
+// defaultOrderPageSize is the page size of Search when none is given.
+const defaultOrderPageSize = 10
+
+// Search lists the orders matching f, and the cursor to continue after the
+// last of them when more follow. Orders are counted only without a cursor,
+// on the first page; with one, count is zero and p only sets the page size.
+func (r *orderRepository) Search(ctx context.Context, p pagination.Pagination, f domain.OrderFilter) (orders domain.Orders, count int64, next *domain.OrderCursor, err error) {
+	sort := f.Sort
+	if sort.String() == "" {
+		sort, f.Descending = domain.SortCreatedAt, true
+	}
+
+	size := p.Size
+	if size <= 0 {
+		size = defaultOrderPageSize
+	}
+
+	stmt := r.db.WithContext(ctx).Model(&domain.Order{}).
+		Where(&domain.Order{OrganizationID: f.OrganizationID, Status: f.Status}).
+		Scopes(orderFilter(f))
+
+	direction, after := "ASC", ">"
+	if f.Descending {
+		direction, after = "DESC", "<"
+	}
+
+	if f.After != nil {
+		if !f.After.Matches(sort, f.Descending) {
+			return nil, 0, nil, domain.ErrInvalidOrderCursor
+		}
+
+		stmt = stmt.Where(fmt.Sprintf("(%s, order_id) %s (?, ?)", sort, after), f.After.Value(), f.After.OrderID)
+	} else {
+		if err = stmt.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+			return
+		}
+
+		if p.Page > 1 {
+			stmt = stmt.Offset((p.Page - 1) * size)
+		}
+	}
+
+	// One order more than the page holds tells whether another page follows.
+	if err = stmt.
+		Preload("OrderItems").
+		Preload("BillingAddress").
+		Preload("ShippingAddress").
+		Preload("Discounts").
+		Limit(size + 1).
+		Order(fmt.Sprintf("%s %s, order_id %s", sort, direction, direction)).
+		Find(&orders).Error; err != nil {
+		return
+	}
+
+	if len(orders) > size {
+		orders = orders[:size]
+		cursor := domain.NewOrderCursor(sort, f.Descending, orders[size-1])
+		next = &cursor
+	}
+
+	return
+}
+
+// orderFilter narrows a query on orders to those matching f, leaving out
+// the organization, status, sort and cursor.
+func orderFilter(f domain.OrderFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.CustomerID != "" {
+			db = db.Where("customer_id = ?", f.CustomerID)
+		}
+
+		if f.LocationID != "" {
+			db = db.Where("location_id = ?", f.LocationID)
+		}
+
+		if f.CreatedFrom != nil {
+			db = db.Where("created_at >= ?", *f.CreatedFrom)
+		}
+
+		if f.CreatedTo != nil {
+			db = db.Where("created_at < ?", *f.CreatedTo)
+		}
+
+		if f.Currency != "" {
+			db = db.Where("currency = ?", f.Currency)
+		}
+
+		if f.MinTotal != nil {
+			db = db.Where("total_amount >= ?", *f.MinTotal)
+		}
+
+		if f.MaxTotal != nil {
+			db = db.Where("total_amount <= ?", *f.MaxTotal)
+		}
+
+		if f.OrderNoPrefix != "" {
+			db = db.Where("order_no LIKE ?", escapeLike(f.OrderNoPrefix)+"%")
+		}
+
+		if f.VariantID != "" {
+			db = db.Where("EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.order_id AND order_items.variant_id = ? AND order_items.deleted_at IS NULL)", f.VariantID)
+		}
+
+		captured := db.Session(&gorm.Session{NewDB: true}).Model(&domain.OrderPayment{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("order_payments.order_id = orders.order_id AND order_payments.status = ?", domain.PaymentCaptured)
+
+		switch f.PaymentStatus {
+		case domain.Unpaid:
+			db = db.Where("total_amount > 0 AND (?) = 0", captured)
+		case domain.PartiallyPaid:
+			db = db.Where("(?) BETWEEN 1 AND total_amount - 1", captured)
+		case domain.FullyPaid:
+			db = db.Where("(?) >= total_amount", captured)
+		}
+
+		// Lines count as fulfilled once every unit the customer kept was sent.
+		const fulfilledLine = "SELECT 1 FROM order_items WHERE order_items.order_id = orders.order_id AND order_items.deleted_at IS NULL AND "
+		switch f.FulfillmentStatus {
+		case domain.Unfulfilled:
+			db = db.Where("NOT EXISTS (" + fulfilledLine + "order_items.fulfilled_quantity > 0)")
+		case domain.PartiallyFulfilled:
+			db = db.Where("EXISTS (" + fulfilledLine + "order_items.fulfilled_quantity > 0)").
+				Where("EXISTS (" + fulfilledLine + "order_items.fulfilled_quantity < order_items.quantity - order_items.returned_quantity)")
+		case domain.FullyFulfilled:
+			db = db.Where("NOT EXISTS (" + fulfilledLine + "order_items.fulfilled_quantity < order_items.quantity - order_items.returned_quantity)")
+		}
+
+		return db
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (r *orderRepository) FindOne(ctx context.Context, f domain.Order) (org *domain.Order, err error) {
 	if err = r.db.WithContext(ctx).Model(&domain.Order{}).
 		Preload("OrderItems").
+		Preload("BillingAddress").
+		Preload("ShippingAddress").
+		Preload("Discounts").
 		Where(&f).First(&org).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
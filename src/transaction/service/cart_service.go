@@ -0,0 +1,523 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartConfig configures carts. TTL is how long a cart lives after its last
+// change.
+type CartConfig struct {
+	TTL time.Duration
+}
+
+type CartService struct {
+	db               *gorm.DB
+	organizationConn organization.ServiceClient
+	customerConn     customer.CustomerServiceClient
+	pricingService   *PricingService
+	orderService     *OrderService
+	ttl              time.Duration
+}
+
+func NewCartService(
+	db *gorm.DB,
+	organizationConn organization.ServiceClient,
+	customerConn customer.CustomerServiceClient,
+	pricingService *PricingService,
+	orderService *OrderService,
+	config CartConfig,
+) *CartService {
+	return &CartService{
+		db:               db,
+		organizationConn: organizationConn,
+		customerConn:     customerConn,
+		pricingService:   pricingService,
+		orderService:     orderService,
+		ttl:              config.TTL,
+	}
+}
+
+// CreateCart starts an empty cart. Its currency, when set, is checked once
+// the first line is priced.
+func (svc *CartService) CreateCart(ctx context.Context, req domain.Cart) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "CreateCart")
+	defer span.End()
+
+	cart := domain.Cart{
+		ID:             uuid.NewString(),
+		OrganizationID: req.OrganizationID,
+		LocationID:     req.LocationID,
+		CustomerID:     req.CustomerID,
+		Currency:       req.Currency,
+		Status:         domain.CartOpen,
+		ExpiresAt:      time.Now().Add(svc.ttl),
+	}
+
+	if err := svc.db.WithContext(ctx).Create(&cart).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &cart, nil
+}
+
+func (svc *CartService) GetCart(ctx context.Context, cartID string) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "GetCart")
+	defer span.End()
+
+	var cart *domain.Cart
+	if err := svc.db.WithContext(ctx).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Coupons").
+		Where(&domain.Cart{ID: cartID}).
+		First(&cart).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "cart not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return cart, nil
+}
+
+// AddCartLine adds quantity of a variant to a cart, on the line already
+// holding the variant if there is one.
+func (svc *CartService) AddCartLine(ctx context.Context, cartID, variantID string, quantity int32) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "AddCartLine")
+	defer span.End()
+
+	if quantity <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+	}
+
+	return svc.change(ctx, cartID, false, func(tx *gorm.DB, cart *domain.Cart) error {
+		for i := range cart.Lines {
+			if cart.Lines[i].VariantID == variantID {
+				cart.Lines[i].Quantity += quantity
+				return nil
+			}
+		}
+
+		cart.Lines = append(cart.Lines, domain.CartLine{
+			ID:        uuid.NewString(),
+			CartID:    cart.ID,
+			VariantID: variantID,
+			Quantity:  quantity,
+		})
+		return nil
+	})
+}
+
+// SetCartLineQuantity changes the quantity of a cart line. A zero quantity
+// removes the line.
+func (svc *CartService) SetCartLineQuantity(ctx context.Context, cartID, lineID string, quantity int32) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "SetCartLineQuantity")
+	defer span.End()
+
+	if quantity < 0 {
+		return nil, status.Error(codes.InvalidArgument, "quantity can't be negative")
+	}
+
+	return svc.change(ctx, cartID, false, func(tx *gorm.DB, cart *domain.Cart) error {
+		i := slices.IndexFunc(cart.Lines, func(line domain.CartLine) bool { return line.ID == lineID })
+		if i < 0 {
+			return status.Error(codes.InvalidArgument, "cart line not found")
+		}
+
+		if quantity == 0 {
+			cart.Lines = slices.Delete(cart.Lines, i, i+1)
+			return nil
+		}
+
+		cart.Lines[i].Quantity = quantity
+		return nil
+	})
+}
+
+func (svc *CartService) RemoveCartLine(ctx context.Context, cartID, lineID string) (*domain.Cart, error) {
+	return svc.SetCartLineQuantity(ctx, cartID, lineID, 0)
+}
+
+// SetCartCustomer sets the customer of a cart and the customer addresses to
+// bill and ship to. Empty IDs clear them.
+func (svc *CartService) SetCartCustomer(ctx context.Context, cartID, customerID, billingAddressID, shippingAddressID string) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "SetCartCustomer")
+	defer span.End()
+
+	if customerID == "" && (billingAddressID != "" || shippingAddressID != "") {
+		return nil, status.Error(codes.InvalidArgument, "addresses need a customer")
+	}
+
+	for _, addressID := range []string{billingAddressID, shippingAddressID} {
+		if addressID == "" {
+			continue
+		}
+
+		if _, err := customerAddress(ctx, svc.customerConn, customerID, addressID); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc.change(ctx, cartID, false, func(tx *gorm.DB, cart *domain.Cart) error {
+		cart.CustomerID = optional(customerID)
+		cart.BillingAddressID = optional(billingAddressID)
+		cart.ShippingAddressID = optional(shippingAddressID)
+		return nil
+	})
+}
+
+// SetCartShippingRate chooses the shipping rate charged on a cart. An empty
+// ID removes it.
+func (svc *CartService) SetCartShippingRate(ctx context.Context, cartID, shippingRateID string) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "SetCartShippingRate")
+	defer span.End()
+
+	return svc.change(ctx, cartID, true, func(tx *gorm.DB, cart *domain.Cart) error {
+		cart.ShippingRateID = optional(shippingRateID)
+		return nil
+	})
+}
+
+// ApplyCartCoupon applies a coupon code to a cart. The code must give a
+// discount on the cart as it is; it is redeemed at checkout.
+func (svc *CartService) ApplyCartCoupon(ctx context.Context, cartID, code string) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "ApplyCartCoupon")
+	defer span.End()
+
+	code = domain.NormalizeCode(code)
+
+	return svc.change(ctx, cartID, true, func(tx *gorm.DB, cart *domain.Cart) error {
+		for _, coupon := range cart.Coupons {
+			if coupon.Code == code {
+				return status.Error(codes.AlreadyExists, "coupon code already applied")
+			}
+		}
+
+		cart.Coupons = append(cart.Coupons, domain.CartCoupon{CartID: cart.ID, Code: code})
+		return nil
+	})
+}
+
+func (svc *CartService) RemoveCartCoupon(ctx context.Context, cartID, code string) (*domain.Cart, error) {
+	ctx, span := tracer.Start(ctx, "RemoveCartCoupon")
+	defer span.End()
+
+	code = domain.NormalizeCode(code)
+
+	return svc.change(ctx, cartID, false, func(tx *gorm.DB, cart *domain.Cart) error {
+		i := slices.IndexFunc(cart.Coupons, func(coupon domain.CartCoupon) bool { return coupon.Code == code })
+		if i < 0 {
+			return status.Error(codes.InvalidArgument, "coupon code not applied")
+		}
+
+		cart.Coupons = slices.Delete(cart.Coupons, i, i+1)
+		return nil
+	})
+}
+
+// CheckoutCart turns a cart into an order, priced again, its coupons
+// redeemed and its stock reserved like any new order. A cart is checked out
+// once; it is open again when the order can't be created.
+func (svc *CartService) CheckoutCart(ctx context.Context, cartID, actor string) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "CheckoutCart")
+	defer span.End()
+
+	cart, err := svc.GetCart(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := usableCart(cart); err != nil {
+		return nil, err
+	}
+
+	if len(cart.Lines) == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "cart is empty")
+	}
+
+	order := cart.Order()
+	order.ID = uuid.NewString()
+	for i := range order.OrderItems {
+		order.OrderItems[i].ID = ""
+		order.OrderItems[i].OrderID = order.ID
+	}
+
+	if cart.BillingAddressID != nil {
+		addr, err := customerAddress(ctx, svc.customerConn, *cart.CustomerID, *cart.BillingAddressID)
+		if err != nil {
+			return nil, err
+		}
+		order.BillingAddress = &domain.OrderBillingAddress{OrderAddress: addr}
+	}
+
+	if cart.ShippingAddressID != nil {
+		addr, err := customerAddress(ctx, svc.customerConn, *cart.CustomerID, *cart.ShippingAddressID)
+		if err != nil {
+			return nil, err
+		}
+		order.ShippingAddress = &domain.OrderShippingAddress{OrderAddress: addr}
+	}
+
+	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
+		OrganizationId: cart.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.pricingService.PriceOrder(ctx, org, &order); err != nil {
+		return nil, err
+	}
+
+	// Claim the cart first so concurrent checkouts don't both create an
+	// order.
+	result := svc.db.WithContext(ctx).Model(&domain.Cart{}).
+		Where("cart_id = ? AND status = ?", cart.ID, domain.CartOpen).
+		Updates(map[string]interface{}{"status": domain.CartConverted, "order_id": order.ID})
+	if result.Error != nil {
+		return nil, status.Error(codes.Internal, result.Error.Error())
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, status.Error(codes.FailedPrecondition, "cart already checked out")
+	}
+
+	if err := svc.orderService.Create(ctx, order, actor); err != nil {
+		if reopenErr := svc.db.WithContext(ctx).Model(&domain.Cart{}).
+			Where(&domain.Cart{ID: cart.ID}).
+			Updates(map[string]interface{}{"status": domain.CartOpen, "order_id": nil}).Error; reopenErr != nil {
+			zap.L().Error("failed reopen cart", zap.String("cart_id", cart.ID), zap.Error(reopenErr))
+		}
+		return nil, err
+	}
+
+	var created *domain.Order
+	if err := svc.db.WithContext(ctx).
+		Preload("OrderItems").
+		Where(&domain.Order{ID: order.ID}).
+		First(&created).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return created, nil
+}
+
+// ListAbandonedCart lists the carts of known customers that were neither
+// checked out nor changed since idleSince, most recently changed first.
+func (svc *CartService) ListAbandonedCart(ctx context.Context, p pagination.Pagination, organizationID string, idleSince time.Time) (domain.Carts, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListAbandonedCart")
+	defer span.End()
+
+	var (
+		carts domain.Carts
+		count int64
+	)
+	if err := svc.db.WithContext(ctx).Model(&domain.Cart{}).
+		Preload("Lines").
+		Where("organization_id = ? AND customer_id IS NOT NULL AND status IN ? AND updated_at < ?",
+			organizationID, []domain.CartStatus{domain.CartOpen, domain.CartExpired}, idleSince).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("updated_at DESC").
+		Find(&carts).Error; err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return carts, count, nil
+}
+
+// ExpireCarts marks the open carts past their expiry as expired. It returns
+// the number of expired carts.
+func (svc *CartService) ExpireCarts(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ExpireCarts")
+	defer span.End()
+
+	result := svc.db.WithContext(ctx).Model(&domain.Cart{}).
+		Where("status = ? AND expires_at < ?", domain.CartOpen, time.Now()).
+		Update("status", domain.CartExpired)
+	if result.Error != nil {
+		return 0, status.Error(codes.Internal, result.Error.Error())
+	}
+
+	return result.RowsAffected, nil
+}
+
+// change locks an open cart, applies fn to it, prices it again and saves
+// it, pushing its expiry back. Strict changes fail when a coupon of the cart
+// no longer applies; other changes drop the coupons instead, so a shopper
+// can always take lines out.
+func (svc *CartService) change(ctx context.Context, cartID string, strict bool, fn func(tx *gorm.DB, cart *domain.Cart) error) (*domain.Cart, error) {
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var cart *domain.Cart
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.Cart{ID: cartID}).
+			First(&cart).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return status.Error(codes.InvalidArgument, "cart not found")
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = usableCart(cart); err != nil {
+			return
+		}
+
+		if err = tx.Where(&domain.CartLine{CartID: cart.ID}).Order("created_at ASC").Find(&cart.Lines).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Where(&domain.CartCoupon{CartID: cart.ID}).Find(&cart.Coupons).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = fn(tx, cart); err != nil {
+			return
+		}
+
+		if err = svc.price(ctx, cart, strict); err != nil {
+			return
+		}
+
+		cart.ExpiresAt = time.Now().Add(svc.ttl)
+		return saveCart(tx, cart)
+	}); err != nil {
+		return nil, err
+	}
+
+	return svc.GetCart(ctx, cartID)
+}
+
+// price works out the lines and totals of cart the way PriceOrder would for
+// the order it becomes.
+func (svc *CartService) price(ctx context.Context, cart *domain.Cart, strict bool) error {
+	if len(cart.Lines) == 0 {
+		cart.SubTotal, cart.DiscountAmount, cart.TaxAmount, cart.ShippingAmount, cart.TotalAmount = 0, 0, 0, 0, 0
+		return nil
+	}
+
+	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
+		OrganizationId: cart.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	order := cart.Order()
+	err = svc.pricingService.PriceOrder(ctx, org, &order)
+	if err != nil && !strict && len(cart.Coupons) > 0 && status.Code(err) == codes.FailedPrecondition {
+		cart.Coupons = nil
+		order = cart.Order()
+		err = svc.pricingService.PriceOrder(ctx, org, &order)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range cart.Lines {
+		cart.Lines[i].UnitPrice = order.OrderItems[i].UnitPrice
+		cart.Lines[i].DiscountAmount = order.OrderItems[i].DiscountAmount
+		cart.Lines[i].TotalPrice = order.OrderItems[i].TotalPrice
+	}
+
+	cart.Currency = order.Currency
+	cart.SubTotal = order.SubTotal
+	cart.DiscountAmount = order.DiscountAmount
+	cart.TaxAmount = order.TaxAmount
+	cart.ShippingAmount = order.ShippingAmount
+	cart.TotalAmount = order.TotalAmount
+	return nil
+}
+
+// customerAddress copies a customer address, checking it belongs to the
+// customer.
+func customerAddress(ctx context.Context, customerConn customer.CustomerServiceClient, customerID, addressID string) (domain.OrderAddress, error) {
+	addr, err := customerConn.GetAddress(ctx, &customer.Address{
+		AddressId:  addressID,
+		CustomerId: customerID,
+	})
+	if err != nil {
+		return domain.OrderAddress{}, err
+	}
+
+	if addr.CustomerId != customerID {
+		return domain.OrderAddress{}, status.Errorf(codes.InvalidArgument, "address %s doesn't belong to customer", addressID)
+	}
+
+	return domain.NewOrderAddress(addr), nil
+}
+
+// usableCart reports why cart can't be changed or checked out, if it can't.
+func usableCart(cart *domain.Cart) error {
+	if cart.Status != domain.CartOpen {
+		return status.Errorf(codes.FailedPrecondition, "cart is %s", cart.Status)
+	}
+
+	if !time.Now().Before(cart.ExpiresAt) {
+		return status.Error(codes.FailedPrecondition, "cart expired")
+	}
+
+	return nil
+}
+
+// saveCart saves cart with its lines and coupons, deleting those it no
+// longer has.
+func saveCart(tx *gorm.DB, cart *domain.Cart) (err error) {
+	lineIDs := make([]string, 0, len(cart.Lines))
+	for i := range cart.Lines {
+		line := &cart.Lines[i]
+		if line.CreatedAt.IsZero() {
+			err = tx.Create(line).Error
+		} else {
+			err = tx.Save(line).Error
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		lineIDs = append(lineIDs, line.ID)
+	}
+
+	stale := tx.Where(&domain.CartLine{CartID: cart.ID})
+	if len(lineIDs) > 0 {
+		stale = stale.Where("cart_line_id NOT IN ?", lineIDs)
+	}
+	if err = stale.Delete(&domain.CartLine{}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err = tx.Where(&domain.CartCoupon{CartID: cart.ID}).Delete(&domain.CartCoupon{}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for i := range cart.Coupons {
+		if err = tx.Create(&cart.Coupons[i]).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if err = tx.Omit(clause.Associations).Save(cart).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+// optional returns a pointer to id, or nil when it is empty.
+func optional(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
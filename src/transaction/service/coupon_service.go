@@ -0,0 +1,474 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxGeneratedCoupons bounds how many codes one GenerateCoupons call
+	// creates.
+	maxGeneratedCoupons = 10000
+	// couponCodeLength is the number of random characters of a generated
+	// code, after its prefix.
+	couponCodeLength = 8
+	// codeAlphabet leaves out characters easily mistaken for others
+	// (0/O, 1/I/L) when a code is read off a screen.
+	codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// CouponService manages the coupon codes unlocking coupon-only promotions
+// and their redemption by orders.
+type CouponService struct {
+	db               *gorm.DB
+	organizationConn organization.ServiceClient
+	orderService     *OrderService
+	pricingService   *PricingService
+}
+
+func NewCouponService(
+	db *gorm.DB,
+	organizationConn organization.ServiceClient,
+	orderService *OrderService,
+	pricingService *PricingService,
+) *CouponService {
+	return &CouponService{
+		db:               db,
+		organizationConn: organizationConn,
+		orderService:     orderService,
+		pricingService:   pricingService,
+	}
+}
+
+// CreateCoupon creates an active coupon with the code given. It returns
+// AlreadyExists when the organization already has the code.
+func (svc *CouponService) CreateCoupon(ctx context.Context, req domain.Coupon) (*domain.Coupon, error) {
+	ctx, span := tracer.Start(ctx, "CreateCoupon")
+	defer span.End()
+
+	coupon, err := svc.newCoupon(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	coupon.Code = domain.NormalizeCode(req.Code)
+	if coupon.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	stmt := svc.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&coupon)
+	if err = stmt.Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if stmt.RowsAffected == 0 {
+		return nil, status.Error(codes.AlreadyExists, "code already exist")
+	}
+
+	return &coupon, nil
+}
+
+// GenerateCoupons creates count active coupons with random codes starting
+// with prefix, such as one-off codes handed out in a campaign. Every coupon
+// takes its promotion, limits and expiry from req.
+func (svc *CouponService) GenerateCoupons(ctx context.Context, req domain.Coupon, prefix string, count int) (domain.Coupons, error) {
+	ctx, span := tracer.Start(ctx, "GenerateCoupons")
+	defer span.End()
+
+	if count <= 0 || count > maxGeneratedCoupons {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxGeneratedCoupons)
+	}
+
+	template, err := svc.newCoupon(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = domain.NormalizeCode(prefix)
+
+	generated := make(domain.Coupons, 0, count)
+	// Codes colliding with existing ones are skipped and drawn again; a
+	// handful of rounds is plenty unless the prefix is nearly used up.
+	for round := 0; round < 5 && len(generated) < count; round++ {
+		batch := make(domain.Coupons, count-len(generated))
+		for i := range batch {
+			code, err := randomCode(couponCodeLength)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+
+			batch[i] = template
+			batch[i].Code = prefix + code
+		}
+
+		if err = svc.db.WithContext(ctx).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(&batch, 500).Error; err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		for _, coupon := range batch {
+			if coupon.ID != "" {
+				generated = append(generated, coupon)
+			}
+		}
+	}
+
+	if len(generated) < count {
+		return generated, status.Errorf(codes.ResourceExhausted, "only %d unique codes could be generated", len(generated))
+	}
+
+	return generated, nil
+}
+
+// ListCoupon lists the coupons of an organization, newest first, optionally
+// only those of a promotion.
+func (svc *CouponService) ListCoupon(ctx context.Context, p pagination.Pagination, organizationID, promotionID string) (domain.Coupons, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListCoupon")
+	defer span.End()
+
+	var (
+		coupons domain.Coupons
+		count   int64
+	)
+	if err := svc.db.WithContext(ctx).Model(&domain.Coupon{}).
+		Where(&domain.Coupon{OrganizationID: organizationID, PromotionID: promotionID}).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("created_at DESC").
+		Find(&coupons).Error; err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return coupons, count, nil
+}
+
+// DeactivateCoupon stops a coupon of an organization from being used by new
+// orders. Orders that already redeemed it keep their discount.
+func (svc *CouponService) DeactivateCoupon(ctx context.Context, organizationID, couponID string) (*domain.Coupon, error) {
+	ctx, span := tracer.Start(ctx, "DeactivateCoupon")
+	defer span.End()
+
+	var coupon *domain.Coupon
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.Coupon{ID: couponID, OrganizationID: organizationID}).
+		First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "coupon not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := svc.db.WithContext(ctx).Model(coupon).Update("active", false).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return coupon, nil
+}
+
+// ApplyCoupon attaches a coupon code to an order already placed and prices
+// the order again with it. Only orders nothing was paid or requested for
+// yet can take a coupon, so payments never disagree with the total.
+func (svc *CouponService) ApplyCoupon(ctx context.Context, orderID, code string) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "ApplyCoupon")
+	defer span.End()
+
+	var order *domain.Order
+	if err := svc.db.WithContext(ctx).
+		Preload("OrderItems").
+		Where(&domain.Order{ID: orderID}).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "order not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	code = domain.NormalizeCode(code)
+
+	redeemed, err := redeemedCodes(svc.db.WithContext(ctx), order.ID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if slices.Contains(redeemed, code) {
+		return nil, status.Error(codes.AlreadyExists, "coupon code already applied")
+	}
+	order.CouponCodes = append(redeemed, code)
+
+	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
+		OrganizationId: order.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = svc.pricingService.RepriceOrder(ctx, org, order); err != nil {
+		return nil, err
+	}
+
+	if err = svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		locked, err := svc.orderService.lockOrder(tx, order.ID)
+		if err != nil {
+			return
+		}
+
+		if locked.Status != domain.OrderCreated && locked.Status != domain.OrderAwaitingPayment {
+			return status.Errorf(codes.FailedPrecondition, "coupon can't be applied to %s order", locked.Status)
+		}
+
+		balance, err := orderBalance(tx, locked)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if balance.Captured > 0 || balance.Open > 0 {
+			return status.Error(codes.FailedPrecondition, "coupon can't be applied to an order with payments")
+		}
+
+		if err = redeemCoupons(tx, order, []string{code}, time.Now()); err != nil {
+			return
+		}
+
+		if err = tx.Where(&domain.OrderDiscount{OrderID: order.ID}).
+			Delete(&domain.OrderDiscount{}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if len(order.Discounts) > 0 {
+			if err = tx.Create(&order.Discounts).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		for _, orderItem := range order.OrderItems {
+			if err = tx.Model(&orderItem).Updates(map[string]any{
+				"discount_amount": orderItem.DiscountAmount,
+				"total_price":     orderItem.TotalPrice,
+				"tax_amount":      orderItem.TaxAmount,
+			}).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		if err = tx.Model(order).Updates(map[string]any{
+			"discount_amount": order.DiscountAmount,
+			"sub_total":       order.SubTotal,
+			"tax_amount":      order.TaxAmount,
+			"total_amount":    order.TotalAmount,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// newCoupon checks the promotion and limits of req and returns the active
+// coupon to create from it.
+func (svc *CouponService) newCoupon(ctx context.Context, req domain.Coupon) (domain.Coupon, error) {
+	var promotion *domain.Promotion
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.Promotion{ID: req.PromotionID, OrganizationID: req.OrganizationID}).
+		First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Coupon{}, status.Error(codes.InvalidArgument, "promotion not found")
+		}
+		return domain.Coupon{}, status.Error(codes.Internal, err.Error())
+	}
+
+	if !promotion.CouponOnly {
+		return domain.Coupon{}, status.Error(codes.InvalidArgument, "promotion doesn't take coupon codes")
+	}
+
+	if req.MaxRedemptions < 0 || req.MaxPerCustomer < 0 {
+		return domain.Coupon{}, status.Error(codes.InvalidArgument, "usage limits can't be negative")
+	}
+
+	return domain.Coupon{
+		OrganizationID: req.OrganizationID,
+		PromotionID:    req.PromotionID,
+		MaxRedemptions: req.MaxRedemptions,
+		MaxPerCustomer: req.MaxPerCustomer,
+		ExpiresAt:      req.ExpiresAt,
+		Active:         true,
+	}, nil
+}
+
+// usableCoupons loads the coupons of the order's organization with the
+// given codes, checking each can be used at t. Coupons the order redeemed
+// already are kept as they were when redeemed: the order itself may have
+// used up their redemptions, or they may have expired since.
+func usableCoupons(tx *gorm.DB, order *domain.Order, couponCodes []string, t time.Time) (coupons domain.Coupons, err error) {
+	normalized := normalizeCouponCodes(couponCodes)
+	if len(normalized) == 0 {
+		return
+	}
+
+	if err = tx.Where("organization_id = ? AND code IN ?", order.OrganizationID, normalized).
+		Find(&coupons).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	var redeemed []string
+	if order.ID != "" {
+		if err = tx.Model(&domain.CouponRedemption{}).
+			Where(&domain.CouponRedemption{OrderID: order.ID, Status: domain.RedemptionRedeemed}).
+			Pluck("coupon_id", &redeemed).Error; err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	for _, code := range normalized {
+		i := slices.IndexFunc(coupons, func(c domain.Coupon) bool { return c.Code == code })
+		if i < 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s: %s", code, domain.ErrUnknownCoupon)
+		}
+
+		if slices.Contains(redeemed, coupons[i].ID) {
+			continue
+		}
+
+		if err = coupons[i].Usable(t); err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s: %s", code, err)
+		}
+	}
+
+	return
+}
+
+// redeemCoupons records that order uses the coupons with the given codes
+// and counts the redemptions. Each coupon row stays locked until tx ends, so
+// concurrent orders can't take a coupon past its usage limits. Coupons are
+// locked in code order to avoid deadlocks between orders sharing coupons.
+func redeemCoupons(tx *gorm.DB, order *domain.Order, couponCodes []string, t time.Time) (err error) {
+	for _, code := range normalizeCouponCodes(couponCodes) {
+		var coupon *domain.Coupon
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.Coupon{OrganizationID: order.OrganizationID, Code: code}).
+			First(&coupon).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return status.Errorf(codes.FailedPrecondition, "%s: %s", code, domain.ErrUnknownCoupon)
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = coupon.Usable(t); err != nil {
+			return status.Errorf(codes.FailedPrecondition, "%s: %s", code, err)
+		}
+
+		if coupon.MaxPerCustomer > 0 {
+			// Anonymous orders could use the coupon without limit.
+			if order.CustomerID == nil {
+				return status.Errorf(codes.FailedPrecondition, "%s: %s", code, domain.ErrCouponCustomerRequired)
+			}
+
+			var used int64
+			if err = tx.Model(&domain.CouponRedemption{}).
+				Where(&domain.CouponRedemption{CouponID: coupon.ID, CustomerID: order.CustomerID, Status: domain.RedemptionRedeemed}).
+				Count(&used).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if used >= int64(coupon.MaxPerCustomer) {
+				return status.Errorf(codes.FailedPrecondition, "%s: %s", code, domain.ErrCouponCustomerExceeded)
+			}
+		}
+
+		if err = tx.Create(&domain.CouponRedemption{
+			CouponID:   coupon.ID,
+			OrderID:    order.ID,
+			CustomerID: order.CustomerID,
+			Status:     domain.RedemptionRedeemed,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Model(coupon).
+			Update("redemptions", gorm.Expr("redemptions + 1")).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return
+}
+
+// reverseRedemptions gives back the coupons an order redeemed, so they can
+// be used again.
+func reverseRedemptions(tx *gorm.DB, orderID string) (err error) {
+	var redemptions domain.CouponRedemptions
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.CouponRedemption{OrderID: orderID, Status: domain.RedemptionRedeemed}).
+		Find(&redemptions).Error; err != nil {
+		return
+	}
+
+	for _, redemption := range redemptions {
+		if err = tx.Model(&redemption).Update("status", domain.RedemptionReversed).Error; err != nil {
+			return
+		}
+
+		if err = tx.Model(&domain.Coupon{}).
+			Where("coupon_id = ? AND redemptions > 0", redemption.CouponID).
+			Update("redemptions", gorm.Expr("redemptions - 1")).Error; err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// redeemedCodes lists the codes of the coupons an order holds.
+func redeemedCodes(tx *gorm.DB, orderID string) (couponCodes []string, err error) {
+	err = tx.Model(&domain.CouponRedemption{}).
+		Joins("JOIN coupons ON coupons.coupon_id = coupon_redemptions.coupon_id").
+		Where("coupon_redemptions.order_id = ? AND coupon_redemptions.status = ?", orderID, domain.RedemptionRedeemed).
+		Order("coupons.code").
+		Pluck("coupons.code", &couponCodes).Error
+	return
+}
+
+// normalizeCouponCodes normalizes codes and returns them sorted, without
+// blanks and duplicates.
+func normalizeCouponCodes(couponCodes []string) []string {
+	normalized := make([]string, 0, len(couponCodes))
+	for _, code := range couponCodes {
+		if code = domain.NormalizeCode(code); code != "" {
+			normalized = append(normalized, code)
+		}
+	}
+
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// randomCode draws a code of length characters from codeAlphabet.
+func randomCode(length int) (string, error) {
+	code := make([]byte, length)
+	size := big.NewInt(int64(len(codeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,516 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FulfillmentService sends order items to customers. An order can be split
+// over several fulfillments, each shipped from one location with a courier
+// adapter. The order is fulfilled once every item the customer kept has
+// been delivered.
+type FulfillmentService struct {
+	db               *gorm.DB
+	organizationConn organization.ServiceClient
+	orderService     *OrderService
+	pricingService   *PricingService
+	couriers         map[string]domain.Courier
+}
+
+func NewFulfillmentService(
+	db *gorm.DB,
+	organizationConn organization.ServiceClient,
+	orderService *OrderService,
+	pricingService *PricingService,
+	couriers []domain.Courier,
+) *FulfillmentService {
+	svc := &FulfillmentService{
+		db:               db,
+		organizationConn: organizationConn,
+		orderService:     orderService,
+		pricingService:   pricingService,
+		couriers:         make(map[string]domain.Courier, len(couriers)),
+	}
+
+	for _, c := range couriers {
+		svc.couriers[c.ID()] = c
+	}
+
+	return svc
+}
+
+// ListFulfillment lists the fulfillments of an order with their items,
+// shipment and tracking history, oldest first.
+func (svc *FulfillmentService) ListFulfillment(ctx context.Context, orderID string) (domain.OrderFulfillments, error) {
+	ctx, span := tracer.Start(ctx, "ListFulfillment")
+	defer span.End()
+
+	var fulfillments domain.OrderFulfillments
+	if err := svc.preload(svc.db.WithContext(ctx)).
+		Where(&domain.OrderFulfillment{OrderID: orderID}).
+		Order("created_at ASC").
+		Find(&fulfillments).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return fulfillments, nil
+}
+
+// GetFulfillment returns a fulfillment with its items, shipment and
+// tracking history.
+func (svc *FulfillmentService) GetFulfillment(ctx context.Context, fulfillmentID string) (*domain.OrderFulfillment, error) {
+	ctx, span := tracer.Start(ctx, "GetFulfillment")
+	defer span.End()
+
+	var fulfillment *domain.OrderFulfillment
+	if err := svc.preload(svc.db.WithContext(ctx)).
+		Where(&domain.OrderFulfillment{ID: fulfillmentID}).
+		First(&fulfillment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "fulfillment not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return fulfillment, nil
+}
+
+// CreateFulfillment picks req.Items of an order at req.LocationID, or at
+// the order location when none is given. A paid order moves to fulfilling.
+func (svc *FulfillmentService) CreateFulfillment(ctx context.Context, req domain.OrderFulfillment, actor string) (*domain.OrderFulfillment, error) {
+	ctx, span := tracer.Start(ctx, "CreateFulfillment")
+	defer span.End()
+
+	if len(req.Items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "items can't be empty")
+	}
+
+	fulfillment := domain.OrderFulfillment{
+		ID:         uuid.NewString(),
+		OrderID:    req.OrderID,
+		LocationID: req.LocationID,
+		Status:     domain.FulfillmentPending,
+		Actor:      actor,
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		order, err := svc.orderService.lockOrder(tx, req.OrderID)
+		if err != nil {
+			return
+		}
+
+		switch order.Status {
+		case domain.OrderPaid, domain.OrderFulfilling, domain.OrderPartiallyRefunded:
+		default:
+			return status.Errorf(codes.FailedPrecondition, "order in status %s can't be fulfilled", order.Status)
+		}
+
+		fulfillment.OrganizationID = order.OrganizationID
+		if fulfillment.LocationID == "" {
+			if order.LocationID == nil {
+				return status.Error(codes.InvalidArgument, "location_id is required")
+			}
+			fulfillment.LocationID = *order.LocationID
+		} else if err = validateLocation(ctx, svc.organizationConn, order.OrganizationID, fulfillment.LocationID); err != nil {
+			return
+		}
+
+		var items domain.OrderItems
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.OrderItem{OrderID: order.ID}).
+			Find(&items).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		requested := make(map[string]int32, len(req.Items))
+		for _, line := range req.Items {
+			idx := slices.IndexFunc(items, func(v domain.OrderItem) bool { return v.ID == line.OrderItemID })
+			if idx < 0 {
+				return status.Errorf(codes.InvalidArgument, "order item %s not found", line.OrderItemID)
+			}
+
+			if line.Quantity <= 0 {
+				return status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+			}
+
+			orderItem := items[idx]
+			left := orderItem.Quantity - orderItem.ReturnedQuantity - orderItem.FulfilledQuantity
+			requested[line.OrderItemID] += line.Quantity
+			if requested[line.OrderItemID] > left {
+				return status.Errorf(codes.FailedPrecondition, "order item %s has only %d left to fulfill", orderItem.ID, left)
+			}
+
+			fulfillment.Items = append(fulfillment.Items, domain.OrderFulfillmentItem{
+				OrderItemID: line.OrderItemID,
+				Quantity:    line.Quantity,
+			})
+		}
+
+		if err = tx.Create(&fulfillment).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = addFulfilled(tx, fulfillment.Items, 1); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if order.Status == domain.OrderPaid {
+			return svc.orderService.transition(tx, order.ID, domain.OrderFulfilling, actor, "fulfillment created")
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return &fulfillment, nil
+}
+
+// CancelFulfillment cancels a fulfillment that hasn't shipped, so its items
+// can be fulfilled again.
+func (svc *FulfillmentService) CancelFulfillment(ctx context.Context, fulfillmentID string) (*domain.OrderFulfillment, error) {
+	ctx, span := tracer.Start(ctx, "CancelFulfillment")
+	defer span.End()
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		fulfillment, err := svc.lockFulfillment(tx, fulfillmentID)
+		if err != nil {
+			return
+		}
+
+		if fulfillment.Status != domain.FulfillmentPending {
+			return status.Errorf(codes.FailedPrecondition, "fulfillment in status %s can't be cancelled", fulfillment.Status)
+		}
+
+		if err = tx.Model(fulfillment).Update("status", domain.FulfillmentCancelled).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = addFulfilled(tx, fulfillment.Items, -1); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return svc.GetFulfillment(ctx, fulfillmentID)
+}
+
+// Ship books the shipment of a pending fulfillment with a courier, at the
+// price of one of the organization's shipping rates. waybill is required by
+// couriers that don't issue waybill numbers themselves.
+func (svc *FulfillmentService) Ship(ctx context.Context, fulfillmentID, shippingRateID, courierID, waybill, actor string) (*domain.OrderFulfillment, error) {
+	ctx, span := tracer.Start(ctx, "ShipFulfillment")
+	defer span.End()
+
+	courier, ok := svc.couriers[courierID]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, domain.ErrUnknownCourier.Error())
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		fulfillment, err := svc.lockFulfillment(tx, fulfillmentID)
+		if err != nil {
+			return
+		}
+
+		if fulfillment.Status != domain.FulfillmentPending {
+			return status.Errorf(codes.FailedPrecondition, "fulfillment in status %s can't be shipped", fulfillment.Status)
+		}
+
+		var order *domain.Order
+		if err = tx.Where(&domain.Order{ID: fulfillment.OrderID}).First(&order).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		rate, err := svc.pricingService.shippingRate(ctx, fulfillment.OrganizationID, shippingRateID, order.BaseCurrency)
+		if err != nil {
+			return
+		}
+
+		shipping := domain.OrderShipping{
+			ID:                 uuid.NewString(),
+			OrderID:            fulfillment.OrderID,
+			OrderFulfillmentID: fulfillment.ID,
+			ShippingMethodID:   rate.ID,
+			ShippingMethodName: rate.Name,
+			ShippingPrice:      order.FromBase(rate.Price),
+			CourierID:          courier.ID(),
+			Status:             domain.ShippingLabelCreated,
+		}
+
+		if shipping.WaybillNumber, err = courier.CreateShipment(ctx, shipping, waybill); err != nil {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+
+		if err = tx.Create(&shipping).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Create(&domain.ShippingHistory{
+			OrderShippingID: shipping.ID,
+			Status:          domain.ShippingLabelCreated,
+			OccurredAt:      time.Now(),
+			Actor:           actor,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Model(fulfillment).Update("status", domain.FulfillmentShipped).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return svc.GetFulfillment(ctx, fulfillmentID)
+}
+
+// AddTrackingEvent appends a tracking event to a shipment. A delivered
+// event delivers the fulfillment, and the order once nothing is left to
+// deliver. A returned event cancels the fulfillment so its items can be
+// sent again.
+func (svc *FulfillmentService) AddTrackingEvent(ctx context.Context, event domain.ShippingHistory, actor string) (*domain.ShippingHistory, error) {
+	ctx, span := tracer.Start(ctx, "AddTrackingEvent")
+	defer span.End()
+
+	if event.Status.String() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid shipping status")
+	}
+
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	event.Actor = actor
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		return svc.track(tx, event.OrderShippingID, domain.ShippingHistories{event}, actor)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// RefreshTracking pulls the tracking events of a shipment from its courier
+// and appends the ones not recorded yet.
+func (svc *FulfillmentService) RefreshTracking(ctx context.Context, shippingID string) (domain.ShippingHistories, error) {
+	ctx, span := tracer.Start(ctx, "RefreshTracking")
+	defer span.End()
+
+	var shipping *domain.OrderShipping
+	if err := svc.db.WithContext(ctx).
+		Preload("Histories").
+		Where(&domain.OrderShipping{ID: shippingID}).
+		First(&shipping).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "shipping not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	courier, ok := svc.couriers[shipping.CourierID]
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, domain.ErrUnknownCourier.Error())
+	}
+
+	events, err := courier.Track(ctx, *shipping)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	var added domain.ShippingHistories
+	for _, event := range events {
+		if slices.ContainsFunc(shipping.Histories, func(v domain.ShippingHistory) bool {
+			return v.Status == event.Status && v.OccurredAt.Equal(event.OccurredAt)
+		}) {
+			continue
+		}
+		event.Actor = courier.ID()
+		added = append(added, event)
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		return svc.track(tx, shippingID, added, courier.ID())
+	}); err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// track records tracking events of a shipment and moves the shipment, its
+// fulfillment and its order along.
+func (svc *FulfillmentService) track(tx *gorm.DB, shippingID string, events domain.ShippingHistories, actor string) (err error) {
+	var shipping *domain.OrderShipping
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.OrderShipping{ID: shippingID}).
+		First(&shipping).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return status.Error(codes.InvalidArgument, "shipping not found")
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	latest := events[0]
+	for i := range events {
+		events[i].ID = ""
+		events[i].OrderShippingID = shipping.ID
+		if events[i].OccurredAt.After(latest.OccurredAt) {
+			latest = events[i]
+		}
+	}
+
+	if err = tx.Create(&events).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if shipping.Status == domain.ShippingDelivered || latest.Status == shipping.Status {
+		return
+	}
+
+	if err = tx.Model(shipping).Update("status", latest.Status).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if latest.Status == domain.ShippingReturned {
+		// The parcel came back: its items can be fulfilled again.
+		fulfillment, err := svc.lockFulfillment(tx, shipping.OrderFulfillmentID)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Model(fulfillment).Update("status", domain.FulfillmentCancelled).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = addFulfilled(tx, fulfillment.Items, -1); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return nil
+	}
+
+	if latest.Status != domain.ShippingDelivered {
+		return
+	}
+
+	if err = tx.Model(&domain.OrderFulfillment{ID: shipping.OrderFulfillmentID}).
+		Update("status", domain.FulfillmentDelivered).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return svc.completeOrder(tx, shipping.OrderID, actor)
+}
+
+// completeOrder moves an order to fulfilled once every item not returned is
+// in a delivered fulfillment.
+func (svc *FulfillmentService) completeOrder(tx *gorm.DB, orderID, actor string) (err error) {
+	order, err := svc.orderService.lockOrder(tx, orderID)
+	if err != nil {
+		return
+	}
+
+	if !order.Status.CanTransitionTo(domain.OrderFulfilled) {
+		return
+	}
+
+	var open int64
+	if err = tx.Model(&domain.OrderFulfillment{}).
+		Where("order_id = ? AND status IN ?", order.ID, []domain.FulfillmentStatus{domain.FulfillmentPending, domain.FulfillmentShipped}).
+		Count(&open).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	var unfulfilled int64
+	if err = tx.Model(&domain.OrderItem{}).
+		Where("order_id = ? AND fulfilled_quantity < quantity - returned_quantity", order.ID).
+		Count(&unfulfilled).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if open > 0 || unfulfilled > 0 {
+		return
+	}
+
+	return svc.orderService.transition(tx, order.ID, domain.OrderFulfilled, actor, "all items delivered")
+}
+
+// lockFulfillment loads a fulfillment and its items with a row lock held
+// until tx ends.
+func (svc *FulfillmentService) lockFulfillment(tx *gorm.DB, fulfillmentID string) (fulfillment *domain.OrderFulfillment, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.OrderFulfillment{ID: fulfillmentID}).
+		First(&fulfillment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "fulfillment not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err = tx.Where(&domain.OrderFulfillmentItem{OrderFulfillmentID: fulfillment.ID}).
+		Find(&fulfillment.Items).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+func (svc *FulfillmentService) preload(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items").
+		Preload("Shipping").
+		Preload("Shipping.Histories", func(db *gorm.DB) *gorm.DB {
+			return db.Order("occurred_at ASC")
+		})
+}
+
+// addFulfilled adds sign times the fulfillment item quantities to the
+// fulfilled quantity of their order items.
+func addFulfilled(tx *gorm.DB, items domain.OrderFulfillmentItems, sign int32) (err error) {
+	for _, line := range items {
+		if err = tx.Model(&domain.OrderItem{ID: line.OrderItemID}).
+			Update("fulfilled_quantity", gorm.Expr("fulfilled_quantity + ?", sign*line.Quantity)).Error; err != nil {
+			return
+		}
+	}
+	return
+}
+
+// validateLocation checks through the organization service that locationID
+// is a location of the organization.
+func validateLocation(ctx context.Context, organizationConn organization.ServiceClient, organizationID, locationID string) error {
+	locations, err := organizationConn.ListLocation(ctx, &organization.ListLocationRequest{
+		OrganizationId: organizationID,
+		Page:           1,
+		Size:           maxLocations,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, loc := range locations.Data {
+		if loc.LocationId == locationID {
+			return nil
+		}
+	}
+
+	return status.Errorf(codes.InvalidArgument, "location %s not found", locationID)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// layawayExpiredReason is the reason recorded for orders cancelled because
+// their layaway expired.
+const layawayExpiredReason = "layaway expired"
+
+// LayawayService keeps the stock of orders reserved while their customers
+// pay them off, and gives the deposits of expired layaways back.
+type LayawayService struct {
+	db             *gorm.DB
+	orderService   *OrderService
+	paymentService *PaymentService
+	refundService  *RefundService
+}
+
+func NewLayawayService(
+	db *gorm.DB,
+	orderService *OrderService,
+	paymentService *PaymentService,
+	refundService *RefundService,
+) *LayawayService {
+	return &LayawayService{
+		db:             db,
+		orderService:   orderService,
+		paymentService: paymentService,
+		refundService:  refundService,
+	}
+}
+
+// PlaceLayaway takes a deposit for an order and keeps its stock reserved
+// until expiresAt. Orders not fully paid by then are cancelled by
+// ExpireLayaways, which releases their stock.
+func (svc *LayawayService) PlaceLayaway(ctx context.Context, deposit domain.OrderPayment, expiresAt time.Time, actor string) (*domain.OrderPayment, error) {
+	ctx, span := tracer.Start(ctx, "PlaceLayaway")
+	defer span.End()
+
+	if !expiresAt.After(time.Now()) {
+		return nil, status.Error(codes.InvalidArgument, "layaway expiry must be in the future")
+	}
+
+	if deposit.Amount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "deposit amount must be greater than zero")
+	}
+
+	payment, err := svc.paymentService.CreateIntent(ctx, deposit, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.db.WithContext(ctx).Model(&domain.Order{ID: deposit.OrderID}).
+		Update("layaway_expires_at", expiresAt).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return payment, nil
+}
+
+// ExpireLayaways cancels the unpaid orders whose layaway expired, voiding
+// their open payments and releasing their reserved stock, then refunds the
+// deposits they captured: to the store credit of the order's customer, or
+// to the original payment of anonymous orders. Deposits whose refund fails
+// are retried on the next call. It returns the number of cancelled orders.
+func (svc *LayawayService) ExpireLayaways(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "ExpireLayaways")
+	defer span.End()
+
+	now := time.Now()
+
+	var orders domain.Orders
+	if err := svc.db.WithContext(ctx).
+		Where("layaway_expires_at < ? AND status IN ?", now, []domain.OrderStatus{domain.OrderCreated, domain.OrderAwaitingPayment}).
+		Find(&orders).Error; err != nil {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+
+	expired := 0
+	for _, order := range orders {
+		var open domain.OrderPayments
+		if err := svc.db.WithContext(ctx).
+			Where("order_id = ? AND status IN ?", order.ID, []domain.PaymentState{domain.PaymentPending, domain.PaymentAuthorized}).
+			Find(&open).Error; err != nil {
+			return expired, status.Error(codes.Internal, err.Error())
+		}
+
+		for _, payment := range open {
+			if _, err := svc.paymentService.Void(ctx, payment.ID); err != nil {
+				return expired, err
+			}
+		}
+
+		if err := svc.orderService.Transition(ctx, order.ID, domain.OrderCancelled, "system", layawayExpiredReason); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+
+	return expired, svc.refundDeposits(ctx, now)
+}
+
+// refundDeposits refunds what is left of the captured payments of the
+// orders ExpireLayaways cancelled. Orders cancelled otherwise are refunded
+// by hand.
+func (svc *LayawayService) refundDeposits(ctx context.Context, now time.Time) error {
+	var deposits domain.OrderPayments
+	if err := svc.db.WithContext(ctx).
+		Joins("JOIN orders ON orders.order_id = order_payments.order_id").
+		Where("orders.layaway_expires_at < ? AND orders.status = ?", now, domain.OrderCancelled).
+		Where("EXISTS (SELECT 1 FROM order_events WHERE order_events.order_id = orders.order_id AND order_events.to_status = ? AND order_events.reason = ?)",
+			domain.OrderCancelled, layawayExpiredReason).
+		Where("order_payments.status = ? AND order_payments.amount > order_payments.refunded_amount", domain.PaymentCaptured).
+		Find(&deposits).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for _, deposit := range deposits {
+		var order domain.Order
+		if err := svc.db.WithContext(ctx).Where(&domain.Order{ID: deposit.OrderID}).First(&order).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		destination := domain.RefundToOriginalPayment
+		if order.CustomerID != nil {
+			destination = domain.RefundToStoreCredit
+		}
+
+		if _, err := svc.refundService.Refund(ctx, domain.OrderRefund{
+			OrderID:        deposit.OrderID,
+			OrderPaymentID: deposit.ID,
+			Destination:    destination,
+			Amount:         deposit.Refundable(),
+			Reason:         layawayExpiredReason,
+		}, "system"); err != nil {
+			zap.L().Error("failed refund layaway deposit", zap.String("order_payment_id", deposit.ID), zap.Error(err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxOrderNumberPadding bounds the zero padding of order numbers.
+const maxOrderNumberPadding = 12
+
+// NumberingService configures how the orders of an organization are
+// numbered.
+type NumberingService struct {
+	db *gorm.DB
+}
+
+func NewNumberingService(db *gorm.DB) *NumberingService {
+	return &NumberingService{
+		db: db,
+	}
+}
+
+// GetOrderNumbering returns the order number sequence of an organization,
+// or the default one when it has none yet.
+func (svc *NumberingService) GetOrderNumbering(ctx context.Context, organizationID string) (*domain.OrderNumberSequence, error) {
+	ctx, span := tracer.Start(ctx, "GetOrderNumbering")
+	defer span.End()
+
+	var seq *domain.OrderNumberSequence
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.OrderNumberSequence{OrganizationID: organizationID}).
+		First(&seq).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			def := domain.DefaultOrderNumberSequence(organizationID)
+			return &def, nil
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return seq, nil
+}
+
+// ConfigureOrderNumbering sets the prefix, yearly reset and padding of an
+// organization's order numbers. The current count is kept, so numbering
+// carries on from the last order.
+func (svc *NumberingService) ConfigureOrderNumbering(ctx context.Context, req domain.OrderNumberSequence) (*domain.OrderNumberSequence, error) {
+	ctx, span := tracer.Start(ctx, "ConfigureOrderNumbering")
+	defer span.End()
+
+	if strings.ContainsAny(req.Prefix, " \t\n") {
+		return nil, status.Error(codes.InvalidArgument, "prefix can't contain spaces")
+	}
+
+	if req.Padding < 1 || req.Padding > maxOrderNumberPadding {
+		return nil, status.Errorf(codes.InvalidArgument, "padding must be between 1 and %d", maxOrderNumberPadding)
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		seq, err := lockOrderNumberSequence(tx, req.OrganizationID)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Model(seq).Updates(map[string]any{
+			"prefix":       req.Prefix,
+			"reset_yearly": req.ResetYearly,
+			"padding":      req.Padding,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return svc.GetOrderNumbering(ctx, req.OrganizationID)
+}
+
+// nextOrderNo allocates the next order number of an organization. It must
+// run in the transaction creating the order: the sequence row stays locked
+// until the order is saved, and a rollback gives the number back. Numbers
+// already taken by orders numbered by hand are skipped.
+func nextOrderNo(tx *gorm.DB, organizationID string, t time.Time) (orderNo string, err error) {
+	seq, err := lockOrderNumberSequence(tx, organizationID)
+	if err != nil {
+		return
+	}
+
+	for {
+		orderNo = seq.Next(t)
+
+		var taken int64
+		if err = tx.Model(&domain.Order{}).Unscoped().
+			Where(&domain.Order{OrganizationID: organizationID, OrderNo: orderNo}).
+			Count(&taken).Error; err != nil {
+			return
+		}
+
+		if taken == 0 {
+			break
+		}
+	}
+
+	err = tx.Model(seq).Updates(map[string]any{
+		"year":       seq.Year,
+		"last_value": seq.LastValue,
+	}).Error
+	return
+}
+
+// lockOrderNumberSequence loads the order number sequence of an
+// organization, creating the default one first, with a row lock held until
+// tx ends.
+func lockOrderNumberSequence(tx *gorm.DB, organizationID string) (seq *domain.OrderNumberSequence, err error) {
+	def := domain.DefaultOrderNumberSequence(organizationID)
+	if err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&def).Error; err != nil {
+		return
+	}
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.OrderNumberSequence{OrganizationID: organizationID}).
+		First(&seq).Error
+	return
+}
@@ -0,0 +1,503 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-genproto/smallbiznis/customer/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderEditService changes the lines, customer and addresses of orders
+// already placed, keeping their reservations and totals in step and
+// recording every edit as an OrderRevision.
+type OrderEditService struct {
+	db               *gorm.DB
+	organizationConn organization.ServiceClient
+	customerConn     customer.CustomerServiceClient
+	orderService     *OrderService
+	pricingService   *PricingService
+	stockService     *StockService
+}
+
+func NewOrderEditService(
+	db *gorm.DB,
+	organizationConn organization.ServiceClient,
+	customerConn customer.CustomerServiceClient,
+	orderService *OrderService,
+	pricingService *PricingService,
+	stockService *StockService,
+) *OrderEditService {
+	return &OrderEditService{
+		db:               db,
+		organizationConn: organizationConn,
+		customerConn:     customerConn,
+		orderService:     orderService,
+		pricingService:   pricingService,
+		stockService:     stockService,
+	}
+}
+
+// ListOrderRevision lists the revisions of an order, oldest first.
+func (svc *OrderEditService) ListOrderRevision(ctx context.Context, orderID string) (domain.OrderRevisions, error) {
+	ctx, span := tracer.Start(ctx, "ListOrderRevision")
+	defer span.End()
+
+	var revisions domain.OrderRevisions
+	if err := svc.db.WithContext(ctx).
+		Preload("Lines").
+		Where(&domain.OrderRevision{OrderID: orderID}).
+		Order("created_at ASC").
+		Find(&revisions).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return revisions, nil
+}
+
+// EditOrder applies edit to an order that is unpaid or being fulfilled and
+// records it as a revision by actor. The order is repriced with its
+// coupons at the time it was placed, lines it had keeping the unit price
+// they were sold at. Stock is reserved for added and grown lines before the
+// edit is saved, and released from shrunk and removed lines once it is,
+// both through stock reservations so ReleaseOrphans finishes what a crash
+// interrupts.
+//
+// Fulfilled quantities can't be taken off and lines with returns can't be
+// changed. No order can come to cost less than was captured, as edits
+// don't refund; unpaid orders can't have open payments and orders being
+// fulfilled can't cost more than was paid. Edits changing nothing return
+// the order as it is.
+func (svc *OrderEditService) EditOrder(ctx context.Context, orderID string, edit domain.OrderEdit, actor, reason string) (*domain.Order, error) {
+	ctx, span := tracer.Start(ctx, "EditOrder")
+	defer span.End()
+
+	var order *domain.Order
+	if err := svc.db.WithContext(ctx).
+		Preload("OrderItems").
+		Where(&domain.Order{ID: orderID}).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "order not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Stock reserved for the edit is recorded against the revision.
+	revision := domain.OrderRevision{
+		ID:                    uuid.NewString(),
+		OrderID:               order.ID,
+		FromCustomerID:        order.CustomerID,
+		FromBillingAddressID:  order.BillingAddressID,
+		FromShippingAddressID: order.ShippingAddressID,
+		FromTotalAmount:       order.TotalAmount,
+		Actor:                 actor,
+		Reason:                reason,
+	}
+
+	previous := make(domain.OrderItems, len(order.OrderItems))
+	copy(previous, order.OrderItems)
+
+	removed, err := editLines(order, edit.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = svc.editCustomer(ctx, order, edit); err != nil {
+		return nil, err
+	}
+
+	revision.Lines = revisionLines(previous, order.OrderItems, removed)
+	revision.ToCustomerID = order.CustomerID
+	revision.ToBillingAddressID = order.BillingAddressID
+	revision.ToShippingAddressID = order.ShippingAddressID
+
+	// editCustomer snapshots the addresses it sets.
+	billingChanged := order.BillingAddress != nil || !sameID(revision.FromBillingAddressID, revision.ToBillingAddressID)
+	shippingChanged := order.ShippingAddress != nil || !sameID(revision.FromShippingAddressID, revision.ToShippingAddressID)
+	if len(revision.Lines) == 0 && !billingChanged && !shippingChanged &&
+		sameID(revision.FromCustomerID, revision.ToCustomerID) {
+		return order, nil
+	}
+
+	if err = editable(order); err != nil {
+		return nil, err
+	}
+
+	if order.CouponCodes, err = redeemedCodes(svc.db.WithContext(ctx), order.ID); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	org, err := svc.organizationConn.GetOrg(ctx, &organization.GetOrganizationRequest{
+		OrganizationId: order.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = svc.pricingService.RepriceOrder(ctx, org, order); err != nil {
+		return nil, err
+	}
+	revision.ToTotalAmount = order.TotalAmount
+
+	if err = svc.grow(ctx, order, revision); err != nil {
+		return nil, err
+	}
+
+	var releases domain.StockReservations
+	if err = svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		locked, err := svc.orderService.lockOrder(tx, order.ID)
+		if err != nil {
+			return
+		}
+
+		// Transitions and other edits touch the order; pricing worked from
+		// the order as it was loaded.
+		if !locked.UpdatedAt.Equal(order.UpdatedAt) {
+			return status.Error(codes.Aborted, "order changed while being edited")
+		}
+
+		balance, err := orderBalance(tx, order)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if order.Status == domain.OrderFulfilling && order.TotalAmount > balance.Captured {
+			return status.Error(codes.FailedPrecondition, "order being fulfilled can't cost more than was paid")
+		}
+
+		if order.Status != domain.OrderFulfilling && balance.Open > 0 {
+			return status.Error(codes.FailedPrecondition, "order with open payments can't be edited")
+		}
+
+		// Edits don't refund; returns do.
+		if order.TotalAmount < balance.Captured {
+			return status.Error(codes.FailedPrecondition, "order can't cost less than was paid; return the lines instead")
+		}
+
+		// The revision holds the stock grow reserved for it from here.
+		if err = tx.Where("order_revision_id = ? AND NOT release", revision.ID).
+			Delete(&domain.StockReservation{}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if releases, err = svc.shrink(tx, order, removed); err != nil {
+			return
+		}
+
+		if err = tx.Where(&domain.OrderDiscount{OrderID: order.ID}).
+			Delete(&domain.OrderDiscount{}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if len(order.Discounts) > 0 {
+			if err = tx.Create(&order.Discounts).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		for _, orderItem := range removed {
+			if err = tx.Delete(&orderItem).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		for i := range order.OrderItems {
+			orderItem := &order.OrderItems[i]
+
+			if orderItem.CreatedAt.IsZero() {
+				err = tx.Omit(clause.Associations).Create(orderItem).Error
+			} else {
+				err = tx.Model(orderItem).Updates(map[string]any{
+					"quantity":          orderItem.Quantity,
+					"inventory_item_id": orderItem.InventoryItemID,
+					"reserved_quantity": orderItem.ReservedQuantity,
+					"discount_amount":   orderItem.DiscountAmount,
+					"total_price":       orderItem.TotalPrice,
+					"tax_amount":        orderItem.TaxAmount,
+				}).Error
+			}
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		if billingChanged {
+			if err = tx.Unscoped().Where(&domain.OrderBillingAddress{OrderID: order.ID}).
+				Delete(&domain.OrderBillingAddress{}).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if order.BillingAddress != nil {
+				if err = tx.Create(order.BillingAddress).Error; err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+			}
+		}
+
+		if shippingChanged {
+			if err = tx.Unscoped().Where(&domain.OrderShippingAddress{OrderID: order.ID}).
+				Delete(&domain.OrderShippingAddress{}).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if order.ShippingAddress != nil {
+				if err = tx.Create(order.ShippingAddress).Error; err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+			}
+		}
+
+		if err = tx.Model(order).Updates(map[string]any{
+			"customer_id":         order.CustomerID,
+			"billing_address_id":  order.BillingAddressID,
+			"shipping_address_id": order.ShippingAddressID,
+			"discount_amount":     order.DiscountAmount,
+			"sub_total":           order.SubTotal,
+			"tax_amount":          order.TaxAmount,
+			"total_amount":        order.TotalAmount,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Create(&revision).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		if releaseErr := svc.stockService.AbandonRevision(ctx, revision.ID); releaseErr != nil {
+			zap.L().Error("failed release stock", zap.String("order_id", order.ID), zap.Error(releaseErr))
+		}
+		return nil, err
+	}
+
+	svc.stockService.ReleaseRecorded(ctx, releases)
+
+	return order, nil
+}
+
+// grow reserves stock for the lines of order that need more than they
+// hold, for the edit saved as revision. Failures release the reservations
+// already made.
+func (svc *OrderEditService) grow(ctx context.Context, order *domain.Order, revision domain.OrderRevision) (err error) {
+	for i := range order.OrderItems {
+		orderItem := &order.OrderItems[i]
+
+		added := orderItem.CreatedAt.IsZero()
+		if !added && (orderItem.InventoryItemID == nil || orderItem.Quantity <= orderItem.ReservedQuantity) {
+			continue
+		}
+
+		if err = svc.stockService.Grow(ctx, order, orderItem, revision.ID); err != nil {
+			return errors.Join(err, svc.stockService.AbandonRevision(ctx, revision.ID))
+		}
+	}
+
+	return
+}
+
+// shrink records within tx the release of what the order items of order
+// hold beyond their quantity, and all that removed ones hold.
+func (svc *OrderEditService) shrink(tx *gorm.DB, order *domain.Order, removed domain.OrderItems) (releases domain.StockReservations, err error) {
+	for i := range order.OrderItems {
+		release, err := svc.stockService.RecordRelease(tx, &order.OrderItems[i], order.OrderItems[i].Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if release != nil {
+			releases = append(releases, *release)
+		}
+	}
+
+	for i := range removed {
+		release, err := svc.stockService.RecordRelease(tx, &removed[i], 0)
+		if err != nil {
+			return nil, err
+		}
+		if release != nil {
+			releases = append(releases, *release)
+		}
+	}
+
+	return
+}
+
+// editCustomer applies the customer and addresses of edit to order,
+// snapshotting the addresses it changes. Addresses belong to a customer, so
+// changing the customer takes new addresses for those the order has.
+func (svc *OrderEditService) editCustomer(ctx context.Context, order *domain.Order, edit domain.OrderEdit) (err error) {
+	customerChanged := false
+	if edit.CustomerID != nil {
+		customerChanged = !sameID(order.CustomerID, optional(*edit.CustomerID))
+		order.CustomerID = optional(*edit.CustomerID)
+	}
+
+	var customerID string
+	if order.CustomerID != nil {
+		customerID = *order.CustomerID
+	}
+
+	if edit.BillingAddressID != nil {
+		if addressID := optional(*edit.BillingAddressID); !sameID(order.BillingAddressID, addressID) || customerChanged {
+			order.BillingAddressID = addressID
+			order.BillingAddress = nil
+			if addressID != nil {
+				addr, err := customerAddress(ctx, svc.customerConn, customerID, *addressID)
+				if err != nil {
+					return err
+				}
+				order.BillingAddress = &domain.OrderBillingAddress{OrderID: order.ID, OrderAddress: addr}
+			}
+		}
+	} else if customerChanged && order.BillingAddressID != nil {
+		return status.Error(codes.InvalidArgument, "billing_address_id is required when the customer changes")
+	}
+
+	if edit.ShippingAddressID != nil {
+		if addressID := optional(*edit.ShippingAddressID); !sameID(order.ShippingAddressID, addressID) || customerChanged {
+			order.ShippingAddressID = addressID
+			order.ShippingAddress = nil
+			if addressID != nil {
+				addr, err := customerAddress(ctx, svc.customerConn, customerID, *addressID)
+				if err != nil {
+					return err
+				}
+				order.ShippingAddress = &domain.OrderShippingAddress{OrderID: order.ID, OrderAddress: addr}
+			}
+		}
+	} else if customerChanged && order.ShippingAddressID != nil {
+		return status.Error(codes.InvalidArgument, "shipping_address_id is required when the customer changes")
+	}
+
+	return
+}
+
+// editable reports why order can't be edited, if it can't.
+func editable(order *domain.Order) error {
+	switch order.Status {
+	case domain.OrderCreated, domain.OrderAwaitingPayment, domain.OrderFulfilling:
+		return nil
+	}
+	return status.Errorf(codes.FailedPrecondition, "order in status %s can't be edited", order.Status)
+}
+
+// editLines applies the edit lines to the order items of order in place,
+// appending the variants added, unsaved. It returns the order items
+// removed, taking them out of the order.
+func editLines(order *domain.Order, lines domain.OrderEditLines) (removed domain.OrderItems, err error) {
+	edited := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if line.Quantity < 0 {
+			return nil, status.Error(codes.InvalidArgument, "quantity can't be negative")
+		}
+
+		if line.OrderItemID == "" {
+			if line.VariantID == "" {
+				return nil, status.Error(codes.InvalidArgument, "variant_id is required")
+			}
+
+			if line.Quantity == 0 {
+				return nil, status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+			}
+
+			// Discounts and the revision refer to the order item.
+			order.OrderItems = append(order.OrderItems, domain.OrderItem{
+				ID:        uuid.NewString(),
+				OrderID:   order.ID,
+				VariantID: line.VariantID,
+				Quantity:  line.Quantity,
+			})
+			continue
+		}
+
+		if edited[line.OrderItemID] {
+			return nil, status.Errorf(codes.InvalidArgument, "order item %s is edited twice", line.OrderItemID)
+		}
+		edited[line.OrderItemID] = true
+
+		i := slices.IndexFunc(order.OrderItems, func(orderItem domain.OrderItem) bool {
+			return orderItem.ID == line.OrderItemID
+		})
+		if i < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "order item %s not found", line.OrderItemID)
+		}
+
+		orderItem := &order.OrderItems[i]
+		if orderItem.Quantity == line.Quantity {
+			continue
+		}
+
+		if orderItem.ReturnedQuantity > 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "order item %s has returns and can't be changed", orderItem.ID)
+		}
+
+		if line.Quantity < orderItem.FulfilledQuantity {
+			return nil, status.Errorf(codes.FailedPrecondition, "order item %s has %d fulfilled", orderItem.ID, orderItem.FulfilledQuantity)
+		}
+
+		orderItem.Quantity = line.Quantity
+	}
+
+	kept := make(domain.OrderItems, 0, len(order.OrderItems))
+	for _, orderItem := range order.OrderItems {
+		if orderItem.Quantity == 0 {
+			removed = append(removed, orderItem)
+			continue
+		}
+		kept = append(kept, orderItem)
+	}
+	order.OrderItems = kept
+
+	return
+}
+
+// revisionLines lists the order items whose quantity changed from previous.
+func revisionLines(previous, orderItems, removed domain.OrderItems) (lines domain.OrderRevisionLines) {
+	quantity := func(orderItemID string) int32 {
+		i := slices.IndexFunc(previous, func(orderItem domain.OrderItem) bool {
+			return orderItem.ID == orderItemID
+		})
+		if i < 0 {
+			return 0
+		}
+		return previous[i].Quantity
+	}
+
+	for _, orderItem := range orderItems {
+		if from := quantity(orderItem.ID); from != orderItem.Quantity {
+			lines = append(lines, domain.OrderRevisionLine{
+				OrderItemID:  orderItem.ID,
+				VariantID:    orderItem.VariantID,
+				FromQuantity: from,
+				ToQuantity:   orderItem.Quantity,
+			})
+		}
+	}
+
+	for _, orderItem := range removed {
+		lines = append(lines, domain.OrderRevisionLine{
+			OrderItemID:  orderItem.ID,
+			VariantID:    orderItem.VariantID,
+			FromQuantity: quantity(orderItem.ID),
+		})
+	}
+
+	return
+}
+
+// sameID reports whether two optional IDs are equal.
+func sameID(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -1,3 +1,320 @@
 package service
 
-func NewNotificationService() {}
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CustomerConn is the connection to the customer service, for its
+// rpc.Services.
+type CustomerConn grpc.ClientConnInterface
+
+// batchCustomerServiceName is the customer service serving customers by ID
+// in batches.
+const batchCustomerServiceName = "smallbiznis.customer.v1.BatchCustomerService"
+
+// WithCustomers sets the customer of orders, loading them from the customer
+// service in one call.
+func WithCustomers(ctx context.Context, customerConn CustomerConn, orders domain.Orders) error {
+	var customerIDs []string
+	for _, order := range orders {
+		if order.CustomerID != nil && !slices.Contains(customerIDs, *order.CustomerID) {
+			customerIDs = append(customerIDs, *order.CustomerID)
+		}
+	}
+
+	if len(customerIDs) == 0 {
+		return nil
+	}
+
+	req := struct {
+		CustomerIDs []string `json:"customer_ids"`
+	}{
+		CustomerIDs: customerIDs,
+	}
+
+	var resp struct {
+		Data domain.Customers `json:"data"`
+	}
+	if err := rpc.Invoke(ctx, customerConn, batchCustomerServiceName, "BatchGetCustomer", &req, &resp); err != nil {
+		return err
+	}
+
+	for i := range orders {
+		if orders[i].CustomerID == nil {
+			continue
+		}
+
+		for j := range resp.Data {
+			if resp.Data[j].ID == *orders[i].CustomerID {
+				orders[i].Customer = &resp.Data[j]
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+type OrderService struct {
+	db           *gorm.DB
+	stockService *StockService
+}
+
+func NewOrderService(
+	db *gorm.DB,
+	stockService *StockService,
+) *OrderService {
+	return &OrderService{
+		db:           db,
+		stockService: stockService,
+	}
+}
+
+// Create reserves stock for the order and saves it together with the event
+// recording its initial status. Orders without an order number get the next
+// one of their organization's sequence, and the coupons of CouponCodes are
+// redeemed. Reserved stock is released again when the order can't be saved,
+// or by StockService.ReleaseOrphans when the service stops before it is.
+func (svc *OrderService) Create(ctx context.Context, order domain.Order, actor string) (err error) {
+	ctx, span := tracer.Start(ctx, "CreateOrder")
+	defer span.End()
+
+	if order.LocationID == nil {
+		locationID, err := svc.stockService.DefaultLocation(ctx, order.OrganizationID)
+		if err != nil {
+			return err
+		}
+		order.LocationID = &locationID
+	}
+
+	if err = svc.stockService.Reserve(ctx, &order); err != nil {
+		return
+	}
+
+	if err = svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if order.OrderNo == "" {
+			if order.OrderNo, err = nextOrderNo(tx, order.OrganizationID, time.Now()); err != nil {
+				return
+			}
+		}
+
+		if err = tx.Create(&order).Error; err != nil {
+			return
+		}
+
+		// The order now holds its reservations itself.
+		if err = tx.Where("order_id = ?", order.ID).Delete(&domain.StockReservation{}).Error; err != nil {
+			return
+		}
+
+		if err = redeemCoupons(tx, &order, order.CouponCodes, time.Now()); err != nil {
+			return
+		}
+
+		return tx.Create(&domain.OrderEvent{
+			OrderID:  order.ID,
+			ToStatus: order.Status,
+			Actor:    actor,
+		}).Error
+	}); err != nil {
+		if abandonErr := svc.stockService.Abandon(ctx, order.ID); abandonErr != nil {
+			zap.L().Error("failed release stock", zap.String("order_id", order.ID), zap.Error(abandonErr))
+		}
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+// Transition moves an order to the next status and records the change.
+// It returns FailedPrecondition when the transition is not allowed, which
+// includes the settled statuses only payments and refunds move orders to.
+// Cancelling an order releases the stock it still holds, through stock
+// reservations ReleaseOrphans retries when the release fails; cancelling or
+// refunding it gives back the coupons it redeemed. Paying it issues the
+// gift cards it sold.
+func (svc *OrderService) Transition(ctx context.Context, orderID string, next domain.OrderStatus, actor, reason string) (err error) {
+	ctx, span := tracer.Start(ctx, "TransitionOrder")
+	defer span.End()
+
+	if next.IsSettled() {
+		return status.Errorf(codes.FailedPrecondition, "order status %s is set by payments and refunds", next)
+	}
+
+	if err = svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return svc.transition(tx, orderID, next, actor, reason)
+	}); err != nil {
+		return
+	}
+
+	if next == domain.OrderCancelled {
+		return svc.ReleaseStock(ctx, orderID)
+	}
+
+	return
+}
+
+// ReleaseStock returns the stock released by the saved changes of an order
+// to the inventory service. Releases that fail are left for
+// ReleaseOrphans.
+func (svc *OrderService) ReleaseStock(ctx context.Context, orderID string) (err error) {
+	var releases domain.StockReservations
+	if err = svc.db.WithContext(ctx).
+		Where("order_id = ? AND release", orderID).
+		Find(&releases).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	svc.stockService.ReleaseRecorded(ctx, releases)
+
+	return
+}
+
+// recordStockRelease records within tx the release of all the stock the
+// order items of an order hold.
+func (svc *OrderService) recordStockRelease(tx *gorm.DB, orderID string) (err error) {
+	var orderItems domain.OrderItems
+	if err = tx.Where("order_id = ? AND reserved_quantity > 0", orderID).
+		Find(&orderItems).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for i := range orderItems {
+		if _, err = svc.stockService.RecordRelease(tx, &orderItems[i], 0); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// transition is Transition within an existing database transaction, so that
+// other subsystems can change an order status atomically with their own writes.
+func (svc *OrderService) transition(tx *gorm.DB, orderID string, next domain.OrderStatus, actor, reason string) (err error) {
+	if next.String() == "" {
+		return status.Error(codes.InvalidArgument, "invalid order status")
+	}
+
+	order, err := svc.lockOrder(tx, orderID)
+	if err != nil {
+		return
+	}
+
+	if !order.Status.CanTransitionTo(next) {
+		return status.Errorf(codes.FailedPrecondition, "order can't transition from %s to %s", order.Status, next)
+	}
+
+	if err = tx.Model(order).Update("status", next).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err = tx.Create(&domain.OrderEvent{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   next,
+		Actor:      actor,
+		Reason:     reason,
+	}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if next == domain.OrderCancelled || next == domain.OrderRefunded {
+		if err = reverseRedemptions(tx, order.ID); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	// The release is recorded with the cancellation, so it happens even when
+	// the inventory service can't be reached once it commits.
+	if next == domain.OrderCancelled {
+		if err = svc.recordStockRelease(tx, order.ID); err != nil {
+			return
+		}
+	}
+
+	if next == domain.OrderPaid {
+		if err = issueGiftCards(tx, order, actor); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if next == domain.OrderFulfilled {
+		if err = svc.publishFulfilled(tx, order); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return
+}
+
+// publishFulfilled publishes the fulfillment of order to the inventory
+// service through the outbox, so the sale is booked even when inventory
+// isn't listening when tx commits.
+func (svc *OrderService) publishFulfilled(tx *gorm.DB, order *domain.Order) (err error) {
+	var items domain.OrderItems
+	if err = tx.Where(&domain.OrderItem{OrderID: order.ID}).Find(&items).Error; err != nil {
+		return
+	}
+
+	event := domain.OrderFulfilledEvent{
+		OrderID:        order.ID,
+		OrganizationID: order.OrganizationID,
+	}
+
+	if order.LocationID != nil {
+		event.LocationID = *order.LocationID
+	}
+
+	for _, item := range items {
+		line := domain.OrderFulfilledLineItem{
+			VariantID:        item.VariantID,
+			Quantity:         item.Quantity - item.ReturnedQuantity,
+			ReservedQuantity: item.ReservedQuantity,
+		}
+
+		if item.InventoryItemID != nil {
+			line.InventoryItemID = *item.InventoryItemID
+		}
+
+		event.Items = append(event.Items, line)
+	}
+
+	if err = publish(tx, domain.OrderFulfilledChannel, event); err != nil {
+		return
+	}
+
+	// The inventory service turns the reservations into sales when it
+	// handles the event.
+	return tx.Model(&domain.OrderItem{}).
+		Where("order_id = ? AND reserved_quantity > 0", order.ID).
+		Update("reserved_quantity", 0).Error
+}
+
+// lockOrder loads an order with a row lock held until tx ends.
+func (svc *OrderService) lockOrder(tx *gorm.DB, orderID string) (order *domain.Order, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.Order{ID: orderID}).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "order not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
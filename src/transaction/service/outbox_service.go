@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxOutboxEvents is the most pending events listed at once.
+const maxOutboxEvents = 500
+
+// OutboxService hands the events published by the other services to their
+// consumers, which list the pending events of a channel and acknowledge
+// each one they handled.
+type OutboxService struct {
+	db *gorm.DB
+}
+
+func NewOutboxService(db *gorm.DB) *OutboxService {
+	return &OutboxService{
+		db: db,
+	}
+}
+
+// ListPending lists up to size unacknowledged events of channel, oldest
+// first.
+func (svc *OutboxService) ListPending(ctx context.Context, channel string, size int) (events domain.OutboxEvents, err error) {
+	ctx, span := tracer.Start(ctx, "ListPendingOutboxEvent")
+	defer span.End()
+
+	if channel == "" {
+		return nil, status.Error(codes.InvalidArgument, "channel is required")
+	}
+
+	if size <= 0 || size > maxOutboxEvents {
+		size = maxOutboxEvents
+	}
+
+	if err = svc.db.WithContext(ctx).
+		Where("channel = ? AND acked_at IS NULL", channel).
+		Order("created_at ASC, outbox_event_id ASC").
+		Limit(size).
+		Find(&events).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return events, nil
+}
+
+// Ack marks an event handled by its consumer. Acknowledging it again is a
+// no-op.
+func (svc *OutboxService) Ack(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "AckOutboxEvent")
+	defer span.End()
+
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "event_id is required")
+	}
+
+	if err := svc.db.WithContext(ctx).Model(&domain.OutboxEvent{}).
+		Where("outbox_event_id = ? AND acked_at IS NULL", id).
+		Update("acked_at", time.Now()).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// publish saves event, JSON encoded, as a pending outbox event on channel
+// and notifies the channel of it. Neither happens unless tx commits.
+func publish(tx *gorm.DB, channel string, event any) (err error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	outbox := domain.OutboxEvent{
+		ID:      uuid.NewString(),
+		Channel: channel,
+		Payload: string(payload),
+	}
+	if err = tx.Create(&outbox).Error; err != nil {
+		return
+	}
+
+	return tx.Exec("SELECT pg_notify(?, ?)", channel, outbox.ID).Error
+}
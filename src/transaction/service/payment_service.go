@@ -0,0 +1,433 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentService takes payments for orders through payment provider
+// adapters. An order becomes paid once its captured payments cover its
+// total amount.
+type PaymentService struct {
+	db           *gorm.DB
+	orderService *OrderService
+	providers    map[string]domain.PaymentProvider
+}
+
+func NewPaymentService(
+	db *gorm.DB,
+	orderService *OrderService,
+	providers []domain.PaymentProvider,
+) *PaymentService {
+	svc := &PaymentService{
+		db:           db,
+		orderService: orderService,
+		providers:    make(map[string]domain.PaymentProvider, len(providers)),
+	}
+
+	for _, p := range providers {
+		svc.providers[p.ID()] = p
+	}
+
+	return svc
+}
+
+// ListPayment lists the payments of an order, oldest first.
+func (svc *PaymentService) ListPayment(ctx context.Context, orderID string) (domain.OrderPayments, error) {
+	ctx, span := tracer.Start(ctx, "ListPayment")
+	defer span.End()
+
+	var payments domain.OrderPayments
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.OrderPayment{OrderID: orderID}).
+		Order("created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return payments, nil
+}
+
+// ListOverduePayment lists the open payments of an organization past their
+// due date, most overdue first.
+func (svc *PaymentService) ListOverduePayment(ctx context.Context, p pagination.Pagination, organizationID string) (domain.OrderPayments, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListOverduePayment")
+	defer span.End()
+
+	var (
+		payments domain.OrderPayments
+		count    int64
+	)
+	if err := svc.db.WithContext(ctx).Model(&domain.OrderPayment{}).
+		Where(&domain.OrderPayment{OrganizationID: organizationID}).
+		Where("status IN ? AND due_date < ?", []domain.PaymentState{domain.PaymentPending, domain.PaymentAuthorized}, time.Now()).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("due_date ASC").
+		Find(&payments).Error; err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return payments, count, nil
+}
+
+// CreateIntent opens a payment for an order and authorizes it with its
+// provider. An order can take several payments, up to its outstanding
+// balance. Without a method the first method the provider supports is
+// used; without an amount the outstanding balance is requested, or as much
+// of it as the tendered cash covers. Change due is computed for cash.
+// A created order moves to awaiting_payment.
+func (svc *PaymentService) CreateIntent(ctx context.Context, req domain.OrderPayment, actor string) (*domain.OrderPayment, error) {
+	ctx, span := tracer.Start(ctx, "CreatePaymentIntent")
+	defer span.End()
+
+	provider, ok := svc.providers[req.PaymentProviderID]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, domain.ErrUnknownPaymentProvider.Error())
+	}
+
+	method := req.Method
+	if method == "" {
+		for _, m := range []domain.PaymentMethod{domain.Cash, domain.Card, domain.BankTransfer, domain.GiftCardPayment, domain.StoreCreditPayment} {
+			if provider.Supports(m) {
+				method = m
+				break
+			}
+		}
+	}
+
+	if !provider.Supports(method) {
+		return nil, status.Errorf(codes.InvalidArgument, "payment provider %s doesn't support method %s", provider.ID(), method)
+	}
+
+	if req.Amount < 0 || req.Tendered < 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount can't be negative")
+	}
+
+	payment := domain.OrderPayment{
+		ID:                uuid.NewString(),
+		OrderID:           req.OrderID,
+		PaymentProviderID: provider.ID(),
+		ProviderReference: req.ProviderReference,
+		Method:            method,
+		Amount:            req.Amount,
+		DueDate:           req.DueDate,
+		Status:            domain.PaymentPending,
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		order, err := svc.orderService.lockOrder(tx, req.OrderID)
+		if err != nil {
+			return
+		}
+
+		if err = acceptsPayments(order); err != nil {
+			return
+		}
+
+		balance, err := orderBalance(tx, order)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if balance.Outstanding <= 0 {
+			return status.Error(codes.FailedPrecondition, "order has no outstanding balance")
+		}
+
+		if payment.Amount == 0 {
+			payment.Amount = balance.Outstanding
+			if method == domain.Cash && req.Tendered > 0 {
+				payment.Amount = min(req.Tendered, balance.Outstanding)
+			}
+		}
+
+		if payment.Amount > balance.Outstanding {
+			return status.Errorf(codes.FailedPrecondition, "amount exceeds outstanding balance of %s", balance.Outstanding.Format(order.Currency))
+		}
+
+		if method == domain.Cash && req.Tendered > 0 {
+			if req.Tendered < payment.Amount {
+				return status.Error(codes.InvalidArgument, "tendered cash is less than amount")
+			}
+			payment.Tendered = req.Tendered
+			payment.ChangeDue = req.Tendered - payment.Amount
+		}
+
+		payment.OrganizationID = order.OrganizationID
+		if err = tx.Create(&payment).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	result, authErr := provider.Authorize(ctx, payment)
+	if authErr != nil {
+		result.Status = domain.PaymentFailed
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if err = tx.Model(&payment).Updates(domain.OrderPayment{
+			ProviderReference: result.Reference,
+			Status:            result.Status,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if authErr != nil {
+			return
+		}
+
+		order, err := svc.orderService.lockOrder(tx, payment.OrderID)
+		if err != nil {
+			return
+		}
+
+		if order.Status == domain.OrderCreated {
+			return svc.orderService.transition(tx, order.ID, domain.OrderAwaitingPayment, actor, "payment intent created")
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	if authErr != nil {
+		return nil, paymentError(authErr)
+	}
+
+	payment.ProviderReference = result.Reference
+	payment.Status = result.Status
+	return &payment, nil
+}
+
+// Balance returns the captured, open and outstanding amounts of an order.
+func (svc *PaymentService) Balance(ctx context.Context, orderID string) (*domain.OrderBalance, error) {
+	ctx, span := tracer.Start(ctx, "OrderBalance")
+	defer span.End()
+
+	var order *domain.Order
+	if err := svc.db.WithContext(ctx).Where(&domain.Order{ID: orderID}).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "order not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	balance, err := orderBalance(svc.db.WithContext(ctx), order)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &balance, nil
+}
+
+// Capture captures an authorized payment. The provider is called outside
+// any transaction; its result is then recorded provided the payment is
+// still authorized. The order moves to paid only once its captured payments
+// cover its total amount. Payments of orders that no longer accept
+// payments, such as cancelled ones, are voided instead and
+// FailedPrecondition returned.
+func (svc *PaymentService) Capture(ctx context.Context, paymentID, actor string) (*domain.OrderPayment, error) {
+	ctx, span := tracer.Start(ctx, "CapturePayment")
+	defer span.End()
+
+	payment, err := svc.getPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != domain.PaymentAuthorized {
+		return nil, status.Errorf(codes.FailedPrecondition, "payment in status %s can't be captured", payment.Status)
+	}
+
+	provider, ok := svc.providers[payment.PaymentProviderID]
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, domain.ErrUnknownPaymentProvider.Error())
+	}
+
+	var order *domain.Order
+	if err = svc.db.WithContext(ctx).Where(&domain.Order{ID: payment.OrderID}).First(&order).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err = acceptsPayments(order); err != nil {
+		if _, voidErr := svc.Void(ctx, paymentID); voidErr != nil {
+			return nil, errors.Join(err, voidErr)
+		}
+		return nil, err
+	}
+
+	result, err := provider.Capture(ctx, *payment)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if payment, err = svc.lockPayment(tx, paymentID); err != nil {
+			return
+		}
+
+		if payment.Status != domain.PaymentAuthorized {
+			return status.Errorf(codes.Aborted, "payment moved to status %s while being captured", payment.Status)
+		}
+
+		now := time.Now()
+		payment.Status = result.Status
+		payment.Date = &now
+		if err = tx.Model(payment).Updates(domain.OrderPayment{
+			Status: payment.Status,
+			Date:   payment.Date,
+		}).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return svc.settle(tx, payment.OrderID, actor)
+	}); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Void cancels a payment that hasn't been captured. Like Capture, the
+// provider is called outside any transaction.
+func (svc *PaymentService) Void(ctx context.Context, paymentID string) (*domain.OrderPayment, error) {
+	ctx, span := tracer.Start(ctx, "VoidPayment")
+	defer span.End()
+
+	payment, err := svc.getPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !payment.Status.IsOpen() {
+		return nil, status.Errorf(codes.FailedPrecondition, "payment in status %s can't be voided", payment.Status)
+	}
+
+	if provider, ok := svc.providers[payment.PaymentProviderID]; ok && payment.Status == domain.PaymentAuthorized {
+		if _, err = provider.Void(ctx, *payment); err != nil {
+			return nil, paymentError(err)
+		}
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if payment, err = svc.lockPayment(tx, paymentID); err != nil {
+			return
+		}
+
+		if !payment.Status.IsOpen() {
+			return status.Errorf(codes.Aborted, "payment moved to status %s while being voided", payment.Status)
+		}
+
+		payment.Status = domain.PaymentVoided
+		if err = tx.Model(payment).Update("status", payment.Status).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// settle moves an order to paid when its captured payments cover its total
+// amount. It must run in the transaction that captured the payment.
+func (svc *PaymentService) settle(tx *gorm.DB, orderID, actor string) (err error) {
+	order, err := svc.orderService.lockOrder(tx, orderID)
+	if err != nil {
+		return
+	}
+
+	balance, err := orderBalance(tx, order)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if balance.Captured < balance.TotalAmount ||
+		!order.Status.CanTransitionTo(domain.OrderPaid) {
+		return
+	}
+
+	return svc.orderService.transition(tx, order.ID, domain.OrderPaid, actor, "payment captured")
+}
+
+// acceptsPayments reports why order can't be paid, if it can't.
+func acceptsPayments(order *domain.Order) error {
+	if order.Status != domain.OrderCreated && order.Status != domain.OrderAwaitingPayment {
+		return status.Errorf(codes.FailedPrecondition, "order in status %s doesn't accept payments", order.Status)
+	}
+	return nil
+}
+
+// getPayment loads a payment without locking it.
+func (svc *PaymentService) getPayment(ctx context.Context, paymentID string) (payment *domain.OrderPayment, err error) {
+	if err = svc.db.WithContext(ctx).
+		Where(&domain.OrderPayment{ID: paymentID}).
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "payment not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+// lockPayment loads a payment with a row lock held until tx ends.
+func (svc *PaymentService) lockPayment(tx *gorm.DB, paymentID string) (payment *domain.OrderPayment, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.OrderPayment{ID: paymentID}).
+		First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "payment not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+// orderBalance sums the captured and open payments of an order.
+func orderBalance(tx *gorm.DB, order *domain.Order) (balance domain.OrderBalance, err error) {
+	var totals struct {
+		Captured domain.Money
+		Open     domain.Money
+	}
+	if err = tx.Model(&domain.OrderPayment{}).
+		Select(`COALESCE(SUM(amount) FILTER (WHERE status = ?), 0) AS captured,
+			COALESCE(SUM(amount) FILTER (WHERE status IN ?), 0) AS open`,
+			domain.PaymentCaptured, []domain.PaymentState{domain.PaymentPending, domain.PaymentAuthorized}).
+		Where(&domain.OrderPayment{OrderID: order.ID}).
+		Scan(&totals).Error; err != nil {
+		return
+	}
+
+	return domain.OrderBalance{
+		OrderID:     order.ID,
+		TotalAmount: order.TotalAmount,
+		Captured:    totals.Captured,
+		Open:        totals.Open,
+		Outstanding: order.TotalAmount - totals.Captured - totals.Open,
+	}, nil
+}
+
+func paymentError(err error) error {
+	if errors.Is(err, domain.ErrPaymentDeclined) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxTaxRules is the page size used when loading an organization's tax rules.
+const maxTaxRules = 100
+
+// ItemConn is the connection to the item service, for its rpc.Services.
+type ItemConn grpc.ClientConnInterface
+
+const (
+	// currencyServiceName is the organization service serving the
+	// currencies of organizations.
+	currencyServiceName = "smallbiznis.organization.v1.CurrencyService"
+
+	// organizationPricingServiceName is the organization service serving
+	// tax rules and shipping rates.
+	organizationPricingServiceName = "smallbiznis.organization.v1.PricingService"
+
+	// variantPriceServiceName is the item service serving the prices of
+	// variants.
+	variantPriceServiceName = "smallbiznis.item.v1.VariantPriceService"
+)
+
+// ListCurrency returns the base and selling currencies of an organization
+// from the organization service.
+func ListCurrency(ctx context.Context, organizationConn OrganizationConn, organizationID string) (domain.OrganizationCurrencies, error) {
+	req := struct {
+		OrganizationID string `json:"organization_id"`
+	}{
+		OrganizationID: organizationID,
+	}
+
+	var resp struct {
+		Data domain.OrganizationCurrencies `json:"data"`
+	}
+	if err := rpc.Invoke(ctx, organizationConn, currencyServiceName, "ListCurrency", &req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+// PricingService prices orders. Prices, tax rates and shipping rates are
+// read from the item and organization services over rpc, in minor units,
+// so no amount goes through the float fields of the protos.
+type PricingService struct {
+	organizationConn OrganizationConn
+	itemConn         ItemConn
+	promotionService *PromotionService
+}
+
+func NewPricingService(
+	organizationConn OrganizationConn,
+	itemConn ItemConn,
+	promotionService *PromotionService,
+) *PricingService {
+	return &PricingService{
+		organizationConn: organizationConn,
+		itemConn:         itemConn,
+		promotionService: promotionService,
+	}
+}
+
+// PriceOrder fills UnitPrice and TotalPrice of every order item from the
+// variant price stored in the item service, applies the organization's
+// promotions and the order's coupon codes and recomputes the order totals.
+//
+// TotalPrice and SubTotal are net of discounts; DiscountAmount holds what
+// promotions took off each line and the order, order discounts being
+// spread over the lines in proportion to their totals. Tax is charged on
+// the discounted amount of every taxable line using every tax rule of the
+// organization's country. Amounts are Money in the order currency: the
+// currency the order was requested in, which must be one of the
+// organization's selling currencies, or its base currency. Each line tax
+// is rounded half away from zero to the minor unit before being summed, so
+// the same order always yields the same receipt. Orders with a
+// ShippingRateID are charged its price, untaxed, on top.
+func (svc *PricingService) PriceOrder(ctx context.Context, org *organization.Organization, order *domain.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "PriceOrder")
+	defer span.End()
+
+	return svc.price(ctx, org, order, false)
+}
+
+// RepriceOrder is PriceOrder for an order already placed: order items keep
+// the UnitPrice they were sold at, and only discounts, taxes and totals are
+// worked out again, with the promotions running when the order was placed.
+// Order items not saved yet, added by an edit, are priced the way
+// PriceOrder prices them.
+func (svc *PricingService) RepriceOrder(ctx context.Context, org *organization.Organization, order *domain.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "RepriceOrder")
+	defer span.End()
+
+	return svc.price(ctx, org, order, true)
+}
+
+func (svc *PricingService) price(ctx context.Context, org *organization.Organization, order *domain.Order, keepUnitPrice bool) (err error) {
+	if len(order.OrderItems) == 0 {
+		return status.Error(codes.InvalidArgument, "order_items can't be empty")
+	}
+
+	// Orders keep the exchange rate they were first priced at.
+	if order.ExchangeRate == 0 {
+		if err = svc.setCurrency(ctx, org, order); err != nil {
+			return
+		}
+	}
+
+	taxRate, err := svc.taxRate(ctx, org)
+	if err != nil {
+		return
+	}
+
+	taxable := make([]bool, len(order.OrderItems))
+	for i := range order.OrderItems {
+		orderItem := &order.OrderItems[i]
+
+		if orderItem.Quantity <= 0 {
+			return status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		variant, err := svc.variantPrice(ctx, order, orderItem.VariantID)
+		if err != nil {
+			return err
+		}
+
+		sold := keepUnitPrice && !orderItem.CreatedAt.IsZero()
+
+		// Discounts refer to their order item, so it needs an ID up front.
+		if orderItem.ID == "" {
+			orderItem.ID = uuid.NewString()
+		}
+
+		if !sold {
+			orderItem.UnitPrice = variant.unitPrice(order)
+		}
+		orderItem.TotalPrice = orderItem.UnitPrice.Mul(orderItem.Quantity)
+		taxable[i] = variant.Taxable
+	}
+
+	pricedAt := time.Now()
+	if keepUnitPrice && !order.CreatedAt.IsZero() {
+		pricedAt = order.CreatedAt
+	}
+
+	discounts, err := svc.promotionService.Apply(ctx, order, pricedAt)
+	if err != nil {
+		return
+	}
+
+	var discountAmount domain.Money
+	for _, discount := range discounts {
+		discountAmount += discount.Amount
+	}
+
+	var subTotal, taxAmount domain.Money
+	for i := range order.OrderItems {
+		orderItem := &order.OrderItems[i]
+
+		orderItem.TotalPrice -= orderItem.DiscountAmount
+
+		// Lines keep their tax, for refunds to give back what they were charged.
+		orderItem.TaxAmount = 0
+		if taxable[i] {
+			orderItem.TaxAmount = orderItem.TotalPrice.Percent(taxRate)
+		}
+
+		subTotal += orderItem.TotalPrice
+		taxAmount += orderItem.TaxAmount
+	}
+
+	order.Discounts = discounts
+	order.DiscountAmount = discountAmount
+	order.SubTotal = subTotal
+	order.TaxAmount = taxAmount
+
+	if !keepUnitPrice {
+		if order.ShippingAmount, err = svc.shippingAmount(ctx, org, order); err != nil {
+			return
+		}
+	}
+	order.TotalAmount = subTotal + taxAmount + order.ShippingAmount
+
+	return
+}
+
+// taxRate is the sum of the tax rules of the organization's country.
+func (svc *PricingService) taxRate(ctx context.Context, org *organization.Organization) (domain.Rate, error) {
+	req := struct {
+		OrganizationID string `json:"organization_id"`
+		CountryID      string `json:"country_id"`
+		Page           int32  `json:"page"`
+		Size           int32  `json:"size"`
+	}{
+		OrganizationID: org.Id,
+		CountryID:      org.CountryId,
+		Page:           1,
+		Size:           maxTaxRules,
+	}
+
+	var resp struct {
+		Data []struct {
+			Rate domain.Rate `json:"rate"`
+		} `json:"data"`
+	}
+	if err := rpc.Invoke(ctx, svc.organizationConn, organizationPricingServiceName, "ListTaxRule", &req, &resp); err != nil {
+		return 0, err
+	}
+
+	var taxRate domain.Rate
+	for _, rule := range resp.Data {
+		taxRate += rule.Rate
+	}
+	return taxRate, nil
+}
+
+// shippingRate is a shipping rate of an organization, priced in the base
+// currency of the organization.
+type shippingRate struct {
+	ID             string       `json:"shipping_rate_id"`
+	OrganizationID string       `json:"organization_id"`
+	Name           string       `json:"name"`
+	Currency       string       `json:"currency"`
+	Price          domain.Money `json:"price"`
+}
+
+// shippingRate returns a shipping rate of the organization, to be charged
+// on an order in baseCurrency.
+func (svc *PricingService) shippingRate(ctx context.Context, organizationID, shippingRateID, baseCurrency string) (*shippingRate, error) {
+	req := struct {
+		ShippingRateID string `json:"shipping_rate_id"`
+	}{
+		ShippingRateID: shippingRateID,
+	}
+
+	var rate shippingRate
+	if err := rpc.Invoke(ctx, svc.organizationConn, organizationPricingServiceName, "GetShippingRate", &req, &rate); err != nil {
+		return nil, err
+	}
+
+	if rate.OrganizationID != organizationID {
+		return nil, status.Error(codes.InvalidArgument, "shipping rate not found")
+	}
+
+	// Rates keep the base currency they were created in.
+	if rate.Currency != baseCurrency {
+		return nil, status.Errorf(codes.FailedPrecondition, "shipping rate is priced in %s, not in the base currency %s", rate.Currency, baseCurrency)
+	}
+
+	return &rate, nil
+}
+
+// shippingAmount is the price of the shipping rate chosen for order, in the
+// order currency. Orders without one aren't charged for shipping.
+func (svc *PricingService) shippingAmount(ctx context.Context, org *organization.Organization, order *domain.Order) (domain.Money, error) {
+	if order.ShippingRateID == nil {
+		return 0, nil
+	}
+
+	rate, err := svc.shippingRate(ctx, org.Id, *order.ShippingRateID, order.BaseCurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	return order.FromBase(rate.Price), nil
+}
+
+// setCurrency checks the currency order was requested in, or picks the base
+// currency of org, and records the base currency and the exchange rate the
+// order is sold at.
+func (svc *PricingService) setCurrency(ctx context.Context, org *organization.Organization, order *domain.Order) error {
+	currencies, err := ListCurrency(ctx, svc.organizationConn, org.Id)
+	if err != nil {
+		return err
+	}
+
+	order.BaseCurrency = domain.DefaultCurrency
+	if org.Country != nil && org.Country.CurrencyCode != "" {
+		order.BaseCurrency = org.Country.CurrencyCode
+	}
+	if base := currencies.Base(); base != nil {
+		order.BaseCurrency = base.Currency
+	}
+
+	if order.Currency == "" || order.Currency == order.BaseCurrency {
+		order.Currency = order.BaseCurrency
+		order.ExchangeRate = domain.BaseRate
+		return nil
+	}
+
+	for _, c := range currencies {
+		if !c.Base && c.Currency == order.Currency && c.ExchangeRate > 0 {
+			order.ExchangeRate = c.ExchangeRate
+			return nil
+		}
+	}
+
+	return status.Errorf(codes.InvalidArgument, "organization doesn't sell in %s", order.Currency)
+}
+
+// variantPrice is the price of a variant from the item service: its base
+// price, and its price in the order currency when one was set.
+type variantPrice struct {
+	VariantID    string        `json:"variant_id"`
+	Taxable      bool          `json:"taxable"`
+	BaseCurrency string        `json:"base_currency"`
+	BasePrice    domain.Money  `json:"base_price"`
+	Price        *domain.Money `json:"price"`
+}
+
+// variantPrice looks up the price of a variant for order.
+func (svc *PricingService) variantPrice(ctx context.Context, order *domain.Order, variantID string) (*variantPrice, error) {
+	req := struct {
+		VariantID string `json:"variant_id"`
+		Currency  string `json:"currency"`
+	}{
+		VariantID: variantID,
+		Currency:  order.Currency,
+	}
+
+	var variant variantPrice
+	if err := rpc.Invoke(ctx, svc.itemConn, variantPriceServiceName, "GetVariantPrice", &req, &variant); err != nil {
+		return nil, err
+	}
+
+	// Variants keep the base currency they were created in.
+	if variant.BaseCurrency != order.BaseCurrency {
+		return nil, status.Errorf(codes.FailedPrecondition, "variant %s is priced in %s, not in the base currency %s", variantID, variant.BaseCurrency, order.BaseCurrency)
+	}
+
+	return &variant, nil
+}
+
+// unitPrice is the price of the variant in the order currency: its price in
+// that currency when the item service has one, its base price converted at
+// the order's exchange rate otherwise.
+func (m *variantPrice) unitPrice(order *domain.Order) domain.Money {
+	if m.Price != nil {
+		return *m.Price
+	}
+	return order.FromBase(m.BasePrice)
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/smallbiznis/common/rpc"
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrganizationConn is the connection to the organization service, for its
+// rpc.Services.
+type OrganizationConn grpc.ClientConnInterface
+
+// timezoneServiceName is the organization service serving the timezones of
+// organizations and their locations.
+const timezoneServiceName = "smallbiznis.organization.v1.TimezoneService"
+
+// PromotionService manages the promotions of organizations and works out
+// the discounts they give on an order.
+type PromotionService struct {
+	db               *gorm.DB
+	organizationConn OrganizationConn
+}
+
+func NewPromotionService(db *gorm.DB, organizationConn OrganizationConn) *PromotionService {
+	return &PromotionService{
+		db:               db,
+		organizationConn: organizationConn,
+	}
+}
+
+// CreatePromotion creates an active promotion.
+func (svc *PromotionService) CreatePromotion(ctx context.Context, req domain.Promotion) (*domain.Promotion, error) {
+	ctx, span := tracer.Start(ctx, "CreatePromotion")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if req.CustomerGroupID != nil {
+		if _, err := svc.customerGroup(ctx, req.OrganizationID, *req.CustomerGroupID); err != nil {
+			return nil, err
+		}
+	}
+
+	promotion := req
+	promotion.ID = ""
+	promotion.Active = true
+	for i := range promotion.Variants {
+		promotion.Variants[i].PromotionID = ""
+	}
+
+	if err := svc.db.WithContext(ctx).Create(&promotion).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &promotion, nil
+}
+
+// ListPromotion lists the promotions of an organization, highest priority
+// first. Deactivated promotions are left out unless all is set.
+func (svc *PromotionService) ListPromotion(ctx context.Context, p pagination.Pagination, organizationID string, all bool) (domain.Promotions, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListPromotion")
+	defer span.End()
+
+	stmt := svc.db.WithContext(ctx).Model(&domain.Promotion{}).
+		Preload("Variants").
+		Where(&domain.Promotion{OrganizationID: organizationID})
+
+	if !all {
+		stmt = stmt.Where("active")
+	}
+
+	var (
+		promotions domain.Promotions
+		count      int64
+	)
+	if err := stmt.Count(&count).
+		Scopes(p.Paginate()).
+		Order("priority DESC, created_at ASC").
+		Find(&promotions).Error; err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return promotions, count, nil
+}
+
+// DeactivatePromotion stops a promotion of an organization from applying to
+// new orders. Discounts already given stay on their orders.
+func (svc *PromotionService) DeactivatePromotion(ctx context.Context, organizationID, promotionID string) (*domain.Promotion, error) {
+	ctx, span := tracer.Start(ctx, "DeactivatePromotion")
+	defer span.End()
+
+	var promotion *domain.Promotion
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.Promotion{ID: promotionID, OrganizationID: organizationID}).
+		First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "promotion not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := svc.db.WithContext(ctx).Model(promotion).Update("active", false).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return promotion, nil
+}
+
+// CreateCustomerGroup creates a group of customers promotions can target.
+func (svc *PromotionService) CreateCustomerGroup(ctx context.Context, req domain.CustomerGroup) (*domain.CustomerGroup, error) {
+	ctx, span := tracer.Start(ctx, "CreateCustomerGroup")
+	defer span.End()
+
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	group := domain.CustomerGroup{
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+	}
+	if err := svc.db.WithContext(ctx).Create(&group).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &group, nil
+}
+
+// AddGroupMembers adds customers to a customer group.
+func (svc *PromotionService) AddGroupMembers(ctx context.Context, organizationID, groupID string, customerIDs ...string) error {
+	ctx, span := tracer.Start(ctx, "AddGroupMembers")
+	defer span.End()
+
+	if _, err := svc.customerGroup(ctx, organizationID, groupID); err != nil {
+		return err
+	}
+
+	if len(customerIDs) == 0 {
+		return nil
+	}
+
+	members := make([]domain.CustomerGroupMember, 0, len(customerIDs))
+	for _, id := range customerIDs {
+		members = append(members, domain.CustomerGroupMember{
+			CustomerGroupID: groupID,
+			CustomerID:      id,
+		})
+	}
+
+	if err := svc.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&members).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// RemoveGroupMember removes a customer from a customer group.
+func (svc *PromotionService) RemoveGroupMember(ctx context.Context, organizationID, groupID, customerID string) error {
+	ctx, span := tracer.Start(ctx, "RemoveGroupMember")
+	defer span.End()
+
+	if _, err := svc.customerGroup(ctx, organizationID, groupID); err != nil {
+		return err
+	}
+
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.CustomerGroupMember{CustomerGroupID: groupID, CustomerID: customerID}).
+		Delete(&domain.CustomerGroupMember{}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// Apply works out the discounts the promotions of the order's organization
+// give on an order placed at t. TotalPrice of the order items must hold
+// their undiscounted line totals.
+//
+// Coupon-only promotions apply when the order carries one of their codes in
+// CouponCodes. Unknown, expired or used up codes, and codes that give no
+// discount, are rejected with FailedPrecondition; usage limits are enforced
+// again when the coupon is redeemed.
+//
+// Promotions apply by descending priority. Line promotions discount each
+// matching line, never below zero; order promotions then discount what is
+// left of the subtotal. Discounts are rounded half away from zero to the
+// minor unit of the order currency.
+func (svc *PromotionService) Apply(ctx context.Context, order *domain.Order, t time.Time) (discounts domain.OrderDiscounts, err error) {
+	ctx, span := tracer.Start(ctx, "ApplyPromotions")
+	defer span.End()
+
+	var promotions domain.Promotions
+	if err = svc.db.WithContext(ctx).
+		Preload("Variants").
+		Where("organization_id = ? AND active", order.OrganizationID).
+		Order("priority DESC, created_at ASC").
+		Find(&promotions).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// Promotion amounts are in the base currency.
+	for i := range promotions {
+		promotions[i].Amount = order.FromBase(promotions[i].Amount)
+		promotions[i].MinSubtotal = order.FromBase(promotions[i].MinSubtotal)
+	}
+
+	coupons, err := usableCoupons(svc.db.WithContext(ctx), order, order.CouponCodes, t)
+	if err != nil {
+		return
+	}
+
+	if len(promotions) == 0 {
+		if len(coupons) > 0 {
+			return nil, status.Error(codes.FailedPrecondition, domain.ErrCouponNotApplicable.Error())
+		}
+		return
+	}
+
+	loc, err := svc.timezone(ctx, order, promotions)
+	if err != nil {
+		return
+	}
+
+	groups := make(map[string]bool)
+	if order.CustomerID != nil {
+		var members []domain.CustomerGroupMember
+		if err = svc.db.WithContext(ctx).
+			Where(&domain.CustomerGroupMember{CustomerID: *order.CustomerID}).
+			Find(&members).Error; err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		for _, m := range members {
+			groups[m.CustomerGroupID] = true
+		}
+	}
+
+	var subTotal domain.Money
+	remaining := make([]domain.Money, len(order.OrderItems))
+	for i, orderItem := range order.OrderItems {
+		remaining[i] = orderItem.TotalPrice
+		subTotal += remaining[i]
+	}
+
+	for _, promotion := range promotions {
+		var couponID *string
+		if promotion.CouponOnly {
+			for i := range coupons {
+				if coupons[i].PromotionID == promotion.ID {
+					couponID = &coupons[i].ID
+					break
+				}
+			}
+
+			if couponID == nil {
+				continue
+			}
+		}
+
+		if !promotion.ActiveAt(t, loc) ||
+			subTotal < promotion.MinSubtotal ||
+			(promotion.CustomerGroupID != nil && !groups[*promotion.CustomerGroupID]) {
+			continue
+		}
+
+		if promotion.Scope == domain.OrderScope {
+			var left domain.Money
+			for _, v := range remaining {
+				left += v
+			}
+
+			amount := orderDiscount(promotion, left)
+			if amount <= 0 {
+				continue
+			}
+
+			// Spread the order discount over the lines so it is taxed
+			// consistently and later promotions see what is left.
+			spread := spreadAmount(amount, remaining)
+			for i := range remaining {
+				remaining[i] -= spread[i]
+			}
+
+			discounts = append(discounts, domain.OrderDiscount{
+				OrderID:     order.ID,
+				PromotionID: promotion.ID,
+				CouponID:    couponID,
+				Name:        promotion.Name,
+				Amount:      amount,
+			})
+			continue
+		}
+
+		for i, orderItem := range order.OrderItems {
+			if !promotion.AppliesTo(orderItem.VariantID) {
+				continue
+			}
+
+			amount := min(lineDiscount(promotion, orderItem, remaining[i]), remaining[i])
+			if amount <= 0 {
+				continue
+			}
+			remaining[i] -= amount
+
+			orderItemID := orderItem.ID
+			discounts = append(discounts, domain.OrderDiscount{
+				OrderID:     order.ID,
+				OrderItemID: &orderItemID,
+				PromotionID: promotion.ID,
+				CouponID:    couponID,
+				Name:        promotion.Name,
+				Amount:      amount,
+			})
+		}
+	}
+
+	for _, coupon := range coupons {
+		if !slices.ContainsFunc(discounts, func(d domain.OrderDiscount) bool {
+			return d.CouponID != nil && *d.CouponID == coupon.ID
+		}) {
+			return nil, status.Errorf(codes.FailedPrecondition, "%s: %s", coupon.Code, domain.ErrCouponNotApplicable)
+		}
+	}
+
+	for i := range order.OrderItems {
+		orderItem := &order.OrderItems[i]
+		orderItem.DiscountAmount = orderItem.TotalPrice - remaining[i]
+	}
+
+	return
+}
+
+func (svc *PromotionService) customerGroup(ctx context.Context, organizationID, groupID string) (group *domain.CustomerGroup, err error) {
+	if err = svc.db.WithContext(ctx).
+		Where(&domain.CustomerGroup{ID: groupID, OrganizationID: organizationID}).
+		First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "customer group not found")
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return
+}
+
+// timezone returns the timezone of the order's location, or of its
+// organization, which the daily windows of promotions are in. It is only
+// looked up when one of the promotions has a daily window.
+func (svc *PromotionService) timezone(ctx context.Context, order *domain.Order, promotions domain.Promotions) (*time.Location, error) {
+	if !slices.ContainsFunc(promotions, func(p domain.Promotion) bool { return p.DailyStart != "" }) {
+		return time.UTC, nil
+	}
+
+	req := struct {
+		OrganizationID string `json:"organization_id"`
+		LocationID     string `json:"location_id"`
+	}{
+		OrganizationID: order.OrganizationID,
+	}
+	if order.LocationID != nil {
+		req.LocationID = *order.LocationID
+	}
+
+	var resp struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := rpc.Invoke(ctx, svc.organizationConn, timezoneServiceName, "GetTimezone", &req, &resp); err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(resp.Timezone)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return loc, nil
+}
+
+// lineDiscount is what a line promotion takes off an order item whose
+// remaining total is left.
+func lineDiscount(promotion domain.Promotion, orderItem domain.OrderItem, left domain.Money) domain.Money {
+	switch promotion.Type {
+	case domain.PercentageDiscount:
+		return left.Percent(promotion.Rate)
+	case domain.FixedDiscount:
+		return promotion.Amount.Mul(orderItem.Quantity)
+	case domain.BuyXGetY:
+		free := orderItem.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+		return orderItem.UnitPrice.Mul(free).Percent(promotion.Rate)
+	}
+	return 0
+}
+
+// orderDiscount is what an order promotion takes off a subtotal of left.
+func orderDiscount(promotion domain.Promotion, left domain.Money) domain.Money {
+	switch promotion.Type {
+	case domain.PercentageDiscount:
+		return left.Percent(promotion.Rate)
+	case domain.FixedDiscount:
+		return min(promotion.Amount, left)
+	}
+	return 0
+}
+
+// spreadAmount splits amount over weights in proportion, rounded half away
+// from zero, with the rounding remainder on the last non-zero weight.
+func spreadAmount(amount domain.Money, weights []domain.Money) []domain.Money {
+	var total domain.Money
+	last := -1
+	for i, w := range weights {
+		total += w
+		if w > 0 {
+			last = i
+		}
+	}
+
+	parts := make([]domain.Money, len(weights))
+	if total <= 0 {
+		return parts
+	}
+
+	var spread domain.Money
+	for i, w := range weights {
+		if i == last {
+			parts[i] = amount - spread
+			break
+		}
+		parts[i] = amount.MulDiv(int64(w), int64(total))
+		spread += parts[i]
+	}
+
+	return parts
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/smallbiznis/transaction/domain"
+)
+
+func TestSpreadAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  domain.Money
+		weights []domain.Money
+		want    []domain.Money
+	}{
+		{"even", 1000, []domain.Money{500, 500}, []domain.Money{500, 500}},
+		{"proportional", 1000, []domain.Money{3000, 1000}, []domain.Money{750, 250}},
+		{"remainder on the last weight", 100, []domain.Money{1, 1, 1}, []domain.Money{33, 33, 34}},
+		{"rounds half away from zero", 5, []domain.Money{1, 1}, []domain.Money{3, 2}},
+		{"remainder skips trailing zero weights", 100, []domain.Money{1, 1, 1, 0}, []domain.Money{33, 33, 34, 0}},
+		{"zero weight gets nothing", 900, []domain.Money{0, 300, 600}, []domain.Money{0, 300, 600}},
+		{"single weight", 777, []domain.Money{12345}, []domain.Money{777}},
+		{"no weight", 1000, []domain.Money{0, 0}, []domain.Money{0, 0}},
+		{"no weights", 1000, nil, []domain.Money{}},
+		{"zero amount", 0, []domain.Money{1, 2}, []domain.Money{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spreadAmount(tt.amount, tt.weights)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spreadAmount(%d, %v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+			}
+
+			var sum domain.Money
+			for _, part := range got {
+				sum += part
+			}
+
+			weighted := slices.ContainsFunc(tt.weights, func(w domain.Money) bool { return w > 0 })
+			if weighted && sum != tt.amount {
+				t.Errorf("spreadAmount(%d, %v) sums to %d", tt.amount, tt.weights, sum)
+			}
+		})
+	}
+}
+
+func TestLineDiscount(t *testing.T) {
+	buy2Get1 := domain.Promotion{
+		Type:        domain.BuyXGetY,
+		Scope:       domain.LineScope,
+		Rate:        domain.HundredPercent,
+		BuyQuantity: 2,
+		GetQuantity: 1,
+	}
+
+	buy1Get1Half := buy2Get1
+	buy1Get1Half.BuyQuantity = 1
+	buy1Get1Half.Rate = domain.NewRate(50)
+
+	tests := []struct {
+		name      string
+		promotion domain.Promotion
+		quantity  int32
+		unitPrice domain.Money
+		left      domain.Money
+		want      domain.Money
+	}{
+		{"buy 2 get 1 below the threshold", buy2Get1, 2, 1000, 2000, 0},
+		{"buy 2 get 1 exactly", buy2Get1, 3, 1000, 3000, 1000},
+		{"buy 2 get 1 with leftover units", buy2Get1, 5, 1000, 5000, 1000},
+		{"buy 2 get 1 twice", buy2Get1, 6, 1000, 6000, 2000},
+		{"buy 1 get 1 half off", buy1Get1Half, 4, 999, 3996, 999},
+		{"buy 1 get 1 half off rounds half away from zero", buy1Get1Half, 2, 999, 1998, 500},
+		{"percentage off what is left", domain.Promotion{Type: domain.PercentageDiscount, Rate: domain.NewRate(10)}, 3, 1000, 2500, 250},
+		{"fixed per unit", domain.Promotion{Type: domain.FixedDiscount, Amount: 150}, 3, 1000, 3000, 450},
+		{"unknown type", domain.Promotion{}, 3, 1000, 3000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderItem := domain.OrderItem{
+				Quantity:  tt.quantity,
+				UnitPrice: tt.unitPrice,
+			}
+
+			if got := lineDiscount(tt.promotion, orderItem, tt.left); got != tt.want {
+				t.Errorf("lineDiscount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
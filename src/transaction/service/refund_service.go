@@ -0,0 +1,517 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundService gives money back for returned order items and puts the
+// items back into stock.
+type RefundService struct {
+	db             *gorm.DB
+	orderService   *OrderService
+	paymentService *PaymentService
+	stockService   *StockService
+}
+
+func NewRefundService(
+	db *gorm.DB,
+	orderService *OrderService,
+	paymentService *PaymentService,
+	stockService *StockService,
+) *RefundService {
+	return &RefundService{
+		db:             db,
+		orderService:   orderService,
+		paymentService: paymentService,
+		stockService:   stockService,
+	}
+}
+
+// ListRefund lists the refunds of an order with their lines, oldest first.
+func (svc *RefundService) ListRefund(ctx context.Context, orderID string) (domain.OrderRefunds, error) {
+	ctx, span := tracer.Start(ctx, "ListRefund")
+	defer span.End()
+
+	var refunds domain.OrderRefunds
+	if err := svc.db.WithContext(ctx).
+		Preload("Lines").
+		Where(&domain.OrderRefund{OrderID: orderID}).
+		Order("created_at ASC").
+		Find(&refunds).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return refunds, nil
+}
+
+// Refund returns the selected quantities of an order's items, or req.Amount
+// of a cancelled order's captured payments when no line is given.
+//
+// Line amounts are the returned share of the discounted line total, so
+// promotions aren't refunded on top of the price paid, and of the tax
+// charged on the line, so untaxed lines refund no tax. Shares are rounded
+// half away from zero to the minor unit of the order currency; the refund
+// returning the last units of an item takes whatever is left of it, so the
+// refunds always add up to the order.
+//
+// The refund is booked against req.OrderPaymentID, or the first captured
+// payment that can cover it. Only the original_payment destination goes
+// through the payment provider; cash is paid out of the till and store
+// credit is credited to the wallet of the order's customer.
+//
+// Like Capture, the payment provider is called outside any transaction:
+// the refund is first saved as pending with its amount and returned items
+// booked, so they can't be refunded twice, then settled with the result of
+// the provider. A failed refund gives them back. A refund left pending,
+// by a crash between the two, keeps them booked until it is reconciled
+// with the provider.
+//
+// Returned items still reserved for the order are released. Items already
+// sold go back into stock at req.RestockLocationID when it is set.
+func (svc *RefundService) Refund(ctx context.Context, req domain.OrderRefund, actor string) (*domain.OrderRefund, error) {
+	ctx, span := tracer.Start(ctx, "RefundOrder")
+	defer span.End()
+
+	if req.Destination.String() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid refund destination")
+	}
+
+	if len(req.Lines) == 0 && req.Amount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "lines or amount is required")
+	}
+
+	refund := domain.OrderRefund{
+		ID:                uuid.NewString(),
+		OrderID:           req.OrderID,
+		Destination:       req.Destination,
+		RestockLocationID: req.RestockLocationID,
+		Reason:            req.Reason,
+		Actor:             actor,
+		Status:            domain.RefundSucceeded,
+	}
+
+	var (
+		payment  *domain.OrderPayment
+		provider domain.PaymentProvider
+		// release holds the returned quantities still reserved for the order,
+		// restock the sold quantities to put back into stock.
+		release domain.OrderItems
+		restock []domain.OrderFulfilledLineItem
+	)
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		order, err := svc.orderService.lockOrder(tx, req.OrderID)
+		if err != nil {
+			return
+		}
+
+		refund.OrganizationID = order.OrganizationID
+
+		var items domain.OrderItems
+		if len(req.Lines) > 0 {
+			switch order.Status {
+			case domain.OrderPaid, domain.OrderFulfilling, domain.OrderFulfilled, domain.OrderPartiallyRefunded:
+			default:
+				return status.Errorf(codes.FailedPrecondition, "order in status %s can't be refunded", order.Status)
+			}
+
+			if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where(&domain.OrderItem{OrderID: order.ID}).
+				Find(&items).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if err = svc.priceLines(tx, order, items, &refund, req.Lines); err != nil {
+				return
+			}
+		} else {
+			if order.Status != domain.OrderCancelled {
+				return status.Error(codes.InvalidArgument, "lines are required unless the order is cancelled")
+			}
+			refund.Amount = req.Amount
+		}
+
+		if payment, err = svc.refundablePayment(tx, order, req.OrderPaymentID, refund.Amount); err != nil {
+			return
+		}
+
+		refund.OrderPaymentID = payment.ID
+
+		if refund.Destination == domain.RefundToOriginalPayment {
+			var ok bool
+			if provider, ok = svc.paymentService.providers[payment.PaymentProviderID]; !ok {
+				return status.Error(codes.FailedPrecondition, domain.ErrUnknownPaymentProvider.Error())
+			}
+			refund.Status = domain.RefundPending
+		}
+
+		if refund.Destination == domain.RefundToStoreCredit {
+			if order.CustomerID == nil {
+				return status.Error(codes.FailedPrecondition, "store credit needs an order with a customer")
+			}
+
+			wallet, err := lockWallet(tx, order.OrganizationID, *order.CustomerID)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			orderID, refundID := order.ID, refund.ID
+			if err = postWallet(tx, wallet, domain.StoredValueEntry{
+				Type:          domain.StoredValueRefund,
+				Amount:        order.ToBase(refund.Amount),
+				OrderID:       &orderID,
+				OrderRefundID: &refundID,
+				Actor:         actor,
+				Note:          refund.Reason,
+			}); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			refund.ProviderReference = wallet.ID
+		}
+
+		if err = tx.Model(payment).
+			Update("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Amount)).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = tx.Create(&refund).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		var returned domain.OrderItems
+		returned, restock = returnedItems(items, refund.Lines)
+		for _, orderItem := range returned {
+			if err = tx.Model(&domain.OrderItem{ID: orderItem.ID}).
+				Update("returned_quantity", orderItem.ReturnedQuantity).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		release = returned
+
+		if refund.Status == domain.RefundPending {
+			return
+		}
+
+		return svc.complete(tx, order, &refund, restock)
+	}); err != nil {
+		return nil, err
+	}
+
+	if refund.Status == domain.RefundPending {
+		result, refundErr := provider.Refund(ctx, *payment, refund.Amount)
+
+		if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+			order, err := svc.orderService.lockOrder(tx, refund.OrderID)
+			if err != nil {
+				return
+			}
+
+			var pending *domain.OrderRefund
+			if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where(&domain.OrderRefund{ID: refund.ID}).
+				First(&pending).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			if pending.Status != domain.RefundPending {
+				return status.Errorf(codes.Aborted, "refund moved to status %s while being refunded", pending.Status)
+			}
+
+			if refundErr != nil {
+				refund.Status = domain.RefundFailed
+				return svc.cancel(tx, &refund)
+			}
+
+			refund.Status = domain.RefundSucceeded
+			refund.ProviderReference = result.Reference
+			if err = tx.Model(&domain.OrderRefund{ID: refund.ID}).Updates(domain.OrderRefund{
+				Status:            refund.Status,
+				ProviderReference: refund.ProviderReference,
+			}).Error; err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			return svc.complete(tx, order, &refund, restock)
+		}); err != nil {
+			return nil, err
+		}
+
+		if refundErr != nil {
+			return nil, paymentError(refundErr)
+		}
+	}
+
+	svc.releaseReturned(ctx, release)
+
+	return &refund, nil
+}
+
+// complete moves the order to refunded once every item is returned, or to
+// partially refunded, and publishes the restock of the sold items returned
+// by a refund whose money went back.
+func (svc *RefundService) complete(tx *gorm.DB, order *domain.Order, refund *domain.OrderRefund, restock []domain.OrderFulfilledLineItem) (err error) {
+	if len(refund.Lines) == 0 {
+		return
+	}
+
+	var items domain.OrderItems
+	if err = tx.Where(&domain.OrderItem{OrderID: order.ID}).Find(&items).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	next := domain.OrderRefunded
+	for _, orderItem := range items {
+		if orderItem.ReturnedQuantity < orderItem.Quantity {
+			next = domain.OrderPartiallyRefunded
+			break
+		}
+	}
+
+	if order.Status != next {
+		if err = svc.orderService.transition(tx, order.ID, next, refund.Actor, "order refunded"); err != nil {
+			return
+		}
+	}
+
+	if refund.RestockLocationID != nil && len(restock) > 0 {
+		if err = publishReturned(tx, order, refund, restock); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return
+}
+
+// cancel records a refund the payment provider refused and gives back the
+// amount and items it booked.
+func (svc *RefundService) cancel(tx *gorm.DB, refund *domain.OrderRefund) (err error) {
+	if err = tx.Model(&domain.OrderRefund{ID: refund.ID}).
+		Update("status", refund.Status).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err = tx.Model(&domain.OrderPayment{ID: refund.OrderPaymentID}).
+		Update("refunded_amount", gorm.Expr("refunded_amount - ?", refund.Amount)).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for _, line := range refund.Lines {
+		if err = tx.Model(&domain.OrderItem{ID: line.OrderItemID}).
+			Update("returned_quantity", gorm.Expr("returned_quantity - ?", line.Quantity)).Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return
+}
+
+// priceLines validates the requested lines against the order items and
+// fills the line and refund amounts.
+func (svc *RefundService) priceLines(tx *gorm.DB, order *domain.Order, items domain.OrderItems, refund *domain.OrderRefund, lines domain.OrderRefundLines) (err error) {
+	byID := make(map[string]*domain.OrderItem, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+
+	requested := make(map[string]int32, len(lines))
+	for _, line := range lines {
+		orderItem, ok := byID[line.OrderItemID]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "order item %s not found", line.OrderItemID)
+		}
+
+		if line.Quantity <= 0 {
+			return status.Error(codes.InvalidArgument, "quantity must be greater than zero")
+		}
+
+		requested[line.OrderItemID] += line.Quantity
+		if requested[line.OrderItemID] > orderItem.Quantity-orderItem.ReturnedQuantity {
+			return status.Errorf(codes.FailedPrecondition, "order item %s has only %d left to return", orderItem.ID, orderItem.Quantity-orderItem.ReturnedQuantity)
+		}
+	}
+
+	refunded, err := refundedLines(tx, order.ID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	var subTotal, taxAmount domain.Money
+	for _, orderItem := range items {
+		quantity, ok := requested[orderItem.ID]
+		if !ok {
+			continue
+		}
+
+		line := domain.OrderRefundLine{
+			OrderItemID: orderItem.ID,
+			Quantity:    quantity,
+		}
+
+		// The refund returning the last units of an item takes what is left
+		// of it, so the refunds of an item add up to what it was charged.
+		if orderItem.ReturnedQuantity+quantity == orderItem.Quantity {
+			line.SubTotal = orderItem.TotalPrice - refunded[orderItem.ID].SubTotal
+			line.TaxAmount = orderItem.TaxAmount - refunded[orderItem.ID].TaxAmount
+		} else {
+			line.SubTotal = orderItem.TotalPrice.MulDiv(int64(quantity), int64(orderItem.Quantity))
+			line.TaxAmount = orderItem.TaxAmount.MulDiv(int64(quantity), int64(orderItem.Quantity))
+		}
+
+		refund.Lines = append(refund.Lines, line)
+
+		subTotal += line.SubTotal
+		taxAmount += line.TaxAmount
+	}
+
+	refund.SubTotal = subTotal
+	refund.TaxAmount = taxAmount
+	refund.Amount = subTotal + taxAmount
+
+	return
+}
+
+// refundedLine is what the refunds of an order gave back for an item.
+type refundedLine struct {
+	OrderItemID string
+	SubTotal    domain.Money
+	TaxAmount   domain.Money
+}
+
+// refundedLines sums the refund lines of an order by item, leaving out
+// failed refunds.
+func refundedLines(tx *gorm.DB, orderID string) (map[string]refundedLine, error) {
+	var lines []refundedLine
+	if err := tx.Model(&domain.OrderRefundLine{}).
+		Select(`order_refund_lines.order_item_id,
+			COALESCE(SUM(order_refund_lines.sub_total), 0) AS sub_total,
+			COALESCE(SUM(order_refund_lines.tax_amount), 0) AS tax_amount`).
+		Joins("JOIN order_refunds ON order_refunds.order_refund_id = order_refund_lines.order_refund_id").
+		Where("order_refunds.order_id = ? AND order_refunds.status <> ? AND order_refunds.deleted_at IS NULL", orderID, domain.RefundFailed).
+		Group("order_refund_lines.order_item_id").
+		Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	byItem := make(map[string]refundedLine, len(lines))
+	for _, line := range lines {
+		byItem[line.OrderItemID] = line
+	}
+	return byItem, nil
+}
+
+// refundablePayment locks the captured payment a refund of amount is booked
+// against.
+func (svc *RefundService) refundablePayment(tx *gorm.DB, order *domain.Order, paymentID string, amount domain.Money) (*domain.OrderPayment, error) {
+	if paymentID != "" {
+		payment, err := svc.paymentService.lockPayment(tx, paymentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if payment.OrderID != order.ID {
+			return nil, status.Error(codes.InvalidArgument, "payment doesn't belong to order")
+		}
+
+		if payment.Refundable() < amount {
+			return nil, status.Errorf(codes.FailedPrecondition, "payment has only %s left to refund", payment.Refundable().Format(order.Currency))
+		}
+
+		return payment, nil
+	}
+
+	var payments domain.OrderPayments
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.OrderPayment{OrderID: order.ID, Status: domain.PaymentCaptured}).
+		Order("created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	for i := range payments {
+		if payments[i].Refundable() >= amount {
+			return &payments[i], nil
+		}
+	}
+
+	return nil, status.Errorf(codes.FailedPrecondition, "no captured payment covers a refund of %s", amount.Format(order.Currency))
+}
+
+// releaseReturned releases the reservations of returned items that were
+// never sold. Failures are logged and the lines stay reserved until the
+// order is cancelled or fulfilled.
+func (svc *RefundService) releaseReturned(ctx context.Context, items domain.OrderItems) {
+	pending := make(domain.OrderItems, len(items))
+	copy(pending, items)
+
+	releaseErr := svc.stockService.Release(ctx, pending)
+
+	for i, orderItem := range pending {
+		released := items[i].ReservedQuantity - orderItem.ReservedQuantity
+		if released <= 0 {
+			continue
+		}
+
+		if err := svc.db.WithContext(ctx).Model(&domain.OrderItem{ID: orderItem.ID}).
+			Update("reserved_quantity", gorm.Expr("reserved_quantity - ?", released)).Error; err != nil {
+			zap.L().Error("failed record released stock", zap.String("order_item_id", orderItem.ID), zap.Error(err))
+		}
+	}
+
+	if releaseErr != nil {
+		zap.L().Error("failed release returned stock", zap.Error(releaseErr))
+	}
+}
+
+// returnedItems applies the refund lines to the order items in place. It
+// returns the changed items, holding in ReservedQuantity the returned
+// quantity still reserved, and the sold quantities to put back into stock.
+func returnedItems(items domain.OrderItems, lines domain.OrderRefundLines) (returned domain.OrderItems, restock []domain.OrderFulfilledLineItem) {
+	for _, line := range lines {
+		for i := range items {
+			orderItem := &items[i]
+			if orderItem.ID != line.OrderItemID {
+				continue
+			}
+
+			orderItem.ReturnedQuantity += line.Quantity
+
+			reserved := min(line.Quantity, orderItem.ReservedQuantity)
+			returned = append(returned, domain.OrderItem{
+				ID:               orderItem.ID,
+				InventoryItemID:  orderItem.InventoryItemID,
+				ReservedQuantity: reserved,
+				ReturnedQuantity: orderItem.ReturnedQuantity,
+			})
+
+			if sold := line.Quantity - reserved; sold > 0 {
+				restock = append(restock, domain.OrderFulfilledLineItem{
+					VariantID: orderItem.VariantID,
+					Quantity:  sold,
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// publishReturned asks the inventory service to restock the returned items
+// through the outbox, so the restock is retried until inventory
+// acknowledges it. The event is only published if tx commits.
+func publishReturned(tx *gorm.DB, order *domain.Order, refund *domain.OrderRefund, items []domain.OrderFulfilledLineItem) error {
+	return publish(tx, domain.OrderReturnedChannel, domain.OrderReturnedEvent{
+		OrderRefundID:  refund.ID,
+		OrderID:        order.ID,
+		OrganizationID: order.OrganizationID,
+		LocationID:     *refund.RestockLocationID,
+		Items:          items,
+	})
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallbiznis/common/idempotency"
+	"github.com/smallbiznis/go-genproto/smallbiznis/inventory/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/item/v1"
+	"github.com/smallbiznis/go-genproto/smallbiznis/organization/v1"
+	"github.com/smallbiznis/transaction/domain"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// maxLocations is the page size used when looking up an organization's locations.
+const maxLocations = 100
+
+// reservationGracePeriod is how old a stock reservation of an unsaved order
+// or edit must be before ReleaseOrphans takes it for lost, and a release
+// before ReleaseOrphans retries it.
+const reservationGracePeriod = 5 * time.Minute
+
+// StockService holds and returns inventory for order items. Every line whose
+// variant tracks inventory remembers the inventory item and the quantity it
+// reserved, so releasing an order never returns more than was taken.
+type StockService struct {
+	db               *gorm.DB
+	organizationConn organization.ServiceClient
+	itemConn         item.ServiceClient
+	inventoryConn    inventory.ServiceClient
+}
+
+func NewStockService(
+	db *gorm.DB,
+	organizationConn organization.ServiceClient,
+	itemConn item.ServiceClient,
+	inventoryConn inventory.ServiceClient,
+) *StockService {
+	return &StockService{
+		db:               db,
+		organizationConn: organizationConn,
+		itemConn:         itemConn,
+		inventoryConn:    inventoryConn,
+	}
+}
+
+// DefaultLocation returns the default location of an organization, or its
+// first location when none is flagged as default.
+func (svc *StockService) DefaultLocation(ctx context.Context, organizationID string) (string, error) {
+	locations, err := svc.organizationConn.ListLocation(ctx, &organization.ListLocationRequest{
+		OrganizationId: organizationID,
+		Page:           1,
+		Size:           maxLocations,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(locations.Data) == 0 {
+		return "", status.Error(codes.FailedPrecondition, "organization has no location")
+	}
+
+	for _, loc := range locations.Data {
+		if loc.IsDefault {
+			return loc.LocationId, nil
+		}
+	}
+
+	return locations.Data[0].LocationId, nil
+}
+
+// Reserve reserves stock at the order location for every order item whose
+// variant tracks inventory, for an order about to be saved. Each reservation
+// is recorded as a StockReservation first; saving the order must delete
+// them, and Abandon releases them when it isn't saved. If any reservation
+// fails, the order is abandoned before the error is returned.
+func (svc *StockService) Reserve(ctx context.Context, order *domain.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "ReserveStock")
+	defer span.End()
+
+	if order.LocationID == nil {
+		return status.Error(codes.InvalidArgument, "order location is required")
+	}
+
+	if order.ID == "" {
+		order.ID = uuid.NewString()
+	}
+
+	for i := range order.OrderItems {
+		orderItem := &order.OrderItems[i]
+		if orderItem.ID == "" {
+			orderItem.ID = uuid.NewString()
+		}
+
+		if err = svc.reserveOrderItem(ctx, order, orderItem); err != nil {
+			if abandonErr := svc.Abandon(ctx, order.ID); abandonErr != nil {
+				err = errors.Join(err, abandonErr)
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (svc *StockService) reserveOrderItem(ctx context.Context, order *domain.Order, orderItem *domain.OrderItem) (err error) {
+	inventoryItemID, err := svc.inventoryItem(ctx, *order.LocationID, orderItem.VariantID)
+	if err != nil || inventoryItemID == "" {
+		return
+	}
+
+	reservation := domain.StockReservation{
+		ID:              orderItem.ID,
+		OrderItemID:     orderItem.ID,
+		OrderID:         order.ID,
+		InventoryItemID: inventoryItemID,
+		Quantity:        orderItem.Quantity,
+	}
+	if err = svc.db.WithContext(ctx).Create(&reservation).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err = svc.reserve(ctx, reservation); err != nil {
+		return
+	}
+
+	orderItem.InventoryItemID = &inventoryItemID
+	orderItem.ReservedQuantity = orderItem.Quantity
+
+	return
+}
+
+// Abandon releases the stock reserved for an order that won't be saved and
+// deletes its reservations. Reservations it can't settle are left for
+// ReleaseOrphans.
+func (svc *StockService) Abandon(ctx context.Context, orderID string) (err error) {
+	ctx, span := tracer.Start(ctx, "AbandonStock")
+	defer span.End()
+
+	return svc.abandon(ctx, "order_id = ? AND order_revision_id IS NULL AND NOT release", orderID)
+}
+
+// Grow reserves what orderItem needs beyond the stock it holds, for the
+// edit saved as the revision revisionID. The reservation is recorded the
+// way Reserve records it; saving the revision must delete it, and
+// AbandonRevision releases it when it isn't saved. Lines that haven't
+// reserved anything are reserved at the order location.
+func (svc *StockService) Grow(ctx context.Context, order *domain.Order, orderItem *domain.OrderItem, revisionID string) (err error) {
+	ctx, span := tracer.Start(ctx, "GrowStock")
+	defer span.End()
+
+	inventoryItemID := orderItem.InventoryItemID
+	if inventoryItemID == nil {
+		if order.LocationID == nil {
+			return status.Error(codes.InvalidArgument, "order location is required")
+		}
+
+		id, err := svc.inventoryItem(ctx, *order.LocationID, orderItem.VariantID)
+		if err != nil || id == "" {
+			return err
+		}
+		inventoryItemID = &id
+	}
+
+	delta := orderItem.Quantity - orderItem.ReservedQuantity
+	if delta <= 0 {
+		return
+	}
+
+	reservation := domain.StockReservation{
+		ID:              uuid.NewString(),
+		OrderItemID:     orderItem.ID,
+		OrderID:         order.ID,
+		OrderRevisionID: &revisionID,
+		InventoryItemID: *inventoryItemID,
+		Quantity:        delta,
+	}
+	if err = svc.db.WithContext(ctx).Create(&reservation).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if err = svc.reserve(ctx, reservation); err != nil {
+		return
+	}
+
+	orderItem.InventoryItemID = inventoryItemID
+	orderItem.ReservedQuantity = orderItem.Quantity
+
+	return
+}
+
+// AbandonRevision releases the stock Grow reserved for an edit that won't
+// be saved and deletes its reservations. Reservations it can't settle are
+// left for ReleaseOrphans.
+func (svc *StockService) AbandonRevision(ctx context.Context, revisionID string) (err error) {
+	ctx, span := tracer.Start(ctx, "AbandonRevisionStock")
+	defer span.End()
+
+	return svc.abandon(ctx, "order_revision_id = ? AND NOT release", revisionID)
+}
+
+func (svc *StockService) abandon(ctx context.Context, query string, args ...any) (err error) {
+	var reservations domain.StockReservations
+	if err = svc.db.WithContext(ctx).Where(query, args...).Find(&reservations).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	for _, reservation := range reservations {
+		if settleErr := svc.settle(ctx, reservation); settleErr != nil {
+			err = errors.Join(err, settleErr)
+		}
+	}
+
+	return
+}
+
+// RecordRelease records within tx the release of what orderItem holds
+// beyond quantity, and takes its reserved quantity down to quantity, so
+// the stock is released with the change tx saves or not at all. The
+// releases recorded are returned for ReleaseRecorded once tx commits;
+// lines holding no more than quantity record none.
+func (svc *StockService) RecordRelease(tx *gorm.DB, orderItem *domain.OrderItem, quantity int32) (*domain.StockReservation, error) {
+	if orderItem.InventoryItemID == nil || orderItem.ReservedQuantity <= quantity {
+		return nil, nil
+	}
+
+	release := domain.StockReservation{
+		ID:              uuid.NewString(),
+		OrderItemID:     orderItem.ID,
+		OrderID:         orderItem.OrderID,
+		InventoryItemID: *orderItem.InventoryItemID,
+		Quantity:        orderItem.ReservedQuantity - quantity,
+		Release:         true,
+	}
+	if err := tx.Create(&release).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if err := tx.Unscoped().Model(&domain.OrderItem{ID: orderItem.ID}).
+		Update("reserved_quantity", quantity).Error; err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	orderItem.ReservedQuantity = quantity
+	return &release, nil
+}
+
+// ReleaseRecorded releases the stock of releases recorded by RecordRelease
+// and deletes them. Failures are logged and left for ReleaseOrphans.
+func (svc *StockService) ReleaseRecorded(ctx context.Context, releases domain.StockReservations) {
+	ctx, span := tracer.Start(ctx, "ReleaseRecordedStock")
+	defer span.End()
+
+	for _, release := range releases {
+		if err := svc.release(ctx, release); err != nil {
+			zap.L().Error("failed release stock", zap.String("order_item_id", release.OrderItemID), zap.Error(err))
+		}
+	}
+}
+
+// ReleaseOrphans releases the stock reserved for orders and edits lost
+// before they were saved, such as by a crash between reserving and saving,
+// retries the releases of saved changes that failed, and returns how many
+// reservations and releases it settled.
+func (svc *StockService) ReleaseOrphans(ctx context.Context) (n int, err error) {
+	ctx, span := tracer.Start(ctx, "ReleaseOrphanedStock")
+	defer span.End()
+
+	var reservations domain.StockReservations
+	if err = svc.db.WithContext(ctx).
+		Where("created_at < ?", time.Now().Add(-reservationGracePeriod)).
+		Find(&reservations).Error; err != nil {
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+
+	for _, reservation := range reservations {
+		if reservation.Release {
+			if releaseErr := svc.release(ctx, reservation); releaseErr != nil {
+				zap.L().Error("failed retry stock release", zap.String("order_item_id", reservation.OrderItemID), zap.Error(releaseErr))
+				continue
+			}
+			n++
+			continue
+		}
+
+		var saved int64
+		if reservation.OrderRevisionID != nil {
+			err = svc.db.WithContext(ctx).Model(&domain.OrderRevision{}).
+				Where("order_revision_id = ?", *reservation.OrderRevisionID).
+				Count(&saved).Error
+		} else {
+			err = svc.db.WithContext(ctx).Model(&domain.Order{}).
+				Where("order_id = ?", reservation.OrderID).
+				Count(&saved).Error
+		}
+		if err != nil {
+			return n, status.Error(codes.Internal, err.Error())
+		}
+
+		// The order or edit was saved and holds the reservation itself.
+		if saved > 0 {
+			if err = svc.db.WithContext(ctx).Delete(&reservation).Error; err != nil {
+				return n, status.Error(codes.Internal, err.Error())
+			}
+			continue
+		}
+
+		if settleErr := svc.settle(ctx, reservation); settleErr != nil {
+			zap.L().Error("failed release orphaned stock", zap.String("order_item_id", reservation.OrderItemID), zap.Error(settleErr))
+			continue
+		}
+		n++
+	}
+
+	return
+}
+
+// settle leaves reservation neither reserved nor recorded. Whether the
+// reservation was made isn't known, so it is made again under its
+// idempotency key, which the inventory service only applies once, and then
+// released.
+func (svc *StockService) settle(ctx context.Context, reservation domain.StockReservation) (err error) {
+	if err = svc.reserve(ctx, reservation); err == nil {
+		return svc.release(ctx, reservation)
+	}
+
+	if code := status.Code(err); code != codes.FailedPrecondition && code != codes.InvalidArgument {
+		return
+	}
+
+	if err = svc.db.WithContext(ctx).Delete(&reservation).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// release releases the stock of reservation under its idempotency key and
+// deletes it.
+func (svc *StockService) release(ctx context.Context, reservation domain.StockReservation) (err error) {
+	if _, err = svc.inventoryConn.ReleaseStock(
+		metadata.AppendToOutgoingContext(ctx, idempotency.KeyHeader, reservation.ReleaseKey()),
+		&inventory.ReleaseStockRequest{
+			InventoryItemId: reservation.InventoryItemID,
+			Body: &inventory.ReleaseStockRequest_Body{
+				ReservedStock: reservation.Quantity,
+			},
+		}); err != nil {
+		return
+	}
+
+	if err = svc.db.WithContext(ctx).Delete(&reservation).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+func (svc *StockService) reserve(ctx context.Context, reservation domain.StockReservation) (err error) {
+	_, err = svc.inventoryConn.ReservedStock(
+		metadata.AppendToOutgoingContext(ctx, idempotency.KeyHeader, reservation.ReserveKey()),
+		&inventory.ReservedStockRequest{
+			InventoryItemId: reservation.InventoryItemID,
+			Body: &inventory.ReservedStockRequest_Body{
+				ReservedStock: reservation.Quantity,
+			},
+		})
+	return
+}
+
+// inventoryItem returns the inventory item of a variant at locationID, or
+// an empty ID for variants that don't track inventory.
+func (svc *StockService) inventoryItem(ctx context.Context, locationID, variantID string) (string, error) {
+	variant, err := svc.itemConn.GetVariant(ctx, &item.GetVariantRequest{
+		VariantId: variantID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Variants without inventory (services, made-to-order menu items) aren't stock tracked.
+	if len(variant.Inventories) == 0 {
+		return "", nil
+	}
+
+	for _, inv := range variant.Inventories {
+		if inv.LocationId == locationID {
+			return inv.InventoryItemId, nil
+		}
+	}
+
+	return "", status.Errorf(codes.FailedPrecondition, "variant %s isn't stocked at location %s", variantID, locationID)
+}
+
+// Release returns the reserved stock of the given order items. It keeps going
+// after a failure so one bad line doesn't strand the others, and clears
+// ReservedQuantity on every line it released.
+func (svc *StockService) Release(ctx context.Context, orderItems domain.OrderItems) (err error) {
+	ctx, span := tracer.Start(ctx, "ReleaseStock")
+	defer span.End()
+
+	for i := range orderItems {
+		orderItem := &orderItems[i]
+
+		if orderItem.InventoryItemID == nil || orderItem.ReservedQuantity <= 0 {
+			continue
+		}
+
+		if _, releaseErr := svc.inventoryConn.ReleaseStock(ctx, &inventory.ReleaseStockRequest{
+			InventoryItemId: *orderItem.InventoryItemID,
+			Body: &inventory.ReleaseStockRequest_Body{
+				ReservedStock: orderItem.ReservedQuantity,
+			},
+		}); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+			continue
+		}
+
+		orderItem.ReservedQuantity = 0
+	}
+
+	return
+}
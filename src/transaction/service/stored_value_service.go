@@ -0,0 +1,504 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallbiznis/go-lib/pkg/pagination"
+	"github.com/smallbiznis/transaction/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// giftCardCodeLength is the number of characters of a generated gift card
+// code. Codes are bearer credentials, so they are longer than coupon codes.
+const giftCardCodeLength = 16
+
+// StoredValueService manages gift cards and customers' store credit. Every
+// balance change is booked as a ledger entry. Both are spent on orders
+// through the payment providers it returns.
+type StoredValueService struct {
+	db *gorm.DB
+}
+
+func NewStoredValueService(db *gorm.DB) *StoredValueService {
+	return &StoredValueService{
+		db: db,
+	}
+}
+
+// IssueGiftCard hands out a gift card loaded with req.InitialAmount. A code
+// is generated unless req.Code is set.
+func (svc *StoredValueService) IssueGiftCard(ctx context.Context, req domain.GiftCard, actor string) (*domain.GiftCard, error) {
+	ctx, span := tracer.Start(ctx, "IssueGiftCard")
+	defer span.End()
+
+	if req.InitialAmount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "initial_amount must be greater than zero")
+	}
+
+	card := domain.GiftCard{
+		OrganizationID: req.OrganizationID,
+		Code:           domain.NormalizeCode(req.Code),
+		InitialAmount:  req.InitialAmount,
+		ExpiresAt:      req.ExpiresAt,
+	}
+
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return issueGiftCard(tx, &card, domain.StoredValueEntry{
+			Actor: actor,
+			Note:  "issued",
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+// GetGiftCard looks up a gift card and its balance by code.
+func (svc *StoredValueService) GetGiftCard(ctx context.Context, organizationID, code string) (*domain.GiftCard, error) {
+	ctx, span := tracer.Start(ctx, "GetGiftCard")
+	defer span.End()
+
+	var card *domain.GiftCard
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.GiftCard{OrganizationID: organizationID, Code: domain.NormalizeCode(code)}).
+		First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.InvalidArgument, domain.ErrUnknownGiftCard.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return card, nil
+}
+
+// AddGiftCardVariant makes the sale of a variant issue gift cards once the
+// order is paid.
+func (svc *StoredValueService) AddGiftCardVariant(ctx context.Context, organizationID, variantID string) error {
+	ctx, span := tracer.Start(ctx, "AddGiftCardVariant")
+	defer span.End()
+
+	if err := svc.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.GiftCardVariant{
+			OrganizationID: organizationID,
+			VariantID:      variantID,
+		}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// RemoveGiftCardVariant stops a variant from issuing gift cards. Cards
+// already issued are kept.
+func (svc *StoredValueService) RemoveGiftCardVariant(ctx context.Context, organizationID, variantID string) error {
+	ctx, span := tracer.Start(ctx, "RemoveGiftCardVariant")
+	defer span.End()
+
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.GiftCardVariant{OrganizationID: organizationID, VariantID: variantID}).
+		Delete(&domain.GiftCardVariant{}).Error; err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return nil
+}
+
+// GetStoreCredit returns the store credit wallet of a customer, empty when
+// the customer never had credit.
+func (svc *StoredValueService) GetStoreCredit(ctx context.Context, organizationID, customerID string) (*domain.StoreCreditWallet, error) {
+	ctx, span := tracer.Start(ctx, "GetStoreCredit")
+	defer span.End()
+
+	var wallet *domain.StoreCreditWallet
+	if err := svc.db.WithContext(ctx).
+		Where(&domain.StoreCreditWallet{OrganizationID: organizationID, CustomerID: customerID}).
+		First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &domain.StoreCreditWallet{OrganizationID: organizationID, CustomerID: customerID}, nil
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return wallet, nil
+}
+
+// AdjustStoreCredit credits, or with a negative amount debits, a customer's
+// store credit. The balance can't go below zero.
+func (svc *StoredValueService) AdjustStoreCredit(ctx context.Context, organizationID, customerID string, amount domain.Money, actor, note string) (*domain.StoreCreditWallet, error) {
+	ctx, span := tracer.Start(ctx, "AdjustStoreCredit")
+	defer span.End()
+
+	if amount == 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount can't be zero")
+	}
+
+	var wallet *domain.StoreCreditWallet
+	if err := svc.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if wallet, err = lockWallet(tx, organizationID, customerID); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err = postWallet(tx, wallet, domain.StoredValueEntry{
+			Type:   domain.StoredValueAdjust,
+			Amount: amount,
+			Actor:  actor,
+			Note:   note,
+		}); err != nil {
+			if errors.Is(err, domain.ErrInsufficientBalance) {
+				return status.Error(codes.FailedPrecondition, domain.ErrInsufficientBalance.Error())
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		return
+	}); err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// ListStoredValueEntry lists the ledger entries of a gift card or store
+// credit wallet, newest first.
+func (svc *StoredValueService) ListStoredValueEntry(ctx context.Context, p pagination.Pagination, account domain.StoredValueAccount, accountID string) (domain.StoredValueEntries, int64, error) {
+	ctx, span := tracer.Start(ctx, "ListStoredValueEntry")
+	defer span.End()
+
+	if account.String() == "" {
+		return nil, 0, status.Error(codes.InvalidArgument, "invalid account")
+	}
+
+	var (
+		entries domain.StoredValueEntries
+		count   int64
+	)
+	if err := svc.db.WithContext(ctx).Model(&domain.StoredValueEntry{}).
+		Where(&domain.StoredValueEntry{Account: account, AccountID: accountID}).
+		Count(&count).
+		Scopes(p.Paginate()).
+		Order("created_at DESC").
+		Find(&entries).Error; err != nil {
+		return nil, 0, status.Error(codes.Internal, err.Error())
+	}
+
+	return entries, count, nil
+}
+
+// GiftCardProvider returns the payment provider spending gift cards. The
+// card balance is taken when the payment is authorized and given back when
+// it is voided or refunded. Balances are in the base currency, so payments
+// of orders in a selling currency are converted at the order's exchange
+// rate.
+func (svc *StoredValueService) GiftCardProvider() domain.PaymentProvider {
+	return &giftCardProvider{db: svc.db}
+}
+
+// StoreCreditProvider returns the payment provider spending the store
+// credit of the order's customer, the same way GiftCardProvider does.
+func (svc *StoredValueService) StoreCreditProvider() domain.PaymentProvider {
+	return &storeCreditProvider{db: svc.db}
+}
+
+type giftCardProvider struct {
+	db *gorm.DB
+}
+
+func (p *giftCardProvider) ID() string {
+	return "gift_card"
+}
+
+func (p *giftCardProvider) Supports(method domain.PaymentMethod) bool {
+	return method == domain.GiftCardPayment
+}
+
+// Authorize debits the card whose code the payment was requested with.
+func (p *giftCardProvider) Authorize(ctx context.Context, payment domain.OrderPayment) (result domain.PaymentResult, err error) {
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		card, err := lockGiftCard(tx, domain.GiftCard{
+			OrganizationID: payment.OrganizationID,
+			Code:           domain.NormalizeCode(payment.ProviderReference),
+		})
+		if err != nil {
+			return
+		}
+
+		if err = card.Usable(time.Now()); err != nil {
+			return fmt.Errorf("%w: %w", domain.ErrPaymentDeclined, err)
+		}
+
+		amount, err := baseAmount(tx, payment.OrderID, payment.Amount)
+		if err != nil {
+			return
+		}
+
+		result = domain.PaymentResult{Reference: card.ID, Status: domain.PaymentAuthorized}
+		return postGiftCard(tx, card, paymentEntry(payment, domain.StoredValueRedeem, -amount))
+	})
+	return
+}
+
+func (p *giftCardProvider) Capture(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
+
+func (p *giftCardProvider) Void(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return p.credit(ctx, payment, domain.StoredValueVoid, payment.Amount, domain.PaymentVoided)
+}
+
+func (p *giftCardProvider) Refund(ctx context.Context, payment domain.OrderPayment, amount domain.Money) (domain.PaymentResult, error) {
+	return p.credit(ctx, payment, domain.StoredValueRefund, amount, domain.PaymentCaptured)
+}
+
+func (p *giftCardProvider) credit(ctx context.Context, payment domain.OrderPayment, entryType domain.StoredValueEntryType, amount domain.Money, state domain.PaymentState) (domain.PaymentResult, error) {
+	if err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		card, err := lockGiftCard(tx, domain.GiftCard{ID: payment.ProviderReference})
+		if err != nil {
+			return
+		}
+
+		base, err := baseAmount(tx, payment.OrderID, amount)
+		if err != nil {
+			return
+		}
+
+		return postGiftCard(tx, card, paymentEntry(payment, entryType, base))
+	}); err != nil {
+		return domain.PaymentResult{}, err
+	}
+
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: state}, nil
+}
+
+type storeCreditProvider struct {
+	db *gorm.DB
+}
+
+func (p *storeCreditProvider) ID() string {
+	return "store_credit"
+}
+
+func (p *storeCreditProvider) Supports(method domain.PaymentMethod) bool {
+	return method == domain.StoreCreditPayment
+}
+
+// Authorize debits the store credit of the order's customer.
+func (p *storeCreditProvider) Authorize(ctx context.Context, payment domain.OrderPayment) (result domain.PaymentResult, err error) {
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var order *domain.Order
+		if err = tx.Where(&domain.Order{ID: payment.OrderID}).First(&order).Error; err != nil {
+			return
+		}
+
+		if order.CustomerID == nil {
+			return fmt.Errorf("%w: order has no customer", domain.ErrPaymentDeclined)
+		}
+
+		wallet, err := lockWallet(tx, order.OrganizationID, *order.CustomerID)
+		if err != nil {
+			return
+		}
+
+		result = domain.PaymentResult{Reference: wallet.ID, Status: domain.PaymentAuthorized}
+		return postWallet(tx, wallet, paymentEntry(payment, domain.StoredValueRedeem, -order.ToBase(payment.Amount)))
+	})
+	return
+}
+
+func (p *storeCreditProvider) Capture(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: domain.PaymentCaptured}, nil
+}
+
+func (p *storeCreditProvider) Void(ctx context.Context, payment domain.OrderPayment) (domain.PaymentResult, error) {
+	return p.credit(ctx, payment, domain.StoredValueVoid, payment.Amount, domain.PaymentVoided)
+}
+
+func (p *storeCreditProvider) Refund(ctx context.Context, payment domain.OrderPayment, amount domain.Money) (domain.PaymentResult, error) {
+	return p.credit(ctx, payment, domain.StoredValueRefund, amount, domain.PaymentCaptured)
+}
+
+func (p *storeCreditProvider) credit(ctx context.Context, payment domain.OrderPayment, entryType domain.StoredValueEntryType, amount domain.Money, state domain.PaymentState) (domain.PaymentResult, error) {
+	if err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		var wallet *domain.StoreCreditWallet
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&domain.StoreCreditWallet{ID: payment.ProviderReference}).
+			First(&wallet).Error; err != nil {
+			return
+		}
+
+		base, err := baseAmount(tx, payment.OrderID, amount)
+		if err != nil {
+			return
+		}
+
+		return postWallet(tx, wallet, paymentEntry(payment, entryType, base))
+	}); err != nil {
+		return domain.PaymentResult{}, err
+	}
+
+	return domain.PaymentResult{Reference: payment.ProviderReference, Status: state}, nil
+}
+
+// issueGiftCards issues the gift cards sold on a paid order: one card per
+// unit of every line whose variant is a gift card variant, worth its unit
+// price in the base currency.
+func issueGiftCards(tx *gorm.DB, order *domain.Order, actor string) (err error) {
+	var items domain.OrderItems
+	if err = tx.Where("order_id = ? AND variant_id IN (?)", order.ID,
+		tx.Model(&domain.GiftCardVariant{}).
+			Select("variant_id").
+			Where(&domain.GiftCardVariant{OrganizationID: order.OrganizationID})).
+		Find(&items).Error; err != nil {
+		return
+	}
+
+	for _, orderItem := range items {
+		orderID, orderItemID := order.ID, orderItem.ID
+		for range orderItem.Quantity - orderItem.ReturnedQuantity {
+			if err = issueGiftCard(tx, &domain.GiftCard{
+				OrganizationID: order.OrganizationID,
+				InitialAmount:  order.ToBase(orderItem.UnitPrice),
+				OrderID:        &orderID,
+				OrderItemID:    &orderItemID,
+			}, domain.StoredValueEntry{
+				OrderID: &orderID,
+				Actor:   actor,
+				Note:    "sold",
+			}); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// issueGiftCard creates an active card, drawing a code when it has none,
+// and books its initial amount.
+func issueGiftCard(tx *gorm.DB, card *domain.GiftCard, entry domain.StoredValueEntry) (err error) {
+	card.Active = true
+	amount := card.InitialAmount
+	card.Balance = 0
+
+	generate := card.Code == ""
+	// Drawn codes colliding with existing ones are drawn again.
+	for round := 0; round < 5; round++ {
+		if generate {
+			if card.Code, err = randomCode(giftCardCodeLength); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+
+		stmt := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(card)
+		if err = stmt.Error; err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if stmt.RowsAffected == 1 {
+			entry.Type = domain.StoredValueIssue
+			entry.Amount = amount
+			if err = postGiftCard(tx, card, entry); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			return
+		}
+
+		if !generate {
+			return status.Error(codes.AlreadyExists, "code already exist")
+		}
+	}
+
+	return status.Error(codes.ResourceExhausted, "no unique gift card code could be generated")
+}
+
+// lockGiftCard loads a gift card with a row lock held until tx ends.
+// Unknown cards decline the payment.
+func lockGiftCard(tx *gorm.DB, filter domain.GiftCard) (card *domain.GiftCard, err error) {
+	if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&filter).
+		First(&card).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %w", domain.ErrPaymentDeclined, domain.ErrUnknownGiftCard)
+		}
+		return nil, err
+	}
+
+	return
+}
+
+// lockWallet loads the store credit wallet of a customer with a row lock
+// held until tx ends, creating it when the customer has none.
+func lockWallet(tx *gorm.DB, organizationID, customerID string) (wallet *domain.StoreCreditWallet, err error) {
+	if err = tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&domain.StoreCreditWallet{
+			OrganizationID: organizationID,
+			CustomerID:     customerID,
+		}).Error; err != nil {
+		return
+	}
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(&domain.StoreCreditWallet{OrganizationID: organizationID, CustomerID: customerID}).
+		First(&wallet).Error
+	return
+}
+
+func postGiftCard(tx *gorm.DB, card *domain.GiftCard, entry domain.StoredValueEntry) error {
+	entry.OrganizationID = card.OrganizationID
+	entry.Account = domain.GiftCardAccount
+	entry.AccountID = card.ID
+	return post(tx, card, &card.Balance, entry)
+}
+
+func postWallet(tx *gorm.DB, wallet *domain.StoreCreditWallet, entry domain.StoredValueEntry) error {
+	entry.OrganizationID = wallet.OrganizationID
+	entry.Account = domain.StoreCreditAccount
+	entry.AccountID = wallet.ID
+	return post(tx, wallet, &wallet.Balance, entry)
+}
+
+// post books entry against the locked account model, whose balance is
+// kept in balance. Debits past the balance decline the payment.
+func post(tx *gorm.DB, model any, balance *domain.Money, entry domain.StoredValueEntry) (err error) {
+	next := *balance + entry.Amount
+	if next < 0 {
+		return fmt.Errorf("%w: %w", domain.ErrPaymentDeclined, domain.ErrInsufficientBalance)
+	}
+
+	if err = tx.Model(model).Update("balance", next).Error; err != nil {
+		return
+	}
+	*balance = next
+
+	entry.Balance = next
+	return tx.Create(&entry).Error
+}
+
+// baseAmount converts amount from the currency of an order to the base
+// currency at the order's exchange rate.
+func baseAmount(tx *gorm.DB, orderID string, amount domain.Money) (domain.Money, error) {
+	var order *domain.Order
+	if err := tx.Where(&domain.Order{ID: orderID}).First(&order).Error; err != nil {
+		return 0, err
+	}
+
+	return order.ToBase(amount), nil
+}
+
+// paymentEntry is the ledger entry moving amount for an order payment.
+func paymentEntry(payment domain.OrderPayment, entryType domain.StoredValueEntryType, amount domain.Money) domain.StoredValueEntry {
+	orderID, paymentID := payment.OrderID, payment.ID
+	return domain.StoredValueEntry{
+		Type:           entryType,
+		Amount:         amount,
+		OrderID:        &orderID,
+		OrderPaymentID: &paymentID,
+	}
+}